@@ -41,6 +41,7 @@ func main() {
 	if err != nil {
 		log.Fatal(err, "create client")
 	}
+	defer client.Close()
 
 	shell := commander.SetupCommands(ctx, client)
 	shell.Run()
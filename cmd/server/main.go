@@ -39,13 +39,32 @@ func main() {
 
 	runner, ctx := errgroup.WithContext(ctx)
 
-	session := session.NewSessionStorage(ctx)
-	storage, err := postgres.NewStorage(ctx, cfg.PostgresDSN)
+	storage, err := postgres.NewStorage(ctx, cfg.PostgresDSN, cfg.HistoryRetention)
 	if err != nil {
 		log.Fatal(err, "create storage")
 	}
 
-	server, err := server.NewServer(storage, session, cfg)
+	runner.Go(func() error {
+		return server.ReapOneTimeSecrets(ctx, storage)
+	})
+
+	var sessions server.SessionStorage
+	switch cfg.SessionStore {
+	case server.SessionStoreMemory:
+		sessions = session.NewSessionStorage(ctx)
+	default:
+		sessions = postgres.NewSessionStorage(ctx, storage)
+	}
+
+	var grpcServer *server.GRPCServer
+	if cfg.GRPCAddress != "" {
+		grpcServer, err = server.NewGRPCServer(storage, sessions, cfg)
+		if err != nil {
+			log.Fatal(err, "create grpc server")
+		}
+	}
+
+	server, err := server.NewServer(storage, sessions, cfg)
 	if err != nil {
 		log.Fatal(err, "create server")
 	}
@@ -59,6 +78,17 @@ func main() {
 		return server.Shutdown(ctx)
 	})
 
+	if grpcServer != nil {
+		runner.Go(func() error {
+			return grpcServer.Run()
+		})
+
+		runner.Go(func() error {
+			<-ctx.Done()
+			return grpcServer.Shutdown(ctx)
+		})
+	}
+
 	if err := runner.Wait(); err != nil {
 		fmt.Printf("exit reason: %s \n", err)
 	}
@@ -0,0 +1,210 @@
+// Package audit reviews decrypted credentials for weak, reused, old and
+// breached passwords. It runs entirely on the client: passwords never leave
+// the machine, breached ones are looked up in a local hash file.
+package audit
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Strength scores, from Run's point of view anything below Fair is weak.
+const (
+	VeryWeak = iota
+	Weak
+	Fair
+	Strong
+	VeryStrong
+)
+
+// DefaultMaxAge is the age after which a password is reported as old.
+const DefaultMaxAge = 365 * 24 * time.Hour
+
+var scoreNames = []string{"very weak", "weak", "fair", "strong", "very strong"}
+
+// commonPasswords are guessed first by any cracker, whatever their length.
+var commonPasswords = map[string]bool{
+	"password": true, "123456": true, "12345678": true, "123456789": true, "1234567890": true,
+	"qwerty": true, "qwertyuiop": true, "abc123": true, "111111": true, "123123": true,
+	"letmein": true, "welcome": true, "admin": true, "iloveyou": true, "monkey": true,
+	"dragon": true, "master": true, "sunshine": true, "princess": true, "football": true,
+	"baseball": true, "shadow": true, "superman": true, "trustno1": true, "passw0rd": true,
+	"p@ssw0rd": true, "p@ssword": true, "changeme": true, "secret": true, "login": true,
+	"starwars": true, "whatever": true, "qazwsx": true, "zaq12wsx": true, "1q2w3e4r": true,
+	"asdfghjkl": true, "000000": true, "654321": true, "hello": true, "freedom": true,
+}
+
+// Entry is a decrypted credential to review.
+type Entry struct {
+	ID       int64
+	Name     string
+	Login    string
+	Password string
+	// ChangedAt is when the password was last changed, zero if unknown.
+	ChangedAt time.Time
+}
+
+// Options of Run. A zero MaxAge means DefaultMaxAge. BreachPath is an
+// optional breach hash file or directory, see BreachCounts.
+type Options struct {
+	MaxAge     time.Duration
+	BreachPath string
+	Now        time.Time
+}
+
+// Result is the review of one entry.
+type Result struct {
+	ID      int64
+	Name    string
+	Login   string
+	Score   int
+	Entropy float64
+	// ReusedWith lists the IDs of other entries with the same password.
+	ReusedWith []int64
+	Age        time.Duration
+	Old        bool
+	// Breaches is how many times the password appears in the breach file.
+	Breaches int
+}
+
+// Weak reports whether the password is too easy to guess.
+func (r *Result) Weak() bool {
+	return r.Score < Fair
+}
+
+// Issues reports whether anything is wrong with the entry.
+func (r *Result) Issues() bool {
+	return r.Weak() || len(r.ReusedWith) > 0 || r.Old || r.Breaches > 0
+}
+
+// ScoreName describes a strength score.
+func ScoreName(score int) string {
+	if score < 0 || score >= len(scoreNames) {
+		return "unknown"
+	}
+	return scoreNames[score]
+}
+
+// Run reviews entries. Results are in the order of entries.
+func Run(entries []Entry, opts Options) ([]Result, error) {
+	if opts.MaxAge == 0 {
+		opts.MaxAge = DefaultMaxAge
+	}
+	if opts.Now.IsZero() {
+		opts.Now = time.Now()
+	}
+
+	var breaches map[string]int
+	if opts.BreachPath != "" {
+		passwords := make([]string, 0, len(entries))
+		for _, v := range entries {
+			passwords = append(passwords, v.Password)
+		}
+
+		var err error
+		if breaches, err = BreachCounts(opts.BreachPath, passwords); err != nil {
+			return nil, err
+		}
+	}
+
+	byPassword := make(map[string][]int64)
+	for _, v := range entries {
+		if v.Password != "" {
+			byPassword[v.Password] = append(byPassword[v.Password], v.ID)
+		}
+	}
+
+	results := make([]Result, len(entries))
+	for i, v := range entries {
+		score, entropy := Strength(v.Password)
+
+		r := Result{
+			ID:       v.ID,
+			Name:     v.Name,
+			Login:    v.Login,
+			Score:    score,
+			Entropy:  entropy,
+			Breaches: breaches[v.Password],
+		}
+
+		for _, id := range byPassword[v.Password] {
+			if id != v.ID {
+				r.ReusedWith = append(r.ReusedWith, id)
+			}
+		}
+		sort.Slice(r.ReusedWith, func(i, j int) bool { return r.ReusedWith[i] < r.ReusedWith[j] })
+
+		if !v.ChangedAt.IsZero() {
+			r.Age = opts.Now.Sub(v.ChangedAt)
+			r.Old = r.Age > opts.MaxAge
+		}
+
+		results[i] = r
+	}
+
+	return results, nil
+}
+
+// Strength estimates the entropy of a password in bits and scores it. The
+// estimate assumes random characters from the classes used, discounted for
+// repeated and sequential characters; common passwords score zero.
+func Strength(password string) (int, float64) {
+	if password == "" || commonPasswords[strings.ToLower(password)] {
+		return VeryWeak, 0
+	}
+
+	var pool int
+	var hasLower, hasUpper, hasDigit, hasSymbol, hasOther bool
+	for _, r := range password {
+		switch {
+		case r >= 'a' && r <= 'z':
+			hasLower = true
+		case r >= 'A' && r <= 'Z':
+			hasUpper = true
+		case r >= '0' && r <= '9':
+			hasDigit = true
+		case r < unicode.MaxASCII && unicode.IsPrint(r):
+			hasSymbol = true
+		default:
+			hasOther = true
+		}
+	}
+	for _, v := range []struct {
+		on   bool
+		size int
+	}{{hasLower, 26}, {hasUpper, 26}, {hasDigit, 10}, {hasSymbol, 33}, {hasOther, 100}} {
+		if v.on {
+			pool += v.size
+		}
+	}
+
+	// characters repeating or continuing a sequence add little
+	var length float64
+	var prev rune
+	for i, r := range []rune(password) {
+		if i > 0 && (r == prev || r == prev+1 || r == prev-1) {
+			length += 0.25
+		} else {
+			length++
+		}
+		prev = r
+	}
+
+	entropy := length * math.Log2(float64(pool))
+
+	switch {
+	case entropy < 28:
+		return VeryWeak, entropy
+	case entropy < 36:
+		return Weak, entropy
+	case entropy < 60:
+		return Fair, entropy
+	case entropy < 80:
+		return Strong, entropy
+	default:
+		return VeryStrong, entropy
+	}
+}
@@ -0,0 +1,97 @@
+package audit
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// prefixSize is the length of the hash prefixes of k-anonymity range files.
+const prefixSize = 5
+
+// BreachCounts looks passwords up in breach data in the format of Have I
+// Been Pwned: uppercase hex SHA-1 hashes with the number of times they were
+// seen. path is either
+//
+//   - a directory of range files named by the first 5 hex digits of the
+//     hash (e.g. 21BD1.txt), each listing "SUFFIX:COUNT" lines, as served
+//     by the k-anonymity range API; or
+//   - a single file of "HASH:COUNT" lines.
+//
+// Only the needed range files are read. The result maps breached passwords
+// to their counts.
+func BreachCounts(path string, passwords []string) (map[string]int, error) {
+	hashes := make(map[string]string, len(passwords))
+	for _, v := range passwords {
+		if v == "" {
+			continue
+		}
+		sum := sha1.Sum([]byte(v))
+		hashes[strings.ToUpper(hex.EncodeToString(sum[:]))] = v
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("open breach data: %w", err)
+	}
+
+	counts := make(map[string]int)
+	if !info.IsDir() {
+		err = scanHashes(path, "", hashes, counts)
+		return counts, err
+	}
+
+	byPrefix := make(map[string]bool)
+	for hash := range hashes {
+		byPrefix[hash[:prefixSize]] = true
+	}
+
+	for prefix := range byPrefix {
+		err := scanHashes(filepath.Join(path, prefix+".txt"), prefix, hashes, counts)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return counts, nil
+}
+
+// scanHashes reads "HASH:COUNT" lines, prefix is prepended to the hashes of
+// range files.
+func scanHashes(path, prefix string, hashes map[string]string, counts map[string]int) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		hash, count, ok := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if !ok {
+			continue
+		}
+
+		password, found := hashes[prefix+strings.ToUpper(hash)]
+		if !found {
+			continue
+		}
+
+		n, err := strconv.Atoi(count)
+		if err != nil {
+			return fmt.Errorf("bad breach count %q: %w", count, err)
+		}
+		counts[password] = n
+	}
+
+	return scanner.Err()
+}
@@ -0,0 +1,494 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nbvehbq/go-password-keeper/internal/model"
+)
+
+// fakeAPI serves the parts of the REST API the client tests need from
+// memory. It keeps only what a real server would be sent. Secrets are
+// shared by all users.
+type fakeAPI struct {
+	*httptest.Server
+
+	// down drops every connection, as if the server could not be reached
+	down atomic.Bool
+
+	mu       sync.Mutex
+	users    map[string]*model.RegisterDTO
+	ids      map[string]int64
+	sessions map[string]string
+
+	// totp maps logins to the only code accepted as their second factor,
+	// challenges map pending logins to the login
+	totp       map[string]string
+	challenges map[string]string
+
+	nextID  int64
+	secrets map[int64]*model.Secret
+
+	// seq numbers changes for the sync endpoint; changed and deleted map
+	// secret IDs to their last change
+	seq     int64
+	changed map[int64]int64
+	deleted map[int64]int64
+
+	onetime map[string]*model.OneTimeSecret
+
+	grants map[int64]*model.EmergencyGrant
+}
+
+func newFakeAPI(t *testing.T) *fakeAPI {
+	t.Helper()
+
+	api := &fakeAPI{
+		users:      make(map[string]*model.RegisterDTO),
+		ids:        make(map[string]int64),
+		sessions:   make(map[string]string),
+		totp:       make(map[string]string),
+		challenges: make(map[string]string),
+		secrets:    make(map[int64]*model.Secret),
+		changed:    make(map[int64]int64),
+		deleted:    make(map[int64]int64),
+		onetime:    make(map[string]*model.OneTimeSecret),
+		grants:     make(map[int64]*model.EmergencyGrant),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/user/register", api.register)
+	mux.HandleFunc("POST /api/user/prelogin", api.prelogin)
+	mux.HandleFunc("POST /api/user/login", api.login)
+	mux.HandleFunc("POST /api/user/login/2fa", api.loginTwoFactor)
+	mux.HandleFunc("GET /api/secret", api.authorized(api.listSecrets))
+	mux.HandleFunc("POST /api/secret", api.authorized(api.createSecret))
+	mux.HandleFunc("GET /api/secret/{id}", api.authorized(api.getSecret))
+	mux.HandleFunc("PUT /api/secret/{id}", api.authorized(api.updateSecret))
+	mux.HandleFunc("DELETE /api/secret/{id}", api.authorized(api.deleteSecret))
+	mux.HandleFunc("GET /api/sync", api.authorized(api.changes))
+	mux.HandleFunc("POST /api/onetime", api.authorized(api.createOneTime))
+	mux.HandleFunc("GET /api/onetime/{id}", api.takeOneTime)
+	mux.HandleFunc("GET /api/user/{login}/key", api.authorized(api.publicKey))
+	mux.HandleFunc("POST /api/emergency", api.authorized(api.createGrant))
+	mux.HandleFunc("POST /api/emergency/{id}/request", api.authorized(api.requestGrant))
+	mux.HandleFunc("GET /api/emergency/{id}/vault", api.authorized(api.emergencyVault))
+
+	api.Server = httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if !api.down.Load() {
+			mux.ServeHTTP(res, req)
+			return
+		}
+		if conn, _, err := http.NewResponseController(res).Hijack(); err == nil {
+			conn.Close()
+		}
+	}))
+	t.Cleanup(api.Close)
+
+	return api
+}
+
+func (a *fakeAPI) newSession(login string) string {
+	sid := fmt.Sprintf("sid-%d", len(a.sessions)+1)
+	a.sessions[sid] = login
+	return sid
+}
+
+func (a *fakeAPI) register(res http.ResponseWriter, req *http.Request) {
+	var dto model.RegisterDTO
+	if err := json.NewDecoder(req.Body).Decode(&dto); err != nil {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if _, ok := a.users[dto.Login]; ok {
+		http.Error(res, "user exists", http.StatusConflict)
+		return
+	}
+	a.users[dto.Login] = &dto
+	a.ids[dto.Login] = int64(len(a.ids) + 1)
+
+	writeJSON(res, map[string]string{"sid": a.newSession(dto.Login)})
+}
+
+func (a *fakeAPI) prelogin(res http.ResponseWriter, req *http.Request) {
+	var dto model.RegisterDTO
+	if err := json.NewDecoder(req.Body).Decode(&dto); err != nil {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	user, ok := a.users[dto.Login]
+	if !ok {
+		http.Error(res, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	writeJSON(res, map[string][]byte{"salt": user.Salt})
+}
+
+func (a *fakeAPI) login(res http.ResponseWriter, req *http.Request) {
+	var dto model.RegisterDTO
+	if err := json.NewDecoder(req.Body).Decode(&dto); err != nil {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	user, ok := a.users[dto.Login]
+	if !ok || user.Password != dto.Password {
+		http.Error(res, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if _, ok := a.totp[dto.Login]; ok {
+		challenge := fmt.Sprintf("challenge-%d", len(a.challenges)+1)
+		a.challenges[challenge] = dto.Login
+		writeJSON(res, map[string]string{"challenge": challenge})
+		return
+	}
+
+	writeJSON(res, map[string]any{"sid": a.newSession(dto.Login), "vault_key": user.VaultKey})
+}
+
+func (a *fakeAPI) loginTwoFactor(res http.ResponseWriter, req *http.Request) {
+	var dto struct {
+		Challenge string `json:"challenge"`
+		Code      string `json:"code"`
+	}
+	if err := json.NewDecoder(req.Body).Decode(&dto); err != nil {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	login, ok := a.challenges[dto.Challenge]
+	if !ok || a.totp[login] != dto.Code {
+		http.Error(res, "invalid code", http.StatusUnauthorized)
+		return
+	}
+	delete(a.challenges, dto.Challenge)
+
+	writeJSON(res, map[string]any{"sid": a.newSession(login), "vault_key": a.users[login].VaultKey})
+}
+
+func (a *fakeAPI) authorized(next http.HandlerFunc) http.HandlerFunc {
+	return func(res http.ResponseWriter, req *http.Request) {
+		a.mu.Lock()
+		_, ok := a.sessions[req.Header.Get("Authorization")]
+		a.mu.Unlock()
+
+		if !ok {
+			http.Error(res, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next(res, req)
+	}
+}
+
+func (a *fakeAPI) listSecrets(res http.ResponseWriter, _ *http.Request) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	list := make([]model.Secret, 0, len(a.secrets))
+	for id := int64(1); id <= a.nextID; id++ {
+		if secret, ok := a.secrets[id]; ok {
+			list = append(list, *secret)
+		}
+	}
+
+	writeJSON(res, list)
+}
+
+func (a *fakeAPI) createSecret(res http.ResponseWriter, req *http.Request) {
+	var secret model.Secret
+	if err := json.NewDecoder(req.Body).Decode(&secret); err != nil {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for _, v := range a.secrets {
+		if v.Name == secret.Name {
+			http.Error(res, "secret exists", http.StatusConflict)
+			return
+		}
+	}
+
+	a.nextID++
+	secret.ID, secret.Revision = a.nextID, 1
+	a.secrets[secret.ID] = &secret
+	a.seq++
+	a.changed[secret.ID] = a.seq
+
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(http.StatusCreated)
+	json.NewEncoder(res).Encode(map[string]int64{"id": secret.ID})
+}
+
+// secret returns the secret in the URL, writing 404 if there is none.
+func (a *fakeAPI) secret(res http.ResponseWriter, req *http.Request) (*model.Secret, bool) {
+	id, _ := strconv.ParseInt(req.PathValue("id"), 10, 64)
+	secret, ok := a.secrets[id]
+	if !ok {
+		http.Error(res, "secret not found", http.StatusNotFound)
+	}
+	return secret, ok
+}
+
+// conflict writes 412 unless If-Match names the revision of secret.
+func conflict(res http.ResponseWriter, req *http.Request, secret *model.Secret) bool {
+	revision, _ := strconv.ParseInt(strings.Trim(req.Header.Get("If-Match"), `"`), 10, 64)
+	if revision == secret.Revision {
+		return false
+	}
+
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(http.StatusPreconditionFailed)
+	json.NewEncoder(res).Encode(map[string]any{"error": "revision mismatch", "revision": secret.Revision})
+	return true
+}
+
+func (a *fakeAPI) getSecret(res http.ResponseWriter, req *http.Request) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if secret, ok := a.secret(res, req); ok {
+		res.Header().Set("ETag", fmt.Sprintf(`"%d"`, secret.Revision))
+		writeJSON(res, secret)
+	}
+}
+
+func (a *fakeAPI) updateSecret(res http.ResponseWriter, req *http.Request) {
+	var data model.Secret
+	if err := json.NewDecoder(req.Body).Decode(&data); err != nil {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	secret, ok := a.secret(res, req)
+	if !ok || conflict(res, req, secret) {
+		return
+	}
+
+	data.ID, data.Name, data.UserID, data.Revision = secret.ID, secret.Name, secret.UserID, secret.Revision+1
+	*secret = data
+	a.seq++
+	a.changed[secret.ID] = a.seq
+
+	res.Header().Set("ETag", fmt.Sprintf(`"%d"`, secret.Revision))
+	writeJSON(res, map[string]int64{"id": secret.ID, "revision": secret.Revision})
+}
+
+func (a *fakeAPI) deleteSecret(res http.ResponseWriter, req *http.Request) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	secret, ok := a.secret(res, req)
+	if !ok || conflict(res, req, secret) {
+		return
+	}
+
+	delete(a.secrets, secret.ID)
+	delete(a.changed, secret.ID)
+	a.seq++
+	a.deleted[secret.ID] = a.seq
+	res.WriteHeader(http.StatusNoContent)
+}
+
+func (a *fakeAPI) changes(res http.ResponseWriter, req *http.Request) {
+	since, _ := strconv.ParseInt(req.URL.Query().Get("since"), 10, 64)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	changes := model.Changes{Cursor: since, Updated: []model.Secret{}, Deleted: []int64{}}
+	for id := int64(1); id <= a.nextID; id++ {
+		if seq := a.changed[id]; seq > since {
+			changes.Updated = append(changes.Updated, *a.secrets[id])
+			changes.Cursor = max(changes.Cursor, seq)
+		}
+		if seq := a.deleted[id]; seq > since {
+			// a first sync has nothing to delete
+			if since > 0 {
+				changes.Deleted = append(changes.Deleted, id)
+			}
+			changes.Cursor = max(changes.Cursor, seq)
+		}
+	}
+
+	writeJSON(res, changes)
+}
+
+func (a *fakeAPI) createOneTime(res http.ResponseWriter, req *http.Request) {
+	var secret model.OneTimeSecret
+	if err := json.NewDecoder(req.Body).Decode(&secret); err != nil {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	secret.ID = fmt.Sprintf("onetime-%d", len(a.onetime)+1)
+	a.onetime[secret.ID] = &secret
+
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(http.StatusCreated)
+	json.NewEncoder(res).Encode(map[string]any{"id": secret.ID, "expires_at": secret.ExpiresAt})
+}
+
+// takeOneTime counts a view, keeping used up secrets for the tests to look
+// at.
+func (a *fakeAPI) takeOneTime(res http.ResponseWriter, req *http.Request) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	secret, ok := a.onetime[req.PathValue("id")]
+	if !ok || secret.Views >= secret.MaxViews {
+		http.Error(res, "one-time secret not found", http.StatusNotFound)
+		return
+	}
+	secret.Views++
+
+	writeJSON(res, secret)
+}
+
+func (a *fakeAPI) publicKey(res http.ResponseWriter, req *http.Request) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	login := req.PathValue("login")
+	user, ok := a.users[login]
+	if !ok {
+		http.Error(res, "user not found", http.StatusNotFound)
+		return
+	}
+
+	writeJSON(res, model.PublicKey{UserID: a.ids[login], Login: login, Key: user.PublicKey})
+}
+
+// caller returns the ID of the user the session of req belongs to.
+func (a *fakeAPI) caller(req *http.Request) int64 {
+	return a.ids[a.sessions[req.Header.Get("Authorization")]]
+}
+
+func (a *fakeAPI) createGrant(res http.ResponseWriter, req *http.Request) {
+	var grant model.EmergencyGrant
+	if err := json.NewDecoder(req.Body).Decode(&grant); err != nil {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	grant.ID, grant.GrantorID = int64(len(a.grants)+1), a.caller(req)
+	a.grants[grant.ID] = &grant
+
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(http.StatusCreated)
+	json.NewEncoder(res).Encode(map[string]int64{"id": grant.ID})
+}
+
+// grant returns the grant in the URL received by the caller, writing 404
+// if there is none.
+func (a *fakeAPI) grant(res http.ResponseWriter, req *http.Request) (*model.EmergencyGrant, bool) {
+	id, _ := strconv.ParseInt(req.PathValue("id"), 10, 64)
+	grant, ok := a.grants[id]
+	if !ok || grant.GranteeID != a.caller(req) {
+		http.Error(res, "grant not found", http.StatusNotFound)
+		return nil, false
+	}
+	return grant, true
+}
+
+func (a *fakeAPI) requestGrant(res http.ResponseWriter, req *http.Request) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	grant, ok := a.grant(res, req)
+	if !ok {
+		return
+	}
+	if grant.RequestedAt == nil {
+		now := time.Now()
+		grant.RequestedAt = &now
+	}
+
+	withoutKeys := *grant
+	withoutKeys.Key, withoutKeys.VaultKey = nil, nil
+	writeJSON(res, withoutKeys)
+}
+
+// emergencyVault releases every secret, as they are all the grantor's in
+// the tests.
+func (a *fakeAPI) emergencyVault(res http.ResponseWriter, req *http.Request) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	grant, ok := a.grant(res, req)
+	if !ok {
+		return
+	}
+	if !grant.Released(time.Now()) {
+		http.Error(res, "waiting", http.StatusForbidden)
+		return
+	}
+
+	vault := model.EmergencyVault{Grant: *grant, Secrets: []model.Secret{}}
+	for id := int64(1); id <= a.nextID; id++ {
+		if secret, ok := a.secrets[id]; ok {
+			vault.Secrets = append(vault.Secrets, *secret)
+		}
+	}
+
+	writeJSON(res, vault)
+}
+
+func writeJSON(res http.ResponseWriter, v any) {
+	res.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(res).Encode(v)
+}
+
+// newAPIClient returns a client of api with a key directory of its own,
+// like a client on another machine.
+func newAPIClient(t *testing.T, api *fakeAPI) *Client {
+	t.Helper()
+
+	c, err := NewClient(context.Background(), &Config{
+		Address: api.URL,
+		KeyPath: t.TempDir() + string(filepath.Separator),
+	})
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	t.Cleanup(func() { c.Close() })
+
+	return c
+}
@@ -0,0 +1,54 @@
+package client
+
+import (
+	"bytes"
+	"context"
+
+	"github.com/nbvehbq/go-password-keeper/internal/model"
+)
+
+// AuditReport is a page of the audit trail of the user, oldest first.
+type AuditReport struct {
+	Events []model.AuditEvent
+	// BrokenAt is the ID of the first event failing the hash chain, zero
+	// when the page checks out.
+	BrokenAt int64
+	// Complete is set when the page reaches the first event of the trail.
+	Complete bool
+}
+
+// AuditLog reads up to limit events of the audit trail of the user and
+// checks their hash chain. newer is the oldest event of the page read
+// before, nil for the newest events; the page must chain into it.
+//
+// The chain proves that no event was altered or removed in between. The
+// newest events could still be cut off by whoever controls the server.
+func (c *Client) AuditLog(ctx context.Context, newer *model.AuditEvent, limit int) (*AuditReport, error) {
+	var before int64
+	if newer != nil {
+		before = newer.ID
+	}
+
+	events, err := c.transport.listAuditEvents(ctx, before, limit)
+	if err != nil {
+		return nil, c.onlineOnly(err)
+	}
+
+	report := &AuditReport{Events: events, Complete: len(events) < limit}
+	if i := model.VerifyAuditChain(events); i >= 0 {
+		report.BrokenAt = events[i].ID
+	}
+
+	switch {
+	case report.BrokenAt != 0:
+	case report.Complete && len(events) > 0 && len(events[0].PrevHash) > 0:
+		// the first event of the trail follows nothing
+		report.BrokenAt = events[0].ID
+	case newer != nil && len(events) > 0 && !bytes.Equal(newer.PrevHash, events[len(events)-1].Hash):
+		report.BrokenAt = newer.ID
+	case newer != nil && len(events) == 0 && len(newer.PrevHash) > 0:
+		report.BrokenAt = newer.ID
+	}
+
+	return report, nil
+}
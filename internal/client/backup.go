@@ -0,0 +1,338 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/nbvehbq/go-password-keeper/internal/model"
+	"github.com/nbvehbq/go-password-keeper/internal/storage"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/hkdf"
+)
+
+// Backup archive format (version 1).
+//
+//	"PKX" | version | kdfTime uint32 | kdfMemory uint32 | kdfThreads | salt[16] | blob
+//
+// blob is the stream of encryptBlob sealed with a key derived from the
+// backup password by Argon2id with the given parameters. The archive header
+// is part of the key derivation, so changing it makes the archive fail to
+// decrypt. The plaintext is a JSON backupManifest followed by its items.
+const (
+	backupVersion    byte = 1
+	backupHeaderSize      = 3 + 1 + 4 + 4 + 1 + saltSize
+
+	// limits of the KDF parameters accepted from an archive
+	maxBackupTime   = 16
+	maxBackupMemory = 1 << 20
+)
+
+var (
+	backupMagic = []byte("PKX")
+
+	ErrBackupFormat   = fmt.Errorf("not a supported backup archive")
+	ErrBackupPassword = fmt.Errorf("wrong backup password or damaged archive")
+)
+
+// BackupItem is a decrypted secret in a backup. Files keep their contents
+// in File and the Binary payload without its key.
+type BackupItem struct {
+	Name      string             `json:"name"`
+	Type      model.ResourceType `json:"type"`
+	Payload   json.RawMessage    `json:"payload"`
+	Meta      string             `json:"meta,omitempty"`
+	UpdatedAt time.Time          `json:"updated_at"`
+	File      []byte             `json:"file,omitempty"`
+}
+
+// backupManifest starts the plaintext of an archive and the plaintext JSON
+// export.
+type backupManifest struct {
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	Count     int       `json:"count"`
+}
+
+// RestoreReport lists the outcome of Restore. Skipped maps the names of
+// items that were not restored to the reason.
+type RestoreReport struct {
+	Restored int
+	Skipped  map[string]string
+}
+
+// Backup decrypts all secrets of the vault, including the contents of
+// files. Files can't be read while offline.
+func (c *Client) Backup(ctx context.Context) ([]BackupItem, error) {
+	list, err := c.ListSecrets(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]BackupItem, 0, len(list))
+	for _, v := range list {
+		secret, err := c.GetSecret(ctx, v.ID)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", v.Name, err)
+		}
+
+		item := BackupItem{
+			Name:      secret.Name,
+			Type:      secret.Type,
+			Payload:   secret.Payload,
+			Meta:      string(secret.Meta),
+			UpdatedAt: secret.UpdatedAt,
+		}
+
+		if secret.Type == model.BinaryType {
+			var entity model.Binary
+			if err := json.Unmarshal(secret.Payload, &entity); err != nil {
+				return nil, fmt.Errorf("%s: %w", v.Name, err)
+			}
+
+			if entity.Key == nil {
+				item.File = entity.Value
+			} else {
+				var buf bytes.Buffer
+				if err := c.downloadBlob(ctx, secret.ID, entity.Key, &buf); err != nil {
+					return nil, fmt.Errorf("%s: %w", v.Name, err)
+				}
+				item.File = buf.Bytes()
+			}
+
+			if item.Payload, err = json.Marshal(&model.Binary{Name: entity.Name, Size: int64(len(item.File))}); err != nil {
+				return nil, err
+			}
+		}
+
+		items = append(items, item)
+	}
+
+	return items, nil
+}
+
+// Restore creates the items of a backup in the vault of the logged in user.
+// Items whose name is already taken are skipped.
+func (c *Client) Restore(ctx context.Context, items []BackupItem) (*RestoreReport, error) {
+	report := &RestoreReport{Skipped: make(map[string]string)}
+
+	for _, v := range items {
+		secret := &model.Secret{
+			Name:    v.Name,
+			Type:    v.Type,
+			Payload: v.Payload,
+			Meta:    []byte(v.Meta),
+		}
+
+		var err error
+		if v.Type == model.BinaryType {
+			var entity model.Binary
+			if err = json.Unmarshal(v.Payload, &entity); err == nil {
+				_, err = c.createFile(ctx, secret, entity.Name, int64(len(v.File)), bytes.NewReader(v.File))
+			}
+		} else {
+			_, err = c.CreateSecret(ctx, secret)
+		}
+
+		switch {
+		case err == nil:
+			report.Restored++
+		case errors.Is(err, ErrUnauthorized):
+			return report, err
+		case errors.Is(err, storage.ErrSecretExists):
+			report.Skipped[v.Name] = "already exists"
+		default:
+			report.Skipped[v.Name] = err.Error()
+		}
+	}
+
+	return report, nil
+}
+
+// WriteBackup writes items to w as an archive encrypted with password.
+func WriteBackup(w io.Writer, password string, items []BackupItem) error {
+	salt, err := newSalt()
+	if err != nil {
+		return err
+	}
+
+	h := make([]byte, 0, backupHeaderSize)
+	h = append(h, backupMagic...)
+	h = append(h, backupVersion)
+	h = binary.BigEndian.AppendUint32(h, kdfTime)
+	h = binary.BigEndian.AppendUint32(h, kdfMemory)
+	h = append(h, kdfThreads)
+	h = append(h, salt...)
+
+	key, err := backupKey(password, h)
+	if err != nil {
+		return err
+	}
+
+	var plaintext bytes.Buffer
+	if err := writeItems(&plaintext, items); err != nil {
+		return err
+	}
+
+	if _, err := w.Write(h); err != nil {
+		return err
+	}
+	_, err = encryptBlob(key, w, &plaintext)
+	return err
+}
+
+// ReadBackup decrypts an archive written by WriteBackup. The whole archive
+// is authenticated before any item is returned.
+func ReadBackup(r io.Reader, password string) ([]BackupItem, error) {
+	h := make([]byte, backupHeaderSize)
+	if _, err := io.ReadFull(r, h); err != nil || !bytes.Equal(h[:3], backupMagic) {
+		return nil, ErrBackupFormat
+	}
+	if h[3] != backupVersion {
+		return nil, ErrBackupFormat
+	}
+
+	key, err := backupKey(password, h)
+	if err != nil {
+		return nil, err
+	}
+
+	var plaintext bytes.Buffer
+	if err := decryptBlob(key, &plaintext, r); err != nil {
+		return nil, ErrBackupPassword
+	}
+
+	dec := json.NewDecoder(&plaintext)
+	var manifest backupManifest
+	if err := dec.Decode(&manifest); err != nil || manifest.Version != int(backupVersion) {
+		return nil, ErrBackupFormat
+	}
+
+	items := make([]BackupItem, 0, manifest.Count)
+	for range manifest.Count {
+		var item BackupItem
+		if err := dec.Decode(&item); err != nil {
+			return nil, ErrBackupFormat
+		}
+		items = append(items, item)
+	}
+
+	return items, nil
+}
+
+// backupKey derives the archive key from the password and the KDF
+// parameters and salt in header h.
+func backupKey(password string, h []byte) ([]byte, error) {
+	iterations := binary.BigEndian.Uint32(h[4:])
+	memory := binary.BigEndian.Uint32(h[8:])
+	threads := h[12]
+	salt := h[13:]
+	if iterations == 0 || iterations > maxBackupTime || memory == 0 || memory > maxBackupMemory || threads == 0 {
+		return nil, ErrBackupFormat
+	}
+
+	master := argon2.IDKey([]byte(password), salt, iterations, memory, threads, kdfKeyLen)
+
+	key := make([]byte, dataKeySize)
+	info := append([]byte("keeper backup"), h...)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, master, salt, info), key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// writeItems writes the manifest and items as a stream of JSON values.
+func writeItems(w io.Writer, items []BackupItem) error {
+	enc := json.NewEncoder(w)
+	manifest := backupManifest{Version: int(backupVersion), CreatedAt: time.Now().UTC(), Count: len(items)}
+	if err := enc.Encode(&manifest); err != nil {
+		return err
+	}
+	for i := range items {
+		if err := enc.Encode(&items[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteJSON writes items to w unencrypted, in the format of the plaintext
+// of an archive.
+func WriteJSON(w io.Writer, items []BackupItem) error {
+	return writeItems(w, items)
+}
+
+// csvHeader are the columns of WriteCSV.
+var csvHeader = []string{
+	"name", "type", "login", "password", "url", "text", "card_number", "expire_at",
+	"card_name", "card_surname", "otp_uri", "file_name", "meta", "updated_at",
+}
+
+// WriteCSV writes items to w unencrypted, one row per secret. Contents of
+// files are not included, only their names.
+func WriteCSV(w io.Writer, items []BackupItem) error {
+	out := csv.NewWriter(w)
+	if err := out.Write(csvHeader); err != nil {
+		return err
+	}
+
+	for _, v := range items {
+		row := make(map[string]string, len(csvHeader))
+		row["name"] = v.Name
+		row["type"] = strconv.Itoa(int(v.Type))
+		row["meta"] = v.Meta
+		row["updated_at"] = v.UpdatedAt.Format(time.RFC3339)
+
+		var err error
+		switch v.Type {
+		case model.LoginPasswordType:
+			var e model.LoginPassword
+			if err = json.Unmarshal(v.Payload, &e); err == nil {
+				row["login"], row["password"], row["url"] = e.Login, e.Password, e.URL
+			}
+		case model.TextType:
+			var e model.Text
+			if err = json.Unmarshal(v.Payload, &e); err == nil {
+				row["text"] = e.Value
+			}
+		case model.BankCardType:
+			var e model.BankCard
+			if err = json.Unmarshal(v.Payload, &e); err == nil {
+				row["card_number"], row["expire_at"] = e.Number, e.ExpireAt
+				row["card_name"], row["card_surname"] = e.Name, e.Surname
+			}
+		case model.OTPType:
+			var e model.OTP
+			if err = json.Unmarshal(v.Payload, &e); err == nil {
+				row["otp_uri"] = e.URI
+			}
+		case model.BinaryType:
+			var e model.Binary
+			if err = json.Unmarshal(v.Payload, &e); err == nil {
+				row["file_name"] = e.Name
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", v.Name, err)
+		}
+
+		record := make([]string, len(csvHeader))
+		for i, name := range csvHeader {
+			record[i] = row[name]
+		}
+		if err := out.Write(record); err != nil {
+			return err
+		}
+	}
+
+	out.Flush()
+	return out.Error()
+}
@@ -0,0 +1,330 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"io"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nbvehbq/go-password-keeper/internal/model"
+	"github.com/nbvehbq/go-password-keeper/internal/storage"
+)
+
+// memTransport is a server holding a single vault in memory. Only the
+// requests used by Backup and Restore are implemented.
+type memTransport struct {
+	transport
+
+	mu      sync.Mutex
+	nextID  int64
+	secrets map[int64]*model.Secret
+	blobs   map[int64][]byte
+}
+
+func newMemTransport() *memTransport {
+	return &memTransport{secrets: make(map[int64]*model.Secret), blobs: make(map[int64][]byte)}
+}
+
+func (m *memTransport) listSecrets(context.Context, model.SecretFilter) ([]model.Secret, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	list := make([]model.Secret, 0, len(m.secrets))
+	for id := int64(1); id <= m.nextID; id++ {
+		if secret, ok := m.secrets[id]; ok {
+			list = append(list, *secret)
+		}
+	}
+	return list, nil
+}
+
+func (m *memTransport) getSecret(_ context.Context, ID int64) (*model.Secret, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	secret, ok := m.secrets[ID]
+	if !ok {
+		return nil, storage.ErrSecretNotFound
+	}
+	copied := *secret
+	return &copied, nil
+}
+
+func (m *memTransport) createSecret(_ context.Context, sealed *model.Secret) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, v := range m.secrets {
+		if v.Name == sealed.Name {
+			return 0, storage.ErrSecretExists
+		}
+	}
+
+	m.nextID++
+	secret := *sealed
+	secret.ID, secret.Revision, secret.UpdatedAt = m.nextID, 1, time.Now().UTC()
+	m.secrets[secret.ID] = &secret
+	return secret.ID, nil
+}
+
+// replace overwrites the secret ID if it is still at the revision of sealed.
+func (m *memTransport) replace(ID int64, sealed *model.Secret) (int64, error) {
+	secret, ok := m.secrets[ID]
+	if !ok {
+		return 0, storage.ErrSecretNotFound
+	}
+	if sealed.Revision != secret.Revision {
+		return 0, &ConflictError{Revision: secret.Revision}
+	}
+
+	secret.Type, secret.Payload, secret.Meta, secret.Key = sealed.Type, sealed.Payload, sealed.Meta, sealed.Key
+	secret.Revision++
+	secret.UpdatedAt = time.Now().UTC()
+	return secret.Revision, nil
+}
+
+func (m *memTransport) updateSecret(_ context.Context, ID int64, sealed *model.Secret) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.replace(ID, sealed)
+}
+
+func (m *memTransport) deleteSecret(_ context.Context, ID, revision int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	secret, ok := m.secrets[ID]
+	if !ok {
+		return storage.ErrSecretNotFound
+	}
+	if revision != secret.Revision {
+		return &ConflictError{Revision: secret.Revision}
+	}
+	delete(m.secrets, ID)
+	delete(m.blobs, ID)
+	return nil
+}
+
+func (m *memTransport) putBlob(_ context.Context, ID int64, sealed *model.Secret, r io.Reader) (int64, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return 0, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if secret, ok := m.secrets[ID]; ok && secret.Type != model.BinaryType {
+		return 0, storage.ErrNotBinary
+	}
+	revision, err := m.replace(ID, sealed)
+	if err != nil {
+		return 0, err
+	}
+	m.blobs[ID] = data
+	return revision, nil
+}
+
+func (m *memTransport) getBlob(_ context.Context, ID int64) (io.ReadCloser, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	data, ok := m.blobs[ID]
+	if !ok {
+		return nil, storage.ErrBlobNotFound
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+// newTestClient returns a client logged in to an empty vault of its own,
+// without a local cache.
+func newTestClient(t *testing.T) (*Client, *memTransport) {
+	t.Helper()
+
+	// smaller than the keys of real accounts, to keep the tests fast
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := newMemTransport()
+	return &Client{transport: m, cfg: &Config{}, privateKey: key}, m
+}
+
+func jsonPayload(t *testing.T, v any) []byte {
+	t.Helper()
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// withoutTimes clears what is set by the server rather than restored.
+func withoutTimes(items []BackupItem) []BackupItem {
+	list := make([]BackupItem, len(items))
+	for i, v := range items {
+		v.UpdatedAt = time.Time{}
+		list[i] = v
+	}
+	return list
+}
+
+func TestBackupRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	src, _ := newTestClient(t)
+
+	secrets := []*model.Secret{
+		{Name: "mail", Type: model.LoginPasswordType, Meta: []byte("personal"),
+			Payload: jsonPayload(t, &model.LoginPassword{Login: "alice", Password: "s3cret", URL: "https://mail.example.com"})},
+		{Name: "note", Type: model.TextType, Payload: jsonPayload(t, &model.Text{Value: "line one\nline two"})},
+		{Name: "visa", Type: model.BankCardType, Meta: []byte("CVV: 123"),
+			Payload: jsonPayload(t, &model.BankCard{Number: "4111111111111111", ExpireAt: "01/30", Name: "Alice"})},
+	}
+	for _, v := range secrets {
+		if _, err := src.CreateSecret(ctx, v); err != nil {
+			t.Fatalf("create %s: %v", v.Name, err)
+		}
+	}
+
+	contents := make([]byte, 3*blobChunkSize/2)
+	rand.Read(contents)
+	for _, v := range []struct {
+		name string
+		data []byte
+	}{{"photo", contents}, {"empty", nil}} {
+		secret := &model.Secret{Name: v.name, Type: model.BinaryType}
+		if _, err := src.createFile(ctx, secret, v.name+".bin", int64(len(v.data)), bytes.NewReader(v.data)); err != nil {
+			t.Fatalf("create file %s: %v", v.name, err)
+		}
+	}
+
+	items, err := src.Backup(ctx)
+	if err != nil {
+		t.Fatalf("backup: %v", err)
+	}
+	if len(items) != 5 {
+		t.Fatalf("%d items in the backup, want 5", len(items))
+	}
+	if !bytes.Equal(items[3].File, contents) || len(items[4].File) != 0 {
+		t.Fatalf("file contents not backed up")
+	}
+
+	var archive bytes.Buffer
+	if err := WriteBackup(&archive, "backup password", items); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if bytes.Contains(archive.Bytes(), []byte("s3cret")) {
+		t.Fatal("archive contains a plaintext password")
+	}
+
+	read, err := ReadBackup(bytes.NewReader(archive.Bytes()), "backup password")
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+
+	dst, server := newTestClient(t)
+	report, err := dst.Restore(ctx, read)
+	if err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	if report.Restored != len(items) || len(report.Skipped) != 0 {
+		t.Fatalf("restored %d, skipped %v; want %d restored", report.Restored, report.Skipped, len(items))
+	}
+
+	restored, err := dst.Backup(ctx)
+	if err != nil {
+		t.Fatalf("backup of the restored vault: %v", err)
+	}
+	if !reflect.DeepEqual(withoutTimes(restored), withoutTimes(items)) {
+		t.Errorf("restored vault differs:\n%+v\nwant\n%+v", restored, items)
+	}
+
+	// the restored vault is encrypted with keys of its own
+	for _, v := range server.secrets {
+		if bytes.Contains(v.Payload, []byte("s3cret")) {
+			t.Errorf("%s is stored in plaintext", v.Name)
+		}
+	}
+
+	// restoring again skips what exists
+	report, err = dst.Restore(ctx, read)
+	if err != nil {
+		t.Fatalf("second restore: %v", err)
+	}
+	if report.Restored != 0 || len(report.Skipped) != len(items) {
+		t.Errorf("second restore: restored %d, skipped %v", report.Restored, report.Skipped)
+	}
+	for name, reason := range report.Skipped {
+		if reason != "already exists" {
+			t.Errorf("%s skipped: %s", name, reason)
+		}
+	}
+}
+
+func TestReadBackupErrors(t *testing.T) {
+	items := []BackupItem{{Name: "note", Type: model.TextType, Payload: json.RawMessage(`{"value":"x"}`)}}
+
+	var buf bytes.Buffer
+	if err := WriteBackup(&buf, "right", items); err != nil {
+		t.Fatal(err)
+	}
+	archive := buf.Bytes()
+
+	tamper := func(i int) []byte {
+		data := bytes.Clone(archive)
+		data[i] ^= 1
+		return data
+	}
+
+	tests := []struct {
+		name     string
+		data     []byte
+		password string
+		wantErr  error
+	}{
+		{name: "wrong password", data: archive, password: "wrong", wantErr: ErrBackupPassword},
+		{name: "empty password", data: archive, password: "", wantErr: ErrBackupPassword},
+		{name: "tampered contents", data: tamper(len(archive) - 1), password: "right", wantErr: ErrBackupPassword},
+		{name: "tampered salt", data: tamper(backupHeaderSize - 1), password: "right", wantErr: ErrBackupPassword},
+		{name: "truncated", data: archive[:len(archive)-5], password: "right", wantErr: ErrBackupPassword},
+		{name: "magic", data: tamper(0), password: "right", wantErr: ErrBackupFormat},
+		{name: "version", data: tamper(3), password: "right", wantErr: ErrBackupFormat},
+		{name: "KDF threads", data: tamper(12), password: "right", wantErr: ErrBackupPassword},
+		{name: "no KDF threads", data: func() []byte {
+			data := bytes.Clone(archive)
+			data[12] = 0
+			return data
+		}(), password: "right", wantErr: ErrBackupFormat},
+		{name: "short header", data: archive[:backupHeaderSize-1], password: "right", wantErr: ErrBackupFormat},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ReadBackup(bytes.NewReader(tt.data), tt.password)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("got %v, want %v", err, tt.wantErr)
+			}
+			if got != nil {
+				t.Errorf("returned %d items", len(got))
+			}
+		})
+	}
+
+	got, err := ReadBackup(bytes.NewReader(archive), "right")
+	if err != nil {
+		t.Fatalf("right password: %v", err)
+	}
+	if !reflect.DeepEqual(withoutTimes(got), items) {
+		t.Errorf("read %+v, want %+v", got, items)
+	}
+}
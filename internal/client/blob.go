@@ -0,0 +1,388 @@
+package client
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/nbvehbq/go-password-keeper/internal/logger"
+	"github.com/nbvehbq/go-password-keeper/internal/model"
+	"go.uber.org/zap"
+)
+
+// File contents of binary secrets are streamed to the server as
+//
+//	"PKB" | version | alg | keyID[8] | frame...
+//	frame: final | len(ct) uint32 | nonce | AES-256-GCM(chunk)
+//
+// with chunks of at most blobChunkSize bytes. Every frame is authenticated
+// together with the stream header, its index and the final flag, so frames
+// cannot be reordered, dropped or the stream truncated without detection.
+// The last frame has the final flag set and may be empty.
+const (
+	blobChunkSize = 64 << 10
+	frameHeader   = 1 + 4
+)
+
+var (
+	blobMagic = []byte("PKB")
+
+	errTruncatedBlob = fmt.Errorf("blob is truncated")
+)
+
+func chunkAAD(h []byte, index uint64, final byte) []byte {
+	aad := make([]byte, 0, len(h)+9)
+	aad = append(aad, h...)
+	aad = binary.BigEndian.AppendUint64(aad, index)
+	return append(aad, final)
+}
+
+// encryptBlob encrypts r to w chunk by chunk and returns the plaintext size.
+func encryptBlob(key []byte, w io.Writer, r io.Reader) (int64, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return 0, err
+	}
+
+	h := header(blobMagic, algAES256GCM, masterKeyID)
+	if _, err := w.Write(h); err != nil {
+		return 0, err
+	}
+
+	var size int64
+	chunk := make([]byte, blobChunkSize)
+	frame := make([]byte, 0, frameHeader+aead.NonceSize()+blobChunkSize+aead.Overhead())
+	for index := uint64(0); ; index++ {
+		n, err := io.ReadFull(r, chunk)
+		var final byte
+		switch {
+		case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+			final = 1
+		case err != nil:
+			return size, err
+		}
+		size += int64(n)
+
+		nonce := make([]byte, aead.NonceSize())
+		if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+			return size, err
+		}
+
+		frame = append(frame[:0], final)
+		frame = binary.BigEndian.AppendUint32(frame, uint32(len(nonce)+n+aead.Overhead()))
+		frame = append(frame, nonce...)
+		frame = aead.Seal(frame, nonce, chunk[:n], chunkAAD(h, index, final))
+		if _, err := w.Write(frame); err != nil {
+			return size, err
+		}
+
+		if final == 1 {
+			return size, nil
+		}
+	}
+}
+
+// decryptBlob decrypts a stream produced by encryptBlob from r to w.
+func decryptBlob(key []byte, w io.Writer, r io.Reader) error {
+	aead, err := newGCM(key)
+	if err != nil {
+		return err
+	}
+
+	h := make([]byte, headerSize)
+	if _, err := io.ReadFull(r, h); err != nil {
+		return errMalformedEnvelope
+	}
+	alg, _, err := parseHeader(blobMagic, h)
+	if err != nil {
+		return err
+	}
+	if alg != algAES256GCM {
+		return errUnsupportedFormat
+	}
+
+	maxFrame := aead.NonceSize() + blobChunkSize + aead.Overhead()
+	buf := make([]byte, maxFrame)
+	fh := make([]byte, frameHeader)
+	for index := uint64(0); ; index++ {
+		if _, err := io.ReadFull(r, fh); err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				return errTruncatedBlob
+			}
+			return err
+		}
+
+		final := fh[0]
+		size := int(binary.BigEndian.Uint32(fh[1:]))
+		if final > 1 || size < aead.NonceSize()+aead.Overhead() || size > maxFrame {
+			return errMalformedEnvelope
+		}
+
+		body := buf[:size]
+		if _, err := io.ReadFull(r, body); err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				return errTruncatedBlob
+			}
+			return err
+		}
+
+		plaintext, err := aead.Open(body[aead.NonceSize():aead.NonceSize()], body[:aead.NonceSize()],
+			body[aead.NonceSize():], chunkAAD(h, index, final))
+		if err != nil {
+			return fmt.Errorf("failed to decrypt blob: %v", err)
+		}
+		if _, err := w.Write(plaintext); err != nil {
+			return err
+		}
+
+		if final == 1 {
+			break
+		}
+	}
+
+	// nothing may follow the final frame
+	if _, err := io.ReadFull(r, buf[:1]); !errors.Is(err, io.EOF) {
+		return errMalformedEnvelope
+	}
+
+	return nil
+}
+
+// CreateFile creates a binary secret from the file at path. The contents
+// are encrypted with a key of their own, kept in the secret's payload, and
+// streamed to the server once the secret is created. Files can't be added
+// while offline.
+func (c *Client) CreateFile(ctx context.Context, data *model.Secret, path string) (int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	return c.createFile(ctx, data, filepath.Base(path), info.Size(), file)
+}
+
+// createFile creates a binary secret named name with size bytes read from r.
+func (c *Client) createFile(ctx context.Context, data *model.Secret, name string, size int64, r io.Reader) (int64, error) {
+	key, err := newDataKey()
+	if err != nil {
+		return 0, ErrEncrypt
+	}
+
+	secret := *data
+	secret.Payload, err = json.Marshal(&model.Binary{
+		Name: name,
+		Size: size,
+		Key:  key,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	sealed := secret
+	sealed.ID, sealed.UUID = 0, ""
+	if err := c.sealSecret(&sealed); err != nil {
+		logger.Log.Error("failed to encrypt data", zap.Error(err))
+		return 0, ErrEncrypt
+	}
+
+	id, err := c.transport.createSecret(ctx, &sealed)
+	if err != nil {
+		return 0, c.onlineOnly(err)
+	}
+
+	// the contents are uploaded against the revision just created
+	secret.Key, secret.UUID, secret.Revision = sealed.Key, sealed.UUID, 1
+	if _, err := c.putFile(ctx, id, &secret, key, r); err != nil {
+		// don't leave a secret without contents behind
+		if err := c.transport.deleteSecret(ctx, id, secret.Revision); err != nil {
+			logger.Log.Warn("failed to remove incomplete file", zap.Error(err))
+		}
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// UpdateFile replaces the contents of a binary secret with the file at
+// path. data is the decrypted secret as returned by GetSecret; the contents
+// and the payload describing them are replaced in one request, subject to
+// the same revision check as UpdateSecret. Files can't be updated while
+// the secret has changes not synced yet.
+func (c *Client) UpdateFile(ctx context.Context, ID int64, data *model.Secret, path string) (int64, error) {
+	if c.hasLocalChanges(ID) {
+		return 0, ErrPendingChanges
+	}
+
+	var entity model.Binary
+	if err := json.Unmarshal(data.Payload, &entity); err != nil {
+		return 0, err
+	}
+
+	// a fresh key every time, so whoever had the old one, like a user the
+	// file is no longer shared with, can't read the new contents
+	key, err := newDataKey()
+	if err != nil {
+		return 0, ErrEncrypt
+	}
+	entity.Key = key
+
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	entity.Name, entity.Size, entity.Value = filepath.Base(path), info.Size(), nil
+
+	secret := *data
+	secret.Payload, err = json.Marshal(&entity)
+	if err != nil {
+		return 0, err
+	}
+
+	return c.putFile(ctx, ID, &secret, entity.Key, file)
+}
+
+// rekeyFile re-encrypts the contents of a binary secret under a fresh file
+// key. secret is decrypted as by GetSecret and updated in place with the
+// new payload and revision. Files with inline contents have no file key.
+func (c *Client) rekeyFile(ctx context.Context, secret *model.Secret) error {
+	var entity model.Binary
+	if err := json.Unmarshal(secret.Payload, &entity); err != nil {
+		return err
+	}
+	if entity.Key == nil {
+		return nil
+	}
+
+	key, err := newDataKey()
+	if err != nil {
+		return ErrEncrypt
+	}
+
+	// the contents are decrypted while being uploaded again; a failed
+	// download fails the upload, which leaves the secret as it was
+	pr, pw := io.Pipe()
+	go func(old []byte) {
+		pw.CloseWithError(c.downloadBlob(ctx, secret.ID, old, pw))
+	}(entity.Key)
+	defer pr.Close()
+
+	entity.Key = key
+	data := *secret
+	if data.Payload, err = json.Marshal(&entity); err != nil {
+		return err
+	}
+
+	revision, err := c.putFile(ctx, secret.ID, &data, key, pr)
+	if err != nil {
+		return err
+	}
+
+	secret.Payload, secret.Revision = data.Payload, revision
+
+	return nil
+}
+
+// SaveFile writes the decrypted contents of a binary secret to path. The
+// file is written to a temporary file first, so a failed download never
+// leaves a partial file at path.
+func (c *Client) SaveFile(ctx context.Context, secret *model.Secret, path string) error {
+	var entity model.Binary
+	if err := json.Unmarshal(secret.Payload, &entity); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".keeper-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	if entity.Key == nil {
+		_, err = tmp.Write(entity.Value)
+	} else {
+		err = c.downloadBlob(ctx, secret.ID, entity.Key, tmp)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// putFile seals data, which must carry the current revision, and replaces
+// it on the server together with the contents read from r, encrypted with
+// key. It returns the new revision.
+func (c *Client) putFile(ctx context.Context, ID int64, data *model.Secret, key []byte, r io.Reader) (int64, error) {
+	// encrypt a copy so the caller keeps the plaintext
+	sealed := *data
+	sealed.ID = ID
+	if err := c.sealSecret(&sealed); err != nil {
+		logger.Log.Error("failed to encrypt data", zap.Error(err))
+		return 0, ErrEncrypt
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		_, err := encryptBlob(key, pw, r)
+		pw.CloseWithError(err)
+	}()
+	// unblocks the encrypting goroutine if the request ends early
+	defer pr.Close()
+
+	revision, err := c.transport.putBlob(ctx, ID, &sealed, pr)
+	if err != nil {
+		if isOffline(err) {
+			c.offline = true
+			return 0, ErrOffline
+		}
+		return 0, err
+	}
+
+	sealed.ID, sealed.Revision = ID, revision
+	c.cacheSecret(&sealed)
+
+	return revision, nil
+}
+
+func (c *Client) downloadBlob(ctx context.Context, ID int64, key []byte, w io.Writer) error {
+	body, err := c.transport.getBlob(ctx, ID)
+	if err != nil {
+		if isOffline(err) {
+			c.offline = true
+			return ErrOffline
+		}
+		return err
+	}
+	defer body.Close()
+
+	if err := decryptBlob(key, w, body); err != nil {
+		logger.Log.Error("failed to decrypt file", zap.Error(err))
+		return ErrDecrypt
+	}
+
+	return nil
+}
@@ -0,0 +1,169 @@
+package client
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+	"testing"
+)
+
+func encryptTestBlob(t *testing.T, key, plaintext []byte) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	size, err := encryptBlob(key, &buf, bytes.NewReader(plaintext))
+	if err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+	if size != int64(len(plaintext)) {
+		t.Fatalf("encrypt: size %d, want %d", size, len(plaintext))
+	}
+	return buf.Bytes()
+}
+
+// splitBlob splits an encrypted blob into its header and frames.
+func splitBlob(t *testing.T, data []byte) ([]byte, [][]byte) {
+	t.Helper()
+
+	h, rest := data[:headerSize], data[headerSize:]
+	var frames [][]byte
+	for len(rest) > 0 {
+		n := frameHeader + int(binary.BigEndian.Uint32(rest[1:frameHeader]))
+		frames = append(frames, rest[:n])
+		rest = rest[n:]
+	}
+	return h, frames
+}
+
+func joinBlob(h []byte, frames ...[]byte) []byte {
+	return bytes.Join(append([][]byte{h}, frames...), nil)
+}
+
+func TestBlobRoundTrip(t *testing.T) {
+	key := newTestKey(t)
+
+	tests := []struct {
+		name   string
+		size   int
+		frames int
+	}{
+		{name: "empty", size: 0, frames: 1},
+		{name: "one byte", size: 1, frames: 1},
+		{name: "below chunk", size: blobChunkSize - 1, frames: 1},
+		{name: "chunk", size: blobChunkSize, frames: 2},
+		{name: "above chunk", size: blobChunkSize + 1, frames: 2},
+		{name: "chunks", size: 3 * blobChunkSize, frames: 4},
+		{name: "chunks and a bit", size: 3*blobChunkSize + 17, frames: 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plaintext := make([]byte, tt.size)
+			rand.Read(plaintext)
+
+			data := encryptTestBlob(t, key, plaintext)
+			if _, frames := splitBlob(t, data); len(frames) != tt.frames {
+				t.Errorf("%d frames, want %d", len(frames), tt.frames)
+			}
+
+			var out bytes.Buffer
+			if err := decryptBlob(key, &out, bytes.NewReader(data)); err != nil {
+				t.Fatalf("decrypt: %v", err)
+			}
+			if !bytes.Equal(out.Bytes(), plaintext) {
+				t.Errorf("decrypted %d bytes differ from the %d encrypted", out.Len(), len(plaintext))
+			}
+		})
+	}
+}
+
+func TestBlobTampering(t *testing.T) {
+	key := newTestKey(t)
+	plaintext := make([]byte, 2*blobChunkSize+100)
+	rand.Read(plaintext)
+
+	data := encryptTestBlob(t, key, plaintext)
+	h, frames := splitBlob(t, data)
+	if len(frames) != 3 {
+		t.Fatalf("%d frames, want 3", len(frames))
+	}
+
+	flip := func(b []byte, i int) []byte {
+		b = bytes.Clone(b)
+		b[i] ^= 1
+		return b
+	}
+
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr error
+	}{
+		{name: "ciphertext", data: joinBlob(h, frames[0], flip(frames[1], len(frames[1])-20), frames[2])},
+		{name: "nonce", data: joinBlob(h, flip(frames[0], frameHeader), frames[1], frames[2])},
+		{name: "header key id", data: joinBlob(flip(h, headerSize-1), frames...)},
+		{name: "header magic", data: joinBlob(flip(h, 0), frames...), wantErr: errMalformedEnvelope},
+		{name: "final flag set early", data: joinBlob(h, flip(frames[0], 0))},
+		{name: "final flag cleared", data: joinBlob(h, frames[0], frames[1], flip(frames[2], 0))},
+		{name: "bad final flag", data: joinBlob(h, frames[0], frames[1], flip(frames[2], 1)),
+			wantErr: errMalformedEnvelope},
+		{name: "reordered", data: joinBlob(h, frames[1], frames[0], frames[2])},
+		{name: "frame dropped", data: joinBlob(h, frames[0], frames[2])},
+		{name: "frame repeated", data: joinBlob(h, frames[0], frames[0], frames[1], frames[2])},
+		{name: "final frame dropped", data: joinBlob(h, frames[0], frames[1]), wantErr: errTruncatedBlob},
+		{name: "cut in a frame", data: data[:len(data)-10], wantErr: errTruncatedBlob},
+		{name: "cut in a frame header", data: joinBlob(h, frames[0], frames[1][:2]), wantErr: errTruncatedBlob},
+		{name: "only the header", data: h, wantErr: errTruncatedBlob},
+		{name: "cut in the header", data: h[:5], wantErr: errMalformedEnvelope},
+		{name: "empty", data: nil, wantErr: errMalformedEnvelope},
+		{name: "trailing data", data: append(bytes.Clone(data), 0), wantErr: errMalformedEnvelope},
+		{name: "frame after the final one", data: joinBlob(h, frames[0], frames[1], frames[2], frames[2]),
+			wantErr: errMalformedEnvelope},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := decryptBlob(key, &bytes.Buffer{}, bytes.NewReader(tt.data))
+			if err == nil {
+				t.Fatal("tampered blob decrypted")
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("got %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestBlobWrongKey(t *testing.T) {
+	data := encryptTestBlob(t, newTestKey(t), []byte("file contents"))
+
+	if err := decryptBlob(newTestKey(t), &bytes.Buffer{}, bytes.NewReader(data)); err == nil {
+		t.Error("blob decrypted with another key")
+	}
+}
+
+// stallReader returns nothing without an error once, then io.EOF.
+type stallReader struct {
+	stalled bool
+}
+
+func (r *stallReader) Read([]byte) (int, error) {
+	if !r.stalled {
+		r.stalled = true
+		return 0, nil
+	}
+	return 0, io.EOF
+}
+
+// A read returning no data is not the end of the stream.
+func TestBlobTrailingDataAfterEmptyRead(t *testing.T) {
+	key := newTestKey(t)
+	data := encryptTestBlob(t, key, []byte("file contents"))
+
+	r := io.MultiReader(bytes.NewReader(data), &stallReader{}, bytes.NewReader([]byte{0}))
+	if err := decryptBlob(key, &bytes.Buffer{}, r); !errors.Is(err, errMalformedEnvelope) {
+		t.Errorf("got %v, want %v", err, errMalformedEnvelope)
+	}
+}
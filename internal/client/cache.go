@@ -0,0 +1,488 @@
+package client
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/nbvehbq/go-password-keeper/internal/model"
+	"github.com/nbvehbq/go-password-keeper/internal/storage"
+	"go.etcd.io/bbolt"
+)
+
+var (
+	metaBucket    = []byte("meta")
+	secretsBucket = []byte("secrets")
+	pendingBucket = []byte("pending")
+	keysBucket    = []byte("keys")
+
+	metaSalt     = []byte("salt")
+	metaVaultKey = []byte("vault_key")
+	metaLocalID  = []byte("local_id")
+	metaCursor   = []byte("cursor")
+
+	errCacheLocked = fmt.Errorf("cache is locked")
+)
+
+// Operations queued while the server is unreachable.
+const (
+	opCreate = "create"
+	opUpdate = "update"
+	opDelete = "delete"
+)
+
+// pendingOp is a change made offline. Secret holds the record in the form
+// sent to the server, i.e. with payload & meta already encrypted, and its
+// Revision is the revision the change was based on.
+type pendingOp struct {
+	Seq    uint64        `json:"seq"`
+	Op     string        `json:"op"`
+	ID     int64         `json:"id"`
+	Secret *model.Secret `json:"secret,omitempty"`
+}
+
+// vaultCache is the local copy of a vault in a single bbolt file under the
+// client's KeyPath. Secrets & queued changes are sealed with a key derived
+// from the master password; meta keeps the salt and the encrypted vault key
+// needed to unlock the vault offline; keys the fingerprints of public keys
+// of other users pinned on first use.
+type vaultCache struct {
+	db  *bbolt.DB
+	key []byte
+}
+
+// cachePath names the cache file after the hash of login, which may hold
+// characters unsafe in a file name.
+func cachePath(dir, login string) string {
+	sum := sha256.Sum256([]byte(login))
+	return filepath.Join(dir, fmt.Sprintf("%x.db", sum))
+}
+
+func openCache(dir, login string) (*vaultCache, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+
+	db, err := bbolt.Open(cachePath(dir, login), 0600, &bbolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Update(func(tx *bbolt.Tx) error {
+		for _, name := range [][]byte{metaBucket, secretsBucket, pendingBucket, keysBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &vaultCache{db: db}, nil
+}
+
+// cacheExists reports whether a vault of login was cached before.
+func cacheExists(dir, login string) bool {
+	_, err := os.Stat(cachePath(dir, login))
+	return err == nil
+}
+
+func (v *vaultCache) Close() error {
+	return v.db.Close()
+}
+
+// unlock sets the key sealing cached records.
+func (v *vaultCache) unlock(key []byte) {
+	v.key = key
+}
+
+func (v *vaultCache) setMeta(name, value []byte) error {
+	return v.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(metaBucket).Put(name, value)
+	})
+}
+
+func (v *vaultCache) meta(name []byte) []byte {
+	var value []byte
+	v.db.View(func(tx *bbolt.Tx) error {
+		if data := tx.Bucket(metaBucket).Get(name); data != nil {
+			value = append([]byte(nil), data...)
+		}
+		return nil
+	})
+	return value
+}
+
+func (v *vaultCache) seal(value any) ([]byte, error) {
+	if v.key == nil {
+		return nil, errCacheLocked
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	return seal(v.key, masterKeyID, data)
+}
+
+func (v *vaultCache) open(data []byte, value any) error {
+	if v.key == nil {
+		return errCacheLocked
+	}
+
+	plain, err := open(v.key, data)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(plain, value)
+}
+
+func itob(v int64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, uint64(v))
+	return b
+}
+
+func btoi(b []byte) int64 {
+	return int64(binary.BigEndian.Uint64(b))
+}
+
+// putSecrets stores secrets as received from the server. Secrets with
+// queued local changes are left untouched.
+func (v *vaultCache) putSecrets(secrets ...model.Secret) error {
+	return v.db.Update(func(tx *bbolt.Tx) error {
+		changed, err := v.changedIDs(tx)
+		if err != nil {
+			return err
+		}
+
+		b := tx.Bucket(secretsBucket)
+		for i := range secrets {
+			if changed[secrets[i].ID] {
+				continue
+			}
+			data, err := v.seal(&secrets[i])
+			if err != nil {
+				return err
+			}
+			if err := b.Put(itob(secrets[i].ID), data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// replaceSecrets drops every cached secret not present in secrets, except
+// for those with queued local changes, and stores the rest.
+func (v *vaultCache) replaceSecrets(secrets []model.Secret) error {
+	err := v.db.Update(func(tx *bbolt.Tx) error {
+		changed, err := v.changedIDs(tx)
+		if err != nil {
+			return err
+		}
+
+		keep := make(map[int64]bool, len(secrets))
+		for _, s := range secrets {
+			keep[s.ID] = true
+		}
+
+		b := tx.Bucket(secretsBucket)
+		var stale [][]byte
+		err = b.ForEach(func(k, _ []byte) error {
+			if id := btoi(k); !keep[id] && !changed[id] {
+				stale = append(stale, append([]byte(nil), k...))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, k := range stale {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return v.putSecrets(secrets...)
+}
+
+// cursor returns the position of the last incremental sync, zero if the
+// vault was never synced.
+func (v *vaultCache) cursor() int64 {
+	if data := v.meta(metaCursor); len(data) == 8 {
+		return btoi(data)
+	}
+	return 0
+}
+
+// applyChanges merges changes received from the server and advances the
+// sync cursor. A sync from zero replaces the whole cache.
+func (v *vaultCache) applyChanges(changes *model.Changes) error {
+	var err error
+	if v.cursor() == 0 {
+		err = v.replaceSecrets(changes.Updated)
+	} else {
+		err = v.putSecrets(changes.Updated...)
+	}
+	if err != nil {
+		return err
+	}
+
+	err = v.db.Update(func(tx *bbolt.Tx) error {
+		changed, err := v.changedIDs(tx)
+		if err != nil {
+			return err
+		}
+
+		b := tx.Bucket(secretsBucket)
+		for _, id := range changes.Deleted {
+			if changed[id] {
+				continue
+			}
+			if err := b.Delete(itob(id)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return v.setMeta(metaCursor, itob(changes.Cursor))
+}
+
+func (v *vaultCache) secret(id int64) (*model.Secret, bool, error) {
+	var data []byte
+	v.db.View(func(tx *bbolt.Tx) error {
+		if value := tx.Bucket(secretsBucket).Get(itob(id)); value != nil {
+			data = append([]byte(nil), value...)
+		}
+		return nil
+	})
+	if data == nil {
+		return nil, false, nil
+	}
+
+	var secret model.Secret
+	if err := v.open(data, &secret); err != nil {
+		return nil, false, err
+	}
+
+	return &secret, true, nil
+}
+
+// secrets lists the cached secrets matching filter. Folders are not cached,
+// so FolderID only matches secrets directly in the folder.
+func (v *vaultCache) secrets(filter model.SecretFilter) ([]model.Secret, error) {
+	list := make([]model.Secret, 0)
+	err := v.db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket(secretsBucket).ForEach(func(_, data []byte) error {
+			var secret model.Secret
+			if err := v.open(data, &secret); err != nil {
+				return err
+			}
+			if matchSecret(&secret, filter) {
+				list = append(list, secret)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+
+	return list, nil
+}
+
+func matchSecret(secret *model.Secret, filter model.SecretFilter) bool {
+	switch {
+	case filter.Type != 0 && secret.Type != filter.Type,
+		filter.FolderID != 0 && secret.FolderID != filter.FolderID,
+		filter.TagID != 0 && !slices.Contains(secret.Tags, filter.TagID),
+		filter.Favorite && !secret.Favorite:
+		return false
+	}
+	return true
+}
+
+// storeLocal writes a secret changed offline to the cache, bypassing the
+// queued-changes check of putSecrets.
+func (v *vaultCache) storeLocal(secret *model.Secret) error {
+	data, err := v.seal(secret)
+	if err != nil {
+		return err
+	}
+
+	return v.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(secretsBucket).Put(itob(secret.ID), data)
+	})
+}
+
+func (v *vaultCache) deleteSecret(id int64) error {
+	return v.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(secretsBucket).Delete(itob(id))
+	})
+}
+
+// pinnedKey returns the fingerprint of the public key pinned for login.
+func (v *vaultCache) pinnedKey(login string) (string, bool, error) {
+	var data []byte
+	v.db.View(func(tx *bbolt.Tx) error {
+		if value := tx.Bucket(keysBucket).Get([]byte(login)); value != nil {
+			data = append([]byte(nil), value...)
+		}
+		return nil
+	})
+	if data == nil {
+		return "", false, nil
+	}
+
+	var fingerprint string
+	if err := v.open(data, &fingerprint); err != nil {
+		return "", false, err
+	}
+	return fingerprint, true, nil
+}
+
+// pinKey pins the fingerprint of the public key of login, replacing the
+// one pinned before.
+func (v *vaultCache) pinKey(login, fingerprint string) error {
+	data, err := v.seal(fingerprint)
+	if err != nil {
+		return err
+	}
+
+	return v.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(keysBucket).Put([]byte(login), data)
+	})
+}
+
+// nextLocalID allocates a negative ID for a secret created offline.
+func (v *vaultCache) nextLocalID() (int64, error) {
+	var id int64
+	err := v.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(metaBucket)
+		id = -1
+		if data := b.Get(metaLocalID); data != nil {
+			id = btoi(data) - 1
+		}
+		return b.Put(metaLocalID, itob(id))
+	})
+	return id, err
+}
+
+// enqueue queues an offline change. A change to a secret that already has
+// a queued one is folded into it, keeping the revision the first change was
+// based on; deleting a secret that only exists locally drops its create.
+// A secret deleted offline can't be changed any more, an update of it fails
+// with storage.ErrSecretNotFound.
+func (v *vaultCache) enqueue(op *pendingOp) error {
+	return v.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(pendingBucket)
+
+		ops, err := v.pendingOps(tx)
+		if err != nil {
+			return err
+		}
+		for _, queued := range ops {
+			if queued.ID != op.ID {
+				continue
+			}
+
+			key := itob(int64(queued.Seq))
+			switch {
+			case queued.Op == opDelete:
+				return storage.ErrSecretNotFound
+			case op.Op == opDelete && queued.Op == opCreate:
+				return b.Delete(key)
+			case op.Op == opDelete:
+				queued.Op = opDelete
+			default:
+				revision := queued.Secret.Revision
+				queued.Secret = op.Secret
+				queued.Secret.Revision = revision
+			}
+
+			data, err := v.seal(&queued)
+			if err != nil {
+				return err
+			}
+			return b.Put(key, data)
+		}
+
+		seq, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+		op.Seq = seq
+
+		data, err := v.seal(op)
+		if err != nil {
+			return err
+		}
+		return b.Put(itob(int64(seq)), data)
+	})
+}
+
+func (v *vaultCache) pending() ([]pendingOp, error) {
+	var ops []pendingOp
+	err := v.db.View(func(tx *bbolt.Tx) error {
+		var err error
+		ops, err = v.pendingOps(tx)
+		return err
+	})
+	return ops, err
+}
+
+func (v *vaultCache) pendingOps(tx *bbolt.Tx) ([]pendingOp, error) {
+	var ops []pendingOp
+	err := tx.Bucket(pendingBucket).ForEach(func(_, data []byte) error {
+		var op pendingOp
+		if err := v.open(data, &op); err != nil {
+			return err
+		}
+		ops = append(ops, op)
+		return nil
+	})
+	return ops, err
+}
+
+func (v *vaultCache) changedIDs(tx *bbolt.Tx) (map[int64]bool, error) {
+	ops, err := v.pendingOps(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make(map[int64]bool, len(ops))
+	for _, op := range ops {
+		ids[op.ID] = true
+	}
+	return ids, nil
+}
+
+func (v *vaultCache) dequeue(seq uint64) error {
+	return v.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(pendingBucket).Delete(itob(int64(seq)))
+	})
+}
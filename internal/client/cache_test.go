@@ -0,0 +1,140 @@
+package client
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/nbvehbq/go-password-keeper/internal/model"
+	"github.com/nbvehbq/go-password-keeper/internal/storage"
+)
+
+func newTestCache(t *testing.T) *vaultCache {
+	t.Helper()
+
+	cache, err := openCache(t.TempDir(), "alice")
+	if err != nil {
+		t.Fatalf("open cache: %v", err)
+	}
+	cache.unlock(newTestKey(t))
+	t.Cleanup(func() { cache.Close() })
+
+	return cache
+}
+
+func TestCachePath(t *testing.T) {
+	dir := t.TempDir()
+
+	for _, login := range []string{"alice", "../alice", "/etc/passwd", `..\..\alice`, "a/b"} {
+		path := cachePath(dir, login)
+		if filepath.Dir(path) != dir {
+			t.Errorf("login %q: cache at %s, outside of %s", login, path, dir)
+		}
+	}
+
+	if cachePath(dir, "alice") == cachePath(dir, "Alice") {
+		t.Error("different logins share a cache")
+	}
+}
+
+func TestEnqueueCoalescing(t *testing.T) {
+	secret := func(id, revision int64, payload string) *model.Secret {
+		return &model.Secret{ID: id, Name: "mail", Payload: []byte(payload), Revision: revision}
+	}
+
+	tests := []struct {
+		name    string
+		ops     []pendingOp
+		wantErr error
+		want    []pendingOp
+	}{
+		{
+			name: "update of a secret created offline",
+			ops: []pendingOp{
+				{Op: opCreate, ID: -1, Secret: secret(-1, 0, "a")},
+				{Op: opUpdate, ID: -1, Secret: secret(-1, 0, "b")},
+			},
+			want: []pendingOp{{Op: opCreate, ID: -1, Secret: secret(-1, 0, "b")}},
+		},
+		{
+			name: "updates keep the first revision",
+			ops: []pendingOp{
+				{Op: opUpdate, ID: 7, Secret: secret(7, 3, "a")},
+				{Op: opUpdate, ID: 7, Secret: secret(7, 4, "b")},
+			},
+			want: []pendingOp{{Op: opUpdate, ID: 7, Secret: secret(7, 3, "b")}},
+		},
+		{
+			name: "delete after an update",
+			ops: []pendingOp{
+				{Op: opUpdate, ID: 7, Secret: secret(7, 3, "a")},
+				{Op: opDelete, ID: 7, Secret: secret(7, 4, "")},
+			},
+			want: []pendingOp{{Op: opDelete, ID: 7, Secret: secret(7, 3, "a")}},
+		},
+		{
+			name: "delete of a secret created offline",
+			ops: []pendingOp{
+				{Op: opCreate, ID: -1, Secret: secret(-1, 0, "a")},
+				{Op: opDelete, ID: -1, Secret: secret(-1, 0, "")},
+			},
+		},
+		{
+			name: "update after a delete",
+			ops: []pendingOp{
+				{Op: opDelete, ID: 7, Secret: secret(7, 3, "")},
+				{Op: opUpdate, ID: 7, Secret: secret(7, 3, "b")},
+			},
+			wantErr: storage.ErrSecretNotFound,
+			want:    []pendingOp{{Op: opDelete, ID: 7, Secret: secret(7, 3, "")}},
+		},
+		{
+			name: "changes of different secrets",
+			ops: []pendingOp{
+				{Op: opUpdate, ID: 7, Secret: secret(7, 3, "a")},
+				{Op: opCreate, ID: -1, Secret: secret(-1, 0, "b")},
+				{Op: opDelete, ID: 8, Secret: secret(8, 1, "")},
+			},
+			want: []pendingOp{
+				{Op: opUpdate, ID: 7, Secret: secret(7, 3, "a")},
+				{Op: opCreate, ID: -1, Secret: secret(-1, 0, "b")},
+				{Op: opDelete, ID: 8, Secret: secret(8, 1, "")},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache := newTestCache(t)
+
+			var err error
+			for i := range tt.ops {
+				op := tt.ops[i]
+				if err = cache.enqueue(&op); err != nil {
+					break
+				}
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("enqueue: got %v, want %v", err, tt.wantErr)
+			}
+
+			got, err := cache.pending()
+			if err != nil {
+				t.Fatalf("pending: %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("%d queued changes, want %d", len(got), len(tt.want))
+			}
+			for i, want := range tt.want {
+				op := got[i]
+				if op.Op != want.Op || op.ID != want.ID {
+					t.Errorf("change %d: %s of %d, want %s of %d", i, op.Op, op.ID, want.Op, want.ID)
+				}
+				if op.Secret.Revision != want.Secret.Revision || string(op.Secret.Payload) != string(want.Secret.Payload) {
+					t.Errorf("change %d: revision %d, payload %q, want %d, %q", i,
+						op.Secret.Revision, op.Secret.Payload, want.Secret.Revision, want.Secret.Payload)
+				}
+			}
+		})
+	}
+}
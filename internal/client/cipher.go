@@ -2,15 +2,69 @@ package client
 
 import (
 	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/sha256"
 	"crypto/x509"
 	"encoding/pem"
 	"fmt"
+	"io"
+
+	"golang.org/x/crypto/hkdf"
 )
 
-const blockLength = 128
+// Envelope format (version 1).
+//
+// Every secret gets a random 256-bit data key. Payload and meta are sealed
+// with it using AES-256-GCM:
+//
+//	"PKE" | version | alg | keyID[8] | nonce[12] | ciphertext+tag
+//
+// The data key itself is wrapped by the user's RSA key and stored next to
+// the secret in model.Secret.Key:
+//
+//	"PKW" | version | wrapAlg | keyID[8] | RSA-OAEP(dataKey)
+//
+// keyID is the fingerprint of the user's public key. The header is used as
+// additional authenticated data, so it cannot be altered without detection.
+// Sharing a secret wraps the same data key again with the recipient's
+// public key; keyID in the envelope names whoever sealed it last.
+// Secrets without a wrapped key were written by the legacy scheme and are
+// decrypted by decryptLegacy.
+//
+// Payload and meta of a secret are sealed in version 2 envelopes bound to
+// the secret and the field: each field has its own key derived from the
+// data key by HKDF-SHA256 with the field name as info, and the UUID of the
+// secret follows the header in the additional data. The client picks the
+// UUID before the secret is created, so a secret is sealed once. A
+// ciphertext moved to another field or secret fails to open. Secrets
+// without a UUID were sealed in version 1 envelopes, openField accepts
+// those for them only.
+const (
+	envelopeVersion byte = 1
+	boundVersion    byte = 2
+
+	algAES256GCM      byte = 1
+	wrapRSAOAEPSHA256 byte = 1
+
+	fieldPayload = "payload"
+	fieldMeta    = "meta"
+
+	dataKeySize = 32
+	keyIDSize   = 8
+	headerSize  = 3 + 1 + 1 + keyIDSize
+)
+
+var (
+	dataMagic = []byte("PKE")
+	keyMagic  = []byte("PKW")
+
+	errMalformedEnvelope = fmt.Errorf("malformed envelope")
+	errUnsupportedFormat = fmt.Errorf("unsupported envelope format")
+	errUnknownKey        = fmt.Errorf("envelope sealed by unknown key")
+)
 
 func setupKeyPair() ([]byte, error) {
 	privateKey, err := rsa.GenerateKey(rand.Reader, 4096)
@@ -27,36 +81,219 @@ func setupKeyPair() ([]byte, error) {
 	return privateKeyPEM.Bytes(), nil
 }
 
-func encrypt(key, data []byte) ([]byte, error) {
-	privateKeyBlock, _ := pem.Decode(key)
-	privateKey, err := x509.ParsePKCS1PrivateKey(privateKeyBlock.Bytes)
+func parsePrivateKey(key []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(key)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data found")
+	}
+
+	return x509.ParsePKCS1PrivateKey(block.Bytes)
+}
+
+// keyID returns the fingerprint of a public key used in envelope headers.
+func keyID(pub *rsa.PublicKey) [keyIDSize]byte {
+	var id [keyIDSize]byte
+	sum := sha256.Sum256(x509.MarshalPKCS1PublicKey(pub))
+	copy(id[:], sum[:])
+	return id
+}
+
+func header(magic []byte, alg byte, kid [keyIDSize]byte) []byte {
+	return versionHeader(magic, envelopeVersion, alg, kid)
+}
+
+func versionHeader(magic []byte, version, alg byte, kid [keyIDSize]byte) []byte {
+	h := make([]byte, 0, headerSize)
+	h = append(h, magic...)
+	h = append(h, version, alg)
+	return append(h, kid[:]...)
+}
+
+func parseHeader(magic, data []byte) (alg byte, kid [keyIDSize]byte, err error) {
+	return parseVersionHeader(magic, envelopeVersion, data)
+}
+
+func parseVersionHeader(magic []byte, version byte, data []byte) (alg byte, kid [keyIDSize]byte, err error) {
+	if len(data) < headerSize || !bytes.Equal(data[:len(magic)], magic) {
+		return 0, kid, errMalformedEnvelope
+	}
+	if data[len(magic)] != version {
+		return 0, kid, errUnsupportedFormat
+	}
+	copy(kid[:], data[len(magic)+2:headerSize])
+	return data[len(magic)+1], kid, nil
+}
+
+func newDataKey() ([]byte, error) {
+	key := make([]byte, dataKeySize)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// wrapKey encrypts a data key for the owner of pub.
+func wrapKey(pub *rsa.PublicKey, dataKey []byte) ([]byte, error) {
+	h := header(keyMagic, wrapRSAOAEPSHA256, keyID(pub))
+	wrapped, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, pub, dataKey, h)
+	if err != nil {
+		return nil, fmt.Errorf("failed to wrap data key: %v", err)
+	}
+	return append(h, wrapped...), nil
+}
+
+// unwrapKey recovers a data key wrapped by wrapKey.
+func unwrapKey(priv *rsa.PrivateKey, data []byte) ([]byte, error) {
+	alg, kid, err := parseHeader(keyMagic, data)
 	if err != nil {
 		return nil, err
 	}
+	if alg != wrapRSAOAEPSHA256 {
+		return nil, errUnsupportedFormat
+	}
+	if kid != keyID(&priv.PublicKey) {
+		return nil, errUnknownKey
+	}
 
-	encryptedData := make([]byte, 0, len(data))
-	var nextBlockLength int
-	for i := 0; i < len(data); i += blockLength {
-		nextBlockLength = i + blockLength
-		if nextBlockLength > len(data) {
-			nextBlockLength = len(data)
-		}
-		block, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, &privateKey.PublicKey, data[i:nextBlockLength], []byte("yandex"))
-		if err != nil {
-			return nil, fmt.Errorf("failed to encrypt data '%s': %v", data, err)
-		}
-		encryptedData = append(encryptedData, block...)
+	dataKey, err := rsa.DecryptOAEP(sha256.New(), rand.Reader, priv, data[headerSize:], data[:headerSize])
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap data key: %v", err)
+	}
+	return dataKey, nil
+}
+
+// seal encrypts plaintext with a data key into a versioned envelope.
+func seal(dataKey []byte, kid [keyIDSize]byte, plaintext []byte) ([]byte, error) {
+	h := header(dataMagic, algAES256GCM, kid)
+	return sealEnvelope(dataKey, h, h, plaintext)
+}
+
+// open decrypts an envelope produced by seal.
+func open(dataKey, data []byte) ([]byte, error) {
+	alg, _, err := parseHeader(dataMagic, data)
+	if err != nil {
+		return nil, err
+	}
+	if alg != algAES256GCM {
+		return nil, errUnsupportedFormat
+	}
+
+	return openEnvelope(dataKey, data, data[:headerSize])
+}
+
+// sealField encrypts a field of the secret secretUUID with a key derived
+// from its data key into an envelope bound to both.
+func sealField(dataKey []byte, kid [keyIDSize]byte, secretUUID, field string, plaintext []byte) ([]byte, error) {
+	key, err := fieldKey(dataKey, field)
+	if err != nil {
+		return nil, err
+	}
+
+	h := versionHeader(dataMagic, boundVersion, algAES256GCM, kid)
+	return sealEnvelope(key, h, fieldAAD(h, secretUUID), plaintext)
+}
+
+// openField decrypts an envelope produced by sealField for the same secret
+// and field. Secrets sealed before envelopes were bound have no UUID and
+// their fields are opened as produced by seal.
+func openField(dataKey []byte, secretUUID, field string, data []byte) ([]byte, error) {
+	if len(data) == 0 {
+		return nil, errMalformedEnvelope
+	}
+	if secretUUID == "" {
+		return open(dataKey, data)
+	}
+
+	alg, _, err := parseVersionHeader(dataMagic, boundVersion, data)
+	if err != nil {
+		return nil, err
+	}
+	if alg != algAES256GCM {
+		return nil, errUnsupportedFormat
+	}
+
+	key, err := fieldKey(dataKey, field)
+	if err != nil {
+		return nil, err
+	}
+
+	return openEnvelope(key, data, fieldAAD(data[:headerSize], secretUUID))
+}
+
+func fieldKey(dataKey []byte, field string) ([]byte, error) {
+	key := make([]byte, dataKeySize)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, dataKey, nil, []byte("keeper "+field)), key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func fieldAAD(h []byte, secretUUID string) []byte {
+	aad := make([]byte, 0, len(h)+len(secretUUID))
+	aad = append(aad, h...)
+	return append(aad, secretUUID...)
+}
+
+// newSecretUUID returns a random (version 4) UUID for a new secret.
+func newSecretUUID() (string, error) {
+	var b [16]byte
+	if _, err := io.ReadFull(rand.Reader, b[:]); err != nil {
+		return "", err
 	}
-	return encryptedData, nil
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
 }
 
-func decrypt(key, data []byte) ([]byte, error) {
-	privateKeyBlock, _ := pem.Decode(key)
-	privateKey, err := x509.ParsePKCS1PrivateKey(privateKeyBlock.Bytes)
+// sealEnvelope encrypts plaintext into h | nonce | ciphertext+tag.
+func sealEnvelope(key, h, aad, plaintext []byte) ([]byte, error) {
+	aead, err := newGCM(key)
 	if err != nil {
 		return nil, err
 	}
 
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, 0, len(h)+len(nonce)+len(plaintext)+aead.Overhead())
+	out = append(out, h...)
+	out = append(out, nonce...)
+	return aead.Seal(out, nonce, plaintext, aad), nil
+}
+
+// openEnvelope decrypts an envelope with a parsed header.
+func openEnvelope(key, data, aad []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	body := data[headerSize:]
+	if len(body) < aead.NonceSize()+aead.Overhead() {
+		return nil, errMalformedEnvelope
+	}
+
+	plaintext, err := aead.Open(nil, body[:aead.NonceSize()], body[aead.NonceSize():], aad)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt data: %v", err)
+	}
+	return plaintext, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// decryptLegacy decrypts data written before envelopes were introduced:
+// the plaintext was split in 128-byte blocks, each RSA-OAEP encrypted.
+func decryptLegacy(privateKey *rsa.PrivateKey, data []byte) ([]byte, error) {
 	decryptedData := make([]byte, 0, len(data))
 	var nextBlockLength int
 	for i := 0; i < len(data); i += privateKey.PublicKey.Size() {
@@ -0,0 +1,270 @@
+package client
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"errors"
+	"testing"
+
+	"github.com/nbvehbq/go-password-keeper/internal/model"
+)
+
+const testUUID = "6f1c8a52-7d0e-4b8e-9a4f-2c3d5e6f7a8b"
+
+func newTestPrivateKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return priv
+}
+
+func newTestKey(t *testing.T) []byte {
+	t.Helper()
+
+	key := make([]byte, dataKeySize)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+// encryptLegacy encrypts data the way secrets were written before
+// envelopes were introduced.
+func encryptLegacy(t *testing.T, pub *rsa.PublicKey, data []byte) []byte {
+	t.Helper()
+
+	var out []byte
+	for i := 0; i < len(data); i += 128 {
+		end := min(i+128, len(data))
+		block, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, pub, data[i:end], []byte("yandex"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		out = append(out, block...)
+	}
+	return out
+}
+
+func TestKeyWrapRoundTrip(t *testing.T) {
+	priv := newTestPrivateKey(t)
+	dataKey := newTestKey(t)
+
+	wrapped, err := wrapKey(&priv.PublicKey, dataKey)
+	if err != nil {
+		t.Fatalf("wrap: %v", err)
+	}
+	got, err := unwrapKey(priv, wrapped)
+	if err != nil {
+		t.Fatalf("unwrap: %v", err)
+	}
+	if !bytes.Equal(got, dataKey) {
+		t.Error("unwrapped key differs")
+	}
+
+	if _, err := unwrapKey(newTestPrivateKey(t), wrapped); !errors.Is(err, errUnknownKey) {
+		t.Errorf("other key: got %v, want %v", err, errUnknownKey)
+	}
+
+	tampered := bytes.Clone(wrapped)
+	tampered[len(tampered)-1] ^= 1
+	if _, err := unwrapKey(priv, tampered); err == nil {
+		t.Error("tampered key unwrapped")
+	}
+}
+
+func TestFieldRoundTrip(t *testing.T) {
+	dataKey := newTestKey(t)
+	kid := keyID(&newTestPrivateKey(t).PublicKey)
+
+	for _, plaintext := range [][]byte{nil, []byte("p"), bytes.Repeat([]byte("secret"), 1000)} {
+		data, err := sealField(dataKey, kid, testUUID, fieldPayload, plaintext)
+		if err != nil {
+			t.Fatalf("seal: %v", err)
+		}
+		if data[len(dataMagic)] != boundVersion {
+			t.Errorf("envelope version %d, want %d", data[len(dataMagic)], boundVersion)
+		}
+
+		got, err := openField(dataKey, testUUID, fieldPayload, data)
+		if err != nil {
+			t.Fatalf("open: %v", err)
+		}
+		if !bytes.Equal(got, plaintext) {
+			t.Errorf("got %q, want %q", got, plaintext)
+		}
+	}
+}
+
+func TestFieldTamper(t *testing.T) {
+	dataKey := newTestKey(t)
+	kid := keyID(&newTestPrivateKey(t).PublicKey)
+
+	data, err := sealField(dataKey, kid, testUUID, fieldPayload, []byte("secret"))
+	if err != nil {
+		t.Fatalf("seal: %v", err)
+	}
+
+	flip := func(i int) []byte {
+		tampered := bytes.Clone(data)
+		tampered[i] ^= 1
+		return tampered
+	}
+
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr error
+	}{
+		{name: "empty", data: nil, wantErr: errMalformedEnvelope},
+		{name: "truncated header", data: data[:headerSize-1], wantErr: errMalformedEnvelope},
+		{name: "truncated body", data: data[:headerSize+4], wantErr: errMalformedEnvelope},
+		{name: "magic", data: flip(0), wantErr: errMalformedEnvelope},
+		{name: "version", data: flip(len(dataMagic)), wantErr: errUnsupportedFormat},
+		{name: "algorithm", data: flip(len(dataMagic) + 1), wantErr: errUnsupportedFormat},
+		// key ID is only authenticated
+		{name: "key id", data: flip(headerSize - 1)},
+		{name: "nonce", data: flip(headerSize)},
+		{name: "ciphertext", data: flip(len(data) - 17)},
+		{name: "tag", data: flip(len(data) - 1)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := openField(dataKey, testUUID, fieldPayload, tt.data)
+			if err == nil {
+				t.Fatal("tampered envelope opened")
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("got %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// A version 2 envelope opens only for the field and secret it was sealed
+// for, and with its own data key.
+func TestFieldBinding(t *testing.T) {
+	dataKey := newTestKey(t)
+	kid := keyID(&newTestPrivateKey(t).PublicKey)
+
+	data, err := sealField(dataKey, kid, testUUID, fieldPayload, []byte("secret"))
+	if err != nil {
+		t.Fatalf("seal: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		key     []byte
+		uuid    string
+		field   string
+		wantErr error
+	}{
+		{name: "other field", key: dataKey, uuid: testUUID, field: fieldMeta},
+		{name: "other secret", key: dataKey, uuid: "0b9e2f4c-1a3d-4e5f-8a7b-9c0d1e2f3a4b", field: fieldPayload},
+		{name: "other data key", key: newTestKey(t), uuid: testUUID, field: fieldPayload},
+		// a secret without a UUID takes version 1 envelopes only
+		{name: "no uuid", key: dataKey, field: fieldPayload, wantErr: errUnsupportedFormat},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := openField(tt.key, tt.uuid, tt.field, data)
+			if err == nil {
+				t.Fatal("envelope opened")
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("got %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// Version 1 envelopes are still read for secrets that haven't been
+// migrated, and rejected for secrets that have a UUID.
+func TestFieldVersion1(t *testing.T) {
+	dataKey := newTestKey(t)
+	kid := keyID(&newTestPrivateKey(t).PublicKey)
+
+	data, err := seal(dataKey, kid, []byte("secret"))
+	if err != nil {
+		t.Fatalf("seal: %v", err)
+	}
+
+	got, err := openField(dataKey, "", fieldPayload, data)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if string(got) != "secret" {
+		t.Errorf("got %q, want %q", got, "secret")
+	}
+
+	if _, err := openField(dataKey, testUUID, fieldPayload, data); !errors.Is(err, errUnsupportedFormat) {
+		t.Errorf("secret with a UUID: got %v, want %v", err, errUnsupportedFormat)
+	}
+}
+
+func TestOpenSecret(t *testing.T) {
+	priv := newTestPrivateKey(t)
+	c := &Client{privateKey: priv}
+
+	secret := &model.Secret{Name: "mail", Payload: []byte("p"), Meta: []byte("m")}
+	if err := c.sealSecret(secret); err != nil {
+		t.Fatalf("seal: %v", err)
+	}
+	if secret.UUID == "" || len(secret.Key) == 0 {
+		t.Fatalf("sealed secret: UUID %q, %d bytes of key", secret.UUID, len(secret.Key))
+	}
+
+	swapped := *secret
+	swapped.Payload, swapped.Meta = secret.Meta, secret.Payload
+	if err := c.openSecret(&swapped); err == nil {
+		t.Error("swapped payload and meta opened")
+	}
+
+	moved := *secret
+	moved.UUID = "0b9e2f4c-1a3d-4e5f-8a7b-9c0d1e2f3a4b"
+	if err := c.openSecret(&moved); err == nil {
+		t.Error("fields of another secret opened")
+	}
+
+	if err := openSecretWith(newTestPrivateKey(t), secret); !errors.Is(err, errUnknownKey) {
+		t.Errorf("other key: got %v, want %v", err, errUnknownKey)
+	}
+
+	if err := c.openSecret(secret); err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if string(secret.Payload) != "p" || string(secret.Meta) != "m" {
+		t.Errorf("got payload %q, meta %q", secret.Payload, secret.Meta)
+	}
+}
+
+func TestDecryptLegacy(t *testing.T) {
+	priv := newTestPrivateKey(t)
+
+	// spans several RSA blocks
+	payload := bytes.Repeat([]byte("legacy "), 50)
+	secret := &model.Secret{
+		Payload: encryptLegacy(t, &priv.PublicKey, payload),
+		Meta:    encryptLegacy(t, &priv.PublicKey, []byte("m")),
+	}
+	if !needsMigration(secret) {
+		t.Error("legacy secret doesn't need migration")
+	}
+
+	if err := openSecretWith(priv, secret); err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if !bytes.Equal(secret.Payload, payload) || string(secret.Meta) != "m" {
+		t.Errorf("got payload %q, meta %q", secret.Payload, secret.Meta)
+	}
+
+	if _, err := decryptLegacy(newTestPrivateKey(t), encryptLegacy(t, &priv.PublicKey, payload)); err == nil {
+		t.Error("decrypted with another key")
+	}
+}
@@ -2,13 +2,15 @@ package client
 
 import (
 	"context"
+	"crypto/rsa"
+	"crypto/x509"
+	"errors"
 	"fmt"
-	"net/http"
 	"os"
 
-	"github.com/go-resty/resty/v2"
 	"github.com/nbvehbq/go-password-keeper/internal/logger"
 	"github.com/nbvehbq/go-password-keeper/internal/model"
+	"github.com/nbvehbq/go-password-keeper/internal/storage"
 	"go.uber.org/zap"
 )
 
@@ -20,55 +22,99 @@ var (
 	ErrLoadKeyFile  = fmt.Errorf("failed to load key file")
 	ErrEncrypt      = fmt.Errorf("failed to encrypt data")
 	ErrDecrypt      = fmt.Errorf("failed to decrypt data")
+	ErrVaultKey     = fmt.Errorf("failed to unlock vault key")
+
+	ErrSessionNotFound     = fmt.Errorf("session not found")
+	ErrConflict            = fmt.Errorf("secret was changed by someone else")
+	ErrOffline             = fmt.Errorf("server is unreachable")
+	ErrNoCache             = fmt.Errorf("local vault is not available")
+	ErrTooLarge            = fmt.Errorf("file is too large")
+	ErrPinMismatch         = fmt.Errorf("server public key does not match the pin")
+	ErrPendingChanges      = fmt.Errorf("secret has changes not synced yet")
+	ErrRecipientNotFound   = fmt.Errorf("user not found or has no public key")
+	ErrRecipientKeyChanged = fmt.Errorf("public key of the user differs from the one trusted before")
+	ErrInvalidLink         = fmt.Errorf("invalid one-time link")
+	ErrLinkFile            = fmt.Errorf("files can't be shared by link")
+
+	ErrTwoFactorRequired = fmt.Errorf("two-factor code required")
+	ErrInvalidCode       = fmt.Errorf("invalid or expired code")
+	ErrTwoFactorState    = fmt.Errorf("two-factor authentication is not in the expected state")
 )
 
+// ConflictError is returned when a secret was modified since it was read.
+// Revision is the current revision on the server.
+type ConflictError struct {
+	Revision int64
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("%s: current revision is %d", ErrConflict, e.Revision)
+}
+
+func (e *ConflictError) Is(target error) bool {
+	return target == ErrConflict
+}
+
 type Client struct {
-	client     *resty.Client
+	transport  transport
 	cfg        *Config
-	privateKey []byte
+	privateKey *rsa.PrivateKey
+
+	// cache is the local copy of the vault of the logged in user, nil when
+	// it could not be opened. offline is set while the server is unreachable.
+	cache   *vaultCache
+	offline bool
+
+	// pending is a login waiting for its second factor.
+	pending *pendingLogin
 }
 
 func NewClient(ctx context.Context, config *Config) (*Client, error) {
-	client := resty.New()
-	// client.SetDebug(true)
-	client.SetHeader("Accept", "application/json")
+	tlsConf, err := config.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	var t transport
+	switch config.Transport {
+	case TransportGRPC:
+		if t, err = newGRPCTransport(config.GRPCAddress, tlsConf); err != nil {
+			return nil, err
+		}
+	default:
+		t = newRESTTransport(config.Address, tlsConf)
+	}
 
 	return &Client{
-		client: client,
-		cfg:    config,
+		transport: t,
+		cfg:       config,
 	}, nil
 }
 
-func (c *Client) Register(ctx context.Context, login, password string) error {
-	var payload struct {
-		SID string `json:"sid"`
+// Close releases the local vault cache.
+func (c *Client) Close() error {
+	if c.cache == nil {
+		return nil
 	}
-	res, err := c.client.R().
-		SetContext(ctx).
-		SetBody(map[string]string{"login": login, "password": password}).
-		Post(fmt.Sprintf("%s/api/user/register", c.cfg.Address))
 
+	err := c.cache.Close()
+	c.cache = nil
+	return err
+}
+
+func (c *Client) Register(ctx context.Context, login, password string) error {
+	salt, err := newSalt()
 	if err != nil {
-		logger.Log.Error("failed to register", zap.Error(err))
-		return ErrInternal
+		logger.Log.Error("failed to generate salt", zap.Error(err))
+		return ErrGenerateKey
 	}
 
-	if res.StatusCode() == 409 {
-		return ErrUserExists
+	keys, err := deriveMasterKeys(password, salt)
+	if err != nil {
+		logger.Log.Error("failed to derive master key", zap.Error(err))
+		return ErrGenerateKey
 	}
 
-	// Set credentials
-	c.client.SetHeader("Authorization", payload.SID)
-	c.client.SetCookie(&http.Cookie{
-		Name:     "session",
-		Value:    payload.SID,
-		Path:     "/",
-		MaxAge:   3600,
-		HttpOnly: true,
-		Secure:   true,
-		SameSite: http.SameSiteLaxMode,
-	})
-
 	// Generate sertificate
 	cert, err := setupKeyPair()
 	if err != nil {
@@ -76,207 +122,623 @@ func (c *Client) Register(ctx context.Context, login, password string) error {
 		return ErrGenerateKey
 	}
 
-	c.privateKey = cert
-
-	// check directory & create if not exists
-	if _, err := os.Stat(c.cfg.KeyPath); os.IsNotExist(err) {
-		if err := os.Mkdir(c.cfg.KeyPath, 0777); err != nil {
-			logger.Log.Error("failed to create directory", zap.Error(err))
-			return ErrGenerateKey
-		}
+	privateKey, err := parsePrivateKey(cert)
+	if err != nil {
+		logger.Log.Error("failed to parse key", zap.Error(err))
+		return ErrGenerateKey
 	}
 
-	f, err := os.OpenFile(fmt.Sprintf("%s%s-cert.pem", c.cfg.KeyPath, login), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
+	vaultKey, err := keys.sealVaultKey(cert)
 	if err != nil {
-		logger.Log.Error("failed to create key file", zap.Error(err))
+		logger.Log.Error("failed to encrypt key", zap.Error(err))
 		return ErrGenerateKey
 	}
-	defer f.Close()
 
-	// write cert
-	if _, err := f.Write(cert); err != nil {
-		logger.Log.Error("failed to write key file", zap.Error(err))
-		return ErrGenerateKey
+	sid, err := c.transport.register(ctx, &model.RegisterDTO{
+		Login:     login,
+		Password:  keys.authPassword(),
+		Salt:      salt,
+		VaultKey:  vaultKey,
+		PublicKey: x509.MarshalPKCS1PublicKey(&privateKey.PublicKey),
+	})
+	if err != nil {
+		return err
 	}
 
+	c.setCredentials(sid)
+	c.privateKey = privateKey
+	c.enableCache(login, keys, salt, vaultKey)
+
 	return nil
 }
 
 func (c *Client) Login(ctx context.Context, login, password string) error {
-	var payload struct {
-		SID string `json:"sid"`
+	c.pending = nil
+
+	salt, err := c.transport.prelogin(ctx, login)
+	if errors.Is(err, ErrOffline) {
+		return c.loginOffline(login, password)
+	}
+	if err != nil {
+		return err
 	}
-	res, err := c.client.R().
-		SetContext(ctx).
-		SetResult(&payload).
-		SetBody(map[string]string{"login": login, "password": password}).
-		Post(fmt.Sprintf("%s/api/user/login", c.cfg.Address))
 
+	// accounts registered before key derivation keep their key on disk
+	if len(salt) == 0 {
+		return c.loginLegacy(ctx, login, password)
+	}
+
+	keys, err := deriveMasterKeys(password, salt)
 	if err != nil {
-		logger.Log.Error("failed to login", zap.Error(err))
+		logger.Log.Error("failed to derive master key", zap.Error(err))
 		return ErrInternal
 	}
 
-	if res.StatusCode() == 401 {
+	vaultKey, err := c.login(ctx, login, keys.authPassword())
+	if err != nil {
+		return c.secondFactor(err, func(_ context.Context, vaultKey []byte) error {
+			return c.unlockVault(login, keys, salt, vaultKey)
+		})
+	}
+
+	return c.unlockVault(login, keys, salt, vaultKey)
+}
+
+// unlockVault decrypts the vault key received on login and opens the local
+// cache.
+func (c *Client) unlockVault(login string, keys *masterKeys, salt, vaultKey []byte) error {
+	cert, err := keys.openVaultKey(vaultKey)
+	if err != nil {
+		logger.Log.Error("failed to decrypt vault key", zap.Error(err))
+		return ErrVaultKey
+	}
+
+	c.privateKey, err = parsePrivateKey(cert)
+	if err != nil {
+		logger.Log.Error("failed to parse vault key", zap.Error(err))
+		return ErrVaultKey
+	}
+
+	c.offline = false
+	c.enableCache(login, keys, salt, vaultKey)
+
+	return nil
+}
+
+// loginOffline unlocks a previously cached vault when the server cannot be
+// reached. The master password is verified by decrypting the vault key.
+func (c *Client) loginOffline(login, password string) error {
+	if !cacheExists(c.cfg.KeyPath, login) {
+		return ErrOffline
+	}
+
+	cache, err := openCache(c.cfg.KeyPath, login)
+	if err != nil {
+		logger.Log.Error("failed to open cache", zap.Error(err))
+		return ErrOffline
+	}
+
+	keys, err := deriveMasterKeys(password, cache.meta(metaSalt))
+	if err != nil {
+		cache.Close()
+		logger.Log.Error("failed to derive master key", zap.Error(err))
+		return ErrInternal
+	}
+
+	cert, err := keys.openVaultKey(cache.meta(metaVaultKey))
+	if err != nil {
+		cache.Close()
 		return ErrUnauthorized
 	}
 
-	// Set credentials
-	c.client.SetHeader("Authorization", payload.SID)
-	c.client.SetCookie(&http.Cookie{
-		Name:     "session",
-		Value:    payload.SID,
-		Path:     "/",
-		MaxAge:   3600,
-		HttpOnly: true,
-		Secure:   true,
-		SameSite: http.SameSiteLaxMode,
-	})
+	privateKey, err := parsePrivateKey(cert)
+	if err != nil {
+		cache.Close()
+		logger.Log.Error("failed to parse vault key", zap.Error(err))
+		return ErrVaultKey
+	}
+
+	c.Close()
+	cache.unlock(keys.cacheKey)
+	c.cache = cache
+	c.privateKey = privateKey
+	c.offline = true
+
+	return nil
+}
+
+// enableCache opens the local vault of login and stores what is needed to
+// unlock it offline. The client keeps working without a cache on failure.
+func (c *Client) enableCache(login string, keys *masterKeys, salt, vaultKey []byte) {
+	c.Close()
+
+	cache, err := openCache(c.cfg.KeyPath, login)
+	if err != nil {
+		logger.Log.Warn("failed to open cache", zap.Error(err))
+		return
+	}
 
+	cache.unlock(keys.cacheKey)
+	if err := cache.setMeta(metaSalt, salt); err != nil {
+		logger.Log.Warn("failed to write cache", zap.Error(err))
+	}
+	if err := cache.setMeta(metaVaultKey, vaultKey); err != nil {
+		logger.Log.Warn("failed to write cache", zap.Error(err))
+	}
+
+	c.cache = cache
+}
+
+// login authenticates and returns the encrypted vault key.
+func (c *Client) login(ctx context.Context, login, password string) ([]byte, error) {
+	sid, vaultKey, err := c.transport.login(ctx, login, password)
+	if err != nil {
+		return nil, err
+	}
+
+	c.setCredentials(sid)
+
+	return vaultKey, nil
+}
+
+// loginLegacy logs in an account whose private key is stored in a PEM file
+// and migrates it to a password-derived vault key stored on the server.
+func (c *Client) loginLegacy(ctx context.Context, login, password string) error {
+	if _, err := c.login(ctx, login, password); err != nil {
+		return c.secondFactor(err, func(ctx context.Context, _ []byte) error {
+			return c.unlockLegacy(ctx, login, password)
+		})
+	}
+
+	return c.unlockLegacy(ctx, login, password)
+}
+
+// unlockLegacy loads the key file of a legacy account and migrates it.
+func (c *Client) unlockLegacy(ctx context.Context, login, password string) error {
 	// Load sertificate
-	cert, err := os.ReadFile(fmt.Sprintf("%s%s-cert.pem", c.cfg.KeyPath, login))
+	keyFile := fmt.Sprintf("%s%s-cert.pem", c.cfg.KeyPath, login)
+	cert, err := os.ReadFile(keyFile)
 	if err != nil {
 		logger.Log.Error("failed to read key file", zap.Error(err))
 		return ErrLoadKeyFile
 	}
 
-	c.privateKey = cert
+	c.privateKey, err = parsePrivateKey(cert)
+	if err != nil {
+		logger.Log.Error("failed to parse key file", zap.Error(err))
+		return ErrLoadKeyFile
+	}
+
+	if err := c.migrateKeys(ctx, login, password, cert); err != nil {
+		logger.Log.Warn("failed to migrate vault key", zap.Error(err))
+		return nil
+	}
+
+	if err := os.Remove(keyFile); err != nil {
+		logger.Log.Warn("failed to remove key file", zap.Error(err))
+	}
 
 	return nil
 }
 
-func (c *Client) ListSecrets(ctx context.Context, resourceType string) ([]model.Secret, error) {
-	var result []model.Secret
-	res, err := c.client.R().
-		SetContext(ctx).
-		SetQueryParam("type", resourceType).
-		SetResult(&result).
-		Get(fmt.Sprintf("%s/api/secret", c.cfg.Address))
+// migrateKeys uploads the vault key of a legacy account encrypted under a
+// password-derived key, and enables the local cache on success.
+func (c *Client) migrateKeys(ctx context.Context, login, password string, cert []byte) error {
+	salt, err := newSalt()
+	if err != nil {
+		return err
+	}
 
+	keys, err := deriveMasterKeys(password, salt)
 	if err != nil {
-		logger.Log.Error("failed to list secrets", zap.Error(err))
-		return nil, err
+		return err
 	}
 
-	if res.StatusCode() == 401 {
-		return nil, ErrUnauthorized
+	vaultKey, err := keys.sealVaultKey(cert)
+	if err != nil {
+		return err
 	}
 
-	return result, nil
+	err = c.transport.updateKeys(ctx, &keysDTO{
+		RegisterDTO: model.RegisterDTO{
+			Login:     login,
+			Password:  keys.authPassword(),
+			Salt:      salt,
+			VaultKey:  vaultKey,
+			PublicKey: x509.MarshalPKCS1PublicKey(&c.privateKey.PublicKey),
+		},
+		CurrentPassword: password,
+	})
+	if err != nil {
+		return err
+	}
+
+	c.enableCache(login, keys, salt, vaultKey)
+
+	return nil
 }
 
-func (c *Client) CreateSecret(ctx context.Context, data *model.Secret) (int64, error) {
-	var response struct {
-		ID int64 `json:"id"`
+func (c *Client) setCredentials(sid string) {
+	c.transport.setCredentials(sid)
+}
+
+func (c *Client) clearCredentials() {
+	c.transport.clearCredentials()
+	c.privateKey = nil
+	if err := c.Close(); err != nil {
+		logger.Log.Warn("failed to close cache", zap.Error(err))
 	}
+}
 
-	// encrypt payload & meta
-	var err error
-	data.Payload, err = encrypt(c.privateKey, data.Payload)
+func (c *Client) Logout(ctx context.Context) error {
+	err := c.transport.logout(ctx)
+
+	// forget local credentials even if the session has already expired
+	c.clearCredentials()
+
+	return err
+}
+
+func (c *Client) ListSessions(ctx context.Context) ([]model.Session, error) {
+	return c.transport.listSessions(ctx)
+}
+
+func (c *Client) RevokeSession(ctx context.Context, ID int64) error {
+	return c.transport.revokeSession(ctx, ID)
+}
+
+func (c *Client) ListSecrets(ctx context.Context, resourceType string) ([]model.Secret, error) {
+	rtype, _ := model.ValidateParam(resourceType)
+	return c.FilterSecrets(ctx, model.SecretFilter{Type: rtype})
+}
+
+// FilterSecrets lists the secrets matching filter. A zero filter lists the
+// whole vault.
+func (c *Client) FilterSecrets(ctx context.Context, filter model.SecretFilter) ([]model.Secret, error) {
+	list, err := c.transport.listSecrets(ctx, filter)
+	if c.fallback(err) {
+		return c.cache.secrets(filter)
+	}
 	if err != nil {
-		logger.Log.Error("failed to encrypt data", zap.Error(err))
-		return 0, ErrEncrypt
+		return nil, err
+	}
+
+	if c.cache == nil {
+		return list, nil
+	}
+
+	if filter == (model.SecretFilter{}) {
+		err = c.cache.replaceSecrets(list)
+	} else {
+		err = c.cache.putSecrets(list...)
 	}
-	data.Meta, err = encrypt(c.privateKey, data.Meta)
 	if err != nil {
+		logger.Log.Warn("failed to update cache", zap.Error(err))
+		return list, nil
+	}
+
+	// show changes not synced yet
+	if ops, _ := c.cache.pending(); len(ops) > 0 {
+		return c.cache.secrets(filter)
+	}
+
+	return list, nil
+}
+
+func (c *Client) CreateSecret(ctx context.Context, data *model.Secret) (int64, error) {
+	// encrypt a copy so the caller keeps the plaintext; a new secret gets
+	// a UUID of its own even if data was read from another one
+	sealed := *data
+	sealed.ID, sealed.UUID = 0, ""
+	if err := c.sealSecret(&sealed); err != nil {
 		logger.Log.Error("failed to encrypt data", zap.Error(err))
 		return 0, ErrEncrypt
 	}
 
-	res, err := c.client.R().
-		SetContext(ctx).
-		SetResult(&response).
-		SetBody(data).
-		Post(fmt.Sprintf("%s/api/secret", c.cfg.Address))
-
+	id, err := c.transport.createSecret(ctx, &sealed)
+	if c.fallback(err) {
+		return c.createOffline(&sealed)
+	}
 	if err != nil {
-		logger.Log.Error("failed to create secret", zap.Error(err))
 		return 0, err
 	}
 
-	if res.StatusCode() == 401 {
-		return 0, ErrUnauthorized
-	}
+	sealed.ID, sealed.Revision = id, 1
+	c.cacheSecret(&sealed)
 
-	return response.ID, nil
+	return id, nil
 }
 
 func (c *Client) GetSecret(ctx context.Context, ID int64) (*model.Secret, error) {
-	var secret model.Secret
-	res, err := c.client.R().
-		SetContext(ctx).
-		SetResult(&secret).
-		Get(fmt.Sprintf("%s/api/secret/%d", c.cfg.Address, ID))
+	var secret *model.Secret
+	var err error
 
+	if c.hasLocalChanges(ID) {
+		secret, err = c.cachedSecret(ID)
+	} else {
+		secret, err = c.transport.getSecret(ctx, ID)
+		switch {
+		case c.fallback(err):
+			secret, err = c.cachedSecret(ID)
+		case errors.Is(err, storage.ErrSecretNotFound) && c.cache != nil:
+			c.cache.deleteSecret(ID)
+		case err == nil:
+			c.cacheSecret(secret)
+		}
+	}
 	if err != nil {
-		logger.Log.Error("failed to get secret", zap.Error(err))
 		return nil, err
 	}
 
-	if res.StatusCode() == 401 {
-		return nil, ErrUnauthorized
-	}
-
 	// decrypt payload & meta
-	secret.Payload, err = decrypt(c.privateKey, secret.Payload)
-	if err != nil {
+	if err := c.openSecret(secret); err != nil {
 		logger.Log.Error("failed to decrypt data", zap.Error(err))
 		return nil, ErrDecrypt
 	}
-	secret.Meta, err = decrypt(c.privateKey, secret.Meta)
+	c.migrateOnRead(ctx, secret)
+
+	return secret, nil
+}
+
+// OpenSecrets lists the secrets matching filter with payload & meta
+// decrypted. Unlike GetSecret for each of them, it takes a single request.
+func (c *Client) OpenSecrets(ctx context.Context, filter model.SecretFilter) ([]model.Secret, error) {
+	list, err := c.FilterSecrets(ctx, filter)
 	if err != nil {
-		logger.Log.Error("failed to decrypt data", zap.Error(err))
-		return nil, ErrDecrypt
+		return nil, err
+	}
+
+	for i := range list {
+		if err := c.openSecret(&list[i]); err != nil {
+			logger.Log.Error("failed to decrypt data", zap.Error(err))
+			return nil, ErrDecrypt
+		}
+		c.migrateOnRead(ctx, &list[i])
+	}
+
+	return list, nil
+}
+
+// migrateOnRead seals a decrypted secret of the user again if it was sealed
+// before envelopes were bound to secrets. It is skipped while offline or
+// while the secret has changes not synced yet; a failure only leaves the
+// secret as it was until the next read.
+func (c *Client) migrateOnRead(ctx context.Context, secret *model.Secret) {
+	if !needsMigration(secret) || c.offline || c.hasLocalChanges(secret.ID) {
+		return
 	}
 
-	return &secret, nil
+	if err := c.migrateSecret(ctx, secret); err != nil {
+		logger.Log.Warn("failed to migrate secret", zap.Int64("id", secret.ID), zap.Error(err))
+	}
 }
 
-func (c *Client) DeleteSecret(ctx context.Context, ID int64) error {
-	res, err := c.client.R().
-		SetContext(ctx).
-		Delete(fmt.Sprintf("%s/api/secret/%d", c.cfg.Address, ID))
+// migrateSecret seals a decrypted secret under a UUID, and under a data key
+// if it has none yet, and stores it. secret is updated in place with the
+// key, UUID and revision stored. The data key of a secret that has one is
+// kept, so recipients of a shared secret can still read it.
+func (c *Client) migrateSecret(ctx context.Context, secret *model.Secret) error {
+	sealed := *secret
+	sealed.UUID = ""
+	if err := c.sealSecret(&sealed); err != nil {
+		logger.Log.Error("failed to encrypt data", zap.Error(err))
+		return ErrEncrypt
+	}
 
+	revision, err := c.transport.updateSecret(ctx, secret.ID, &sealed)
 	if err != nil {
-		logger.Log.Error("failed to delete secret", zap.Error(err))
 		return err
 	}
 
-	if res.StatusCode() == 401 {
-		return ErrUnauthorized
+	sealed.Revision = revision
+	c.cacheSecret(&sealed)
+	secret.Key, secret.UUID, secret.Revision = sealed.Key, sealed.UUID, revision
+
+	return nil
+}
+
+// needsMigration reports whether a secret was sealed by the legacy scheme
+// or in unbound envelopes.
+func needsMigration(secret *model.Secret) bool {
+	return len(secret.Key) == 0 || secret.UUID == ""
+}
+
+// DeleteSecret deletes a secret if its revision on the server is still
+// revision, otherwise a *ConflictError is returned.
+func (c *Client) DeleteSecret(ctx context.Context, ID, revision int64) error {
+	if c.hasLocalChanges(ID) {
+		return c.deleteOffline(ID, revision)
+	}
+
+	err := c.transport.deleteSecret(ctx, ID, revision)
+	if c.fallback(err) {
+		return c.deleteOffline(ID, revision)
+	}
+	if err != nil {
+		return err
+	}
+
+	if c.cache != nil {
+		c.cache.deleteSecret(ID)
 	}
 
 	return nil
 }
 
+// UpdateSecret replaces a secret, expecting data.Revision to be the current
+// revision on the server. It returns the new revision, or a *ConflictError
+// when someone else has changed the secret in the meantime.
 func (c *Client) UpdateSecret(ctx context.Context, id int64, data *model.Secret) (int64, error) {
-	var newID int64
-	res, err := c.client.R().
-		SetContext(ctx).
-		SetResult(&newID).
-		SetBody(data).
-		Put(fmt.Sprintf("%s/api/secret/%d", c.cfg.Address, id))
+	// encrypt a copy so the caller keeps the plaintext
+	sealed := *data
+	if err := c.sealSecret(&sealed); err != nil {
+		logger.Log.Error("failed to encrypt data", zap.Error(err))
+		return 0, ErrEncrypt
+	}
 
+	if c.hasLocalChanges(id) {
+		return c.updateOffline(id, &sealed)
+	}
+
+	revision, err := c.transport.updateSecret(ctx, id, &sealed)
+	if c.fallback(err) {
+		return c.updateOffline(id, &sealed)
+	}
 	if err != nil {
-		logger.Log.Error("failed to update secret", zap.Error(err))
 		return 0, err
 	}
 
-	if res.StatusCode() == 401 {
-		return 0, ErrUnauthorized
+	sealed.ID, sealed.Revision = id, revision
+	c.cacheSecret(&sealed)
+
+	return revision, nil
+}
+
+func (c *Client) cacheSecret(secret *model.Secret) {
+	if c.cache == nil {
+		return
+	}
+
+	if err := c.cache.putSecrets(*secret); err != nil {
+		logger.Log.Warn("failed to update cache", zap.Error(err))
 	}
+}
 
-	// decrypt payload & meta
-	data.Payload, err = decrypt(c.privateKey, data.Payload)
+func (c *Client) cachedSecret(ID int64) (*model.Secret, error) {
+	if c.cache == nil {
+		return nil, ErrNoCache
+	}
+
+	secret, ok, err := c.cache.secret(ID)
 	if err != nil {
-		logger.Log.Error("failed to decrypt data", zap.Error(err))
-		return 0, ErrDecrypt
+		return nil, err
+	}
+	if !ok {
+		return nil, storage.ErrSecretNotFound
 	}
-	data.Meta, err = decrypt(c.privateKey, data.Meta)
+
+	return secret, nil
+}
+
+// hasLocalChanges reports whether a secret has changes not synced yet, in
+// which case further changes are queued behind them.
+func (c *Client) hasLocalChanges(ID int64) bool {
+	if c.cache == nil {
+		return false
+	}
+
+	ops, err := c.cache.pending()
+	if err != nil {
+		return false
+	}
+	for _, op := range ops {
+		if op.ID == ID {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (c *Client) ListVersions(ctx context.Context, ID int64) ([]model.SecretVersion, error) {
+	return c.transport.listVersions(ctx, ID)
+}
+
+func (c *Client) GetVersion(ctx context.Context, ID, version int64) (*model.SecretVersion, error) {
+	v, err := c.transport.getVersion(ctx, ID, version)
 	if err != nil {
+		return nil, err
+	}
+
+	// decrypt payload & meta
+	secret := model.Secret{ID: ID, Payload: v.Payload, Meta: v.Meta, Key: v.Key, UUID: v.UUID}
+	if err := c.openSecret(&secret); err != nil {
 		logger.Log.Error("failed to decrypt data", zap.Error(err))
-		return 0, ErrDecrypt
+		return nil, ErrDecrypt
+	}
+	v.Payload, v.Meta = secret.Payload, secret.Meta
+
+	return v, nil
+}
+
+func (c *Client) RestoreVersion(ctx context.Context, ID, version int64) error {
+	return c.transport.restoreVersion(ctx, ID, version)
+}
+
+// sealSecret encrypts payload & meta for data.UUID and stores the wrapped
+// data key in data.Key. A data key already in data.Key is kept, so
+// recipients of a shared secret can still read it after an update;
+// otherwise a fresh one is generated. A secret without a UUID gets a new
+// one.
+func (c *Client) sealSecret(data *model.Secret) error {
+	if c.privateKey == nil {
+		return ErrUnauthorized
 	}
 
-	return newID, nil
+	var dataKey []byte
+	var err error
+	if len(data.Key) > 0 {
+		if dataKey, err = unwrapKey(c.privateKey, data.Key); err != nil {
+			return err
+		}
+	} else {
+		if dataKey, err = newDataKey(); err != nil {
+			return err
+		}
+		if data.Key, err = wrapKey(&c.privateKey.PublicKey, dataKey); err != nil {
+			return err
+		}
+	}
+
+	if data.UUID == "" {
+		if data.UUID, err = newSecretUUID(); err != nil {
+			return err
+		}
+	}
+
+	kid := keyID(&c.privateKey.PublicKey)
+	if data.Payload, err = sealField(dataKey, kid, data.UUID, fieldPayload, data.Payload); err != nil {
+		return err
+	}
+	if data.Meta, err = sealField(dataKey, kid, data.UUID, fieldMeta, data.Meta); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// openSecret decrypts payload & meta in place.
+func (c *Client) openSecret(secret *model.Secret) error {
+	if c.privateKey == nil {
+		return ErrUnauthorized
+	}
+
+	return openSecretWith(c.privateKey, secret)
+}
+
+// openSecretWith decrypts payload & meta in place with the private key of
+// the owner. Secrets without a wrapped data key were written by the legacy
+// scheme.
+func openSecretWith(priv *rsa.PrivateKey, secret *model.Secret) error {
+	var err error
+	if len(secret.Key) == 0 {
+		if secret.Payload, err = decryptLegacy(priv, secret.Payload); err != nil {
+			return err
+		}
+		secret.Meta, err = decryptLegacy(priv, secret.Meta)
+		return err
+	}
+
+	dataKey, err := unwrapKey(priv, secret.Key)
+	if err != nil {
+		return err
+	}
+	if secret.Payload, err = openField(dataKey, secret.UUID, fieldPayload, secret.Payload); err != nil {
+		return err
+	}
+	if secret.Meta, err = openField(dataKey, secret.UUID, fieldMeta, secret.Meta); err != nil {
+		return err
+	}
+
+	return nil
 }
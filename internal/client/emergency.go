@@ -0,0 +1,130 @@
+package client
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+
+	"github.com/nbvehbq/go-password-keeper/internal/logger"
+	"github.com/nbvehbq/go-password-keeper/internal/model"
+	"go.uber.org/zap"
+)
+
+// Emergency access hands the private key of the grantor to a trusted
+// contact. The key is sealed with a fresh data key wrapped for the contact,
+// and the server only releases both once a request of the contact has not
+// been rejected within the wait period.
+
+// GrantEmergencyAccess makes the user login an emergency contact, who may
+// take over the vault waitDays after asking for it.
+func (c *Client) GrantEmergencyAccess(ctx context.Context, login string, waitDays int) error {
+	if c.privateKey == nil {
+		return ErrUnauthorized
+	}
+
+	granteeID, granteeKey, err := c.recipientKey(ctx, login)
+	if err != nil {
+		return err
+	}
+
+	dataKey, err := newDataKey()
+	if err != nil {
+		logger.Log.Error("failed to generate key", zap.Error(err))
+		return ErrGenerateKey
+	}
+	wrapped, err := wrapKey(granteeKey, dataKey)
+	if err != nil {
+		logger.Log.Error("failed to wrap data key", zap.Error(err))
+		return ErrEncrypt
+	}
+
+	privateKeyPEM := pem.EncodeToMemory(&pem.Block{
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(c.privateKey),
+	})
+	vaultKey, err := seal(dataKey, keyID(granteeKey), privateKeyPEM)
+	if err != nil {
+		logger.Log.Error("failed to encrypt data", zap.Error(err))
+		return ErrEncrypt
+	}
+
+	_, err = c.transport.createEmergencyGrant(ctx, &model.EmergencyGrant{
+		GranteeID: granteeID,
+		WaitDays:  waitDays,
+		Key:       wrapped,
+		VaultKey:  vaultKey,
+	})
+	return c.onlineOnly(err)
+}
+
+// ListEmergencyGrants lists the emergency contacts of the user and the
+// users who made them one.
+func (c *Client) ListEmergencyGrants(ctx context.Context) ([]model.EmergencyGrant, error) {
+	list, err := c.transport.listEmergencyGrants(ctx)
+	if err != nil {
+		return nil, c.onlineOnly(err)
+	}
+
+	return list, nil
+}
+
+// RevokeEmergencyAccess ends a grant given or received by the user.
+func (c *Client) RevokeEmergencyAccess(ctx context.Context, ID int64) error {
+	return c.onlineOnly(c.transport.deleteEmergencyGrant(ctx, ID))
+}
+
+// RequestEmergencyAccess asks for the vault of the grantor, which is
+// released when the grantor doesn't reject the request in time.
+func (c *Client) RequestEmergencyAccess(ctx context.Context, ID int64) (*model.EmergencyGrant, error) {
+	grant, err := c.transport.requestEmergencyAccess(ctx, ID)
+	if err != nil {
+		return nil, c.onlineOnly(err)
+	}
+
+	return grant, nil
+}
+
+// RejectEmergencyAccess cancels a pending request to a grant of the user.
+func (c *Client) RejectEmergencyAccess(ctx context.Context, ID int64) error {
+	return c.onlineOnly(c.transport.rejectEmergencyAccess(ctx, ID))
+}
+
+// OpenEmergencyVault returns the secrets of the grantor with payload &
+// meta decrypted, once the request has been released.
+func (c *Client) OpenEmergencyVault(ctx context.Context, ID int64) (*model.EmergencyVault, error) {
+	if c.privateKey == nil {
+		return nil, ErrUnauthorized
+	}
+
+	vault, err := c.transport.getEmergencyVault(ctx, ID)
+	if err != nil {
+		return nil, c.onlineOnly(err)
+	}
+
+	dataKey, err := unwrapKey(c.privateKey, vault.Grant.Key)
+	if err != nil {
+		logger.Log.Error("failed to unwrap data key", zap.Error(err))
+		return nil, ErrDecrypt
+	}
+	privateKeyPEM, err := open(dataKey, vault.Grant.VaultKey)
+	if err != nil {
+		logger.Log.Error("failed to decrypt data", zap.Error(err))
+		return nil, ErrDecrypt
+	}
+	grantorKey, err := parsePrivateKey(privateKeyPEM)
+	if err != nil {
+		logger.Log.Error("failed to parse private key", zap.Error(err))
+		return nil, ErrDecrypt
+	}
+
+	for i := range vault.Secrets {
+		if err := openSecretWith(grantorKey, &vault.Secrets[i]); err != nil {
+			logger.Log.Error("failed to decrypt data", zap.Error(err))
+			return nil, ErrDecrypt
+		}
+	}
+
+	// the keys are of no use to callers
+	vault.Grant.Key, vault.Grant.VaultKey = nil, nil
+	return vault, nil
+}
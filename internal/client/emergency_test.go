@@ -0,0 +1,82 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nbvehbq/go-password-keeper/internal/model"
+	"github.com/nbvehbq/go-password-keeper/internal/storage"
+)
+
+// The grantee reads the vault of the grantor with their own key once the
+// server releases it, and nobody else can.
+func TestEmergencyVault(t *testing.T) {
+	ctx := context.Background()
+	api := newFakeAPI(t)
+
+	clients := make(map[string]*Client)
+	for _, login := range []string{"alice", "bob", "carol"} {
+		clients[login] = newAPIClient(t, api)
+		if err := clients[login].Register(ctx, login, "correct horse"); err != nil {
+			t.Fatalf("register %s: %v", login, err)
+		}
+	}
+	alice, bob, carol := clients["alice"], clients["bob"], clients["carol"]
+
+	if _, err := alice.CreateSecret(ctx, &model.Secret{Name: "mail", Type: model.TextType,
+		Payload: []byte("hunter2")}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	if err := alice.GrantEmergencyAccess(ctx, "dave", 1); !errors.Is(err, ErrRecipientNotFound) {
+		t.Errorf("grant to an unknown user: got %v, want %v", err, ErrRecipientNotFound)
+	}
+	if err := alice.GrantEmergencyAccess(ctx, "bob", 1); err != nil {
+		t.Fatalf("grant: %v", err)
+	}
+
+	if _, err := bob.OpenEmergencyVault(ctx, 1); !errors.Is(err, storage.ErrEmergencyWaiting) {
+		t.Errorf("open before a request: got %v, want %v", err, storage.ErrEmergencyWaiting)
+	}
+	grant, err := bob.RequestEmergencyAccess(ctx, 1)
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	if until := time.Until(grant.ReleasesAt()); until <= 0 || until > 24*time.Hour {
+		t.Errorf("released in %v, want within a day", until)
+	}
+	if _, err := bob.OpenEmergencyVault(ctx, 1); !errors.Is(err, storage.ErrEmergencyWaiting) {
+		t.Errorf("open while waiting: got %v, want %v", err, storage.ErrEmergencyWaiting)
+	}
+
+	api.mu.Lock()
+	past := time.Now().Add(-48 * time.Hour)
+	api.grants[1].RequestedAt = &past
+	api.mu.Unlock()
+
+	if _, err := carol.OpenEmergencyVault(ctx, 1); !errors.Is(err, storage.ErrEmergencyNotFound) {
+		t.Errorf("open by another user: got %v, want %v", err, storage.ErrEmergencyNotFound)
+	}
+
+	vault, err := bob.OpenEmergencyVault(ctx, 1)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if len(vault.Secrets) != 1 || vault.Secrets[0].Name != "mail" || string(vault.Secrets[0].Payload) != "hunter2" {
+		t.Errorf("vault secrets %+v", vault.Secrets)
+	}
+	if vault.Grant.Key != nil || vault.Grant.VaultKey != nil {
+		t.Error("released keys returned to the caller")
+	}
+
+	// the sealed private key is of no use to anyone but the grantee
+	api.mu.Lock()
+	api.grants[1].GranteeID = api.ids["carol"]
+	api.mu.Unlock()
+
+	if _, err := carol.OpenEmergencyVault(ctx, 1); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("open with another key: got %v, want %v", err, ErrDecrypt)
+	}
+}
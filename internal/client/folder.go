@@ -0,0 +1,143 @@
+package client
+
+import (
+	"context"
+
+	"github.com/nbvehbq/go-password-keeper/internal/model"
+)
+
+// Folders and tags are not cached, so they are managed only while online.
+// Their names are encrypted like secrets, with a data key of their own.
+
+// onlineOnly reports ErrOffline for requests the cache can't serve.
+func (c *Client) onlineOnly(err error) error {
+	if isOffline(err) {
+		c.offline = true
+		return ErrOffline
+	}
+	return err
+}
+
+// sealName encrypts name with a fresh data key and returns it along with
+// the wrapped data key.
+func (c *Client) sealName(name string) (sealed, key []byte, err error) {
+	if c.privateKey == nil {
+		return nil, nil, ErrUnauthorized
+	}
+
+	dataKey, err := newDataKey()
+	if err != nil {
+		return nil, nil, err
+	}
+	if key, err = wrapKey(&c.privateKey.PublicKey, dataKey); err != nil {
+		return nil, nil, err
+	}
+	if sealed, err = seal(dataKey, keyID(&c.privateKey.PublicKey), []byte(name)); err != nil {
+		return nil, nil, err
+	}
+
+	return sealed, key, nil
+}
+
+// openName decrypts a name sealed by sealName.
+func (c *Client) openName(sealed, key []byte) ([]byte, error) {
+	if c.privateKey == nil {
+		return nil, ErrUnauthorized
+	}
+
+	dataKey, err := unwrapKey(c.privateKey, key)
+	if err != nil {
+		return nil, err
+	}
+	return open(dataKey, sealed)
+}
+
+// ListFolders returns the folders of the vault with decrypted names.
+func (c *Client) ListFolders(ctx context.Context) ([]model.Folder, error) {
+	list, err := c.transport.listFolders(ctx)
+	if err != nil {
+		return nil, c.onlineOnly(err)
+	}
+
+	for i := range list {
+		if list[i].Name, err = c.openName(list[i].Name, list[i].Key); err != nil {
+			return nil, ErrDecrypt
+		}
+		list[i].Key = nil
+	}
+
+	return list, nil
+}
+
+// CreateFolder creates a folder in parentID, or at the top level if
+// parentID is 0.
+func (c *Client) CreateFolder(ctx context.Context, parentID int64, name string) (int64, error) {
+	sealed, key, err := c.sealName(name)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := c.transport.createFolder(ctx, &model.Folder{ParentID: parentID, Name: sealed, Key: key})
+	if err != nil {
+		return 0, c.onlineOnly(err)
+	}
+
+	return id, nil
+}
+
+// DeleteFolder removes an empty folder.
+func (c *Client) DeleteFolder(ctx context.Context, ID int64) error {
+	return c.onlineOnly(c.transport.deleteFolder(ctx, ID))
+}
+
+// ListTags returns the tags of the vault with decrypted names.
+func (c *Client) ListTags(ctx context.Context) ([]model.Tag, error) {
+	list, err := c.transport.listTags(ctx)
+	if err != nil {
+		return nil, c.onlineOnly(err)
+	}
+
+	for i := range list {
+		if list[i].Name, err = c.openName(list[i].Name, list[i].Key); err != nil {
+			return nil, ErrDecrypt
+		}
+		list[i].Key = nil
+	}
+
+	return list, nil
+}
+
+func (c *Client) CreateTag(ctx context.Context, name string) (int64, error) {
+	sealed, key, err := c.sealName(name)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := c.transport.createTag(ctx, &model.Tag{Name: sealed, Key: key})
+	if err != nil {
+		return 0, c.onlineOnly(err)
+	}
+
+	return id, nil
+}
+
+// PatchSecret moves a secret to another folder, marks it as favorite or
+// replaces its tags. Secrets with changes not synced yet can't be patched.
+func (c *Client) PatchSecret(ctx context.Context, ID int64, patch *model.SecretPatch) error {
+	if c.hasLocalChanges(ID) {
+		return ErrPendingChanges
+	}
+
+	if err := c.transport.patchSecret(ctx, ID, patch); err != nil {
+		return c.onlineOnly(err)
+	}
+
+	// refresh the cached copy, the revision stays the same
+	if c.cache != nil {
+		if secret, err := c.transport.getSecret(ctx, ID); err == nil {
+			c.cacheSecret(secret)
+		}
+	}
+
+	return nil
+}
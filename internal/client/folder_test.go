@@ -0,0 +1,79 @@
+package client
+
+import (
+	"bytes"
+	"slices"
+	"testing"
+
+	"github.com/nbvehbq/go-password-keeper/internal/model"
+)
+
+func TestFolderNames(t *testing.T) {
+	c := &Client{privateKey: newTestPrivateKey(t)}
+
+	sealed, key, err := c.sealName("banking")
+	if err != nil {
+		t.Fatalf("seal: %v", err)
+	}
+	if bytes.Contains(sealed, []byte("banking")) {
+		t.Error("name sent in plain text")
+	}
+
+	name, err := c.openName(sealed, key)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if string(name) != "banking" {
+		t.Errorf("got %q, want %q", name, "banking")
+	}
+
+	other := &Client{privateKey: newTestPrivateKey(t)}
+	if _, err := other.openName(sealed, key); err == nil {
+		t.Error("name opened by another user")
+	}
+}
+
+// Offline, secrets are filtered in the cache.
+func TestCachedSecretsFilter(t *testing.T) {
+	cache := newTestCache(t)
+
+	err := cache.putSecrets(
+		model.Secret{ID: 1, Name: "mail", Type: model.LoginPasswordType, FolderID: 10, Tags: []int64{1, 2}},
+		model.Secret{ID: 2, Name: "bank", Type: model.BankCardType, FolderID: 10, Favorite: true},
+		model.Secret{ID: 3, Name: "notes", Type: model.TextType, Tags: []int64{2}, Favorite: true},
+	)
+	if err != nil {
+		t.Fatalf("put: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		filter model.SecretFilter
+		want   []int64
+	}{
+		{name: "all", want: []int64{1, 2, 3}},
+		{name: "type", filter: model.SecretFilter{Type: model.BankCardType}, want: []int64{2}},
+		{name: "folder", filter: model.SecretFilter{FolderID: 10}, want: []int64{1, 2}},
+		{name: "tag", filter: model.SecretFilter{TagID: 2}, want: []int64{1, 3}},
+		{name: "favorite", filter: model.SecretFilter{Favorite: true}, want: []int64{2, 3}},
+		{name: "favorite in folder", filter: model.SecretFilter{FolderID: 10, Favorite: true}, want: []int64{2}},
+		{name: "unknown tag", filter: model.SecretFilter{TagID: 7}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			list, err := cache.secrets(tt.filter)
+			if err != nil {
+				t.Fatalf("secrets: %v", err)
+			}
+
+			var got []int64
+			for _, v := range list {
+				got = append(got, v.ID)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
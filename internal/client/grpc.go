@@ -0,0 +1,939 @@
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+
+	"github.com/nbvehbq/go-password-keeper/internal/logger"
+	"github.com/nbvehbq/go-password-keeper/internal/model"
+	pb "github.com/nbvehbq/go-password-keeper/internal/proto"
+	"github.com/nbvehbq/go-password-keeper/internal/storage"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// grpcChunkSize is the size of blob chunks sent to the server.
+const grpcChunkSize = 64 << 10
+
+// grpcTransport talks to the gRPC API of the server. The session ID is sent
+// in the "authorization" metadata of every call.
+type grpcTransport struct {
+	conn   *grpc.ClientConn
+	client pb.KeeperClient
+	sid    string
+}
+
+// newGRPCTransport connects over TLS when tlsConf is set and in plain text
+// otherwise.
+func newGRPCTransport(address string, tlsConf *tls.Config) (*grpcTransport, error) {
+	t := &grpcTransport{}
+
+	creds := insecure.NewCredentials()
+	if tlsConf != nil {
+		creds = credentials.NewTLS(tlsConf)
+	}
+
+	conn, err := grpc.NewClient(address,
+		grpc.WithTransportCredentials(creds),
+		grpc.WithUnaryInterceptor(t.unaryAuth),
+		grpc.WithStreamInterceptor(t.streamAuth),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	t.conn, t.client = conn, pb.NewKeeperClient(conn)
+
+	return t, nil
+}
+
+func (t *grpcTransport) withSession(ctx context.Context) context.Context {
+	if t.sid == "" {
+		return ctx
+	}
+	return metadata.AppendToOutgoingContext(ctx, "authorization", t.sid)
+}
+
+func (t *grpcTransport) unaryAuth(ctx context.Context, method string, req, reply any,
+	cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	return invoker(t.withSession(ctx), method, req, reply, cc, opts...)
+}
+
+func (t *grpcTransport) streamAuth(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn,
+	method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	return streamer(t.withSession(ctx), desc, cc, method, opts...)
+}
+
+// fromStatus maps a gRPC error to the errors of the REST transport.
+// notFound is returned for NOT_FOUND, as its meaning depends on the call.
+// Unreachable server errors are returned as is for isOffline.
+func fromStatus(err error, notFound error) error {
+	st, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+
+	switch st.Code() {
+	case codes.Unavailable:
+		return err
+	case codes.Unauthenticated:
+		return ErrUnauthorized
+	case codes.PermissionDenied:
+		return ErrInvalidCode
+	case codes.NotFound:
+		return notFound
+	case codes.AlreadyExists:
+		return storage.ErrSecretExists
+	case codes.FailedPrecondition:
+		for _, detail := range st.Details() {
+			if conflict, ok := detail.(*pb.RevisionConflict); ok {
+				return &ConflictError{Revision: conflict.Revision}
+			}
+		}
+		return ErrConflict
+	case codes.ResourceExhausted:
+		return ErrTooLarge
+	default:
+		return ErrInternal
+	}
+}
+
+func (t *grpcTransport) register(ctx context.Context, dto *model.RegisterDTO) (string, error) {
+	res, err := t.client.Register(ctx, &pb.RegisterRequest{
+		Login:     dto.Login,
+		Password:  dto.Password,
+		Salt:      dto.Salt,
+		VaultKey:  dto.VaultKey,
+		PublicKey: dto.PublicKey,
+	})
+	if err != nil {
+		logger.Log.Error("failed to register", zap.Error(err))
+		if status.Code(err) == codes.AlreadyExists {
+			return "", ErrUserExists
+		}
+		return "", ErrInternal
+	}
+
+	return res.Sid, nil
+}
+
+func (t *grpcTransport) prelogin(ctx context.Context, login string) ([]byte, error) {
+	res, err := t.client.Prelogin(ctx, &pb.PreloginRequest{Login: login})
+	if err != nil {
+		logger.Log.Error("failed to prelogin", zap.Error(err))
+		switch status.Code(err) {
+		case codes.Unavailable:
+			return nil, ErrOffline
+		case codes.Unauthenticated:
+			return nil, ErrUnauthorized
+		default:
+			return nil, ErrInternal
+		}
+	}
+
+	return res.Salt, nil
+}
+
+func (t *grpcTransport) login(ctx context.Context, login, password string) (string, []byte, error) {
+	res, err := t.client.Login(ctx, &pb.LoginRequest{Login: login, Password: password})
+	if err != nil {
+		logger.Log.Error("failed to login", zap.Error(err))
+		if status.Code(err) == codes.Unauthenticated {
+			return "", nil, ErrUnauthorized
+		}
+		return "", nil, ErrInternal
+	}
+
+	if res.Challenge != "" {
+		return "", nil, &challengeError{challenge: res.Challenge}
+	}
+
+	return res.Sid, res.VaultKey, nil
+}
+
+func (t *grpcTransport) loginTwoFactor(ctx context.Context, challenge, code string) (string, []byte, error) {
+	res, err := t.client.LoginTwoFactor(ctx, &pb.LoginTwoFactorRequest{Challenge: challenge, Code: code})
+	if err != nil {
+		logger.Log.Error("failed to login", zap.Error(err))
+		if status.Code(err) == codes.PermissionDenied {
+			return "", nil, ErrInvalidCode
+		}
+		return "", nil, ErrInternal
+	}
+
+	return res.Sid, res.VaultKey, nil
+}
+
+func (t *grpcTransport) enrollTOTP(ctx context.Context) (*model.TOTPEnrollment, error) {
+	res, err := t.client.EnrollTOTP(ctx, &emptypb.Empty{})
+	if err != nil {
+		logger.Log.Error("failed to enroll totp", zap.Error(err))
+		return nil, twoFactorStatus(err)
+	}
+
+	return &model.TOTPEnrollment{Secret: res.Secret, URI: res.Uri}, nil
+}
+
+func (t *grpcTransport) verifyTOTP(ctx context.Context, code string) ([]string, error) {
+	res, err := t.client.VerifyTOTP(ctx, &pb.TOTPCode{Code: code})
+	if err != nil {
+		logger.Log.Error("failed to verify totp", zap.Error(err))
+		return nil, twoFactorStatus(err)
+	}
+
+	return res.Codes, nil
+}
+
+func (t *grpcTransport) disableTOTP(ctx context.Context, code string) error {
+	if _, err := t.client.DisableTOTP(ctx, &pb.TOTPCode{Code: code}); err != nil {
+		logger.Log.Error("failed to disable totp", zap.Error(err))
+		return twoFactorStatus(err)
+	}
+
+	return nil
+}
+
+// twoFactorStatus maps FAILED_PRECONDITION of the two-factor calls, which
+// is not a revision conflict there.
+func twoFactorStatus(err error) error {
+	if status.Code(err) == codes.FailedPrecondition {
+		return ErrTwoFactorState
+	}
+	return fromStatus(err, ErrInternal)
+}
+
+func (t *grpcTransport) updateKeys(ctx context.Context, dto *keysDTO) error {
+	_, err := t.client.UpdateKeys(ctx, &pb.UpdateKeysRequest{
+		Login:           dto.Login,
+		CurrentPassword: dto.CurrentPassword,
+		Password:        dto.Password,
+		Salt:            dto.Salt,
+		VaultKey:        dto.VaultKey,
+		PublicKey:       dto.PublicKey,
+	})
+
+	return err
+}
+
+func (t *grpcTransport) logout(ctx context.Context) error {
+	if _, err := t.client.Logout(ctx, &emptypb.Empty{}); err != nil {
+		logger.Log.Error("failed to logout", zap.Error(err))
+		if status.Code(err) == codes.Unauthenticated {
+			return ErrUnauthorized
+		}
+		return ErrInternal
+	}
+
+	return nil
+}
+
+func (t *grpcTransport) listSessions(ctx context.Context) ([]model.Session, error) {
+	res, err := t.client.ListSessions(ctx, &emptypb.Empty{})
+	if err != nil {
+		logger.Log.Error("failed to list sessions", zap.Error(err))
+		return nil, fromStatus(err, ErrSessionNotFound)
+	}
+
+	list := make([]model.Session, len(res.Sessions))
+	for i, s := range res.Sessions {
+		list[i] = model.Session{
+			ID:         s.Id,
+			UserAgent:  s.UserAgent,
+			CreatedAt:  s.CreatedAt.AsTime(),
+			LastSeenAt: s.LastSeenAt.AsTime(),
+			ExpiresAt:  s.ExpiresAt.AsTime(),
+			Current:    s.Current,
+		}
+	}
+
+	return list, nil
+}
+
+func (t *grpcTransport) listAuditEvents(ctx context.Context, before int64, limit int) ([]model.AuditEvent, error) {
+	res, err := t.client.ListAuditEvents(ctx, &pb.AuditRequest{Before: before, Limit: int32(limit)})
+	if err != nil {
+		logger.Log.Error("failed to list audit events", zap.Error(err))
+		return nil, fromStatus(err, ErrInternal)
+	}
+
+	list := make([]model.AuditEvent, len(res.Events))
+	for i, v := range res.Events {
+		list[i] = model.AuditEvent{
+			ID:        v.Id,
+			UserID:    v.UserId,
+			ActorID:   v.ActorId,
+			Action:    v.Action,
+			Detail:    v.Detail,
+			CreatedAt: v.CreatedAt.AsTime(),
+			PrevHash:  v.PrevHash,
+			Hash:      v.Hash,
+		}
+	}
+
+	return list, nil
+}
+
+func (t *grpcTransport) revokeSession(ctx context.Context, ID int64) error {
+	if _, err := t.client.RevokeSession(ctx, &pb.RevokeSessionRequest{Id: ID}); err != nil {
+		logger.Log.Error("failed to revoke session", zap.Error(err))
+		return fromStatus(err, ErrSessionNotFound)
+	}
+
+	return nil
+}
+
+func (t *grpcTransport) listSecrets(ctx context.Context, filter model.SecretFilter) ([]model.Secret, error) {
+	res, err := t.client.ListSecrets(ctx, &pb.ListSecretsRequest{
+		Type:     uint32(filter.Type),
+		FolderId: filter.FolderID,
+		TagId:    filter.TagID,
+		Favorite: filter.Favorite,
+	})
+	if err != nil {
+		logger.Log.Error("failed to list secrets", zap.Error(err))
+		return nil, fromStatus(err, storage.ErrSecretNotFound)
+	}
+
+	list := make([]model.Secret, len(res.Secrets))
+	for i, s := range res.Secrets {
+		list[i] = *secretFromProto(s)
+	}
+
+	return list, nil
+}
+
+func (t *grpcTransport) getSecret(ctx context.Context, ID int64) (*model.Secret, error) {
+	res, err := t.client.GetSecret(ctx, &pb.SecretRequest{Id: ID})
+	if err != nil {
+		logger.Log.Error("failed to get secret", zap.Error(err))
+		return nil, fromStatus(err, storage.ErrSecretNotFound)
+	}
+
+	return secretFromProto(res), nil
+}
+
+func (t *grpcTransport) createSecret(ctx context.Context, sealed *model.Secret) (int64, error) {
+	res, err := t.client.CreateSecret(ctx, secretToProto(sealed))
+	if err != nil {
+		logger.Log.Error("failed to create secret", zap.Error(err))
+		return 0, placementStatus(err, storage.ErrSecretNotFound)
+	}
+
+	return res.Id, nil
+}
+
+func (t *grpcTransport) updateSecret(ctx context.Context, ID int64, sealed *model.Secret) (int64, error) {
+	req := secretToProto(sealed)
+	req.Id = ID
+
+	res, err := t.client.UpdateSecret(ctx, req)
+	if err != nil {
+		logger.Log.Error("failed to update secret", zap.Error(err))
+		return 0, fromStatus(err, storage.ErrSecretNotFound)
+	}
+
+	return res.Revision, nil
+}
+
+func (t *grpcTransport) deleteSecret(ctx context.Context, ID, revision int64) error {
+	_, err := t.client.DeleteSecret(ctx, &pb.DeleteSecretRequest{Id: ID, Revision: revision})
+	if err != nil {
+		logger.Log.Error("failed to delete secret", zap.Error(err))
+		return fromStatus(err, storage.ErrSecretNotFound)
+	}
+
+	return nil
+}
+
+func (t *grpcTransport) patchSecret(ctx context.Context, ID int64, patch *model.SecretPatch) error {
+	_, err := t.client.PatchSecret(ctx, &pb.SecretPatch{
+		Id:       ID,
+		FolderId: patch.FolderID,
+		Favorite: patch.Favorite,
+		SetTags:  patch.SetTags,
+		Tags:     patch.Tags,
+	})
+	if err != nil {
+		logger.Log.Error("failed to patch secret", zap.Error(err))
+		return placementStatus(err, storage.ErrSecretNotFound)
+	}
+
+	return nil
+}
+
+// placementStatus maps INVALID_ARGUMENT of calls referring to folders and
+// tags of other users or ones that don't exist.
+func placementStatus(err error, notFound error) error {
+	st, _ := status.FromError(err)
+	if st.Code() != codes.InvalidArgument {
+		return fromStatus(err, notFound)
+	}
+	if st.Message() == storage.ErrTagNotFound.Error() {
+		return storage.ErrTagNotFound
+	}
+	return storage.ErrFolderNotFound
+}
+
+func (t *grpcTransport) listFolders(ctx context.Context) ([]model.Folder, error) {
+	res, err := t.client.ListFolders(ctx, &emptypb.Empty{})
+	if err != nil {
+		logger.Log.Error("failed to list folders", zap.Error(err))
+		return nil, fromStatus(err, ErrInternal)
+	}
+
+	list := make([]model.Folder, len(res.Folders))
+	for i, v := range res.Folders {
+		list[i] = model.Folder{ID: v.Id, ParentID: v.ParentId, Name: v.Name, Key: v.Key}
+	}
+
+	return list, nil
+}
+
+func (t *grpcTransport) createFolder(ctx context.Context, sealed *model.Folder) (int64, error) {
+	res, err := t.client.CreateFolder(ctx, &pb.Folder{ParentId: sealed.ParentID, Name: sealed.Name, Key: sealed.Key})
+	if err != nil {
+		logger.Log.Error("failed to create folder", zap.Error(err))
+		return 0, placementStatus(err, ErrInternal)
+	}
+
+	return res.Id, nil
+}
+
+func (t *grpcTransport) deleteFolder(ctx context.Context, ID int64) error {
+	if _, err := t.client.DeleteFolder(ctx, &pb.FolderRequest{Id: ID}); err != nil {
+		logger.Log.Error("failed to delete folder", zap.Error(err))
+		if status.Code(err) == codes.FailedPrecondition {
+			return storage.ErrFolderNotEmpty
+		}
+		return fromStatus(err, storage.ErrFolderNotFound)
+	}
+
+	return nil
+}
+
+func (t *grpcTransport) listTags(ctx context.Context) ([]model.Tag, error) {
+	res, err := t.client.ListTags(ctx, &emptypb.Empty{})
+	if err != nil {
+		logger.Log.Error("failed to list tags", zap.Error(err))
+		return nil, fromStatus(err, ErrInternal)
+	}
+
+	list := make([]model.Tag, len(res.Tags))
+	for i, v := range res.Tags {
+		list[i] = model.Tag{ID: v.Id, Name: v.Name, Key: v.Key}
+	}
+
+	return list, nil
+}
+
+func (t *grpcTransport) createTag(ctx context.Context, sealed *model.Tag) (int64, error) {
+	res, err := t.client.CreateTag(ctx, &pb.Tag{Name: sealed.Name, Key: sealed.Key})
+	if err != nil {
+		logger.Log.Error("failed to create tag", zap.Error(err))
+		return 0, fromStatus(err, ErrInternal)
+	}
+
+	return res.Id, nil
+}
+
+// shareStatus maps the errors of sharing calls, which use PERMISSION_DENIED
+// for read-only shares and ABORTED for changed recipients.
+func shareStatus(err error, notFound error) error {
+	switch status.Code(err) {
+	case codes.PermissionDenied:
+		return storage.ErrReadOnly
+	case codes.Aborted:
+		return storage.ErrSharesChanged
+	case codes.InvalidArgument:
+		return ErrRecipientNotFound
+	}
+	return fromStatus(err, notFound)
+}
+
+func (t *grpcTransport) getPublicKey(ctx context.Context, login string) (*model.PublicKey, error) {
+	res, err := t.client.GetPublicKey(ctx, &pb.PublicKeyRequest{Login: login})
+	if err != nil {
+		logger.Log.Error("failed to get public key", zap.Error(err))
+		return nil, fromStatus(err, ErrRecipientNotFound)
+	}
+
+	return &model.PublicKey{UserID: res.UserId, Login: res.Login, Key: res.Key}, nil
+}
+
+func (t *grpcTransport) shareSecret(ctx context.Context, share *model.Share) error {
+	_, err := t.client.ShareSecret(ctx, &pb.Share{
+		SecretId: share.SecretID,
+		UserId:   share.UserID,
+		Key:      share.Key,
+		CanEdit:  share.CanEdit,
+	})
+	if err != nil {
+		logger.Log.Error("failed to share secret", zap.Error(err))
+		return shareStatus(err, storage.ErrSecretNotFound)
+	}
+
+	return nil
+}
+
+func (t *grpcTransport) listShares(ctx context.Context, ID int64) ([]model.Share, error) {
+	res, err := t.client.ListShares(ctx, &pb.SecretRequest{Id: ID})
+	if err != nil {
+		logger.Log.Error("failed to list shares", zap.Error(err))
+		return nil, fromStatus(err, storage.ErrSecretNotFound)
+	}
+
+	list := make([]model.Share, len(res.Shares))
+	for i, v := range res.Shares {
+		list[i] = model.Share{
+			SecretID:  v.SecretId,
+			UserID:    v.UserId,
+			Login:     v.Login,
+			CanEdit:   v.CanEdit,
+			CreatedAt: v.CreatedAt.AsTime(),
+		}
+	}
+
+	return list, nil
+}
+
+func (t *grpcTransport) rekeySecret(ctx context.Context, ID int64, rekey *model.SecretRekey) (int64, error) {
+	req := &pb.SecretRekey{
+		Id:       ID,
+		Revision: rekey.Revision,
+		Payload:  rekey.Payload,
+		Meta:     rekey.Meta,
+		Key:      rekey.Key,
+		Uuid:     rekey.UUID,
+		Shares:   make([]*pb.Share, len(rekey.Shares)),
+		Revoke:   rekey.Revoke,
+	}
+	for i, v := range rekey.Shares {
+		req.Shares[i] = &pb.Share{UserId: v.UserID, Key: v.Key}
+	}
+
+	res, err := t.client.RekeySecret(ctx, req)
+	if err != nil {
+		logger.Log.Error("failed to rekey secret", zap.Error(err))
+		return 0, shareStatus(err, storage.ErrSecretNotFound)
+	}
+
+	return res.Revision, nil
+}
+
+func (t *grpcTransport) listSharedSecrets(ctx context.Context) ([]model.SharedSecret, error) {
+	res, err := t.client.ListSharedSecrets(ctx, &emptypb.Empty{})
+	if err != nil {
+		logger.Log.Error("failed to list shared secrets", zap.Error(err))
+		return nil, fromStatus(err, ErrInternal)
+	}
+
+	list := make([]model.SharedSecret, len(res.Secrets))
+	for i, v := range res.Secrets {
+		list[i] = model.SharedSecret{
+			Secret:  *secretFromProto(v.Secret),
+			Owner:   v.Owner,
+			CanEdit: v.CanEdit,
+		}
+	}
+
+	return list, nil
+}
+
+func (t *grpcTransport) updateSharedSecret(ctx context.Context, ID int64, sealed *model.Secret) (int64, error) {
+	req := secretToProto(sealed)
+	req.Id = ID
+
+	res, err := t.client.UpdateSharedSecret(ctx, req)
+	if err != nil {
+		logger.Log.Error("failed to update shared secret", zap.Error(err))
+		return 0, shareStatus(err, storage.ErrSecretNotFound)
+	}
+
+	return res.Revision, nil
+}
+
+func (t *grpcTransport) createOneTimeSecret(ctx context.Context, secret *model.OneTimeSecret) (*model.OneTimeSecret, error) {
+	res, err := t.client.CreateOneTimeSecret(ctx, &pb.OneTimeSecret{
+		Payload:   secret.Payload,
+		MaxViews:  int32(secret.MaxViews),
+		ExpiresAt: timestamppb.New(secret.ExpiresAt),
+	})
+	if err != nil {
+		logger.Log.Error("failed to create one-time secret", zap.Error(err))
+		return nil, fromStatus(err, ErrInternal)
+	}
+
+	return &model.OneTimeSecret{ID: res.Id, ExpiresAt: res.ExpiresAt.AsTime()}, nil
+}
+
+func (t *grpcTransport) getOneTimeSecret(ctx context.Context, ID string) (*model.OneTimeSecret, error) {
+	res, err := t.client.GetOneTimeSecret(ctx, &pb.OneTimeSecretRequest{Id: ID})
+	if err != nil {
+		logger.Log.Error("failed to get one-time secret", zap.Error(err))
+		return nil, fromStatus(err, storage.ErrOneTimeNotFound)
+	}
+
+	return &model.OneTimeSecret{
+		ID:        res.Id,
+		Payload:   res.Payload,
+		MaxViews:  int(res.MaxViews),
+		Views:     int(res.Views),
+		ExpiresAt: res.ExpiresAt.AsTime(),
+	}, nil
+}
+
+// emergencyStatus maps the statuses of the emergency access calls.
+func emergencyStatus(err error) error {
+	switch status.Code(err) {
+	case codes.InvalidArgument:
+		return ErrRecipientNotFound
+	case codes.AlreadyExists:
+		return storage.ErrEmergencyExists
+	case codes.FailedPrecondition:
+		return storage.ErrEmergencyNotPending
+	case codes.PermissionDenied:
+		return storage.ErrEmergencyWaiting
+	}
+	return fromStatus(err, storage.ErrEmergencyNotFound)
+}
+
+func (t *grpcTransport) listEmergencyGrants(ctx context.Context) ([]model.EmergencyGrant, error) {
+	res, err := t.client.ListEmergencyGrants(ctx, &emptypb.Empty{})
+	if err != nil {
+		logger.Log.Error("failed to list emergency grants", zap.Error(err))
+		return nil, emergencyStatus(err)
+	}
+
+	list := make([]model.EmergencyGrant, len(res.Grants))
+	for i, v := range res.Grants {
+		list[i] = *emergencyFromProto(v)
+	}
+
+	return list, nil
+}
+
+func (t *grpcTransport) createEmergencyGrant(ctx context.Context, grant *model.EmergencyGrant) (int64, error) {
+	res, err := t.client.CreateEmergencyGrant(ctx, &pb.EmergencyGrant{
+		GranteeId: grant.GranteeID,
+		WaitDays:  int32(grant.WaitDays),
+		Key:       grant.Key,
+		VaultKey:  grant.VaultKey,
+	})
+	if err != nil {
+		logger.Log.Error("failed to create emergency grant", zap.Error(err))
+		return 0, emergencyStatus(err)
+	}
+
+	return res.Id, nil
+}
+
+func (t *grpcTransport) deleteEmergencyGrant(ctx context.Context, ID int64) error {
+	_, err := t.client.DeleteEmergencyGrant(ctx, &pb.EmergencyRequest{Id: ID})
+	if err != nil {
+		logger.Log.Error("failed to delete emergency grant", zap.Error(err))
+		return emergencyStatus(err)
+	}
+
+	return nil
+}
+
+func (t *grpcTransport) requestEmergencyAccess(ctx context.Context, ID int64) (*model.EmergencyGrant, error) {
+	res, err := t.client.RequestEmergencyAccess(ctx, &pb.EmergencyRequest{Id: ID})
+	if err != nil {
+		logger.Log.Error("failed to request emergency access", zap.Error(err))
+		return nil, emergencyStatus(err)
+	}
+
+	return emergencyFromProto(res), nil
+}
+
+func (t *grpcTransport) rejectEmergencyAccess(ctx context.Context, ID int64) error {
+	_, err := t.client.RejectEmergencyAccess(ctx, &pb.EmergencyRequest{Id: ID})
+	if err != nil {
+		logger.Log.Error("failed to reject emergency access", zap.Error(err))
+		return emergencyStatus(err)
+	}
+
+	return nil
+}
+
+func (t *grpcTransport) getEmergencyVault(ctx context.Context, ID int64) (*model.EmergencyVault, error) {
+	res, err := t.client.GetEmergencyVault(ctx, &pb.EmergencyRequest{Id: ID})
+	if err != nil {
+		logger.Log.Error("failed to get emergency vault", zap.Error(err))
+		return nil, emergencyStatus(err)
+	}
+
+	vault := &model.EmergencyVault{
+		Grant:   *emergencyFromProto(res.Grant),
+		Secrets: make([]model.Secret, len(res.Secrets)),
+	}
+	for i, v := range res.Secrets {
+		vault.Secrets[i] = *secretFromProto(v)
+	}
+
+	return vault, nil
+}
+
+func (t *grpcTransport) listVersions(ctx context.Context, ID int64) ([]model.SecretVersion, error) {
+	res, err := t.client.ListVersions(ctx, &pb.SecretRequest{Id: ID})
+	if err != nil {
+		logger.Log.Error("failed to list versions", zap.Error(err))
+		return nil, fromStatus(err, storage.ErrSecretNotFound)
+	}
+
+	list := make([]model.SecretVersion, len(res.Versions))
+	for i, v := range res.Versions {
+		list[i] = *versionFromProto(v)
+	}
+
+	return list, nil
+}
+
+func (t *grpcTransport) getVersion(ctx context.Context, ID, version int64) (*model.SecretVersion, error) {
+	res, err := t.client.GetVersion(ctx, &pb.VersionRequest{Id: ID, Version: version})
+	if err != nil {
+		logger.Log.Error("failed to get version", zap.Error(err))
+		return nil, fromStatus(err, storage.ErrVersionNotFound)
+	}
+
+	return versionFromProto(res), nil
+}
+
+func (t *grpcTransport) restoreVersion(ctx context.Context, ID, version int64) error {
+	_, err := t.client.RestoreVersion(ctx, &pb.VersionRequest{Id: ID, Version: version})
+	if err != nil {
+		logger.Log.Error("failed to restore version", zap.Error(err))
+		return fromStatus(err, storage.ErrVersionNotFound)
+	}
+
+	return nil
+}
+
+// changes collects the streamed sync events; the cursor comes last.
+func (t *grpcTransport) changes(ctx context.Context, since int64) (*model.Changes, error) {
+	stream, err := t.client.Sync(ctx, &pb.SyncRequest{Since: since})
+	if err != nil {
+		logger.Log.Error("failed to get changes", zap.Error(err))
+		return nil, fromStatus(err, storage.ErrSecretNotFound)
+	}
+
+	changes := &model.Changes{}
+	for {
+		event, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			logger.Log.Error("failed to get changes", zap.Error(err))
+			return nil, fromStatus(err, storage.ErrSecretNotFound)
+		}
+
+		switch e := event.Event.(type) {
+		case *pb.SyncEvent_Updated:
+			changes.Updated = append(changes.Updated, *secretFromProto(e.Updated))
+		case *pb.SyncEvent_Deleted:
+			changes.Deleted = append(changes.Deleted, e.Deleted)
+		case *pb.SyncEvent_Cursor:
+			changes.Cursor = e.Cursor
+		}
+	}
+
+	return changes, nil
+}
+
+func (t *grpcTransport) putBlob(ctx context.Context, ID int64, sealed *model.Secret, r io.Reader) (int64, error) {
+	// cancelling aborts the upload, the server then discards what it got
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stream, err := t.client.PutBlob(ctx)
+	if err != nil {
+		logger.Log.Error("failed to upload file", zap.Error(err))
+		return 0, fromStatus(err, storage.ErrSecretNotFound)
+	}
+
+	// the first chunk carries the secret, even if there is no data
+	chunk := &pb.BlobChunk{Id: ID, Secret: secretToProto(sealed)}
+	buf := make([]byte, grpcChunkSize)
+	for {
+		n, err := io.ReadFull(r, buf)
+		if n > 0 || chunk.Secret != nil {
+			chunk.Data = buf[:n]
+			if err := stream.Send(chunk); err != nil {
+				// io.EOF means the server ended the call, CloseAndRecv has the reason
+				if !errors.Is(err, io.EOF) {
+					logger.Log.Error("failed to upload file", zap.Error(err))
+					return 0, fromStatus(err, storage.ErrSecretNotFound)
+				}
+				break
+			}
+			chunk = &pb.BlobChunk{Id: ID}
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	res, err := stream.CloseAndRecv()
+	if err != nil {
+		logger.Log.Error("failed to upload file", zap.Error(err))
+		if status.Code(err) == codes.InvalidArgument {
+			return 0, storage.ErrNotBinary
+		}
+		return 0, fromStatus(err, storage.ErrSecretNotFound)
+	}
+
+	return res.Revision, nil
+}
+
+func (t *grpcTransport) getBlob(ctx context.Context, ID int64) (io.ReadCloser, error) {
+	ctx, cancel := context.WithCancel(ctx)
+
+	stream, err := t.client.GetBlob(ctx, &pb.SecretRequest{Id: ID})
+	if err != nil {
+		cancel()
+		logger.Log.Error("failed to download file", zap.Error(err))
+		return nil, fromStatus(err, storage.ErrBlobNotFound)
+	}
+
+	// receive the first chunk, so a missing blob is reported right away
+	first, err := stream.Recv()
+	if err != nil && !errors.Is(err, io.EOF) {
+		cancel()
+		logger.Log.Error("failed to download file", zap.Error(err))
+		return nil, fromStatus(err, storage.ErrBlobNotFound)
+	}
+
+	blob := &blobReader{stream: stream, cancel: cancel}
+	if first != nil {
+		blob.data = first.Data
+	} else {
+		blob.done = true
+	}
+
+	return blob, nil
+}
+
+// blobReader reads the data of streamed blob chunks.
+type blobReader struct {
+	stream pb.Keeper_GetBlobClient
+	cancel context.CancelFunc
+	data   []byte
+	done   bool
+}
+
+func (b *blobReader) Read(p []byte) (int, error) {
+	for len(b.data) == 0 {
+		if b.done {
+			return 0, io.EOF
+		}
+
+		chunk, err := b.stream.Recv()
+		if errors.Is(err, io.EOF) {
+			b.done = true
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		b.data = chunk.Data
+	}
+
+	n := copy(p, b.data)
+	b.data = b.data[n:]
+
+	return n, nil
+}
+
+func (b *blobReader) Close() error {
+	b.cancel()
+	return nil
+}
+
+func (t *grpcTransport) setCredentials(sid string) {
+	t.sid = sid
+}
+
+func (t *grpcTransport) clearCredentials() {
+	t.sid = ""
+}
+
+func secretToProto(secret *model.Secret) *pb.Secret {
+	return &pb.Secret{
+		Id:       secret.ID,
+		Name:     secret.Name,
+		Type:     uint32(secret.Type),
+		Payload:  secret.Payload,
+		Meta:     secret.Meta,
+		Key:      secret.Key,
+		Uuid:     secret.UUID,
+		Revision: secret.Revision,
+		FolderId: secret.FolderID,
+		Favorite: secret.Favorite,
+		Tags:     secret.Tags,
+	}
+}
+
+func secretFromProto(secret *pb.Secret) *model.Secret {
+	return &model.Secret{
+		ID:        secret.Id,
+		Name:      secret.Name,
+		Type:      model.ResourceType(secret.Type),
+		Payload:   secret.Payload,
+		Meta:      secret.Meta,
+		Key:       secret.Key,
+		UUID:      secret.Uuid,
+		Revision:  secret.Revision,
+		UpdatedAt: secret.UpdatedAt.AsTime(),
+		FolderID:  secret.FolderId,
+		Favorite:  secret.Favorite,
+		Tags:      secret.Tags,
+	}
+}
+
+func emergencyFromProto(grant *pb.EmergencyGrant) *model.EmergencyGrant {
+	res := &model.EmergencyGrant{
+		ID:           grant.Id,
+		GrantorID:    grant.GrantorId,
+		GrantorLogin: grant.GrantorLogin,
+		GranteeID:    grant.GranteeId,
+		GranteeLogin: grant.GranteeLogin,
+		WaitDays:     int(grant.WaitDays),
+		Key:          grant.Key,
+		VaultKey:     grant.VaultKey,
+		CreatedAt:    grant.CreatedAt.AsTime(),
+	}
+	if grant.RequestedAt != nil {
+		requestedAt := grant.RequestedAt.AsTime()
+		res.RequestedAt = &requestedAt
+	}
+
+	return res
+}
+
+func versionFromProto(v *pb.SecretVersion) *model.SecretVersion {
+	return &model.SecretVersion{
+		SecretID:  v.SecretId,
+		Version:   v.Version,
+		Name:      v.Name,
+		Type:      model.ResourceType(v.Type),
+		Payload:   v.Payload,
+		Meta:      v.Meta,
+		Key:       v.Key,
+		UUID:      v.Uuid,
+		CreatedAt: v.CreatedAt.AsTime(),
+	}
+}
@@ -0,0 +1,101 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	pb "github.com/nbvehbq/go-password-keeper/internal/proto"
+	"github.com/nbvehbq/go-password-keeper/internal/storage"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// fakeKeeper answers the secret calls of the gRPC API for the session
+// "sid" with the status errors the server uses.
+type fakeKeeper struct {
+	pb.UnimplementedKeeperServer
+}
+
+func (fakeKeeper) authorized(ctx context.Context) error {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if values := md.Get("authorization"); len(values) == 0 || values[0] != "sid" {
+		return status.Error(codes.Unauthenticated, "session not found")
+	}
+	return nil
+}
+
+func (k fakeKeeper) GetSecret(ctx context.Context, req *pb.SecretRequest) (*pb.Secret, error) {
+	if err := k.authorized(ctx); err != nil {
+		return nil, err
+	}
+	if req.Id != 1 {
+		return nil, status.Error(codes.NotFound, storage.ErrSecretNotFound.Error())
+	}
+	return &pb.Secret{Id: 1, Name: "mail", Payload: []byte("p"), Revision: 3}, nil
+}
+
+func (k fakeKeeper) UpdateSecret(ctx context.Context, req *pb.Secret) (*pb.UpdateSecretResponse, error) {
+	if err := k.authorized(ctx); err != nil {
+		return nil, err
+	}
+	if req.Revision != 3 {
+		st, _ := status.New(codes.FailedPrecondition, storage.ErrRevisionMismatch.Error()).
+			WithDetails(&pb.RevisionConflict{Revision: 3})
+		return nil, st.Err()
+	}
+	return &pb.UpdateSecretResponse{Id: req.Id, Revision: 4}, nil
+}
+
+func TestGRPCTransport(t *testing.T) {
+	ctx := context.Background()
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	srv := grpc.NewServer()
+	pb.RegisterKeeperServer(srv, fakeKeeper{})
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	tr, err := newGRPCTransport(lis.Addr().String(), nil)
+	if err != nil {
+		t.Fatalf("newGRPCTransport: %v", err)
+	}
+	t.Cleanup(func() { tr.conn.Close() })
+
+	if _, err := tr.getSecret(ctx, 1); !errors.Is(err, ErrUnauthorized) {
+		t.Fatalf("without a session: got %v, want %v", err, ErrUnauthorized)
+	}
+
+	tr.setCredentials("sid")
+	secret, err := tr.getSecret(ctx, 1)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if secret.Name != "mail" || string(secret.Payload) != "p" || secret.Revision != 3 {
+		t.Errorf("secret %+v", secret)
+	}
+	if _, err := tr.getSecret(ctx, 2); !errors.Is(err, storage.ErrSecretNotFound) {
+		t.Errorf("missing secret: got %v, want %v", err, storage.ErrSecretNotFound)
+	}
+
+	var conflict *ConflictError
+	secret.Revision = 2
+	if _, err := tr.updateSecret(ctx, 1, secret); !errors.As(err, &conflict) || conflict.Revision != 3 {
+		t.Errorf("stale update: got %v, want a conflict at revision 3", err)
+	}
+	secret.Revision = 3
+	if revision, err := tr.updateSecret(ctx, 1, secret); err != nil || revision != 4 {
+		t.Errorf("update: revision %d, %v", revision, err)
+	}
+
+	srv.Stop()
+	if _, err := tr.getSecret(ctx, 1); !isOffline(err) {
+		t.Errorf("server stopped: got %v, want an unreachable server", err)
+	}
+}
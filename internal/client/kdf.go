@@ -0,0 +1,74 @@
+package client
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/hkdf"
+)
+
+// Argon2id parameters for deriving the master key from the master password.
+const (
+	kdfTime    = 3
+	kdfMemory  = 64 * 1024
+	kdfThreads = 4
+	kdfKeyLen  = 32
+	saltSize   = 16
+)
+
+// masterKeyID marks envelopes sealed directly with a password-derived key.
+var masterKeyID [keyIDSize]byte
+
+// masterKeys are derived from the master password. authKey is sent to the
+// server instead of the password, vaultKey never leaves the client and
+// encrypts the user's private key, cacheKey encrypts the local vault cache.
+type masterKeys struct {
+	authKey  []byte
+	vaultKey []byte
+	cacheKey []byte
+}
+
+func newSalt() ([]byte, error) {
+	salt := make([]byte, saltSize)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, err
+	}
+	return salt, nil
+}
+
+func deriveMasterKeys(password string, salt []byte) (*masterKeys, error) {
+	master := argon2.IDKey([]byte(password), salt, kdfTime, kdfMemory, kdfThreads, kdfKeyLen)
+
+	keys := &masterKeys{
+		authKey:  make([]byte, kdfKeyLen),
+		vaultKey: make([]byte, kdfKeyLen),
+		cacheKey: make([]byte, kdfKeyLen),
+	}
+	if _, err := io.ReadFull(hkdf.New(sha256.New, master, salt, []byte("keeper auth")), keys.authKey); err != nil {
+		return nil, err
+	}
+	if _, err := io.ReadFull(hkdf.New(sha256.New, master, salt, []byte("keeper vault")), keys.vaultKey); err != nil {
+		return nil, err
+	}
+	if _, err := io.ReadFull(hkdf.New(sha256.New, master, salt, []byte("keeper cache")), keys.cacheKey); err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+// authPassword is what the server stores (bcrypt-hashed) as the password.
+func (k *masterKeys) authPassword() string {
+	return hex.EncodeToString(k.authKey)
+}
+
+func (k *masterKeys) sealVaultKey(privateKeyPEM []byte) ([]byte, error) {
+	return seal(k.vaultKey, masterKeyID, privateKeyPEM)
+}
+
+func (k *masterKeys) openVaultKey(data []byte) ([]byte, error) {
+	return open(k.vaultKey, data)
+}
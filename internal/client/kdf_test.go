@@ -0,0 +1,117 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestDeriveMasterKeys(t *testing.T) {
+	salt := bytes.Repeat([]byte{1}, saltSize)
+
+	keys, err := deriveMasterKeys("correct horse", salt)
+	if err != nil {
+		t.Fatalf("derive: %v", err)
+	}
+
+	same, err := deriveMasterKeys("correct horse", salt)
+	if err != nil {
+		t.Fatalf("derive: %v", err)
+	}
+	if !bytes.Equal(keys.authKey, same.authKey) || !bytes.Equal(keys.vaultKey, same.vaultKey) {
+		t.Error("keys differ for the same password and salt")
+	}
+	if bytes.Equal(keys.authKey, keys.vaultKey) {
+		t.Error("the server learns the vault key")
+	}
+	if strings.Contains(keys.authPassword(), "correct horse") {
+		t.Error("the password is sent to the server")
+	}
+
+	tests := []struct {
+		name     string
+		password string
+		salt     []byte
+	}{
+		{name: "other password", password: "correct horse!", salt: salt},
+		{name: "other salt", password: "correct horse", salt: bytes.Repeat([]byte{2}, saltSize)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			other, err := deriveMasterKeys(tt.password, tt.salt)
+			if err != nil {
+				t.Fatalf("derive: %v", err)
+			}
+			if bytes.Equal(keys.authKey, other.authKey) || bytes.Equal(keys.vaultKey, other.vaultKey) {
+				t.Error("same keys")
+			}
+		})
+	}
+}
+
+func TestVaultKeyRoundTrip(t *testing.T) {
+	salt, err := newSalt()
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, err := deriveMasterKeys("correct horse", salt)
+	if err != nil {
+		t.Fatalf("derive: %v", err)
+	}
+
+	sealed, err := keys.sealVaultKey([]byte("private key"))
+	if err != nil {
+		t.Fatalf("seal: %v", err)
+	}
+	got, err := keys.openVaultKey(sealed)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if string(got) != "private key" {
+		t.Errorf("got %q, want %q", got, "private key")
+	}
+
+	wrong, err := deriveMasterKeys("wrong", salt)
+	if err != nil {
+		t.Fatalf("derive: %v", err)
+	}
+	if _, err := wrong.openVaultKey(sealed); err == nil {
+		t.Error("vault key opened with a wrong password")
+	}
+}
+
+// An account registered on one machine logs in on another with the
+// password alone, and no key is written to disk.
+func TestLoginOnAnotherMachine(t *testing.T) {
+	ctx := context.Background()
+	api := newFakeAPI(t)
+
+	first := newAPIClient(t, api)
+	if err := first.Register(ctx, "alice", "correct horse"); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	if api.users["alice"].Password == "correct horse" {
+		t.Error("the password is sent to the server")
+	}
+
+	second := newAPIClient(t, api)
+	if err := second.Login(ctx, "alice", "wrong"); !errors.Is(err, ErrUnauthorized) {
+		t.Fatalf("wrong password: got %v, want %v", err, ErrUnauthorized)
+	}
+	if err := second.Login(ctx, "alice", "correct horse"); err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	if second.privateKey == nil || !second.privateKey.Equal(first.privateKey) {
+		t.Error("private key differs from the registered one")
+	}
+
+	for _, c := range []*Client{first, second} {
+		if _, err := os.Stat(c.cfg.KeyPath + "alice-cert.pem"); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("key file in %s: %v", c.cfg.KeyPath, err)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/nbvehbq/go-password-keeper/internal/model"
+)
+
+// Changes made while the server is down are queued and pushed by the next
+// sync; an edit of a secret changed meanwhile is kept as a copy.
+func TestOfflineChangesAreSynced(t *testing.T) {
+	ctx := context.Background()
+	api := newFakeAPI(t)
+
+	c := newAPIClient(t, api)
+	if err := c.Register(ctx, "alice", "correct horse"); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	mailID, err := c.CreateSecret(ctx, &model.Secret{Name: "mail", Type: model.TextType, Payload: []byte("v1")})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	mail, err := c.GetSecret(ctx, mailID)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+
+	other := newAPIClient(t, api)
+	if err := other.Login(ctx, "alice", "correct horse"); err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	if _, err := other.UpdateSecret(ctx, mailID, &model.Secret{Name: "mail", Type: model.TextType,
+		Payload: []byte("theirs"), Revision: 1}); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+
+	api.down.Store(true)
+
+	if _, err := c.GetSecret(ctx, mailID); err != nil {
+		t.Fatalf("get from cache: %v", err)
+	}
+	if !c.Offline() {
+		t.Error("not offline while the server is down")
+	}
+
+	bankID, err := c.CreateSecret(ctx, &model.Secret{Name: "bank", Type: model.TextType, Payload: []byte("pin")})
+	if err != nil {
+		t.Fatalf("create offline: %v", err)
+	}
+	if bankID >= 0 {
+		t.Errorf("offline secret got ID %d, want a local one", bankID)
+	}
+	mail.Payload = []byte("mine")
+	if _, err := c.UpdateSecret(ctx, mailID, mail); err != nil {
+		t.Fatalf("update offline: %v", err)
+	}
+
+	bank, err := c.GetSecret(ctx, bankID)
+	if err != nil {
+		t.Fatalf("get offline secret: %v", err)
+	}
+	if string(bank.Payload) != "pin" {
+		t.Errorf("offline secret: payload %q, want %q", bank.Payload, "pin")
+	}
+
+	if _, err := c.Sync(ctx); !errors.Is(err, ErrOffline) {
+		t.Fatalf("sync while down: got %v, want %v", err, ErrOffline)
+	}
+	if ops, err := c.cache.pending(); err != nil || len(ops) != 2 {
+		t.Fatalf("queued changes: %d, %v, want 2", len(ops), err)
+	}
+
+	api.down.Store(false)
+
+	report, err := c.Sync(ctx)
+	if err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	if c.Offline() {
+		t.Error("still offline after sync")
+	}
+	if report.Pushed != 1 || len(report.Conflicts) != 1 {
+		t.Fatalf("report: %+v, want 1 pushed and 1 conflict", report)
+	}
+	if ops, err := c.cache.pending(); err != nil || len(ops) != 0 {
+		t.Errorf("queued changes after sync: %d, %v", len(ops), err)
+	}
+
+	// the server keeps the other change, the local one is a copy
+	got, err := c.GetSecret(ctx, mailID)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if string(got.Payload) != "theirs" {
+		t.Errorf("changed on server: payload %q, want %q", got.Payload, "theirs")
+	}
+	copied, err := c.GetSecret(ctx, report.Conflicts[0].CopyID)
+	if err != nil {
+		t.Fatalf("get copy: %v", err)
+	}
+	if string(copied.Payload) != "mine" {
+		t.Errorf("conflict copy: payload %q, want %q", copied.Payload, "mine")
+	}
+
+	// the pushed secret is cached under its ID on the server only
+	if _, ok, _ := c.cache.secret(bankID); ok {
+		t.Error("secret still cached under its local ID")
+	}
+	var pushed int64
+	api.mu.Lock()
+	for id, secret := range api.secrets {
+		if secret.Name == "bank" {
+			pushed = id
+		}
+	}
+	api.mu.Unlock()
+	if _, ok, err := c.cache.secret(pushed); err != nil || !ok {
+		t.Errorf("pushed secret %d not cached: %v, %v", pushed, ok, err)
+	}
+}
+
+// A restarted client unlocks the cached vault with the master password
+// while the server is down.
+func TestLoginOffline(t *testing.T) {
+	ctx := context.Background()
+	api := newFakeAPI(t)
+
+	c := newAPIClient(t, api)
+	if err := c.Register(ctx, "alice", "correct horse"); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	id, err := c.CreateSecret(ctx, &model.Secret{Name: "mail", Type: model.TextType, Payload: []byte("p")})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	api.down.Store(true)
+
+	restarted, err := NewClient(ctx, c.cfg)
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	t.Cleanup(func() { restarted.Close() })
+
+	if err := restarted.Login(ctx, "alice", "wrong"); !errors.Is(err, ErrUnauthorized) {
+		t.Fatalf("wrong password: got %v, want %v", err, ErrUnauthorized)
+	}
+	if err := restarted.Login(ctx, "alice", "correct horse"); err != nil {
+		t.Fatalf("login offline: %v", err)
+	}
+	if !restarted.Offline() {
+		t.Error("not offline")
+	}
+
+	secret, err := restarted.GetSecret(ctx, id)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if string(secret.Payload) != "p" {
+		t.Errorf("payload %q, want %q", secret.Payload, "p")
+	}
+}
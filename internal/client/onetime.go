@@ -0,0 +1,106 @@
+package client
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/nbvehbq/go-password-keeper/internal/logger"
+	"github.com/nbvehbq/go-password-keeper/internal/model"
+	"go.uber.org/zap"
+)
+
+// oneTimePath prefixes the ID in one-time links. The key follows in the
+// fragment, which is never sent to the server.
+const oneTimePath = "/api/onetime/"
+
+// CreateOneTimeLink hands out a copy of a secret to someone without an
+// account. The copy is sealed with a fresh key that only the returned link
+// carries; the server deletes it after maxViews reads or at the returned
+// expiry, whichever comes first.
+func (c *Client) CreateOneTimeLink(ctx context.Context, ID int64, maxViews int, ttl time.Duration) (string, time.Time, error) {
+	secret, err := c.GetSecret(ctx, ID)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	if secret.Type == model.BinaryType {
+		return "", time.Time{}, ErrLinkFile
+	}
+
+	plaintext, err := json.Marshal(model.Secret{
+		Name:    secret.Name,
+		Type:    secret.Type,
+		Payload: secret.Payload,
+		Meta:    secret.Meta,
+	})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	key, err := newDataKey()
+	if err != nil {
+		logger.Log.Error("failed to generate key", zap.Error(err))
+		return "", time.Time{}, ErrGenerateKey
+	}
+	// the key isn't wrapped, so the envelope names no key
+	sealed, err := seal(key, [keyIDSize]byte{}, plaintext)
+	if err != nil {
+		logger.Log.Error("failed to encrypt data", zap.Error(err))
+		return "", time.Time{}, ErrEncrypt
+	}
+
+	created, err := c.transport.createOneTimeSecret(ctx, &model.OneTimeSecret{
+		Payload:   sealed,
+		MaxViews:  maxViews,
+		ExpiresAt: time.Now().Add(ttl),
+	})
+	if err != nil {
+		return "", time.Time{}, c.onlineOnly(err)
+	}
+
+	link := strings.TrimSuffix(c.cfg.Address, "/") + oneTimePath + url.PathEscape(created.ID) +
+		"#" + base64.RawURLEncoding.EncodeToString(key)
+
+	return link, created.ExpiresAt, nil
+}
+
+// OpenOneTimeLink reads the secret behind a link made by CreateOneTimeLink,
+// which counts as one of its views. No login is needed; the secret is read
+// from the server the client is configured for.
+func (c *Client) OpenOneTimeLink(ctx context.Context, link string) (*model.Secret, error) {
+	u, err := url.Parse(strings.TrimSpace(link))
+	if err != nil {
+		return nil, ErrInvalidLink
+	}
+
+	ID, ok := strings.CutPrefix(u.Path, oneTimePath)
+	if !ok || ID == "" || strings.Contains(ID, "/") {
+		return nil, ErrInvalidLink
+	}
+	key, err := base64.RawURLEncoding.DecodeString(u.Fragment)
+	if err != nil || len(key) != dataKeySize {
+		return nil, ErrInvalidLink
+	}
+
+	shared, err := c.transport.getOneTimeSecret(ctx, ID)
+	if err != nil {
+		return nil, c.onlineOnly(err)
+	}
+
+	plaintext, err := open(key, shared.Payload)
+	if err != nil {
+		logger.Log.Error("failed to decrypt data", zap.Error(err))
+		return nil, ErrDecrypt
+	}
+
+	var secret model.Secret
+	if err := json.Unmarshal(plaintext, &secret); err != nil {
+		logger.Log.Error("failed to decode one-time secret", zap.Error(err))
+		return nil, ErrDecrypt
+	}
+
+	return &secret, nil
+}
@@ -0,0 +1,93 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nbvehbq/go-password-keeper/internal/model"
+	"github.com/nbvehbq/go-password-keeper/internal/storage"
+)
+
+// A link opens on a client without an account, and the server never sees
+// the key in its fragment.
+func TestOneTimeLink(t *testing.T) {
+	ctx := context.Background()
+	api := newFakeAPI(t)
+
+	alice := newAPIClient(t, api)
+	if err := alice.Register(ctx, "alice", "correct horse"); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	id, err := alice.CreateSecret(ctx, &model.Secret{Name: "wifi", Type: model.TextType, Payload: []byte("hunter2")})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	link, expires, err := alice.CreateOneTimeLink(ctx, id, 1, time.Hour)
+	if err != nil {
+		t.Fatalf("create link: %v", err)
+	}
+	if !strings.HasPrefix(link, api.URL+oneTimePath) {
+		t.Errorf("link %q is not on %s", link, api.URL)
+	}
+	if until := time.Until(expires); until <= 0 || until > time.Hour {
+		t.Errorf("expires in %v, want within an hour", until)
+	}
+	api.mu.Lock()
+	for _, shared := range api.onetime {
+		if bytes.Contains(shared.Payload, []byte("hunter2")) || shared.MaxViews != 1 {
+			t.Errorf("stored one-time secret %+v", shared)
+		}
+	}
+	api.mu.Unlock()
+
+	_, fragment, _ := strings.Cut(link, "#")
+	tampered := strings.TrimSuffix(link, fragment) + strings.Repeat("A", len(fragment))
+
+	tests := []struct {
+		name    string
+		link    string
+		wantErr error
+	}{
+		{name: "no key", link: strings.TrimSuffix(link, "#"+fragment), wantErr: ErrInvalidLink},
+		{name: "other path", link: api.URL + "/api/secret/1#" + fragment, wantErr: ErrInvalidLink},
+		{name: "other key", link: tampered, wantErr: ErrDecrypt},
+	}
+
+	bob := newAPIClient(t, api)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := bob.OpenOneTimeLink(ctx, tt.link); !errors.Is(err, tt.wantErr) {
+				t.Errorf("got %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	// the tampered link used up the only view
+	api.mu.Lock()
+	api.onetime["onetime-1"].Views = 0
+	api.mu.Unlock()
+
+	secret, err := bob.OpenOneTimeLink(ctx, link)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if secret.Name != "wifi" || string(secret.Payload) != "hunter2" {
+		t.Errorf("opened %q with payload %q", secret.Name, secret.Payload)
+	}
+	if _, err := bob.OpenOneTimeLink(ctx, link); !errors.Is(err, storage.ErrOneTimeNotFound) {
+		t.Errorf("open twice: got %v, want %v", err, storage.ErrOneTimeNotFound)
+	}
+
+	file, err := alice.CreateSecret(ctx, &model.Secret{Name: "photo", Type: model.BinaryType, Payload: []byte("jpeg")})
+	if err != nil {
+		t.Fatalf("create file: %v", err)
+	}
+	if _, _, err := alice.CreateOneTimeLink(ctx, file, 1, time.Hour); !errors.Is(err, ErrLinkFile) {
+		t.Errorf("link to a file: got %v, want %v", err, ErrLinkFile)
+	}
+}
@@ -0,0 +1,1182 @@
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/nbvehbq/go-password-keeper/internal/logger"
+	"github.com/nbvehbq/go-password-keeper/internal/model"
+	"github.com/nbvehbq/go-password-keeper/internal/storage"
+	"go.uber.org/zap"
+)
+
+// restTransport talks to the REST API of the server.
+type restTransport struct {
+	client  *resty.Client
+	address string
+}
+
+func newRESTTransport(address string, tlsConf *tls.Config) *restTransport {
+	client := resty.New()
+	// client.SetDebug(true)
+	client.SetHeader("Accept", "application/json")
+	if tlsConf != nil {
+		client.SetTLSClientConfig(tlsConf)
+	}
+
+	return &restTransport{client: client, address: address}
+}
+
+func (t *restTransport) register(ctx context.Context, dto *model.RegisterDTO) (string, error) {
+	var payload struct {
+		SID string `json:"sid"`
+	}
+	res, err := t.client.R().
+		SetContext(ctx).
+		SetResult(&payload).
+		SetBody(dto).
+		Post(fmt.Sprintf("%s/api/user/register", t.address))
+
+	if err != nil {
+		logger.Log.Error("failed to register", zap.Error(err))
+		return "", ErrInternal
+	}
+
+	switch res.StatusCode() {
+	case 200:
+	case 409:
+		return "", ErrUserExists
+	default:
+		return "", ErrInternal
+	}
+
+	return payload.SID, nil
+}
+
+func (t *restTransport) prelogin(ctx context.Context, login string) ([]byte, error) {
+	var payload struct {
+		Salt []byte `json:"salt"`
+	}
+	res, err := t.client.R().
+		SetContext(ctx).
+		SetResult(&payload).
+		SetBody(map[string]string{"login": login}).
+		Post(fmt.Sprintf("%s/api/user/prelogin", t.address))
+
+	if err != nil {
+		logger.Log.Error("failed to prelogin", zap.Error(err))
+		if isOffline(err) {
+			return nil, ErrOffline
+		}
+		return nil, ErrInternal
+	}
+
+	switch res.StatusCode() {
+	case 200:
+	case 401:
+		return nil, ErrUnauthorized
+	default:
+		return nil, ErrInternal
+	}
+
+	return payload.Salt, nil
+}
+
+func (t *restTransport) login(ctx context.Context, login, password string) (string, []byte, error) {
+	var payload struct {
+		SID       string `json:"sid"`
+		VaultKey  []byte `json:"vault_key"`
+		Challenge string `json:"challenge"`
+	}
+	res, err := t.client.R().
+		SetContext(ctx).
+		SetResult(&payload).
+		SetBody(map[string]string{"login": login, "password": password}).
+		Post(fmt.Sprintf("%s/api/user/login", t.address))
+
+	if err != nil {
+		logger.Log.Error("failed to login", zap.Error(err))
+		return "", nil, ErrInternal
+	}
+
+	switch res.StatusCode() {
+	case 200:
+	case 401:
+		return "", nil, ErrUnauthorized
+	default:
+		return "", nil, ErrInternal
+	}
+
+	if payload.Challenge != "" {
+		return "", nil, &challengeError{challenge: payload.Challenge}
+	}
+
+	return payload.SID, payload.VaultKey, nil
+}
+
+func (t *restTransport) loginTwoFactor(ctx context.Context, challenge, code string) (string, []byte, error) {
+	var payload struct {
+		SID      string `json:"sid"`
+		VaultKey []byte `json:"vault_key"`
+	}
+	res, err := t.client.R().
+		SetContext(ctx).
+		SetResult(&payload).
+		SetBody(map[string]string{"challenge": challenge, "code": code}).
+		Post(fmt.Sprintf("%s/api/user/login/2fa", t.address))
+
+	if err != nil {
+		logger.Log.Error("failed to login", zap.Error(err))
+		return "", nil, ErrInternal
+	}
+
+	switch res.StatusCode() {
+	case 200:
+	case 401:
+		return "", nil, ErrInvalidCode
+	default:
+		return "", nil, ErrInternal
+	}
+
+	return payload.SID, payload.VaultKey, nil
+}
+
+func (t *restTransport) enrollTOTP(ctx context.Context) (*model.TOTPEnrollment, error) {
+	var enrollment model.TOTPEnrollment
+	res, err := t.client.R().
+		SetContext(ctx).
+		SetResult(&enrollment).
+		Post(fmt.Sprintf("%s/api/user/2fa/enroll", t.address))
+
+	if err != nil {
+		logger.Log.Error("failed to enroll totp", zap.Error(err))
+		return nil, err
+	}
+
+	switch res.StatusCode() {
+	case 200:
+	case 401:
+		return nil, ErrUnauthorized
+	case 409:
+		return nil, ErrTwoFactorState
+	default:
+		return nil, ErrInternal
+	}
+
+	return &enrollment, nil
+}
+
+func (t *restTransport) verifyTOTP(ctx context.Context, code string) ([]string, error) {
+	var payload struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}
+	res, err := t.client.R().
+		SetContext(ctx).
+		SetResult(&payload).
+		SetBody(map[string]string{"code": code}).
+		Post(fmt.Sprintf("%s/api/user/2fa/verify", t.address))
+
+	if err != nil {
+		logger.Log.Error("failed to verify totp", zap.Error(err))
+		return nil, err
+	}
+
+	switch res.StatusCode() {
+	case 200:
+	case 401:
+		return nil, t.unauthorized(res)
+	case 409:
+		return nil, ErrTwoFactorState
+	default:
+		return nil, ErrInternal
+	}
+
+	return payload.RecoveryCodes, nil
+}
+
+func (t *restTransport) disableTOTP(ctx context.Context, code string) error {
+	res, err := t.client.R().
+		SetContext(ctx).
+		SetBody(map[string]string{"code": code}).
+		Post(fmt.Sprintf("%s/api/user/2fa/disable", t.address))
+
+	if err != nil {
+		logger.Log.Error("failed to disable totp", zap.Error(err))
+		return err
+	}
+
+	switch res.StatusCode() {
+	case 204:
+	case 401:
+		return t.unauthorized(res)
+	case 409:
+		return ErrTwoFactorState
+	default:
+		return ErrInternal
+	}
+
+	return nil
+}
+
+// unauthorized tells a rejected code from a missing session: the
+// Authenticator middleware answers in plain text, handlers in JSON.
+func (t *restTransport) unauthorized(res *resty.Response) error {
+	if strings.HasPrefix(res.Header().Get("Content-Type"), "application/json") {
+		return ErrInvalidCode
+	}
+	return ErrUnauthorized
+}
+
+func (t *restTransport) updateKeys(ctx context.Context, dto *keysDTO) error {
+	res, err := t.client.R().
+		SetContext(ctx).
+		SetBody(dto).
+		Put(fmt.Sprintf("%s/api/user/keys", t.address))
+
+	if err != nil {
+		return err
+	}
+
+	if res.StatusCode() != 204 {
+		return fmt.Errorf("unexpected status %d", res.StatusCode())
+	}
+
+	return nil
+}
+
+func (t *restTransport) logout(ctx context.Context) error {
+	res, err := t.client.R().
+		SetContext(ctx).
+		Post(fmt.Sprintf("%s/api/user/logout", t.address))
+
+	if err != nil {
+		logger.Log.Error("failed to logout", zap.Error(err))
+		return ErrInternal
+	}
+
+	if res.StatusCode() == 401 {
+		return ErrUnauthorized
+	}
+
+	return nil
+}
+
+func (t *restTransport) listSessions(ctx context.Context) ([]model.Session, error) {
+	var result []model.Session
+	res, err := t.client.R().
+		SetContext(ctx).
+		SetResult(&result).
+		Get(fmt.Sprintf("%s/api/user/sessions", t.address))
+
+	if err != nil {
+		logger.Log.Error("failed to list sessions", zap.Error(err))
+		return nil, err
+	}
+
+	if res.StatusCode() == 401 {
+		return nil, ErrUnauthorized
+	}
+
+	return result, nil
+}
+
+func (t *restTransport) listAuditEvents(ctx context.Context, before int64, limit int) ([]model.AuditEvent, error) {
+	var result []model.AuditEvent
+	res, err := t.client.R().
+		SetContext(ctx).
+		SetResult(&result).
+		SetQueryParam("before", strconv.FormatInt(before, 10)).
+		SetQueryParam("limit", strconv.Itoa(limit)).
+		Get(fmt.Sprintf("%s/api/user/audit", t.address))
+
+	if err != nil {
+		logger.Log.Error("failed to list audit events", zap.Error(err))
+		return nil, err
+	}
+
+	switch res.StatusCode() {
+	case 200:
+	case 401:
+		return nil, ErrUnauthorized
+	default:
+		return nil, ErrInternal
+	}
+
+	return result, nil
+}
+
+func (t *restTransport) revokeSession(ctx context.Context, ID int64) error {
+	res, err := t.client.R().
+		SetContext(ctx).
+		Delete(fmt.Sprintf("%s/api/user/sessions/%d", t.address, ID))
+
+	if err != nil {
+		logger.Log.Error("failed to revoke session", zap.Error(err))
+		return err
+	}
+
+	switch res.StatusCode() {
+	case 401:
+		return ErrUnauthorized
+	case 404:
+		return ErrSessionNotFound
+	}
+
+	return nil
+}
+
+func (t *restTransport) listSecrets(ctx context.Context, filter model.SecretFilter) ([]model.Secret, error) {
+	query := make(map[string]string)
+	if filter.Type != 0 {
+		query["type"] = strconv.Itoa(int(filter.Type))
+	}
+	if filter.FolderID != 0 {
+		query["folder"] = strconv.FormatInt(filter.FolderID, 10)
+	}
+	if filter.TagID != 0 {
+		query["tag"] = strconv.FormatInt(filter.TagID, 10)
+	}
+	if filter.Favorite {
+		query["favorite"] = "true"
+	}
+
+	var result []model.Secret
+	res, err := t.client.R().
+		SetContext(ctx).
+		SetQueryParams(query).
+		SetResult(&result).
+		Get(fmt.Sprintf("%s/api/secret", t.address))
+
+	if err != nil {
+		logger.Log.Error("failed to list secrets", zap.Error(err))
+		return nil, err
+	}
+
+	if res.StatusCode() == 401 {
+		return nil, ErrUnauthorized
+	}
+
+	return result, nil
+}
+
+func (t *restTransport) getSecret(ctx context.Context, ID int64) (*model.Secret, error) {
+	var secret model.Secret
+	res, err := t.client.R().
+		SetContext(ctx).
+		SetResult(&secret).
+		Get(fmt.Sprintf("%s/api/secret/%d", t.address, ID))
+
+	if err != nil {
+		logger.Log.Error("failed to get secret", zap.Error(err))
+		return nil, err
+	}
+
+	switch res.StatusCode() {
+	case 401:
+		return nil, ErrUnauthorized
+	case 404:
+		return nil, storage.ErrSecretNotFound
+	}
+
+	return &secret, nil
+}
+
+func (t *restTransport) createSecret(ctx context.Context, sealed *model.Secret) (int64, error) {
+	var response struct {
+		ID int64 `json:"id"`
+	}
+	res, err := t.client.R().
+		SetContext(ctx).
+		SetResult(&response).
+		SetBody(sealed).
+		Post(fmt.Sprintf("%s/api/secret", t.address))
+
+	if err != nil {
+		logger.Log.Error("failed to create secret", zap.Error(err))
+		return 0, err
+	}
+
+	switch res.StatusCode() {
+	case 401:
+		return 0, ErrUnauthorized
+	case 409:
+		return 0, storage.ErrSecretExists
+	case 422:
+		return 0, placementError(res)
+	}
+
+	return response.ID, nil
+}
+
+func (t *restTransport) updateSecret(ctx context.Context, ID int64, sealed *model.Secret) (int64, error) {
+	var response struct {
+		ID       int64 `json:"id"`
+		Revision int64 `json:"revision"`
+	}
+	var conflict struct {
+		Revision int64 `json:"revision"`
+	}
+	res, err := t.client.R().
+		SetContext(ctx).
+		SetHeader("If-Match", fmt.Sprintf(`"%d"`, sealed.Revision)).
+		SetResult(&response).
+		SetError(&conflict).
+		SetBody(sealed).
+		Put(fmt.Sprintf("%s/api/secret/%d", t.address, ID))
+
+	if err != nil {
+		logger.Log.Error("failed to update secret", zap.Error(err))
+		return 0, err
+	}
+
+	switch res.StatusCode() {
+	case 401:
+		return 0, ErrUnauthorized
+	case 404:
+		return 0, storage.ErrSecretNotFound
+	case 412:
+		return 0, &ConflictError{Revision: conflict.Revision}
+	}
+
+	return response.Revision, nil
+}
+
+func (t *restTransport) deleteSecret(ctx context.Context, ID, revision int64) error {
+	var conflict struct {
+		Revision int64 `json:"revision"`
+	}
+	res, err := t.client.R().
+		SetContext(ctx).
+		SetHeader("If-Match", fmt.Sprintf(`"%d"`, revision)).
+		SetError(&conflict).
+		Delete(fmt.Sprintf("%s/api/secret/%d", t.address, ID))
+
+	if err != nil {
+		logger.Log.Error("failed to delete secret", zap.Error(err))
+		return err
+	}
+
+	switch res.StatusCode() {
+	case 401:
+		return ErrUnauthorized
+	case 404:
+		return storage.ErrSecretNotFound
+	case 412:
+		return &ConflictError{Revision: conflict.Revision}
+	}
+
+	return nil
+}
+
+func (t *restTransport) patchSecret(ctx context.Context, ID int64, patch *model.SecretPatch) error {
+	res, err := t.client.R().
+		SetContext(ctx).
+		SetBody(patch).
+		Patch(fmt.Sprintf("%s/api/secret/%d", t.address, ID))
+
+	if err != nil {
+		logger.Log.Error("failed to patch secret", zap.Error(err))
+		return err
+	}
+
+	switch res.StatusCode() {
+	case 401:
+		return ErrUnauthorized
+	case 404:
+		return storage.ErrSecretNotFound
+	case 422:
+		return placementError(res)
+	}
+
+	return nil
+}
+
+// placementError reads which of the folders or tags a request referred to
+// was not found.
+func placementError(res *resty.Response) error {
+	var body struct {
+		Err string `json:"error"`
+	}
+	if err := json.Unmarshal(res.Body(), &body); err == nil && body.Err == storage.ErrTagNotFound.Error() {
+		return storage.ErrTagNotFound
+	}
+	return storage.ErrFolderNotFound
+}
+
+func (t *restTransport) listFolders(ctx context.Context) ([]model.Folder, error) {
+	var result []model.Folder
+	res, err := t.client.R().
+		SetContext(ctx).
+		SetResult(&result).
+		Get(fmt.Sprintf("%s/api/folder", t.address))
+
+	if err != nil {
+		logger.Log.Error("failed to list folders", zap.Error(err))
+		return nil, err
+	}
+
+	if res.StatusCode() == 401 {
+		return nil, ErrUnauthorized
+	}
+
+	return result, nil
+}
+
+func (t *restTransport) createFolder(ctx context.Context, sealed *model.Folder) (int64, error) {
+	var response struct {
+		ID int64 `json:"id"`
+	}
+	res, err := t.client.R().
+		SetContext(ctx).
+		SetResult(&response).
+		SetBody(sealed).
+		Post(fmt.Sprintf("%s/api/folder", t.address))
+
+	if err != nil {
+		logger.Log.Error("failed to create folder", zap.Error(err))
+		return 0, err
+	}
+
+	switch res.StatusCode() {
+	case 401:
+		return 0, ErrUnauthorized
+	case 422:
+		return 0, storage.ErrFolderNotFound
+	}
+
+	return response.ID, nil
+}
+
+func (t *restTransport) deleteFolder(ctx context.Context, ID int64) error {
+	res, err := t.client.R().
+		SetContext(ctx).
+		Delete(fmt.Sprintf("%s/api/folder/%d", t.address, ID))
+
+	if err != nil {
+		logger.Log.Error("failed to delete folder", zap.Error(err))
+		return err
+	}
+
+	switch res.StatusCode() {
+	case 401:
+		return ErrUnauthorized
+	case 404:
+		return storage.ErrFolderNotFound
+	case 409:
+		return storage.ErrFolderNotEmpty
+	}
+
+	return nil
+}
+
+func (t *restTransport) listTags(ctx context.Context) ([]model.Tag, error) {
+	var result []model.Tag
+	res, err := t.client.R().
+		SetContext(ctx).
+		SetResult(&result).
+		Get(fmt.Sprintf("%s/api/tag", t.address))
+
+	if err != nil {
+		logger.Log.Error("failed to list tags", zap.Error(err))
+		return nil, err
+	}
+
+	if res.StatusCode() == 401 {
+		return nil, ErrUnauthorized
+	}
+
+	return result, nil
+}
+
+func (t *restTransport) createTag(ctx context.Context, sealed *model.Tag) (int64, error) {
+	var response struct {
+		ID int64 `json:"id"`
+	}
+	res, err := t.client.R().
+		SetContext(ctx).
+		SetResult(&response).
+		SetBody(sealed).
+		Post(fmt.Sprintf("%s/api/tag", t.address))
+
+	if err != nil {
+		logger.Log.Error("failed to create tag", zap.Error(err))
+		return 0, err
+	}
+
+	if res.StatusCode() == 401 {
+		return 0, ErrUnauthorized
+	}
+
+	return response.ID, nil
+}
+
+func (t *restTransport) getPublicKey(ctx context.Context, login string) (*model.PublicKey, error) {
+	var result model.PublicKey
+	res, err := t.client.R().
+		SetContext(ctx).
+		SetResult(&result).
+		SetPathParam("login", login).
+		Get(t.address + "/api/user/{login}/key")
+
+	if err != nil {
+		logger.Log.Error("failed to get public key", zap.Error(err))
+		return nil, err
+	}
+
+	switch res.StatusCode() {
+	case 401:
+		return nil, ErrUnauthorized
+	case 404:
+		return nil, ErrRecipientNotFound
+	}
+
+	return &result, nil
+}
+
+func (t *restTransport) shareSecret(ctx context.Context, share *model.Share) error {
+	res, err := t.client.R().
+		SetContext(ctx).
+		SetBody(share).
+		Post(fmt.Sprintf("%s/api/secret/%d/share", t.address, share.SecretID))
+
+	if err != nil {
+		logger.Log.Error("failed to share secret", zap.Error(err))
+		return err
+	}
+
+	switch res.StatusCode() {
+	case 400, 422:
+		return ErrRecipientNotFound
+	case 401:
+		return ErrUnauthorized
+	case 404:
+		return storage.ErrSecretNotFound
+	}
+
+	return nil
+}
+
+func (t *restTransport) listShares(ctx context.Context, ID int64) ([]model.Share, error) {
+	var result []model.Share
+	res, err := t.client.R().
+		SetContext(ctx).
+		SetResult(&result).
+		Get(fmt.Sprintf("%s/api/secret/%d/share", t.address, ID))
+
+	if err != nil {
+		logger.Log.Error("failed to list shares", zap.Error(err))
+		return nil, err
+	}
+
+	switch res.StatusCode() {
+	case 401:
+		return nil, ErrUnauthorized
+	case 404:
+		return nil, storage.ErrSecretNotFound
+	}
+
+	return result, nil
+}
+
+func (t *restTransport) rekeySecret(ctx context.Context, ID int64, rekey *model.SecretRekey) (int64, error) {
+	var response struct {
+		ID       int64 `json:"id"`
+		Revision int64 `json:"revision"`
+	}
+	var conflict struct {
+		Revision int64 `json:"revision"`
+	}
+	res, err := t.client.R().
+		SetContext(ctx).
+		SetHeader("If-Match", fmt.Sprintf(`"%d"`, rekey.Revision)).
+		SetResult(&response).
+		SetError(&conflict).
+		SetBody(rekey).
+		Post(fmt.Sprintf("%s/api/secret/%d/rekey", t.address, ID))
+
+	if err != nil {
+		logger.Log.Error("failed to rekey secret", zap.Error(err))
+		return 0, err
+	}
+
+	switch res.StatusCode() {
+	case 401:
+		return 0, ErrUnauthorized
+	case 404:
+		return 0, storage.ErrSecretNotFound
+	case 409:
+		return 0, storage.ErrSharesChanged
+	case 412:
+		return 0, &ConflictError{Revision: conflict.Revision}
+	}
+
+	return response.Revision, nil
+}
+
+func (t *restTransport) listSharedSecrets(ctx context.Context) ([]model.SharedSecret, error) {
+	var result []model.SharedSecret
+	res, err := t.client.R().
+		SetContext(ctx).
+		SetResult(&result).
+		Get(fmt.Sprintf("%s/api/secret/shared", t.address))
+
+	if err != nil {
+		logger.Log.Error("failed to list shared secrets", zap.Error(err))
+		return nil, err
+	}
+
+	if res.StatusCode() == 401 {
+		return nil, ErrUnauthorized
+	}
+
+	return result, nil
+}
+
+func (t *restTransport) updateSharedSecret(ctx context.Context, ID int64, sealed *model.Secret) (int64, error) {
+	var response struct {
+		ID       int64 `json:"id"`
+		Revision int64 `json:"revision"`
+	}
+	var conflict struct {
+		Revision int64 `json:"revision"`
+	}
+	res, err := t.client.R().
+		SetContext(ctx).
+		SetHeader("If-Match", fmt.Sprintf(`"%d"`, sealed.Revision)).
+		SetResult(&response).
+		SetError(&conflict).
+		SetBody(sealed).
+		Put(fmt.Sprintf("%s/api/secret/shared/%d", t.address, ID))
+
+	if err != nil {
+		logger.Log.Error("failed to update shared secret", zap.Error(err))
+		return 0, err
+	}
+
+	switch res.StatusCode() {
+	case 401:
+		return 0, ErrUnauthorized
+	case 403:
+		return 0, storage.ErrReadOnly
+	case 404:
+		return 0, storage.ErrSecretNotFound
+	case 412:
+		return 0, &ConflictError{Revision: conflict.Revision}
+	}
+
+	return response.Revision, nil
+}
+
+func (t *restTransport) createOneTimeSecret(ctx context.Context, secret *model.OneTimeSecret) (*model.OneTimeSecret, error) {
+	var result model.OneTimeSecret
+	res, err := t.client.R().
+		SetContext(ctx).
+		SetResult(&result).
+		SetBody(secret).
+		Post(fmt.Sprintf("%s/api/onetime", t.address))
+
+	if err != nil {
+		logger.Log.Error("failed to create one-time secret", zap.Error(err))
+		return nil, err
+	}
+
+	switch res.StatusCode() {
+	case 201:
+	case 401:
+		return nil, ErrUnauthorized
+	default:
+		return nil, ErrInternal
+	}
+
+	return &result, nil
+}
+
+func (t *restTransport) getOneTimeSecret(ctx context.Context, ID string) (*model.OneTimeSecret, error) {
+	var result model.OneTimeSecret
+	res, err := t.client.R().
+		SetContext(ctx).
+		SetResult(&result).
+		SetPathParam("id", ID).
+		Get(t.address + "/api/onetime/{id}")
+
+	if err != nil {
+		logger.Log.Error("failed to get one-time secret", zap.Error(err))
+		return nil, err
+	}
+
+	switch res.StatusCode() {
+	case 200:
+	case 404:
+		return nil, storage.ErrOneTimeNotFound
+	default:
+		return nil, ErrInternal
+	}
+
+	return &result, nil
+}
+
+func (t *restTransport) listEmergencyGrants(ctx context.Context) ([]model.EmergencyGrant, error) {
+	var result []model.EmergencyGrant
+	res, err := t.client.R().
+		SetContext(ctx).
+		SetResult(&result).
+		Get(fmt.Sprintf("%s/api/emergency", t.address))
+
+	if err != nil {
+		logger.Log.Error("failed to list emergency grants", zap.Error(err))
+		return nil, err
+	}
+
+	switch res.StatusCode() {
+	case 200:
+	case 401:
+		return nil, ErrUnauthorized
+	default:
+		return nil, ErrInternal
+	}
+
+	return result, nil
+}
+
+func (t *restTransport) createEmergencyGrant(ctx context.Context, grant *model.EmergencyGrant) (int64, error) {
+	var response struct {
+		ID int64 `json:"id"`
+	}
+	res, err := t.client.R().
+		SetContext(ctx).
+		SetResult(&response).
+		SetBody(grant).
+		Post(fmt.Sprintf("%s/api/emergency", t.address))
+
+	if err != nil {
+		logger.Log.Error("failed to create emergency grant", zap.Error(err))
+		return 0, err
+	}
+
+	switch res.StatusCode() {
+	case 201:
+	case 400, 422:
+		return 0, ErrRecipientNotFound
+	case 401:
+		return 0, ErrUnauthorized
+	case 409:
+		return 0, storage.ErrEmergencyExists
+	default:
+		return 0, ErrInternal
+	}
+
+	return response.ID, nil
+}
+
+func (t *restTransport) deleteEmergencyGrant(ctx context.Context, ID int64) error {
+	res, err := t.client.R().
+		SetContext(ctx).
+		Delete(fmt.Sprintf("%s/api/emergency/%d", t.address, ID))
+
+	if err != nil {
+		logger.Log.Error("failed to delete emergency grant", zap.Error(err))
+		return err
+	}
+
+	switch res.StatusCode() {
+	case 204:
+	case 401:
+		return ErrUnauthorized
+	case 404:
+		return storage.ErrEmergencyNotFound
+	default:
+		return ErrInternal
+	}
+
+	return nil
+}
+
+func (t *restTransport) requestEmergencyAccess(ctx context.Context, ID int64) (*model.EmergencyGrant, error) {
+	var result model.EmergencyGrant
+	res, err := t.client.R().
+		SetContext(ctx).
+		SetResult(&result).
+		Post(fmt.Sprintf("%s/api/emergency/%d/request", t.address, ID))
+
+	if err != nil {
+		logger.Log.Error("failed to request emergency access", zap.Error(err))
+		return nil, err
+	}
+
+	switch res.StatusCode() {
+	case 200:
+	case 401:
+		return nil, ErrUnauthorized
+	case 404:
+		return nil, storage.ErrEmergencyNotFound
+	default:
+		return nil, ErrInternal
+	}
+
+	return &result, nil
+}
+
+func (t *restTransport) rejectEmergencyAccess(ctx context.Context, ID int64) error {
+	res, err := t.client.R().
+		SetContext(ctx).
+		Post(fmt.Sprintf("%s/api/emergency/%d/reject", t.address, ID))
+
+	if err != nil {
+		logger.Log.Error("failed to reject emergency access", zap.Error(err))
+		return err
+	}
+
+	switch res.StatusCode() {
+	case 204:
+	case 401:
+		return ErrUnauthorized
+	case 404:
+		return storage.ErrEmergencyNotFound
+	case 409:
+		return storage.ErrEmergencyNotPending
+	default:
+		return ErrInternal
+	}
+
+	return nil
+}
+
+func (t *restTransport) getEmergencyVault(ctx context.Context, ID int64) (*model.EmergencyVault, error) {
+	var result model.EmergencyVault
+	res, err := t.client.R().
+		SetContext(ctx).
+		SetResult(&result).
+		Get(fmt.Sprintf("%s/api/emergency/%d/vault", t.address, ID))
+
+	if err != nil {
+		logger.Log.Error("failed to get emergency vault", zap.Error(err))
+		return nil, err
+	}
+
+	switch res.StatusCode() {
+	case 200:
+	case 401:
+		return nil, ErrUnauthorized
+	case 403:
+		return nil, storage.ErrEmergencyWaiting
+	case 404:
+		return nil, storage.ErrEmergencyNotFound
+	default:
+		return nil, ErrInternal
+	}
+
+	return &result, nil
+}
+
+func (t *restTransport) listVersions(ctx context.Context, ID int64) ([]model.SecretVersion, error) {
+	var result []model.SecretVersion
+	res, err := t.client.R().
+		SetContext(ctx).
+		SetResult(&result).
+		Get(fmt.Sprintf("%s/api/secret/%d/versions", t.address, ID))
+
+	if err != nil {
+		logger.Log.Error("failed to list versions", zap.Error(err))
+		return nil, err
+	}
+
+	switch res.StatusCode() {
+	case 401:
+		return nil, ErrUnauthorized
+	case 404:
+		return nil, storage.ErrSecretNotFound
+	}
+
+	return result, nil
+}
+
+func (t *restTransport) getVersion(ctx context.Context, ID, version int64) (*model.SecretVersion, error) {
+	var v model.SecretVersion
+	res, err := t.client.R().
+		SetContext(ctx).
+		SetResult(&v).
+		Get(fmt.Sprintf("%s/api/secret/%d/versions/%d", t.address, ID, version))
+
+	if err != nil {
+		logger.Log.Error("failed to get version", zap.Error(err))
+		return nil, err
+	}
+
+	switch res.StatusCode() {
+	case 401:
+		return nil, ErrUnauthorized
+	case 404:
+		return nil, storage.ErrVersionNotFound
+	}
+
+	return &v, nil
+}
+
+func (t *restTransport) restoreVersion(ctx context.Context, ID, version int64) error {
+	res, err := t.client.R().
+		SetContext(ctx).
+		Post(fmt.Sprintf("%s/api/secret/%d/versions/%d/restore", t.address, ID, version))
+
+	if err != nil {
+		logger.Log.Error("failed to restore version", zap.Error(err))
+		return err
+	}
+
+	switch res.StatusCode() {
+	case 401:
+		return ErrUnauthorized
+	case 404:
+		return storage.ErrVersionNotFound
+	}
+
+	return nil
+}
+
+func (t *restTransport) changes(ctx context.Context, since int64) (*model.Changes, error) {
+	var changes model.Changes
+	res, err := t.client.R().
+		SetContext(ctx).
+		SetQueryParam("since", strconv.FormatInt(since, 10)).
+		SetResult(&changes).
+		Get(fmt.Sprintf("%s/api/sync", t.address))
+
+	if err != nil {
+		logger.Log.Error("failed to get changes", zap.Error(err))
+		return nil, err
+	}
+
+	switch res.StatusCode() {
+	case 200:
+	case 401:
+		return nil, ErrUnauthorized
+	default:
+		return nil, ErrInternal
+	}
+
+	return &changes, nil
+}
+
+func (t *restTransport) putBlob(ctx context.Context, ID int64, sealed *model.Secret, r io.Reader) (int64, error) {
+	var response struct {
+		ID       int64 `json:"id"`
+		Revision int64 `json:"revision"`
+	}
+	var conflict struct {
+		Revision int64 `json:"revision"`
+	}
+
+	pr, pw := io.Pipe()
+	form := multipart.NewWriter(pw)
+	go func() {
+		pw.CloseWithError(writeBlobForm(form, sealed, r))
+	}()
+	// unblocks the writing goroutine if the request ends early
+	defer pr.Close()
+
+	res, err := t.client.R().
+		SetContext(ctx).
+		SetHeader("Content-Type", form.FormDataContentType()).
+		SetHeader("If-Match", fmt.Sprintf(`"%d"`, sealed.Revision)).
+		SetResult(&response).
+		SetError(&conflict).
+		SetBody(pr).
+		Put(fmt.Sprintf("%s/api/secret/%d/blob", t.address, ID))
+
+	if err != nil {
+		logger.Log.Error("failed to upload file", zap.Error(err))
+		return 0, err
+	}
+
+	switch res.StatusCode() {
+	case 200:
+	case 401:
+		return 0, ErrUnauthorized
+	case 404:
+		return 0, storage.ErrSecretNotFound
+	case 412:
+		return 0, &ConflictError{Revision: conflict.Revision}
+	case 413:
+		return 0, ErrTooLarge
+	case 422:
+		return 0, storage.ErrNotBinary
+	default:
+		return 0, ErrInternal
+	}
+
+	return response.Revision, nil
+}
+
+// writeBlobForm writes the multipart body of putBlob: the sealed secret,
+// then the file contents.
+func writeBlobForm(form *multipart.Writer, sealed *model.Secret, r io.Reader) error {
+	part, err := form.CreateFormField("secret")
+	if err != nil {
+		return err
+	}
+	if err := json.NewEncoder(part).Encode(sealed); err != nil {
+		return err
+	}
+
+	part, err = form.CreateFormFile("blob", "blob")
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(part, r); err != nil {
+		return err
+	}
+
+	return form.Close()
+}
+
+func (t *restTransport) getBlob(ctx context.Context, ID int64) (io.ReadCloser, error) {
+	res, err := t.client.R().
+		SetContext(ctx).
+		SetDoNotParseResponse(true).
+		Get(fmt.Sprintf("%s/api/secret/%d/blob", t.address, ID))
+
+	if err != nil {
+		logger.Log.Error("failed to download file", zap.Error(err))
+		return nil, err
+	}
+
+	switch res.StatusCode() {
+	case 200:
+		return res.RawBody(), nil
+	case 401:
+		err = ErrUnauthorized
+	case 404:
+		err = storage.ErrBlobNotFound
+	default:
+		err = ErrInternal
+	}
+	res.RawBody().Close()
+
+	return nil, err
+}
+
+func (t *restTransport) setCredentials(sid string) {
+	t.client.Cookies = nil
+	t.client.SetHeader("Authorization", sid)
+	t.client.SetCookie(&http.Cookie{
+		Name:     "session",
+		Value:    sid,
+		Path:     "/",
+		MaxAge:   3600,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+func (t *restTransport) clearCredentials() {
+	t.client.Cookies = nil
+	t.client.Header.Del("Authorization")
+}
@@ -0,0 +1,69 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/nbvehbq/go-password-keeper/internal/model"
+)
+
+// Two machines edit the same secret: the one that saved second learns the
+// revision it has missed instead of overwriting the other's change.
+func TestConcurrentEdits(t *testing.T) {
+	ctx := context.Background()
+	api := newFakeAPI(t)
+
+	first := newAPIClient(t, api)
+	if err := first.Register(ctx, "alice", "correct horse"); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	second := newAPIClient(t, api)
+	if err := second.Login(ctx, "alice", "correct horse"); err != nil {
+		t.Fatalf("login: %v", err)
+	}
+
+	id, err := first.CreateSecret(ctx, &model.Secret{Name: "mail", Type: model.TextType, Payload: []byte("v1")})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	mine, err := first.GetSecret(ctx, id)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	theirs, err := second.GetSecret(ctx, id)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+
+	mine.Payload = []byte("v2")
+	revision, err := first.UpdateSecret(ctx, id, mine)
+	if err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if revision != 2 {
+		t.Errorf("revision %d after update, want 2", revision)
+	}
+
+	var conflict *ConflictError
+	theirs.Payload = []byte("v2'")
+	if _, err := second.UpdateSecret(ctx, id, theirs); !errors.As(err, &conflict) || conflict.Revision != 2 {
+		t.Fatalf("stale update: got %v, want a conflict at revision 2", err)
+	}
+	if err := second.DeleteSecret(ctx, id, theirs.Revision); !errors.As(err, &conflict) || conflict.Revision != 2 {
+		t.Fatalf("stale delete: got %v, want a conflict at revision 2", err)
+	}
+
+	got, err := second.GetSecret(ctx, id)
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if string(got.Payload) != "v2" || got.Revision != 2 {
+		t.Errorf("reloaded payload %q at revision %d, want %q at 2", got.Payload, got.Revision, "v2")
+	}
+
+	if err := second.DeleteSecret(ctx, id, got.Revision); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+}
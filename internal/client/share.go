@@ -0,0 +1,347 @@
+package client
+
+import (
+	"context"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"strings"
+
+	"github.com/nbvehbq/go-password-keeper/internal/logger"
+	"github.com/nbvehbq/go-password-keeper/internal/model"
+	"github.com/nbvehbq/go-password-keeper/internal/storage"
+	"go.uber.org/zap"
+)
+
+// Sharing wraps the data key of a secret with the public key of another
+// user. Shares are managed while online only and secrets shared with the
+// user are not cached.
+//
+// Public keys of other users come from the server, which could hand out a
+// key of its own instead. The client pins the fingerprint of a key in the
+// local vault the first time it wraps a key for the user and refuses a
+// different one later with ErrRecipientKeyChanged. The first use is only as
+// safe as the key the server returns then: RecipientKey shows the
+// fingerprint to compare with the user out of band before TrustRecipientKey
+// pins it, and TrustRecipientKey is also how a changed key is accepted.
+// Without a local vault nothing is pinned and every use is a first one.
+
+// maxRekeyAttempts bounds retries of a key rotation racing other changes.
+const maxRekeyAttempts = 3
+
+// ShareSecret shares a secret with the user login, who can read it or, if
+// canEdit is set, also update it. Sharing it again changes the permission.
+func (c *Client) ShareSecret(ctx context.Context, ID int64, login string, canEdit bool) error {
+	if c.privateKey == nil {
+		return ErrUnauthorized
+	}
+	if c.hasLocalChanges(ID) {
+		return ErrPendingChanges
+	}
+
+	secret, err := c.transport.getSecret(ctx, ID)
+	if err != nil {
+		return c.onlineOnly(err)
+	}
+
+	// legacy ciphertexts have no data key to share, seal the secret again
+	if needsMigration(secret) {
+		if err := c.openSecret(secret); err != nil {
+			logger.Log.Error("failed to decrypt data", zap.Error(err))
+			return ErrDecrypt
+		}
+		if err := c.migrateSecret(ctx, secret); err != nil {
+			return c.onlineOnly(err)
+		}
+	}
+
+	userID, pub, err := c.recipientKey(ctx, login)
+	if err != nil {
+		return err
+	}
+
+	wrapped, err := c.wrapFor(secret.Key, pub)
+	if err != nil {
+		return err
+	}
+
+	err = c.transport.shareSecret(ctx, &model.Share{SecretID: ID, UserID: userID, Key: wrapped, CanEdit: canEdit})
+	return c.onlineOnly(err)
+}
+
+// RecipientKey is the public key of another user as seen by the client.
+// Trusted is set if the key is pinned, Changed if a different key is.
+type RecipientKey struct {
+	Login       string
+	Fingerprint string
+	Trusted     bool
+	Changed     bool
+}
+
+// RecipientKey fetches the public key of login and compares it with the one
+// pinned, so its fingerprint can be confirmed with the user before sharing.
+func (c *Client) RecipientKey(ctx context.Context, login string) (*RecipientKey, error) {
+	pub, err := c.transport.getPublicKey(ctx, login)
+	if err != nil {
+		return nil, c.onlineOnly(err)
+	}
+
+	key := &RecipientKey{Login: login, Fingerprint: keyFingerprint(pub.Key)}
+	if c.cache == nil {
+		return key, nil
+	}
+
+	pinned, ok, err := c.cache.pinnedKey(login)
+	if err != nil {
+		return nil, err
+	}
+	key.Trusted = ok && pinned == key.Fingerprint
+	key.Changed = ok && pinned != key.Fingerprint
+
+	return key, nil
+}
+
+// TrustRecipientKey pins the public key of login after its fingerprint was
+// confirmed. The key on the server must still have that fingerprint.
+func (c *Client) TrustRecipientKey(ctx context.Context, login, fingerprint string) error {
+	pub, err := c.transport.getPublicKey(ctx, login)
+	if err != nil {
+		return c.onlineOnly(err)
+	}
+	if keyFingerprint(pub.Key) != fingerprint {
+		return ErrRecipientKeyChanged
+	}
+	if c.cache == nil {
+		return nil
+	}
+
+	return c.cache.pinKey(login, fingerprint)
+}
+
+// KeyFingerprint returns the fingerprint of the user's own public key, for
+// others to compare with the one RecipientKey shows them.
+func (c *Client) KeyFingerprint() (string, error) {
+	if c.privateKey == nil {
+		return "", ErrUnauthorized
+	}
+
+	return keyFingerprint(x509.MarshalPKCS1PublicKey(&c.privateKey.PublicKey)), nil
+}
+
+// recipientKey fetches the public key of login, checks it against the one
+// pinned and pins it on first use.
+func (c *Client) recipientKey(ctx context.Context, login string) (int64, *rsa.PublicKey, error) {
+	pub, err := c.transport.getPublicKey(ctx, login)
+	if err != nil {
+		return 0, nil, c.onlineOnly(err)
+	}
+
+	key, err := x509.ParsePKCS1PublicKey(pub.Key)
+	if err != nil {
+		logger.Log.Error("failed to parse public key", zap.Error(err))
+		return 0, nil, ErrRecipientNotFound
+	}
+
+	if c.cache == nil {
+		return pub.UserID, key, nil
+	}
+
+	fingerprint := keyFingerprint(pub.Key)
+	pinned, ok, err := c.cache.pinnedKey(login)
+	if err != nil {
+		return 0, nil, err
+	}
+	switch {
+	case !ok:
+		if err := c.cache.pinKey(login, fingerprint); err != nil {
+			return 0, nil, err
+		}
+	case pinned != fingerprint:
+		return 0, nil, ErrRecipientKeyChanged
+	}
+
+	return pub.UserID, key, nil
+}
+
+// keyFingerprint formats the SHA-256 of a PKCS #1 public key in groups of
+// four hex digits for comparing it by eye.
+func keyFingerprint(key []byte) string {
+	sum := sha256.Sum256(key)
+	digits := hex.EncodeToString(sum[:])
+
+	groups := make([]string, 0, len(digits)/4)
+	for i := 0; i < len(digits); i += 4 {
+		groups = append(groups, digits[i:i+4])
+	}
+	return strings.Join(groups, " ")
+}
+
+// wrapFor unwraps a data key of the user and wraps it again for pub.
+func (c *Client) wrapFor(key []byte, pub *rsa.PublicKey) ([]byte, error) {
+	dataKey, err := unwrapKey(c.privateKey, key)
+	if err != nil {
+		logger.Log.Error("failed to unwrap data key", zap.Error(err))
+		return nil, ErrDecrypt
+	}
+
+	wrapped, err := wrapKey(pub, dataKey)
+	if err != nil {
+		logger.Log.Error("failed to wrap data key", zap.Error(err))
+		return nil, ErrEncrypt
+	}
+
+	return wrapped, nil
+}
+
+// ListShares returns the users a secret is shared with.
+func (c *Client) ListShares(ctx context.Context, ID int64) ([]model.Share, error) {
+	list, err := c.transport.listShares(ctx, ID)
+	if err != nil {
+		return nil, c.onlineOnly(err)
+	}
+
+	return list, nil
+}
+
+// UnshareSecret revokes access of login to a secret. The share is revoked
+// together with replacing the data key of the secret, and the file key of a
+// binary secret with it, wrapped again for the remaining recipients, so a
+// copy of the old keys kept by the revoked user is of no use for later
+// revisions.
+func (c *Client) UnshareSecret(ctx context.Context, ID int64, login string) error {
+	if c.privateKey == nil {
+		return ErrUnauthorized
+	}
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = c.rekeySecret(ctx, ID, login)
+
+		var conflict *ConflictError
+		retry := errors.As(err, &conflict) || errors.Is(err, storage.ErrSharesChanged)
+		if !retry || attempt == maxRekeyAttempts {
+			break
+		}
+	}
+
+	return err
+}
+
+// rekeySecret seals a secret under a fresh data key, wrapped for the owner
+// and every current recipient but revoke, whose share is deleted with the
+// change. The contents of a file get a fresh key first, as the file key is
+// part of the payload.
+func (c *Client) rekeySecret(ctx context.Context, ID int64, revoke string) error {
+	shares, err := c.transport.listShares(ctx, ID)
+	if err != nil {
+		return c.onlineOnly(err)
+	}
+
+	var kept []model.Share
+	var revoked []int64
+	for _, v := range shares {
+		if v.Login == revoke {
+			revoked = append(revoked, v.UserID)
+		} else {
+			kept = append(kept, v)
+		}
+	}
+	if len(revoked) == 0 {
+		return storage.ErrShareNotFound
+	}
+
+	secret, err := c.transport.getSecret(ctx, ID)
+	if err != nil {
+		return c.onlineOnly(err)
+	}
+	if err := c.openSecret(secret); err != nil {
+		logger.Log.Error("failed to decrypt data", zap.Error(err))
+		return ErrDecrypt
+	}
+
+	if secret.Type == model.BinaryType {
+		if err := c.rekeyFile(ctx, secret); err != nil {
+			return c.onlineOnly(err)
+		}
+	}
+
+	sealed := *secret
+	sealed.Key = nil
+	if err := c.sealSecret(&sealed); err != nil {
+		logger.Log.Error("failed to encrypt data", zap.Error(err))
+		return ErrEncrypt
+	}
+
+	rekey := &model.SecretRekey{
+		Revision: secret.Revision,
+		Payload:  sealed.Payload,
+		Meta:     sealed.Meta,
+		Key:      sealed.Key,
+		UUID:     sealed.UUID,
+		Shares:   make([]model.Share, len(kept)),
+		Revoke:   revoked,
+	}
+	for i, v := range kept {
+		_, pub, err := c.recipientKey(ctx, v.Login)
+		if err != nil {
+			return err
+		}
+		wrapped, err := c.wrapFor(sealed.Key, pub)
+		if err != nil {
+			return err
+		}
+		rekey.Shares[i] = model.Share{SecretID: ID, UserID: v.UserID, Key: wrapped}
+	}
+
+	revision, err := c.transport.rekeySecret(ctx, ID, rekey)
+	if err != nil {
+		return c.onlineOnly(err)
+	}
+
+	sealed.Revision = revision
+	c.cacheSecret(&sealed)
+
+	return nil
+}
+
+// ListSharedSecrets returns the secrets other users have shared with the
+// user, decrypted.
+func (c *Client) ListSharedSecrets(ctx context.Context) ([]model.SharedSecret, error) {
+	list, err := c.transport.listSharedSecrets(ctx)
+	if err != nil {
+		return nil, c.onlineOnly(err)
+	}
+
+	for i := range list {
+		if err := c.openSecret(&list[i].Secret); err != nil {
+			logger.Log.Error("failed to decrypt data", zap.Error(err))
+			return nil, ErrDecrypt
+		}
+	}
+
+	return list, nil
+}
+
+// UpdateSharedSecret updates a secret shared with the user for editing.
+// data.Key must be the key it was shared with, data.Revision the current
+// revision as for UpdateSecret.
+func (c *Client) UpdateSharedSecret(ctx context.Context, ID int64, data *model.Secret) (int64, error) {
+	if len(data.Key) == 0 {
+		return 0, ErrEncrypt
+	}
+
+	sealed := *data
+	if err := c.sealSecret(&sealed); err != nil {
+		logger.Log.Error("failed to encrypt data", zap.Error(err))
+		return 0, ErrEncrypt
+	}
+
+	revision, err := c.transport.updateSharedSecret(ctx, ID, &sealed)
+	if err != nil {
+		return 0, c.onlineOnly(err)
+	}
+
+	return revision, nil
+}
@@ -0,0 +1,85 @@
+package client
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"testing"
+
+	"github.com/nbvehbq/go-password-keeper/internal/model"
+)
+
+// keyTransport hands out the public keys of other users.
+type keyTransport struct {
+	transport
+
+	keys map[string]*model.PublicKey
+}
+
+func (k *keyTransport) getPublicKey(_ context.Context, login string) (*model.PublicKey, error) {
+	pub, ok := k.keys[login]
+	if !ok {
+		return nil, ErrRecipientNotFound
+	}
+	return pub, nil
+}
+
+func newTestPublicKey(t *testing.T, userID int64, login string) *model.PublicKey {
+	t.Helper()
+
+	return &model.PublicKey{UserID: userID, Login: login,
+		Key: x509.MarshalPKCS1PublicKey(&newTestPrivateKey(t).PublicKey)}
+}
+
+func TestRecipientKeyPinning(t *testing.T) {
+	ctx := context.Background()
+
+	bob := newTestPublicKey(t, 2, "bob")
+	server := &keyTransport{keys: map[string]*model.PublicKey{"bob": bob}}
+	c := &Client{transport: server, cfg: &Config{}, privateKey: newTestPrivateKey(t), cache: newTestCache(t)}
+
+	key, err := c.RecipientKey(ctx, "bob")
+	if err != nil {
+		t.Fatalf("recipient key: %v", err)
+	}
+	if key.Trusted || key.Changed {
+		t.Errorf("unused key: trusted %v, changed %v", key.Trusted, key.Changed)
+	}
+	if key.Fingerprint != keyFingerprint(bob.Key) {
+		t.Errorf("fingerprint %s, want %s", key.Fingerprint, keyFingerprint(bob.Key))
+	}
+
+	// pinned on first use
+	userID, _, err := c.recipientKey(ctx, "bob")
+	if err != nil || userID != bob.UserID {
+		t.Fatalf("first use: user %d, %v", userID, err)
+	}
+	if key, _ := c.RecipientKey(ctx, "bob"); !key.Trusted {
+		t.Error("key not pinned on first use")
+	}
+
+	// the server hands out another key
+	server.keys["bob"] = newTestPublicKey(t, 2, "bob")
+	if _, _, err := c.recipientKey(ctx, "bob"); !errors.Is(err, ErrRecipientKeyChanged) {
+		t.Fatalf("changed key: got %v, want %v", err, ErrRecipientKeyChanged)
+	}
+	key, err = c.RecipientKey(ctx, "bob")
+	if err != nil {
+		t.Fatalf("recipient key: %v", err)
+	}
+	if key.Trusted || !key.Changed {
+		t.Errorf("changed key: trusted %v, changed %v", key.Trusted, key.Changed)
+	}
+
+	// trusting a fingerprint other than the one on the server fails
+	if err := c.TrustRecipientKey(ctx, "bob", keyFingerprint(bob.Key)); !errors.Is(err, ErrRecipientKeyChanged) {
+		t.Errorf("stale fingerprint: got %v, want %v", err, ErrRecipientKeyChanged)
+	}
+
+	if err := c.TrustRecipientKey(ctx, "bob", key.Fingerprint); err != nil {
+		t.Fatalf("trust: %v", err)
+	}
+	if _, _, err := c.recipientKey(ctx, "bob"); err != nil {
+		t.Errorf("trusted key: %v", err)
+	}
+}
@@ -0,0 +1,257 @@
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/nbvehbq/go-password-keeper/internal/logger"
+	"github.com/nbvehbq/go-password-keeper/internal/model"
+	"github.com/nbvehbq/go-password-keeper/internal/storage"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// SyncConflict describes a queued change that could not be applied as is.
+// When the local version was kept as a new secret, CopyID is its ID.
+type SyncConflict struct {
+	ID     int64
+	Name   string
+	Reason string
+	CopyID int64
+}
+
+// SyncReport summarizes a Sync run.
+type SyncReport struct {
+	Pushed    int
+	Pulled    int
+	Deleted   int
+	Conflicts []SyncConflict
+}
+
+// isOffline reports whether a request failed because the server could not
+// be reached, as opposed to an error answer.
+func isOffline(err error) bool {
+	// a server failing verification is reachable but not trusted
+	var certErr *tls.CertificateVerificationError
+	if errors.As(err, &certErr) || errors.Is(err, ErrPinMismatch) {
+		return false
+	}
+
+	var netErr net.Error
+	return err != nil && (errors.As(err, &netErr) || status.Code(err) == codes.Unavailable)
+}
+
+// fallback records whether the server is reachable and reports whether the
+// local cache should serve the request instead.
+func (c *Client) fallback(err error) bool {
+	c.offline = isOffline(err)
+	return c.offline && c.cache != nil
+}
+
+// Offline reports whether the last request could not reach the server and
+// changes are queued locally.
+func (c *Client) Offline() bool {
+	return c.offline
+}
+
+func (c *Client) createOffline(sealed *model.Secret) (int64, error) {
+	id, err := c.cache.nextLocalID()
+	if err != nil {
+		return 0, err
+	}
+
+	sealed.ID = id
+	if err := c.cache.enqueue(&pendingOp{Op: opCreate, ID: id, Secret: sealed}); err != nil {
+		return 0, err
+	}
+
+	return id, c.cache.storeLocal(sealed)
+}
+
+func (c *Client) updateOffline(id int64, sealed *model.Secret) (int64, error) {
+	sealed.ID = id
+	if err := c.cache.enqueue(&pendingOp{Op: opUpdate, ID: id, Secret: sealed}); err != nil {
+		return 0, err
+	}
+
+	return sealed.Revision, c.cache.storeLocal(sealed)
+}
+
+func (c *Client) deleteOffline(id, revision int64) error {
+	secret, ok, err := c.cache.secret(id)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return storage.ErrSecretNotFound
+	}
+
+	op := &pendingOp{
+		Op:     opDelete,
+		ID:     id,
+		Secret: &model.Secret{ID: id, Name: secret.Name, Revision: revision},
+	}
+	if err := c.cache.enqueue(op); err != nil {
+		return err
+	}
+
+	return c.cache.deleteSecret(id)
+}
+
+// Changes returns what changed in the vault after the since cursor. The
+// secrets are returned encrypted, as stored on the server.
+func (c *Client) Changes(ctx context.Context, since int64) (*model.Changes, error) {
+	return c.transport.changes(ctx, since)
+}
+
+// Sync pushes changes queued while offline and pulls changes made on the
+// server since the previous sync into the local cache.
+// Conflicting changes never overwrite the server: an edit of a secret that
+// was changed or deleted meanwhile is kept as a new secret, a delete of a
+// changed secret is dropped. Both are listed in the report.
+func (c *Client) Sync(ctx context.Context) (*SyncReport, error) {
+	if c.cache == nil {
+		return nil, ErrNoCache
+	}
+
+	report := &SyncReport{}
+
+	ops, err := c.cache.pending()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range ops {
+		if err := c.push(ctx, &ops[i], report); err != nil {
+			if isOffline(err) {
+				c.offline = true
+				return report, ErrOffline
+			}
+			return report, err
+		}
+		if err := c.cache.dequeue(ops[i].Seq); err != nil {
+			return report, err
+		}
+	}
+
+	changes, err := c.Changes(ctx, c.cache.cursor())
+	if err != nil {
+		if isOffline(err) {
+			c.offline = true
+			return report, ErrOffline
+		}
+		return report, err
+	}
+
+	if err := c.cache.applyChanges(changes); err != nil {
+		return report, err
+	}
+	report.Pulled = len(changes.Updated)
+	report.Deleted = len(changes.Deleted)
+	c.offline = false
+
+	return report, nil
+}
+
+func (c *Client) push(ctx context.Context, op *pendingOp, report *SyncReport) error {
+	switch op.Op {
+	case opCreate:
+		// sealed for its UUID already, the ID is assigned by the server
+		id, err := c.transport.createSecret(ctx, op.Secret)
+		if errors.Is(err, storage.ErrSecretExists) {
+			return c.pushCopy(ctx, op, "name already taken on server", report)
+		}
+		if err != nil {
+			return err
+		}
+
+		created := *op.Secret
+		created.ID, created.Revision = id, 1
+		if err := c.replaceLocal(op.ID, &created); err != nil {
+			return err
+		}
+	case opUpdate:
+		_, err := c.transport.updateSecret(ctx, op.ID, op.Secret)
+		switch {
+		case errors.Is(err, ErrConflict):
+			return c.pushCopy(ctx, op, "changed on server", report)
+		case errors.Is(err, storage.ErrSecretNotFound):
+			return c.pushCopy(ctx, op, "deleted on server", report)
+		case err != nil:
+			return err
+		}
+	case opDelete:
+		err := c.transport.deleteSecret(ctx, op.ID, op.Secret.Revision)
+		switch {
+		case errors.Is(err, ErrConflict):
+			report.Conflicts = append(report.Conflicts, SyncConflict{
+				ID:     op.ID,
+				Name:   op.Secret.Name,
+				Reason: "changed on server, not deleted",
+			})
+			return nil
+		case errors.Is(err, storage.ErrSecretNotFound):
+			return nil
+		case err != nil:
+			return err
+		}
+	default:
+		logger.Log.Warn("unknown queued operation", zap.String("op", op.Op))
+		return nil
+	}
+
+	report.Pushed++
+	return nil
+}
+
+// pushCopy saves the local version of a conflicting change as a new secret.
+func (c *Client) pushCopy(ctx context.Context, op *pendingOp, reason string, report *SyncReport) error {
+	local := *op.Secret
+	if err := c.openSecret(&local); err != nil {
+		logger.Log.Error("failed to decrypt data", zap.Error(err))
+		return ErrDecrypt
+	}
+
+	// a secret of its own: a fresh data key and UUID
+	local.Name = fmt.Sprintf("%s (conflict %s)", op.Secret.Name, time.Now().Format(time.DateTime))
+	local.ID, local.Key, local.UUID = 0, nil, ""
+	if err := c.sealSecret(&local); err != nil {
+		logger.Log.Error("failed to encrypt data", zap.Error(err))
+		return ErrEncrypt
+	}
+
+	id, err := c.transport.createSecret(ctx, &local)
+	if err != nil {
+		return err
+	}
+
+	local.ID, local.Revision = id, 1
+	if err := c.replaceLocal(op.ID, &local); err != nil {
+		return err
+	}
+
+	report.Conflicts = append(report.Conflicts, SyncConflict{
+		ID:     op.ID,
+		Name:   op.Secret.Name,
+		Reason: reason,
+		CopyID: id,
+	})
+
+	return nil
+}
+
+// replaceLocal drops the cached record a queued change was made to and
+// caches the secret pushed for it under its ID on the server. A secret that
+// exists on the server is pulled again by the incremental sync following.
+func (c *Client) replaceLocal(localID int64, pushed *model.Secret) error {
+	if err := c.cache.deleteSecret(localID); err != nil {
+		return err
+	}
+
+	return c.cache.storeLocal(pushed)
+}
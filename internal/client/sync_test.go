@@ -0,0 +1,71 @@
+package client
+
+import (
+	"context"
+	"testing"
+
+	"github.com/nbvehbq/go-password-keeper/internal/model"
+)
+
+// A machine pulls only what another one changed since its previous sync.
+func TestIncrementalSync(t *testing.T) {
+	ctx := context.Background()
+	api := newFakeAPI(t)
+
+	first := newAPIClient(t, api)
+	if err := first.Register(ctx, "alice", "correct horse"); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+
+	var ids []int64
+	for _, name := range []string{"mail", "bank"} {
+		id, err := first.CreateSecret(ctx, &model.Secret{Name: name, Type: model.TextType, Payload: []byte("v1")})
+		if err != nil {
+			t.Fatalf("create: %v", err)
+		}
+		ids = append(ids, id)
+	}
+
+	second := newAPIClient(t, api)
+	if err := second.Login(ctx, "alice", "correct horse"); err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	report, err := second.Sync(ctx)
+	if err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	if report.Pulled != 2 || report.Deleted != 0 {
+		t.Fatalf("first sync: %+v", report)
+	}
+
+	if _, err := first.UpdateSecret(ctx, ids[0], &model.Secret{Name: "mail", Type: model.TextType,
+		Payload: []byte("v2"), Revision: 1}); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if err := first.DeleteSecret(ctx, ids[1], 1); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+
+	report, err = second.Sync(ctx)
+	if err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	if report.Pulled != 1 || report.Deleted != 1 {
+		t.Errorf("second sync: %+v, want 1 pulled and 1 deleted", report)
+	}
+
+	if cached, ok, err := second.cache.secret(ids[0]); err != nil || !ok || cached.Revision != 2 {
+		t.Errorf("updated secret in cache: %+v, %v, %v", cached, ok, err)
+	}
+	if _, ok, err := second.cache.secret(ids[1]); err != nil || ok {
+		t.Errorf("deleted secret still cached: %v, %v", ok, err)
+	}
+
+	report, err = second.Sync(ctx)
+	if err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	if report.Pulled != 0 || report.Deleted != 0 {
+		t.Errorf("sync without changes: %+v", report)
+	}
+}
@@ -0,0 +1,70 @@
+package client
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// tlsConfig returns the TLS configuration for connections to the server, nil
+// when the server is reached over plain HTTP and no TLS option is set.
+//
+// CACert pins the CA: the server certificate must chain to it instead of the
+// system roots. PinSHA256 additionally pins the public key of the server
+// certificate. ClientCert & ClientKey are presented for mutual TLS.
+func (cfg *Config) tlsConfig() (*tls.Config, error) {
+	if !strings.HasPrefix(cfg.Address, "https://") &&
+		cfg.CACert == "" && cfg.PinSHA256 == "" && cfg.ClientCert == "" {
+		return nil, nil
+	}
+
+	conf := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if cfg.CACert != "" {
+		data, err := os.ReadFile(cfg.CACert)
+		if err != nil {
+			return nil, fmt.Errorf("load CA: %w", err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("no certificates in %s", cfg.CACert)
+		}
+		conf.RootCAs = pool
+	}
+
+	if cfg.ClientCert != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.ClientCert, cfg.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("load client certificate: %w", err)
+		}
+		conf.Certificates = []tls.Certificate{cert}
+	}
+
+	if cfg.PinSHA256 != "" {
+		pin, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(cfg.PinSHA256, "sha256//"))
+		if err != nil || len(pin) != sha256.Size {
+			return nil, fmt.Errorf("invalid public key pin %q", cfg.PinSHA256)
+		}
+
+		conf.VerifyConnection = func(state tls.ConnectionState) error {
+			if len(state.PeerCertificates) == 0 {
+				return ErrPinMismatch
+			}
+
+			sum := sha256.Sum256(state.PeerCertificates[0].RawSubjectPublicKeyInfo)
+			if subtle.ConstantTimeCompare(sum[:], pin) != 1 {
+				return ErrPinMismatch
+			}
+
+			return nil
+		}
+	}
+
+	return conf, nil
+}
@@ -0,0 +1,170 @@
+package client
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeTestCert writes a self-signed certificate for 127.0.0.1 that is its
+// own CA and serves as a server and a client certificate.
+func writeTestCert(t *testing.T, name string) (certPath, keyPath string, cert tls.Certificate) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1)},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	certPath, keyPath = filepath.Join(dir, name+".crt"), filepath.Join(dir, name+".key")
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	if err := os.WriteFile(certPath, certPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyPath, keyPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if cert, err = tls.X509KeyPair(certPEM, keyPEM); err != nil {
+		t.Fatal(err)
+	}
+	return certPath, keyPath, cert
+}
+
+func TestTLSConfig(t *testing.T) {
+	serverCert, _, cert := writeTestCert(t, "server")
+	otherCert, _, _ := writeTestCert(t, "other")
+	clientCert, clientKey, client := writeTestCert(t, "client")
+
+	sum := sha256.Sum256(mustParse(t, cert).RawSubjectPublicKeyInfo)
+	pin := base64.StdEncoding.EncodeToString(sum[:])
+	otherPin := base64.StdEncoding.EncodeToString(make([]byte, sha256.Size))
+
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(mustParse(t, client))
+
+	tests := []struct {
+		name       string
+		cfg        Config
+		mutualTLS  bool
+		wantErr    bool
+		wantPinErr bool
+	}{
+		{name: "pinned CA", cfg: Config{CACert: serverCert}},
+		{name: "system roots", cfg: Config{}, wantErr: true},
+		{name: "other CA", cfg: Config{CACert: otherCert}, wantErr: true},
+		{name: "pinned key", cfg: Config{CACert: serverCert, PinSHA256: "sha256//" + pin}},
+		{name: "other key", cfg: Config{CACert: serverCert, PinSHA256: otherPin}, wantErr: true, wantPinErr: true},
+		{name: "mutual TLS", cfg: Config{CACert: serverCert, ClientCert: clientCert, ClientKey: clientKey},
+			mutualTLS: true},
+		{name: "mutual TLS without certificate", cfg: Config{CACert: serverCert}, mutualTLS: true, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewUnstartedServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+			srv.TLS = &tls.Config{Certificates: []tls.Certificate{cert}}
+			srv.Config.ErrorLog = log.New(io.Discard, "", 0)
+			if tt.mutualTLS {
+				srv.TLS.ClientCAs, srv.TLS.ClientAuth = clientCAs, tls.RequireAndVerifyClientCert
+			}
+			srv.StartTLS()
+			defer srv.Close()
+
+			tt.cfg.Address = srv.URL
+			conf, err := tt.cfg.tlsConfig()
+			if err != nil {
+				t.Fatalf("tlsConfig: %v", err)
+			}
+
+			res, err := (&http.Client{Transport: &http.Transport{TLSClientConfig: conf}}).Get(srv.URL)
+			if err == nil {
+				res.Body.Close()
+			}
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got %v, want error %v", err, tt.wantErr)
+			}
+			if errors.Is(err, ErrPinMismatch) != tt.wantPinErr {
+				t.Errorf("got %v, want pin mismatch %v", err, tt.wantPinErr)
+			}
+			if err != nil && !tt.mutualTLS && isOffline(err) {
+				t.Errorf("untrusted server taken for an unreachable one: %v", err)
+			}
+		})
+	}
+}
+
+func mustParse(t *testing.T, cert tls.Certificate) *x509.Certificate {
+	t.Helper()
+
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return leaf
+}
+
+func TestTLSConfigOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantNil bool
+		wantErr bool
+	}{
+		{name: "plain HTTP", cfg: Config{Address: "http://localhost:8080"}, wantNil: true},
+		{name: "HTTPS", cfg: Config{Address: "https://localhost:8080"}},
+		{name: "pin only", cfg: Config{Address: "localhost:9090", PinSHA256: base64.StdEncoding.EncodeToString(make([]byte, 32))}},
+		{name: "invalid pin", cfg: Config{PinSHA256: "short"}, wantErr: true},
+		{name: "missing CA", cfg: Config{CACert: filepath.Join(t.TempDir(), "ca.crt")}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf, err := tt.cfg.tlsConfig()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got %v, want error %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && (conf == nil) != tt.wantNil {
+				t.Errorf("config %v, want nil %v", conf, tt.wantNil)
+			}
+		})
+	}
+}
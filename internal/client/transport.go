@@ -0,0 +1,97 @@
+package client
+
+import (
+	"context"
+	"io"
+
+	"github.com/nbvehbq/go-password-keeper/internal/model"
+)
+
+// Transports selectable by Config.Transport.
+const (
+	TransportHTTP = "http"
+	TransportGRPC = "grpc"
+)
+
+// transport carries the client's requests to the server. Secrets are sent
+// and received encrypted. Besides the client errors (ErrUnauthorized,
+// ErrConflict, ...) and storage errors, a transport returns the underlying
+// error when the server can't be reached, so isOffline can recognize it.
+type transport interface {
+	register(ctx context.Context, dto *model.RegisterDTO) (string, error)
+	prelogin(ctx context.Context, login string) ([]byte, error)
+	// login returns the session ID and the encrypted vault key, or a
+	// *challengeError when the account requires a second factor.
+	login(ctx context.Context, login, password string) (string, []byte, error)
+	loginTwoFactor(ctx context.Context, challenge, code string) (string, []byte, error)
+	enrollTOTP(ctx context.Context) (*model.TOTPEnrollment, error)
+	verifyTOTP(ctx context.Context, code string) ([]string, error)
+	disableTOTP(ctx context.Context, code string) error
+	updateKeys(ctx context.Context, dto *keysDTO) error
+	logout(ctx context.Context) error
+	listSessions(ctx context.Context) ([]model.Session, error)
+	revokeSession(ctx context.Context, ID int64) error
+	// listAuditEvents returns up to limit events older than before (the
+	// newest ones for zero), oldest first.
+	listAuditEvents(ctx context.Context, before int64, limit int) ([]model.AuditEvent, error)
+
+	listSecrets(ctx context.Context, filter model.SecretFilter) ([]model.Secret, error)
+	getSecret(ctx context.Context, ID int64) (*model.Secret, error)
+	createSecret(ctx context.Context, sealed *model.Secret) (int64, error)
+	// updateSecret expects sealed.Revision to be the current revision.
+	updateSecret(ctx context.Context, ID int64, sealed *model.Secret) (int64, error)
+	deleteSecret(ctx context.Context, ID, revision int64) error
+
+	patchSecret(ctx context.Context, ID int64, patch *model.SecretPatch) error
+
+	listFolders(ctx context.Context) ([]model.Folder, error)
+	createFolder(ctx context.Context, sealed *model.Folder) (int64, error)
+	deleteFolder(ctx context.Context, ID int64) error
+	listTags(ctx context.Context) ([]model.Tag, error)
+	createTag(ctx context.Context, sealed *model.Tag) (int64, error)
+
+	// getPublicKey returns ErrRecipientNotFound for unknown users and users
+	// without a published key.
+	getPublicKey(ctx context.Context, login string) (*model.PublicKey, error)
+	shareSecret(ctx context.Context, share *model.Share) error
+	listShares(ctx context.Context, ID int64) ([]model.Share, error)
+	// rekeySecret and updateSharedSecret expect the current revision like
+	// updateSecret. rekeySecret deletes the shares of rekey.Revoke.
+	rekeySecret(ctx context.Context, ID int64, rekey *model.SecretRekey) (int64, error)
+	listSharedSecrets(ctx context.Context) ([]model.SharedSecret, error)
+	updateSharedSecret(ctx context.Context, ID int64, sealed *model.Secret) (int64, error)
+
+	// getOneTimeSecret needs no session and returns
+	// storage.ErrOneTimeNotFound once the secret is used up or expired.
+	createOneTimeSecret(ctx context.Context, secret *model.OneTimeSecret) (*model.OneTimeSecret, error)
+	getOneTimeSecret(ctx context.Context, ID string) (*model.OneTimeSecret, error)
+
+	// getEmergencyVault returns storage.ErrEmergencyWaiting until the wait
+	// period of a request has passed.
+	listEmergencyGrants(ctx context.Context) ([]model.EmergencyGrant, error)
+	createEmergencyGrant(ctx context.Context, grant *model.EmergencyGrant) (int64, error)
+	deleteEmergencyGrant(ctx context.Context, ID int64) error
+	requestEmergencyAccess(ctx context.Context, ID int64) (*model.EmergencyGrant, error)
+	rejectEmergencyAccess(ctx context.Context, ID int64) error
+	getEmergencyVault(ctx context.Context, ID int64) (*model.EmergencyVault, error)
+
+	listVersions(ctx context.Context, ID int64) ([]model.SecretVersion, error)
+	getVersion(ctx context.Context, ID, version int64) (*model.SecretVersion, error)
+	restoreVersion(ctx context.Context, ID, version int64) error
+
+	changes(ctx context.Context, since int64) (*model.Changes, error)
+
+	// putBlob replaces a binary secret and its file contents read from r
+	// like updateSecret and returns the new revision.
+	putBlob(ctx context.Context, ID int64, sealed *model.Secret, r io.Reader) (int64, error)
+	getBlob(ctx context.Context, ID int64) (io.ReadCloser, error)
+
+	setCredentials(sid string)
+	clearCredentials()
+}
+
+// keysDTO replaces the password and key material of the current user.
+type keysDTO struct {
+	model.RegisterDTO
+	CurrentPassword string `json:"current_password"`
+}
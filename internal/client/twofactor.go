@@ -0,0 +1,81 @@
+package client
+
+import (
+	"context"
+	"errors"
+
+	"github.com/nbvehbq/go-password-keeper/internal/model"
+)
+
+// challengeError is returned by transport.login when the account requires
+// a second factor.
+type challengeError struct {
+	challenge string
+}
+
+func (e *challengeError) Error() string {
+	return ErrTwoFactorRequired.Error()
+}
+
+func (e *challengeError) Is(target error) bool {
+	return target == ErrTwoFactorRequired
+}
+
+// pendingLogin is a login whose password was accepted. finish completes it
+// with the vault key received after the second factor.
+type pendingLogin struct {
+	challenge string
+	finish    func(ctx context.Context, vaultKey []byte) error
+}
+
+// secondFactor keeps a login that requires a second factor pending until
+// LoginTwoFactor, and returns ErrTwoFactorRequired. Other errors are
+// returned as is.
+func (c *Client) secondFactor(err error, finish func(ctx context.Context, vaultKey []byte) error) error {
+	var challenge *challengeError
+	if !errors.As(err, &challenge) {
+		return err
+	}
+
+	c.pending = &pendingLogin{challenge: challenge.challenge, finish: finish}
+
+	return ErrTwoFactorRequired
+}
+
+// LoginTwoFactor completes a login that returned ErrTwoFactorRequired with
+// a TOTP code or a recovery code.
+func (c *Client) LoginTwoFactor(ctx context.Context, code string) error {
+	if c.pending == nil {
+		return ErrUnauthorized
+	}
+
+	sid, vaultKey, err := c.transport.loginTwoFactor(ctx, c.pending.challenge, code)
+	if err != nil {
+		return err
+	}
+
+	finish := c.pending.finish
+	c.pending = nil
+	c.setCredentials(sid)
+	c.offline = false
+
+	return finish(ctx, vaultKey)
+}
+
+// EnrollTOTP starts enrolling a second factor. It is enabled by VerifyTOTP
+// with a code from the authenticator app.
+func (c *Client) EnrollTOTP(ctx context.Context) (*model.TOTPEnrollment, error) {
+	return c.transport.enrollTOTP(ctx)
+}
+
+// VerifyTOTP enables the enrolled second factor and returns the recovery
+// codes, which are shown only once.
+func (c *Client) VerifyTOTP(ctx context.Context, code string) ([]string, error) {
+	return c.transport.verifyTOTP(ctx, code)
+}
+
+// DisableTOTP turns off the second factor, which takes a TOTP or recovery
+// code.
+func (c *Client) DisableTOTP(ctx context.Context, code string) error {
+	return c.transport.disableTOTP(ctx, code)
+}
@@ -0,0 +1,54 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/nbvehbq/go-password-keeper/internal/model"
+)
+
+// With a second factor enabled the password alone neither opens a session
+// nor unlocks the vault.
+func TestLoginTwoFactor(t *testing.T) {
+	ctx := context.Background()
+	api := newFakeAPI(t)
+
+	first := newAPIClient(t, api)
+	if err := first.Register(ctx, "alice", "correct horse"); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	api.totp["alice"] = "123456"
+
+	c := newAPIClient(t, api)
+	if err := c.LoginTwoFactor(ctx, "123456"); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("second factor without a login: got %v, want %v", err, ErrUnauthorized)
+	}
+
+	if err := c.Login(ctx, "alice", "correct horse"); !errors.Is(err, ErrTwoFactorRequired) {
+		t.Fatalf("login: got %v, want %v", err, ErrTwoFactorRequired)
+	}
+	if c.privateKey != nil {
+		t.Error("vault unlocked before the second factor")
+	}
+	if len(api.sessions) != 1 {
+		t.Errorf("session opened before the second factor")
+	}
+
+	if err := c.LoginTwoFactor(ctx, "000000"); !errors.Is(err, ErrInvalidCode) {
+		t.Fatalf("wrong code: got %v, want %v", err, ErrInvalidCode)
+	}
+	if err := c.LoginTwoFactor(ctx, "123456"); err != nil {
+		t.Fatalf("second factor: %v", err)
+	}
+	if c.privateKey == nil || !c.privateKey.Equal(first.privateKey) {
+		t.Error("vault not unlocked after the second factor")
+	}
+	if _, err := c.CreateSecret(ctx, &model.Secret{Name: "mail", Type: model.TextType, Payload: []byte("p")}); err != nil {
+		t.Errorf("create after the second factor: %v", err)
+	}
+
+	if err := c.LoginTwoFactor(ctx, "123456"); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("second factor twice: got %v, want %v", err, ErrUnauthorized)
+	}
+}
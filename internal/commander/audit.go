@@ -0,0 +1,132 @@
+package commander
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/abiosoft/ishell/v2"
+	"github.com/nbvehbq/go-password-keeper/internal/audit"
+	"github.com/nbvehbq/go-password-keeper/internal/client"
+	"github.com/nbvehbq/go-password-keeper/internal/model"
+)
+
+// auditCmd reviews the stored logins for weak, reused, old and breached
+// passwords. Secrets are decrypted locally and nothing is sent anywhere.
+func auditCmd(ctx context.Context, keeper Keeper) *ishell.Cmd {
+	return &ishell.Cmd{
+		Name: "audit",
+		Help: "Report weak, reused, old & breached passwords",
+		Func: func(c *ishell.Context) {
+			c.ShowPrompt(false)
+			defer c.ShowPrompt(true)
+
+			c.Print("Breach hash file or directory (empty to skip): ")
+			breachPath := strings.TrimSpace(c.ReadLine())
+			c.Print("Max password age in days: ")
+			days, err := strconv.Atoi(c.ReadLineWithDefault(strconv.Itoa(int(audit.DefaultMaxAge.Hours() / 24))))
+			if err != nil {
+				c.Println("Unexpected error:", err)
+				return
+			}
+
+			entries, err := auditEntries(ctx, keeper)
+			if err != nil {
+				switch {
+				case errors.Is(err, client.ErrUnauthorized):
+					c.Println("Please login first.")
+				default:
+					c.Println("Unexpected error:", err)
+				}
+				return
+			}
+
+			if len(entries) == 0 {
+				c.Println("No logins found.")
+				return
+			}
+
+			results, err := audit.Run(entries, audit.Options{
+				MaxAge:     time.Duration(days) * 24 * time.Hour,
+				BreachPath: breachPath,
+			})
+			if err != nil {
+				c.Println("Unexpected error:", err)
+				return
+			}
+
+			var weak, reused, old, breached int
+			for _, r := range results {
+				var issues []string
+				if r.Weak() {
+					weak++
+				}
+				if len(r.ReusedWith) > 0 {
+					reused++
+					ids := make([]string, len(r.ReusedWith))
+					for i, id := range r.ReusedWith {
+						ids[i] = strconv.FormatInt(id, 10)
+					}
+					issues = append(issues, "reused by "+strings.Join(ids, ", "))
+				}
+				if r.Old {
+					old++
+					issues = append(issues, fmt.Sprintf("%d days old", int(r.Age.Hours()/24)))
+				}
+				if r.Breaches > 0 {
+					breached++
+					issues = append(issues, fmt.Sprintf("breached %d times", r.Breaches))
+				}
+
+				c.Printf("| %4d | %10s | %11s (%3.0f bits) | %s\n", r.ID, r.Name,
+					audit.ScoreName(r.Score), r.Entropy, strings.Join(issues, "; "))
+			}
+
+			c.Printf("Audited %d login(s): %d weak, %d reused, %d old, %d breached.\n",
+				len(results), weak, reused, old, breached)
+		},
+	}
+}
+
+// auditEntries decrypts all logins of the vault.
+func auditEntries(ctx context.Context, keeper Keeper) ([]audit.Entry, error) {
+	list, err := keeper.ListSecrets(ctx, strconv.Itoa(int(model.LoginPasswordType)))
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]audit.Entry, 0, len(list))
+	for _, v := range list {
+		secret, err := keeper.GetSecret(ctx, v.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		var entity model.LoginPassword
+		if err := json.Unmarshal(secret.Payload, &entity); err != nil {
+			return nil, err
+		}
+
+		// sharing, key rotation and the like update a secret as well, so
+		// its UpdatedAt is only the best guess for logins saved before the
+		// payload recorded password changes
+		changedAt := entity.PasswordChangedAt
+		if changedAt.IsZero() {
+			changedAt = secret.UpdatedAt
+		}
+
+		entries = append(entries, audit.Entry{
+			ID:        secret.ID,
+			Name:      secret.Name,
+			Login:     entity.Login,
+			Password:  entity.Password,
+			ChangedAt: changedAt,
+		})
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,74 @@
+package commander
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/abiosoft/ishell/v2"
+	"github.com/nbvehbq/go-password-keeper/internal/client"
+	"github.com/nbvehbq/go-password-keeper/internal/model"
+)
+
+const auditPageSize = 20
+
+// printAuditLogError prints errors of the audit-log command.
+func printAuditLogError(c *ishell.Context, err error) {
+	switch {
+	case errors.Is(err, client.ErrUnauthorized):
+		c.Println("Please login first.")
+	case errors.Is(err, client.ErrOffline):
+		c.Println("The audit log needs the server, it is unreachable.")
+	default:
+		c.Println("Unexpected error:", err)
+	}
+}
+
+// auditLogCmd pages backwards through the audit trail of the account and
+// reports whether its hash chain holds.
+func auditLogCmd(ctx context.Context, keeper Keeper) *ishell.Cmd {
+	return &ishell.Cmd{
+		Name: "audit-log",
+		Help: "Show the audit log of your account",
+		Func: func(c *ishell.Context) {
+			c.ShowPrompt(false)
+			defer c.ShowPrompt(true)
+
+			var newer *model.AuditEvent
+			for {
+				report, err := keeper.AuditLog(ctx, newer, auditPageSize)
+				if err != nil {
+					printAuditLogError(c, err)
+					return
+				}
+				if len(report.Events) == 0 && newer == nil {
+					c.Println("No events recorded yet.")
+					return
+				}
+
+				for _, v := range report.Events {
+					actor := ""
+					if v.ActorID != v.UserID {
+						actor = " (by another user)"
+					}
+					c.Printf("| %6d | %s | %-20s | %s%s |\n", v.ID, v.CreatedAt.Local().Format(time.DateTime),
+						v.Action, v.Detail, actor)
+				}
+
+				if report.BrokenAt != 0 {
+					c.Printf("WARNING: the hash chain breaks at event %d, the log was altered.\n", report.BrokenAt)
+					return
+				}
+				if report.Complete {
+					c.Println("Hash chain verified back to the first event.")
+					return
+				}
+				c.Println("Hash chain verified for these events.")
+				if c.MultiChoice([]string{"No", "Yes"}, "Show older events?") != 1 {
+					return
+				}
+				newer = &report.Events[0]
+			}
+		},
+	}
+}
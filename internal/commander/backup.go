@@ -0,0 +1,159 @@
+package commander
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/abiosoft/ishell/v2"
+	"github.com/nbvehbq/go-password-keeper/internal/client"
+)
+
+// exportCmd writes the whole vault to a file: an encrypted archive, or
+// plaintext JSON or CSV after an explicit confirmation.
+func exportCmd(ctx context.Context, keeper Keeper) *ishell.Cmd {
+	return &ishell.Cmd{
+		Name: "export",
+		Help: "Export the vault to an encrypted archive or plaintext file",
+		Func: func(c *ishell.Context) {
+			c.ShowPrompt(false)
+			defer c.ShowPrompt(true)
+
+			choice := c.MultiChoice([]string{"Encrypted archive", "Plaintext JSON", "Plaintext CSV"}, "Witch format you want to export?")
+			if choice < 0 {
+				return
+			}
+
+			var password string
+			if choice == 0 {
+				c.Print("Archive password: ")
+				password = c.ReadPassword()
+				c.Print("Repeat password: ")
+				if c.ReadPassword() != password {
+					c.Println("Passwords don't match.")
+					return
+				}
+				if password == "" {
+					c.Println("Password is required.")
+					return
+				}
+			} else {
+				c.Println("WARNING: the export will contain all your secrets unencrypted.")
+				c.Print(`Type "yes" to continue: `)
+				if strings.TrimSpace(c.ReadLine()) != "yes" {
+					c.Println("Export cancelled.")
+					return
+				}
+			}
+
+			c.Print("File: ")
+			path := strings.TrimSpace(c.ReadLine())
+
+			items, err := keeper.Backup(ctx)
+			if err != nil {
+				switch {
+				case errors.Is(err, client.ErrUnauthorized):
+					c.Println("Please login first.")
+				case errors.Is(err, client.ErrOffline):
+					c.Println("Files can't be exported while offline.")
+				default:
+					c.Println("Unexpected error:", err)
+				}
+				return
+			}
+
+			err = writeExport(path, func(w io.Writer) error {
+				switch choice {
+				case 1:
+					return client.WriteJSON(w, items)
+				case 2:
+					return client.WriteCSV(w, items)
+				default:
+					return client.WriteBackup(w, password, items)
+				}
+			})
+			if err != nil {
+				c.Println("Unexpected error:", err)
+				return
+			}
+
+			c.Printf("Exported %d secrets to %s\n", len(items), path)
+		},
+	}
+}
+
+// writeExport creates a new file at path, readable by the owner only, and
+// writes it with write. The file is removed if writing fails.
+func writeExport(path string, write func(w io.Writer) error) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return err
+	}
+
+	err = write(f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+	}
+
+	return err
+}
+
+// restoreCmd creates the secrets of an encrypted archive in the vault of
+// the logged in user, which may be another account than the exported one.
+func restoreCmd(ctx context.Context, keeper Keeper) *ishell.Cmd {
+	return &ishell.Cmd{
+		Name: "restore",
+		Help: "Restore secrets from an encrypted archive",
+		Func: func(c *ishell.Context) {
+			c.ShowPrompt(false)
+			defer c.ShowPrompt(true)
+
+			c.Print("Archive: ")
+			path := strings.TrimSpace(c.ReadLine())
+			c.Print("Archive password: ")
+			password := c.ReadPassword()
+
+			f, err := os.Open(path)
+			if err != nil {
+				c.Println("Unexpected error:", err)
+				return
+			}
+			defer f.Close()
+
+			items, err := client.ReadBackup(f, password)
+			if err != nil {
+				switch {
+				case errors.Is(err, client.ErrBackupPassword):
+					c.Println("Wrong password or damaged archive.")
+				case errors.Is(err, client.ErrBackupFormat):
+					c.Println("Not a backup archive.")
+				default:
+					c.Println("Unexpected error:", err)
+				}
+				return
+			}
+
+			report, err := keeper.Restore(ctx, items)
+			if err != nil {
+				switch {
+				case errors.Is(err, client.ErrUnauthorized):
+					c.Println("Please login first.")
+				default:
+					c.Println("Unexpected error:", err)
+				}
+				return
+			}
+
+			for name, reason := range report.Skipped {
+				c.Printf("Skipped %s: %s\n", name, reason)
+			}
+			c.Printf("Restored %d secrets, skipped %d.\n", report.Restored, len(report.Skipped))
+			printOffline(c, keeper)
+		},
+	}
+}
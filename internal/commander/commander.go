@@ -5,9 +5,9 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"os"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/abiosoft/ishell/v2"
 	"github.com/nbvehbq/go-password-keeper/internal/client"
@@ -18,15 +18,64 @@ import (
 type Keeper interface {
 	Register(ctx context.Context, login, password string) error
 	Login(ctx context.Context, login, password string) error
+	LoginTwoFactor(ctx context.Context, code string) error
+	EnrollTOTP(ctx context.Context) (*model.TOTPEnrollment, error)
+	VerifyTOTP(ctx context.Context, code string) ([]string, error)
+	DisableTOTP(ctx context.Context, code string) error
 	ListSecrets(ctx context.Context, resourceType string) ([]model.Secret, error)
+	FilterSecrets(ctx context.Context, filter model.SecretFilter) ([]model.Secret, error)
+	OpenSecrets(ctx context.Context, filter model.SecretFilter) ([]model.Secret, error)
 	CreateSecret(ctx context.Context, data *model.Secret) (int64, error)
 	GetSecret(ctx context.Context, ID int64) (*model.Secret, error)
-	DeleteSecret(ctx context.Context, ID int64) error
+	DeleteSecret(ctx context.Context, ID, revision int64) error
 	UpdateSecret(ctx context.Context, ID int64, data *model.Secret) (int64, error)
+
+	CreateFile(ctx context.Context, data *model.Secret, path string) (int64, error)
+	UpdateFile(ctx context.Context, ID int64, data *model.Secret, path string) (int64, error)
+	SaveFile(ctx context.Context, secret *model.Secret, path string) error
+
+	Logout(ctx context.Context) error
+	ListSessions(ctx context.Context) ([]model.Session, error)
+	RevokeSession(ctx context.Context, ID int64) error
+
+	ListVersions(ctx context.Context, ID int64) ([]model.SecretVersion, error)
+	GetVersion(ctx context.Context, ID, version int64) (*model.SecretVersion, error)
+	RestoreVersion(ctx context.Context, ID, version int64) error
+
+	PatchSecret(ctx context.Context, ID int64, patch *model.SecretPatch) error
+	ListFolders(ctx context.Context) ([]model.Folder, error)
+	CreateFolder(ctx context.Context, parentID int64, name string) (int64, error)
+	DeleteFolder(ctx context.Context, ID int64) error
+	ListTags(ctx context.Context) ([]model.Tag, error)
+	CreateTag(ctx context.Context, name string) (int64, error)
+
+	KeyFingerprint() (string, error)
+	RecipientKey(ctx context.Context, login string) (*client.RecipientKey, error)
+	TrustRecipientKey(ctx context.Context, login, fingerprint string) error
+	ShareSecret(ctx context.Context, ID int64, login string, canEdit bool) error
+	ListShares(ctx context.Context, ID int64) ([]model.Share, error)
+	UnshareSecret(ctx context.Context, ID int64, login string) error
+	ListSharedSecrets(ctx context.Context) ([]model.SharedSecret, error)
+	UpdateSharedSecret(ctx context.Context, ID int64, data *model.Secret) (int64, error)
+	CreateOneTimeLink(ctx context.Context, ID int64, maxViews int, ttl time.Duration) (string, time.Time, error)
+	OpenOneTimeLink(ctx context.Context, link string) (*model.Secret, error)
+	GrantEmergencyAccess(ctx context.Context, login string, waitDays int) error
+	ListEmergencyGrants(ctx context.Context) ([]model.EmergencyGrant, error)
+	RevokeEmergencyAccess(ctx context.Context, ID int64) error
+	RequestEmergencyAccess(ctx context.Context, ID int64) (*model.EmergencyGrant, error)
+	RejectEmergencyAccess(ctx context.Context, ID int64) error
+	OpenEmergencyVault(ctx context.Context, ID int64) (*model.EmergencyVault, error)
+	AuditLog(ctx context.Context, newer *model.AuditEvent, limit int) (*client.AuditReport, error)
+
+	Backup(ctx context.Context) ([]client.BackupItem, error)
+	Restore(ctx context.Context, items []client.BackupItem) (*client.RestoreReport, error)
+
+	Sync(ctx context.Context) (*client.SyncReport, error)
+	Offline() bool
 }
 
 var (
-	resorces = []string{"All", "Login & password", "Text", "Binary (file)", "Bank card"}
+	resorces = []string{"All", "Login & password", "Text", "Binary (file)", "Bank card", "One-time password (TOTP)"}
 )
 
 func SetupCommands(ctx context.Context, keeper Keeper) *ishell.Shell {
@@ -38,7 +87,7 @@ func SetupCommands(ctx context.Context, keeper Keeper) *ishell.Shell {
 	// Register user cmd
 	shell.AddCmd(&ishell.Cmd{
 		Name: "register",
-		Help: "Register as new user & create public/private key pair",
+		Help: "Register as new user & create a vault key protected by the master password",
 		Func: func(c *ishell.Context) {
 			c.ShowPrompt(false)
 			defer c.ShowPrompt(true)
@@ -57,9 +106,10 @@ func SetupCommands(ctx context.Context, keeper Keeper) *ishell.Shell {
 				default:
 					c.Println("Unexpected error:", err)
 				}
+				return
 			}
 
-			// TODO: Create public/private key pair
+			c.Println("Registration successful.")
 		},
 	})
 
@@ -78,18 +128,28 @@ func SetupCommands(ctx context.Context, keeper Keeper) *ishell.Shell {
 				c.Print("Password: ")
 				password := c.ReadPassword()
 
-				if err = keeper.Login(ctx, login, password); err == nil {
+				err = keeper.Login(ctx, login, password)
+				if errors.Is(err, client.ErrTwoFactorRequired) {
+					err = readSecondFactor(ctx, c, keeper)
+				}
+				if err == nil {
 					c.Println("Authentication successful.")
+					if keeper.Offline() {
+						c.Println("Server is unreachable, working with the local copy of the vault.")
+					}
 					break
 				}
 
 				switch {
-				case errors.Is(err, client.ErrUnauthorized):
+				case errors.Is(err, client.ErrUnauthorized), errors.Is(err, client.ErrInvalidCode):
 					c.Println("Authentication Failed. Try again...")
 					continue
 				case errors.Is(err, client.ErrInternal):
 					c.Println("Internal error. Try later.")
 					break
+				case errors.Is(err, client.ErrOffline):
+					c.Println("Server is unreachable and there is no local copy of this vault.")
+					break
 				default:
 					c.Println("Unexpected error:", err)
 					break
@@ -102,17 +162,97 @@ func SetupCommands(ctx context.Context, keeper Keeper) *ishell.Shell {
 		},
 	})
 
+	// Logout cmd
+	shell.AddCmd(&ishell.Cmd{
+		Name: "logout",
+		Help: "Logout & close current session",
+		Func: func(c *ishell.Context) {
+			if err := keeper.Logout(ctx); err != nil {
+				switch {
+				case errors.Is(err, client.ErrUnauthorized):
+					c.Println("Session already expired.")
+				default:
+					c.Println("Unexpected error:", err)
+				}
+				return
+			}
+
+			c.Println("Logged out.")
+		},
+	})
+
+	// List & revoke sessions cmd
+	shell.AddCmd(&ishell.Cmd{
+		Name: "sessions",
+		Help: "List active sessions & revoke one of them",
+		Func: func(c *ishell.Context) {
+			c.ShowPrompt(false)
+			defer c.ShowPrompt(true)
+
+			list, err := keeper.ListSessions(ctx)
+			if err != nil {
+				switch {
+				case errors.Is(err, client.ErrUnauthorized):
+					c.Println("Please login first.")
+				default:
+					c.Println("Unexpected error:", err)
+				}
+				return
+			}
+
+			for _, v := range list {
+				current := " "
+				if v.Current {
+					current = "*"
+				}
+				c.Printf("| %s %4d | %s | %s | %s |\n", current, v.ID,
+					v.CreatedAt.Local().Format(time.DateTime), v.LastSeenAt.Local().Format(time.DateTime), v.UserAgent)
+			}
+
+			c.Print("Session ID to revoke (empty to skip): ")
+			line := strings.TrimSpace(c.ReadLine())
+			if line == "" {
+				return
+			}
+
+			ID, err := strconv.ParseInt(line, 10, 64)
+			if err != nil {
+				c.Println("Unexpected error:", err)
+				return
+			}
+
+			if err := keeper.RevokeSession(ctx, ID); err != nil {
+				switch {
+				case errors.Is(err, client.ErrUnauthorized):
+					c.Println("Please login first.")
+				case errors.Is(err, client.ErrSessionNotFound):
+					c.Println("Session not found")
+				default:
+					c.Println("Unexpected error:", err)
+				}
+				return
+			}
+
+			c.Println("Session revoked")
+		},
+	})
+
 	// List secrets cmd
 	shell.AddCmd(&ishell.Cmd{
 		Name: "list",
-		Help: "List resources saved by current user",
+		Help: "List resources saved by current user, optionally in a folder, with #tag or * for favourites",
 		Func: func(c *ishell.Context) {
 			choice := c.MultiChoice(resorces, "Witch resorce you want to list?")
-			type_ := fmt.Sprintf("%d", choice)
-			if choice == 0 {
-				type_ = ""
+			filter, err := secretFilter(ctx, keeper, c.Args)
+			if err != nil {
+				printPlacementError(c, err)
+				return
+			}
+			if choice > 0 {
+				filter.Type = model.ResourceType(choice)
 			}
-			list, err := keeper.ListSecrets(ctx, type_)
+
+			list, err := keeper.FilterSecrets(ctx, filter)
 			if err != nil {
 				switch {
 				case errors.Is(err, client.ErrUnauthorized):
@@ -128,11 +268,7 @@ func SetupCommands(ctx context.Context, keeper Keeper) *ishell.Shell {
 				return
 			}
 
-			text := make([]string, len(list))
-			for i, v := range list {
-				text[i] = fmt.Sprintf("| %4d | %10s |", v.ID, v.Name)
-			}
-			c.ShowPaged(strings.Join(text, "\n"))
+			c.ShowPaged(formatSecrets(ctx, keeper, list))
 		},
 	})
 
@@ -148,6 +284,7 @@ func SetupCommands(ctx context.Context, keeper Keeper) *ishell.Shell {
 			defer c.ShowPrompt(true)
 
 			var payload []byte
+			var path string
 			var err error
 
 			c.Print("Name: ")
@@ -160,8 +297,10 @@ func SetupCommands(ctx context.Context, keeper Keeper) *ishell.Shell {
 				entity := &model.LoginPassword{}
 				c.Print("Login: ")
 				entity.Login = c.ReadLine()
-				c.Print("Password: ")
-				entity.Password = c.ReadPassword()
+				entity.Password = readPassword(c, "Password: ")
+				entity.PasswordChangedAt = time.Now().UTC()
+				c.Print("URL: ")
+				entity.URL = c.ReadLine()
 				payload, err = json.Marshal(entity)
 				if err != nil {
 					c.Println("Unexpected error:", err)
@@ -177,20 +316,8 @@ func SetupCommands(ctx context.Context, keeper Keeper) *ishell.Shell {
 					return
 				}
 			case model.BinaryType:
-				entity := &model.Binary{}
 				c.Print("File path: ")
-				path := c.ReadLine()
-				entity.Name = path
-				entity.Value, err = os.ReadFile(path)
-				if err != nil {
-					c.Println("Unexpected error:", err)
-					return
-				}
-				payload, err = json.Marshal(entity)
-				if err != nil {
-					c.Println("Unexpected error:", err)
-					return
-				}
+				path = c.ReadLine()
 			case model.BankCardType:
 				entity := &model.BankCard{}
 				c.Print("Number: ")
@@ -206,20 +333,38 @@ func SetupCommands(ctx context.Context, keeper Keeper) *ishell.Shell {
 					c.Println("Unexpected error:", err)
 					return
 				}
+			case model.OTPType:
+				c.Print("otpauth:// URI or secret: ")
+				payload, err = parseOTP(c.ReadLine(), name)
+				if err != nil {
+					c.Println("Unexpected error:", err)
+					return
+				}
 			}
 
-			id, err := keeper.CreateSecret(ctx, &model.Secret{
+			secret := &model.Secret{
 				Name:    name,
 				Type:    model.ResourceType(choice + 1),
 				Payload: payload,
 				Meta:    []byte(meta),
-			})
+			}
+
+			var id int64
+			if rtype == model.BinaryType {
+				id, err = keeper.CreateFile(ctx, secret, path)
+			} else {
+				id, err = keeper.CreateSecret(ctx, secret)
+			}
 			if err != nil {
 				switch {
 				case errors.Is(err, client.ErrUnauthorized):
 					c.Println("Please login first.")
 				case errors.Is(err, storage.ErrSecretExists):
 					c.Println("Secret already exists")
+				case errors.Is(err, client.ErrTooLarge):
+					c.Println("File is too large")
+				case errors.Is(err, client.ErrOffline):
+					c.Println("Files can't be saved while offline.")
 				default:
 					c.Println("Unexpected error:", err)
 				}
@@ -227,6 +372,7 @@ func SetupCommands(ctx context.Context, keeper Keeper) *ishell.Shell {
 			}
 
 			c.Println("Secret created with ID:", id)
+			printOffline(c, keeper)
 		},
 	})
 
@@ -262,45 +408,10 @@ func SetupCommands(ctx context.Context, keeper Keeper) *ishell.Shell {
 			c.Println("Name: ", secret.Name)
 			c.Println("Type: ", secret.Type)
 
-			// TODO: decrypt payload & meta
+			printPayload(c, secret.Type, secret.Meta, secret.Payload)
 
-			c.Println("Metadata: ", string(secret.Meta))
-			rtype := model.ResourceType(secret.Type)
-			switch rtype {
-			case model.LoginPasswordType:
-				lp := model.LoginPassword{}
-				if err = json.Unmarshal(secret.Payload, &lp); err != nil {
-					c.Println("Unexpected error:", err)
-					return
-				}
-				c.Println("Login: ", lp.Login)
-				c.Println("Password: ", lp.Password)
-			case model.TextType:
-				t := model.Text{}
-				if err = json.Unmarshal(secret.Payload, &t); err != nil {
-					c.Println("Unexpected error:", err)
-					return
-				}
-				c.Println("Text: ", t.Value)
-			case model.BinaryType:
-				b := model.Binary{}
-				if err = json.Unmarshal(secret.Payload, &b); err != nil {
-					c.Println("Unexpected error:", err)
-					return
-				}
-				c.Println("Binary name: ", b.Name)
-			case model.BankCardType:
-				bc := model.BankCard{}
-				if err = json.Unmarshal(secret.Payload, &bc); err != nil {
-					c.Println("Unexpected error:", err)
-					return
-				}
-				c.Println("Number: ", bc.Number)
-				c.Println("Expire at: ", bc.ExpireAt)
-				c.Println("Name: ", bc.Name)
-				c.Println("Surname: ", bc.Surname)
-			default:
-				c.Println("Unknown type")
+			if secret.Type == model.BinaryType {
+				saveFile(ctx, c, keeper, secret)
 			}
 		},
 	})
@@ -320,7 +431,7 @@ func SetupCommands(ctx context.Context, keeper Keeper) *ishell.Shell {
 				return
 			}
 
-			err = keeper.DeleteSecret(ctx, ID)
+			secret, err := keeper.GetSecret(ctx, ID)
 			if err != nil {
 				switch {
 				case errors.Is(err, client.ErrUnauthorized):
@@ -333,7 +444,27 @@ func SetupCommands(ctx context.Context, keeper Keeper) *ishell.Shell {
 				return
 			}
 
+			if c.MultiChoice([]string{"No", "Yes"}, fmt.Sprintf("Delete %q?", secret.Name)) != 1 {
+				return
+			}
+
+			err = keeper.DeleteSecret(ctx, ID, secret.Revision)
+			if err != nil {
+				switch {
+				case errors.Is(err, client.ErrUnauthorized):
+					c.Println("Please login first.")
+				case errors.Is(err, storage.ErrSecretNotFound):
+					c.Println("Secret not found")
+				case errors.Is(err, client.ErrConflict):
+					c.Println("Someone changed this secret in the meantime. Review it & try again.")
+				default:
+					c.Println("Unexpected error:", err)
+				}
+				return
+			}
+
 			c.Println("Secret deleted")
+			printOffline(c, keeper)
 		},
 	})
 
@@ -352,28 +483,186 @@ func SetupCommands(ctx context.Context, keeper Keeper) *ishell.Shell {
 				return
 			}
 
-			secret, err := keeper.GetSecret(ctx, ID)
-			if err != nil {
+			for {
+				secret, err := keeper.GetSecret(ctx, ID)
+				if err != nil {
+					switch {
+					case errors.Is(err, client.ErrUnauthorized):
+						c.Println("Please login first.")
+					case errors.Is(err, storage.ErrSecretNotFound):
+						c.Println("Secret not found")
+					default:
+						c.Println("Unexpected error:", err)
+					}
+					return
+				}
+
+				c.Println("ID: ", secret.ID)
+				c.Println("Name: ", secret.Name)
+				c.Println("Type: ", secret.Type)
+
+				var path string
+				if secret.Type == model.BinaryType {
+					c.Print("File path (empty keeps current file): ")
+					path = c.ReadLine()
+				}
+
+				payload, err := editPayload(c, secret.Type, secret.Payload)
+				if err != nil {
+					c.Println("Unexpected error:", err)
+					return
+				}
+				c.Print("Metadata (EOF to finish, empty keeps current): ")
+				meta := secret.Meta
+				if value := c.ReadMultiLines("EOF"); value != "" {
+					meta = []byte(value)
+				}
+
+				// keeping the data key keeps the secret readable by recipients
+				data := &model.Secret{
+					Name:     secret.Name,
+					Type:     secret.Type,
+					Payload:  payload,
+					Meta:     meta,
+					Key:      secret.Key,
+					UUID:     secret.UUID,
+					Revision: secret.Revision,
+				}
+				if path != "" {
+					_, err = keeper.UpdateFile(ctx, ID, data, path)
+				} else {
+					_, err = keeper.UpdateSecret(ctx, ID, data)
+				}
+				if err == nil {
+					c.Println("Secret updated")
+					printOffline(c, keeper)
+					return
+				}
+
 				switch {
 				case errors.Is(err, client.ErrUnauthorized):
 					c.Println("Please login first.")
 				case errors.Is(err, storage.ErrSecretNotFound):
 					c.Println("Secret not found")
+				case errors.Is(err, client.ErrConflict):
+					if c.MultiChoice([]string{"No", "Yes"}, "Someone changed this secret; reload?") == 1 {
+						continue
+					}
+				case errors.Is(err, client.ErrTooLarge):
+					c.Println("File is too large")
+				case errors.Is(err, client.ErrOffline):
+					c.Println("Files can't be saved while offline.")
 				default:
 					c.Println("Unexpected error:", err)
 				}
 				return
 			}
-
-			c.Println("ID: ", secret.ID)
-			c.Println("Name: ", secret.Name)
-			c.Println("Type: ", secret.Type)
-
-			// TODO: decrypt payload & meta
-
-			// c.ReadLineWithDefault()
 		},
 	})
 
+	shell.AddCmd(historyCmd(ctx, keeper))
+	shell.AddCmd(syncCmd(ctx, keeper))
+	shell.AddCmd(twoFactorCmd(ctx, keeper))
+	shell.AddCmd(generateCmd())
+	shell.AddCmd(auditCmd(ctx, keeper))
+	shell.AddCmd(importCmd(ctx, keeper))
+	shell.AddCmd(exportCmd(ctx, keeper))
+	shell.AddCmd(restoreCmd(ctx, keeper))
+	shell.AddCmd(mkdirCmd(ctx, keeper))
+	shell.AddCmd(rmdirCmd(ctx, keeper))
+	shell.AddCmd(mvCmd(ctx, keeper))
+	shell.AddCmd(tagCmd(ctx, keeper))
+	shell.AddCmd(favCmd(ctx, keeper))
+	shell.AddCmd(searchCmd(ctx, keeper))
+	shell.AddCmd(fingerprintCmd(keeper))
+	shell.AddCmd(shareCmd(ctx, keeper))
+	shell.AddCmd(unshareCmd(ctx, keeper))
+	shell.AddCmd(sharedCmd(ctx, keeper))
+	shell.AddCmd(linkCmd(ctx, keeper))
+	shell.AddCmd(openLinkCmd(ctx, keeper))
+	shell.AddCmd(emergencyCmd(ctx, keeper))
+	shell.AddCmd(emergencyGrantCmd(ctx, keeper))
+	shell.AddCmd(emergencyRevokeCmd(ctx, keeper))
+	shell.AddCmd(emergencyRequestCmd(ctx, keeper))
+	shell.AddCmd(emergencyRejectCmd(ctx, keeper))
+	shell.AddCmd(emergencyVaultCmd(ctx, keeper))
+	shell.AddCmd(auditLogCmd(ctx, keeper))
+
 	return shell
 }
+
+// saveFile offers to save the decrypted contents of a binary secret.
+func saveFile(ctx context.Context, c *ishell.Context, keeper Keeper, secret *model.Secret) {
+	c.Print("Save file to (empty skips): ")
+	path := c.ReadLine()
+	if path == "" {
+		return
+	}
+
+	if err := keeper.SaveFile(ctx, secret, path); err != nil {
+		switch {
+		case errors.Is(err, client.ErrUnauthorized):
+			c.Println("Please login first.")
+		case errors.Is(err, storage.ErrBlobNotFound):
+			c.Println("File contents not found")
+		case errors.Is(err, client.ErrOffline):
+			c.Println("Files can't be downloaded while offline.")
+		default:
+			c.Println("Unexpected error:", err)
+		}
+		return
+	}
+
+	c.Println("File saved to", path)
+}
+
+// printPayload prints decrypted metadata & payload of a secret.
+func printPayload(c *ishell.Context, rtype model.ResourceType, meta, payload []byte) {
+	c.Println("Metadata: ", string(meta))
+	switch rtype {
+	case model.LoginPasswordType:
+		lp := model.LoginPassword{}
+		if err := json.Unmarshal(payload, &lp); err != nil {
+			c.Println("Unexpected error:", err)
+			return
+		}
+		c.Println("Login: ", lp.Login)
+		c.Println("Password: ", lp.Password)
+		if lp.URL != "" {
+			c.Println("URL: ", lp.URL)
+		}
+	case model.TextType:
+		t := model.Text{}
+		if err := json.Unmarshal(payload, &t); err != nil {
+			c.Println("Unexpected error:", err)
+			return
+		}
+		c.Println("Text: ", t.Value)
+	case model.BinaryType:
+		b := model.Binary{}
+		if err := json.Unmarshal(payload, &b); err != nil {
+			c.Println("Unexpected error:", err)
+			return
+		}
+		c.Println("Binary name: ", b.Name)
+		if b.Key == nil {
+			c.Println("Size: ", len(b.Value))
+		} else {
+			c.Println("Size: ", b.Size)
+		}
+	case model.BankCardType:
+		bc := model.BankCard{}
+		if err := json.Unmarshal(payload, &bc); err != nil {
+			c.Println("Unexpected error:", err)
+			return
+		}
+		c.Println("Number: ", bc.Number)
+		c.Println("Expire at: ", bc.ExpireAt)
+		c.Println("Name: ", bc.Name)
+		c.Println("Surname: ", bc.Surname)
+	case model.OTPType:
+		printOTP(c, payload)
+	default:
+		c.Println("Unknown type")
+	}
+}
@@ -0,0 +1,74 @@
+package commander
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/abiosoft/ishell/v2"
+	"github.com/nbvehbq/go-password-keeper/internal/model"
+	"github.com/nbvehbq/go-password-keeper/internal/otp"
+)
+
+// editPayload asks for new field values of a decrypted payload, offering
+// the current ones as defaults. Empty password keeps the current password.
+// Files are replaced by the update command itself.
+func editPayload(c *ishell.Context, rtype model.ResourceType, payload []byte) ([]byte, error) {
+	switch rtype {
+	case model.LoginPasswordType:
+		entity := &model.LoginPassword{}
+		if err := json.Unmarshal(payload, entity); err != nil {
+			return nil, err
+		}
+		c.Print("Login: ")
+		entity.Login = c.ReadLineWithDefault(entity.Login)
+		if password := readPassword(c, "Password (empty keeps current): "); password != "" && password != entity.Password {
+			entity.Password = password
+			entity.PasswordChangedAt = time.Now().UTC()
+		}
+		c.Print("URL: ")
+		entity.URL = c.ReadLineWithDefault(entity.URL)
+		return json.Marshal(entity)
+	case model.TextType:
+		entity := &model.Text{}
+		if err := json.Unmarshal(payload, entity); err != nil {
+			return nil, err
+		}
+		c.Println("Current text:", entity.Value)
+		c.Print("Text (EOF to finish, empty keeps current): ")
+		if value := c.ReadMultiLines("EOF"); value != "" {
+			entity.Value = value
+		}
+		return json.Marshal(entity)
+	case model.BankCardType:
+		entity := &model.BankCard{}
+		if err := json.Unmarshal(payload, entity); err != nil {
+			return nil, err
+		}
+		c.Print("Number: ")
+		entity.Number = c.ReadLineWithDefault(entity.Number)
+		c.Print("ExpireAt: ")
+		entity.ExpireAt = c.ReadLineWithDefault(entity.ExpireAt)
+		c.Print("Name: ")
+		entity.Name = c.ReadLineWithDefault(entity.Name)
+		c.Print("Surname: ")
+		entity.Surname = c.ReadLineWithDefault(entity.Surname)
+		return json.Marshal(entity)
+	case model.OTPType:
+		entity := &model.OTP{}
+		if err := json.Unmarshal(payload, entity); err != nil {
+			return nil, err
+		}
+		c.Println("Current URI:", entity.URI)
+		c.Print("otpauth:// URI or secret (empty keeps current): ")
+		if value := c.ReadLine(); value != "" {
+			var account string
+			if key, err := otp.Parse(entity.URI); err == nil {
+				account = key.Account
+			}
+			return parseOTP(value, account)
+		}
+		return payload, nil
+	default:
+		return payload, nil
+	}
+}
@@ -0,0 +1,250 @@
+package commander
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/abiosoft/ishell/v2"
+	"github.com/nbvehbq/go-password-keeper/internal/client"
+	"github.com/nbvehbq/go-password-keeper/internal/model"
+	"github.com/nbvehbq/go-password-keeper/internal/storage"
+)
+
+const defaultWaitDays = 7
+
+// printEmergencyError prints errors of the emergency access commands.
+func printEmergencyError(c *ishell.Context, err error) {
+	switch {
+	case errors.Is(err, client.ErrUnauthorized):
+		c.Println("Please login first.")
+	case errors.Is(err, client.ErrOffline):
+		c.Println("Emergency access needs the server, it is unreachable.")
+	case errors.Is(err, client.ErrRecipientNotFound):
+		c.Println("User not found or can't be an emergency contact.")
+	case errors.Is(err, client.ErrRecipientKeyChanged):
+		c.Println("The public key of this user has changed. Grant access again to review it.")
+	case errors.Is(err, storage.ErrEmergencyNotFound):
+		c.Println("Emergency access not found")
+	case errors.Is(err, storage.ErrEmergencyExists):
+		c.Println("This user already is your emergency contact.")
+	case errors.Is(err, storage.ErrEmergencyNotPending):
+		c.Println("There is no request to reject, or the wait period is over.")
+	case errors.Is(err, storage.ErrEmergencyWaiting):
+		c.Println("Access is not released yet. Request it first and wait for the wait period.")
+	default:
+		c.Println("Unexpected error:", err)
+	}
+}
+
+func emergencyStatus(grant *model.EmergencyGrant) string {
+	switch {
+	case grant.RequestedAt == nil:
+		return "idle"
+	case grant.Released(time.Now()):
+		return "released"
+	default:
+		return "requested, releases at " + grant.ReleasesAt().Local().Format(time.DateTime)
+	}
+}
+
+// emergencyCmd lists the grants given and received by the user.
+func emergencyCmd(ctx context.Context, keeper Keeper) *ishell.Cmd {
+	return &ishell.Cmd{
+		Name: "emergency",
+		Help: "List emergency access you granted & received",
+		Func: func(c *ishell.Context) {
+			list, err := keeper.ListEmergencyGrants(ctx)
+			if err != nil {
+				printEmergencyError(c, err)
+				return
+			}
+			if len(list) == 0 {
+				c.Println("No emergency access granted.")
+				return
+			}
+
+			for i := range list {
+				v := &list[i]
+				c.Printf("| %4d | %10s -> %-10s | %2d day(s) | %s |\n", v.ID, v.GrantorLogin, v.GranteeLogin,
+					v.WaitDays, emergencyStatus(v))
+			}
+		},
+	}
+}
+
+// emergencyGrantCmd makes another user an emergency contact. Their access
+// is sealed on this machine.
+func emergencyGrantCmd(ctx context.Context, keeper Keeper) *ishell.Cmd {
+	return &ishell.Cmd{
+		Name: "emergency-grant",
+		Help: "Make a user your emergency contact",
+		Func: func(c *ishell.Context) {
+			c.ShowPrompt(false)
+			defer c.ShowPrompt(true)
+
+			c.Print("Login: ")
+			login := strings.TrimSpace(c.ReadLine())
+
+			ok, err := confirmRecipientKey(ctx, c, keeper, login)
+			if err != nil {
+				printEmergencyError(c, err)
+				return
+			}
+			if !ok {
+				c.Println("Emergency access not granted.")
+				return
+			}
+
+			c.Print("Wait period in days (1-90): ")
+			days, err := strconv.Atoi(c.ReadLineWithDefault(strconv.Itoa(defaultWaitDays)))
+			if err != nil || days < 1 || days > 90 {
+				c.Println("Wait period must be 1 to 90 days.")
+				return
+			}
+
+			if err := keeper.GrantEmergencyAccess(ctx, login, days); err != nil {
+				printEmergencyError(c, err)
+				return
+			}
+
+			c.Printf("%s can request access to your vault. You have %d day(s) to reject a request.\n", login, days)
+		},
+	}
+}
+
+// emergencyRevokeCmd ends a grant, given or received.
+func emergencyRevokeCmd(ctx context.Context, keeper Keeper) *ishell.Cmd {
+	return &ishell.Cmd{
+		Name: "emergency-revoke",
+		Help: "End emergency access you granted or received",
+		Func: func(c *ishell.Context) {
+			c.ShowPrompt(false)
+			defer c.ShowPrompt(true)
+
+			c.Print("ID: ")
+			ID, err := strconv.ParseInt(c.ReadLine(), 10, 64)
+			if err != nil {
+				c.Println("Unexpected error:", err)
+				return
+			}
+
+			if err := keeper.RevokeEmergencyAccess(ctx, ID); err != nil {
+				printEmergencyError(c, err)
+				return
+			}
+
+			c.Println("Emergency access revoked.")
+		},
+	}
+}
+
+// emergencyRequestCmd asks for the vault of a grantor.
+func emergencyRequestCmd(ctx context.Context, keeper Keeper) *ishell.Cmd {
+	return &ishell.Cmd{
+		Name: "emergency-request",
+		Help: "Request emergency access to a vault",
+		Func: func(c *ishell.Context) {
+			c.ShowPrompt(false)
+			defer c.ShowPrompt(true)
+
+			c.Print("ID: ")
+			ID, err := strconv.ParseInt(c.ReadLine(), 10, 64)
+			if err != nil {
+				c.Println("Unexpected error:", err)
+				return
+			}
+
+			grant, err := keeper.RequestEmergencyAccess(ctx, ID)
+			if err != nil {
+				printEmergencyError(c, err)
+				return
+			}
+
+			c.Printf("Access requested. Unless %s rejects it, the vault is released at %s.\n",
+				grant.GrantorLogin, grant.ReleasesAt().Local().Format(time.DateTime))
+		},
+	}
+}
+
+// emergencyRejectCmd rejects a pending request to a grant of the user.
+func emergencyRejectCmd(ctx context.Context, keeper Keeper) *ishell.Cmd {
+	return &ishell.Cmd{
+		Name: "emergency-reject",
+		Help: "Reject a pending emergency access request",
+		Func: func(c *ishell.Context) {
+			c.ShowPrompt(false)
+			defer c.ShowPrompt(true)
+
+			c.Print("ID: ")
+			ID, err := strconv.ParseInt(c.ReadLine(), 10, 64)
+			if err != nil {
+				c.Println("Unexpected error:", err)
+				return
+			}
+
+			if err := keeper.RejectEmergencyAccess(ctx, ID); err != nil {
+				printEmergencyError(c, err)
+				return
+			}
+
+			c.Println("Request rejected.")
+		},
+	}
+}
+
+// emergencyVaultCmd lists the secrets of a released vault and shows one of
+// them.
+func emergencyVaultCmd(ctx context.Context, keeper Keeper) *ishell.Cmd {
+	return &ishell.Cmd{
+		Name: "emergency-vault",
+		Help: "Show a vault released to you",
+		Func: func(c *ishell.Context) {
+			c.ShowPrompt(false)
+			defer c.ShowPrompt(true)
+
+			c.Print("ID: ")
+			ID, err := strconv.ParseInt(c.ReadLine(), 10, 64)
+			if err != nil {
+				c.Println("Unexpected error:", err)
+				return
+			}
+
+			vault, err := keeper.OpenEmergencyVault(ctx, ID)
+			if err != nil {
+				printEmergencyError(c, err)
+				return
+			}
+			if len(vault.Secrets) == 0 {
+				c.Printf("The vault of %s is empty.\n", vault.Grant.GrantorLogin)
+				return
+			}
+
+			for _, v := range vault.Secrets {
+				c.Printf("| %4d | %10s | %s |\n", v.ID, v.Name, resorces[v.Type])
+			}
+
+			c.Print("ID to show (empty to skip): ")
+			line := strings.TrimSpace(c.ReadLine())
+			if line == "" {
+				return
+			}
+			secretID, err := strconv.ParseInt(line, 10, 64)
+			if err != nil {
+				c.Println("Unexpected error:", err)
+				return
+			}
+
+			for _, v := range vault.Secrets {
+				if v.ID == secretID {
+					c.Println("Name: ", v.Name)
+					printPayload(c, v.Type, v.Meta, v.Payload)
+					return
+				}
+			}
+			c.Println("Secret not found")
+		},
+	}
+}
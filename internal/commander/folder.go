@@ -0,0 +1,378 @@
+package commander
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/abiosoft/ishell/v2"
+	"github.com/nbvehbq/go-password-keeper/internal/client"
+	"github.com/nbvehbq/go-password-keeper/internal/model"
+	"github.com/nbvehbq/go-password-keeper/internal/storage"
+)
+
+// folderPaths maps IDs of folders to their paths, like "/work/mail".
+func folderPaths(folders []model.Folder) map[int64]string {
+	byID := make(map[int64]model.Folder, len(folders))
+	for _, v := range folders {
+		byID[v.ID] = v
+	}
+
+	paths := make(map[int64]string, len(folders))
+	var path func(id int64) string
+	path = func(id int64) string {
+		if p, ok := paths[id]; ok {
+			return p
+		}
+		v := byID[id]
+		p := "/" + string(v.Name)
+		if parent, ok := byID[v.ParentID]; ok && parent.ID != id {
+			p = path(v.ParentID) + p
+		}
+		paths[id] = p
+		return p
+	}
+	for _, v := range folders {
+		path(v.ID)
+	}
+
+	return paths
+}
+
+// splitPath returns the names of the folders of path, "/" being the top
+// level.
+func splitPath(path string) []string {
+	var names []string
+	for _, v := range strings.Split(path, "/") {
+		if v = strings.TrimSpace(v); v != "" {
+			names = append(names, v)
+		}
+	}
+	return names
+}
+
+// resolveFolder returns the ID of the folder at path, 0 for the top level.
+// Missing folders are created if create is set, otherwise
+// storage.ErrFolderNotFound is returned.
+func resolveFolder(ctx context.Context, keeper Keeper, path string, create bool) (int64, error) {
+	names := splitPath(path)
+	if len(names) == 0 {
+		return 0, nil
+	}
+
+	folders, err := keeper.ListFolders(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	var parentID int64
+next:
+	for _, name := range names {
+		for _, v := range folders {
+			if v.ParentID == parentID && string(v.Name) == name {
+				parentID = v.ID
+				continue next
+			}
+		}
+
+		if !create {
+			return 0, storage.ErrFolderNotFound
+		}
+		if parentID, err = keeper.CreateFolder(ctx, parentID, name); err != nil {
+			return 0, err
+		}
+	}
+
+	return parentID, nil
+}
+
+// resolveTags returns the IDs of tags with the given names, creating the
+// missing ones.
+func resolveTags(ctx context.Context, keeper Keeper, names []string) ([]int64, error) {
+	tags, err := keeper.ListTags(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	IDs := make([]int64, 0, len(names))
+	for _, name := range names {
+		i := slices.IndexFunc(tags, func(v model.Tag) bool { return string(v.Name) == name })
+		if i >= 0 {
+			IDs = append(IDs, tags[i].ID)
+			continue
+		}
+
+		id, err := keeper.CreateTag(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, model.Tag{ID: id, Name: []byte(name)})
+		IDs = append(IDs, id)
+	}
+
+	return IDs, nil
+}
+
+// printPlacementError prints errors of commands managing folders & tags.
+func printPlacementError(c *ishell.Context, err error) {
+	switch {
+	case errors.Is(err, client.ErrUnauthorized):
+		c.Println("Please login first.")
+	case errors.Is(err, client.ErrOffline):
+		c.Println("Folders & tags can't be changed while offline.")
+	case errors.Is(err, client.ErrPendingChanges):
+		c.Println("Secret has changes not synced yet. Run sync first.")
+	case errors.Is(err, storage.ErrSecretNotFound):
+		c.Println("Secret not found")
+	case errors.Is(err, storage.ErrFolderNotFound):
+		c.Println("Folder not found")
+	case errors.Is(err, storage.ErrFolderNotEmpty):
+		c.Println("Folder is not empty")
+	case errors.Is(err, storage.ErrTagNotFound):
+		c.Println("Tag not found")
+	default:
+		c.Println("Unexpected error:", err)
+	}
+}
+
+// readArg returns the arguments of the command, or reads them after prompt.
+func readArg(c *ishell.Context, prompt string) string {
+	if len(c.Args) > 0 {
+		return strings.Join(c.Args, " ")
+	}
+	c.Print(prompt)
+	return strings.TrimSpace(c.ReadLine())
+}
+
+func mkdirCmd(ctx context.Context, keeper Keeper) *ishell.Cmd {
+	return &ishell.Cmd{
+		Name: "mkdir",
+		Help: "Create a folder, e.g. mkdir /work/mail",
+		Func: func(c *ishell.Context) {
+			c.ShowPrompt(false)
+			defer c.ShowPrompt(true)
+
+			path := readArg(c, "Path: ")
+			if len(splitPath(path)) == 0 {
+				c.Println("Path is required.")
+				return
+			}
+
+			if _, err := resolveFolder(ctx, keeper, path, true); err != nil {
+				printPlacementError(c, err)
+				return
+			}
+
+			c.Println("Folder created.")
+		},
+	}
+}
+
+func rmdirCmd(ctx context.Context, keeper Keeper) *ishell.Cmd {
+	return &ishell.Cmd{
+		Name: "rmdir",
+		Help: "Remove an empty folder",
+		Func: func(c *ishell.Context) {
+			c.ShowPrompt(false)
+			defer c.ShowPrompt(true)
+
+			path := readArg(c, "Path: ")
+			if len(splitPath(path)) == 0 {
+				c.Println("Path is required.")
+				return
+			}
+
+			ID, err := resolveFolder(ctx, keeper, path, false)
+			if err == nil {
+				err = keeper.DeleteFolder(ctx, ID)
+			}
+			if err != nil {
+				printPlacementError(c, err)
+				return
+			}
+
+			c.Println("Folder removed.")
+		},
+	}
+}
+
+func mvCmd(ctx context.Context, keeper Keeper) *ishell.Cmd {
+	return &ishell.Cmd{
+		Name: "mv",
+		Help: `Move a secret to a folder, "/" for the top level`,
+		Func: func(c *ishell.Context) {
+			c.ShowPrompt(false)
+			defer c.ShowPrompt(true)
+
+			c.Print("ID: ")
+			ID, err := strconv.ParseInt(c.ReadLine(), 10, 64)
+			if err != nil {
+				c.Println("Unexpected error:", err)
+				return
+			}
+			c.Print("Folder: ")
+			path := strings.TrimSpace(c.ReadLine())
+
+			folderID, err := resolveFolder(ctx, keeper, path, false)
+			if err == nil {
+				err = keeper.PatchSecret(ctx, ID, &model.SecretPatch{FolderID: &folderID})
+			}
+			if err != nil {
+				printPlacementError(c, err)
+				return
+			}
+
+			c.Println("Secret moved.")
+		},
+	}
+}
+
+func tagCmd(ctx context.Context, keeper Keeper) *ishell.Cmd {
+	return &ishell.Cmd{
+		Name: "tag",
+		Help: "Set the tags of a secret",
+		Func: func(c *ishell.Context) {
+			c.ShowPrompt(false)
+			defer c.ShowPrompt(true)
+
+			c.Print("ID: ")
+			ID, err := strconv.ParseInt(c.ReadLine(), 10, 64)
+			if err != nil {
+				c.Println("Unexpected error:", err)
+				return
+			}
+			c.Print("Tags (comma separated, empty to clear): ")
+			var names []string
+			for _, v := range strings.Split(c.ReadLine(), ",") {
+				if v = strings.TrimSpace(v); v != "" && !slices.Contains(names, v) {
+					names = append(names, v)
+				}
+			}
+
+			tags, err := resolveTags(ctx, keeper, names)
+			if err == nil {
+				err = keeper.PatchSecret(ctx, ID, &model.SecretPatch{Tags: tags, SetTags: true})
+			}
+			if err != nil {
+				printPlacementError(c, err)
+				return
+			}
+
+			c.Println("Tags saved.")
+		},
+	}
+}
+
+func favCmd(ctx context.Context, keeper Keeper) *ishell.Cmd {
+	return &ishell.Cmd{
+		Name: "fav",
+		Help: "Mark a secret as favourite or unmark it",
+		Func: func(c *ishell.Context) {
+			c.ShowPrompt(false)
+			defer c.ShowPrompt(true)
+
+			c.Print("ID: ")
+			ID, err := strconv.ParseInt(c.ReadLine(), 10, 64)
+			if err != nil {
+				c.Println("Unexpected error:", err)
+				return
+			}
+
+			secret, err := keeper.GetSecret(ctx, ID)
+			if err != nil {
+				printPlacementError(c, err)
+				return
+			}
+
+			favorite := !secret.Favorite
+			if err := keeper.PatchSecret(ctx, ID, &model.SecretPatch{Favorite: &favorite}); err != nil {
+				printPlacementError(c, err)
+				return
+			}
+
+			if favorite {
+				c.Println("Added to favourites.")
+			} else {
+				c.Println("Removed from favourites.")
+			}
+		},
+	}
+}
+
+// secretFilter reads the filter of the list command from its arguments:
+// a folder path, "#tag" and "*" for favourites.
+func secretFilter(ctx context.Context, keeper Keeper, args []string) (model.SecretFilter, error) {
+	var filter model.SecretFilter
+
+	for _, arg := range args {
+		switch {
+		case arg == "*":
+			filter.Favorite = true
+		case strings.HasPrefix(arg, "#"):
+			tags, err := keeper.ListTags(ctx)
+			if err != nil {
+				return filter, err
+			}
+			i := slices.IndexFunc(tags, func(v model.Tag) bool { return string(v.Name) == arg[1:] })
+			if i < 0 {
+				return filter, storage.ErrTagNotFound
+			}
+			filter.TagID = tags[i].ID
+		default:
+			folderID, err := resolveFolder(ctx, keeper, arg, false)
+			if err != nil {
+				return filter, err
+			}
+			filter.FolderID = folderID
+		}
+	}
+
+	return filter, nil
+}
+
+// formatSecrets formats the list of secrets with their folders & tags.
+// Names of folders & tags are left out while offline.
+func formatSecrets(ctx context.Context, keeper Keeper, list []model.Secret) string {
+	var paths map[int64]string
+	tagNames := make(map[int64]string)
+	if !keeper.Offline() {
+		if folders, err := keeper.ListFolders(ctx); err == nil {
+			paths = folderPaths(folders)
+		}
+		if tags, err := keeper.ListTags(ctx); err == nil {
+			for _, v := range tags {
+				tagNames[v.ID] = string(v.Name)
+			}
+		}
+	}
+
+	text := make([]string, len(list))
+	for i, v := range list {
+		mark := " "
+		if v.Favorite {
+			mark = "*"
+		}
+
+		folder := "/"
+		if v.FolderID != 0 {
+			var ok bool
+			if folder, ok = paths[v.FolderID]; !ok {
+				folder = fmt.Sprintf("#%d", v.FolderID)
+			}
+		}
+
+		tags := make([]string, len(v.Tags))
+		for j, id := range v.Tags {
+			if tags[j] = tagNames[id]; tags[j] == "" {
+				tags[j] = strconv.FormatInt(id, 10)
+			}
+		}
+
+		text[i] = fmt.Sprintf("| %4d |%s| %10s | %s | %s |", v.ID, mark, v.Name, folder, strings.Join(tags, ", "))
+	}
+
+	return strings.Join(text, "\n")
+}
@@ -0,0 +1,99 @@
+package commander
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/abiosoft/ishell/v2"
+	"github.com/nbvehbq/go-password-keeper/internal/generator"
+)
+
+const defaultWords = 6
+
+// generateCmd generates a password or a passphrase without saving it.
+func generateCmd() *ishell.Cmd {
+	return &ishell.Cmd{
+		Name: "generate",
+		Help: "Generate a random password or diceware passphrase",
+		Func: func(c *ishell.Context) {
+			c.ShowPrompt(false)
+			defer c.ShowPrompt(true)
+
+			value, ok := readGenerated(c)
+			if !ok {
+				return
+			}
+			c.Println("Generated: ", value)
+		},
+	}
+}
+
+// readPassword asks for a password, offering to generate it instead. Empty
+// input is returned as is.
+func readPassword(c *ishell.Context, prompt string) string {
+	c.Print("Generate a password? (y/N): ")
+	if answer := strings.ToLower(strings.TrimSpace(c.ReadLine())); answer == "y" || answer == "yes" {
+		if value, ok := readGenerated(c); ok {
+			c.Println("Generated: ", value)
+			return value
+		}
+	}
+
+	c.Print(prompt)
+	return c.ReadPassword()
+}
+
+// readGenerated asks for a generation policy and generates a password or a
+// passphrase, reporting its entropy.
+func readGenerated(c *ishell.Context) (string, bool) {
+	choice := c.MultiChoice([]string{"Password", "Passphrase"}, "What do you want to generate?")
+
+	var value string
+	var entropy float64
+	var err error
+
+	if choice == 0 {
+		policy := generator.DefaultPolicy
+
+		c.Print("Length: ")
+		if policy.Length, err = strconv.Atoi(c.ReadLineWithDefault(strconv.Itoa(policy.Length))); err != nil {
+			c.Println("Unexpected error:", err)
+			return "", false
+		}
+
+		c.Print("Character classes (l - lower, u - upper, d - digits, s - symbols): ")
+		classes := c.ReadLineWithDefault("luds")
+		policy.Lower = strings.Contains(classes, "l")
+		policy.Upper = strings.Contains(classes, "u")
+		policy.Digits = strings.Contains(classes, "d")
+		policy.Symbols = strings.Contains(classes, "s")
+
+		c.Print("Exclude ambiguous characters like l, 1, O, 0 (y/n): ")
+		policy.ExcludeAmbiguous = strings.HasPrefix(strings.ToLower(c.ReadLineWithDefault("n")), "y")
+
+		value, err = generator.Password(policy)
+		entropy = policy.Entropy()
+	} else {
+		var count int
+
+		c.Print("Words: ")
+		if count, err = strconv.Atoi(c.ReadLineWithDefault(strconv.Itoa(defaultWords))); err != nil {
+			c.Println("Unexpected error:", err)
+			return "", false
+		}
+
+		c.Print("Separator: ")
+		separator := c.ReadLineWithDefault("-")
+
+		value, err = generator.Passphrase(count, separator)
+		entropy = generator.PassphraseEntropy(count)
+	}
+	if err != nil {
+		c.Println("Unexpected error:", err)
+		return "", false
+	}
+
+	c.Printf("Entropy: ~%.0f bits\n", entropy)
+
+	return value, true
+}
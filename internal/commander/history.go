@@ -0,0 +1,102 @@
+package commander
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/abiosoft/ishell/v2"
+	"github.com/nbvehbq/go-password-keeper/internal/client"
+	"github.com/nbvehbq/go-password-keeper/internal/storage"
+)
+
+// historyCmd lists previous revisions of a secret, shows one of them and
+// optionally restores it.
+func historyCmd(ctx context.Context, keeper Keeper) *ishell.Cmd {
+	return &ishell.Cmd{
+		Name: "history",
+		Help: "Show previous revisions of a secret & restore one of them",
+		Func: func(c *ishell.Context) {
+			c.ShowPrompt(false)
+			defer c.ShowPrompt(true)
+
+			c.Print("ID: ")
+			ID, err := strconv.ParseInt(c.ReadLine(), 10, 64)
+			if err != nil {
+				c.Println("Unexpected error:", err)
+				return
+			}
+
+			list, err := keeper.ListVersions(ctx, ID)
+			if err != nil {
+				switch {
+				case errors.Is(err, client.ErrUnauthorized):
+					c.Println("Please login first.")
+				case errors.Is(err, storage.ErrSecretNotFound):
+					c.Println("Secret not found")
+				default:
+					c.Println("Unexpected error:", err)
+				}
+				return
+			}
+
+			if len(list) == 0 {
+				c.Println("No previous revisions.")
+				return
+			}
+
+			for _, v := range list {
+				c.Printf("| %4d | %s | %10s |\n", v.Version, v.CreatedAt.Local().Format(time.DateTime), v.Name)
+			}
+
+			c.Print("Version to show (empty to skip): ")
+			line := strings.TrimSpace(c.ReadLine())
+			if line == "" {
+				return
+			}
+
+			version, err := strconv.ParseInt(line, 10, 64)
+			if err != nil {
+				c.Println("Unexpected error:", err)
+				return
+			}
+
+			v, err := keeper.GetVersion(ctx, ID, version)
+			if err != nil {
+				switch {
+				case errors.Is(err, client.ErrUnauthorized):
+					c.Println("Please login first.")
+				case errors.Is(err, storage.ErrVersionNotFound):
+					c.Println("Version not found")
+				default:
+					c.Println("Unexpected error:", err)
+				}
+				return
+			}
+
+			c.Println("Version: ", v.Version)
+			c.Println("Name: ", v.Name)
+			printPayload(c, v.Type, v.Meta, v.Payload)
+
+			if c.MultiChoice([]string{"No", "Yes"}, "Restore this version?") != 1 {
+				return
+			}
+
+			if err := keeper.RestoreVersion(ctx, ID, version); err != nil {
+				switch {
+				case errors.Is(err, client.ErrUnauthorized):
+					c.Println("Please login first.")
+				case errors.Is(err, storage.ErrVersionNotFound):
+					c.Println("Version not found")
+				default:
+					c.Println("Unexpected error:", err)
+				}
+				return
+			}
+
+			c.Println("Version restored")
+		},
+	}
+}
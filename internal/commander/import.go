@@ -0,0 +1,104 @@
+package commander
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/abiosoft/ishell/v2"
+	"github.com/nbvehbq/go-password-keeper/internal/client"
+	"github.com/nbvehbq/go-password-keeper/internal/importer"
+	"github.com/nbvehbq/go-password-keeper/internal/model"
+	"github.com/nbvehbq/go-password-keeper/internal/storage"
+)
+
+// importCmd imports the export of another password manager. Entries whose
+// name is already taken in the vault are skipped, like duplicates within
+// the export.
+func importCmd(ctx context.Context, keeper Keeper) *ishell.Cmd {
+	return &ishell.Cmd{
+		Name: "import",
+		Help: "Import secrets from another password manager",
+		Func: func(c *ishell.Context) {
+			c.ShowPrompt(false)
+			defer c.ShowPrompt(true)
+
+			formats := make([]string, len(importer.Formats))
+			for i, v := range importer.Formats {
+				formats[i] = v.Description
+			}
+			choice := c.MultiChoice(formats, "Witch format you want to import?")
+			if choice < 0 {
+				return
+			}
+
+			c.Print("Export file: ")
+			path := strings.TrimSpace(c.ReadLine())
+
+			res, err := importer.ReadFile(importer.Formats[choice].Name, path)
+			if err != nil {
+				c.Println("Unexpected error:", err)
+				return
+			}
+
+			skipped := res.Skipped
+			var imported int
+			for _, item := range res.Items {
+				err := importItem(ctx, keeper, item)
+				switch {
+				case err == nil:
+					imported++
+				case errors.Is(err, client.ErrUnauthorized):
+					c.Println("Please login first.")
+					return
+				case errors.Is(err, storage.ErrSecretExists):
+					skipped = append(skipped, importer.Skipped{Entry: item.Name, Reason: "already in the vault"})
+				case errors.Is(err, client.ErrTooLarge):
+					skipped = append(skipped, importer.Skipped{Entry: item.Name, Reason: "file is too large"})
+				case errors.Is(err, client.ErrOffline):
+					skipped = append(skipped, importer.Skipped{Entry: item.Name, Reason: "files can't be saved while offline"})
+				default:
+					skipped = append(skipped, importer.Skipped{Entry: item.Name, Reason: err.Error()})
+				}
+			}
+
+			for _, v := range skipped {
+				c.Printf("Skipped %s: %s\n", v.Entry, v.Reason)
+			}
+			c.Printf("Imported %d secrets, skipped %d.\n", imported, len(skipped))
+			printOffline(c, keeper)
+		},
+	}
+}
+
+// importItem creates the secret of an imported item. Files are written to
+// a temporary directory under their original name for CreateFile.
+func importItem(ctx context.Context, keeper Keeper, item importer.Item) error {
+	secret := &model.Secret{
+		Name:    item.Name,
+		Type:    item.Type,
+		Payload: item.Payload,
+		Meta:    []byte(item.Meta),
+	}
+
+	if item.Type != model.BinaryType {
+		_, err := keeper.CreateSecret(ctx, secret)
+		return err
+	}
+
+	dir, err := os.MkdirTemp("", "keeper-import-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, filepath.Base(item.FileName))
+	if err := os.WriteFile(path, item.File, 0o600); err != nil {
+		return err
+	}
+
+	_, err = keeper.CreateFile(ctx, secret, path)
+	return err
+}
@@ -0,0 +1,103 @@
+package commander
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/abiosoft/ishell/v2"
+	"github.com/nbvehbq/go-password-keeper/internal/client"
+	"github.com/nbvehbq/go-password-keeper/internal/storage"
+)
+
+const (
+	defaultLinkViews = 1
+	defaultLinkHours = 24
+)
+
+// printLinkError prints errors of the one-time link commands.
+func printLinkError(c *ishell.Context, err error) {
+	switch {
+	case errors.Is(err, client.ErrUnauthorized):
+		c.Println("Please login first.")
+	case errors.Is(err, client.ErrOffline):
+		c.Println("One-time links need the server, it is unreachable.")
+	case errors.Is(err, storage.ErrSecretNotFound):
+		c.Println("Secret not found")
+	case errors.Is(err, client.ErrLinkFile):
+		c.Println("Files can't be shared by link.")
+	case errors.Is(err, client.ErrInvalidLink):
+		c.Println("This is not a one-time link.")
+	case errors.Is(err, storage.ErrOneTimeNotFound):
+		c.Println("Link was already used or has expired.")
+	default:
+		c.Println("Unexpected error:", err)
+	}
+}
+
+// linkCmd creates a one-time link to a secret for someone without an
+// account.
+func linkCmd(ctx context.Context, keeper Keeper) *ishell.Cmd {
+	return &ishell.Cmd{
+		Name: "link",
+		Help: "Create a one-time link to a secret",
+		Func: func(c *ishell.Context) {
+			c.ShowPrompt(false)
+			defer c.ShowPrompt(true)
+
+			c.Print("ID: ")
+			ID, err := strconv.ParseInt(c.ReadLine(), 10, 64)
+			if err != nil {
+				c.Println("Unexpected error:", err)
+				return
+			}
+
+			c.Print("Max views (1-100): ")
+			views, err := strconv.Atoi(c.ReadLineWithDefault(strconv.Itoa(defaultLinkViews)))
+			if err != nil || views < 1 || views > 100 {
+				c.Println("Max views must be 1 to 100.")
+				return
+			}
+
+			c.Print("Expires in hours (1-168): ")
+			hours, err := strconv.Atoi(c.ReadLineWithDefault(strconv.Itoa(defaultLinkHours)))
+			if err != nil || hours < 1 || hours > 168 {
+				c.Println("Expiry must be 1 to 168 hours.")
+				return
+			}
+
+			link, expiresAt, err := keeper.CreateOneTimeLink(ctx, ID, views, time.Duration(hours)*time.Hour)
+			if err != nil {
+				printLinkError(c, err)
+				return
+			}
+
+			c.Println("Link: ", link)
+			c.Printf("It can be opened %d time(s) until %s. Anyone with the link can read the secret.\n",
+				views, expiresAt.Local().Format(time.DateTime))
+		},
+	}
+}
+
+// openLinkCmd shows the secret behind a one-time link, no login needed.
+func openLinkCmd(ctx context.Context, keeper Keeper) *ishell.Cmd {
+	return &ishell.Cmd{
+		Name: "open-link",
+		Help: "Show the secret behind a one-time link",
+		Func: func(c *ishell.Context) {
+			c.ShowPrompt(false)
+			defer c.ShowPrompt(true)
+
+			c.Print("Link: ")
+			secret, err := keeper.OpenOneTimeLink(ctx, c.ReadLine())
+			if err != nil {
+				printLinkError(c, err)
+				return
+			}
+
+			c.Println("Name: ", secret.Name)
+			printPayload(c, secret.Type, secret.Meta, secret.Payload)
+		},
+	}
+}
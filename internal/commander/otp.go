@@ -0,0 +1,62 @@
+package commander
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/abiosoft/ishell/v2"
+	"github.com/nbvehbq/go-password-keeper/internal/model"
+	"github.com/nbvehbq/go-password-keeper/internal/otp"
+)
+
+// parseOTP accepts an otpauth:// URI as scanned from a QR code, or a bare
+// base32 secret with the default parameters, and returns the payload of an
+// OTP secret.
+func parseOTP(input, account string) ([]byte, error) {
+	input = strings.TrimSpace(input)
+
+	var key *otp.Key
+	if strings.HasPrefix(input, "otpauth://") {
+		var err error
+		if key, err = otp.Parse(input); err != nil {
+			return nil, err
+		}
+	} else {
+		secret, err := otp.DecodeSecret(input)
+		if err != nil || len(secret) == 0 {
+			return nil, otp.ErrInvalidURI
+		}
+		key = &otp.Key{
+			Account:   account,
+			Secret:    secret,
+			Algorithm: otp.SHA1,
+			Digits:    otp.DefaultDigits,
+			Period:    otp.DefaultPeriod,
+		}
+	}
+
+	return json.Marshal(&model.OTP{URI: key.URI()})
+}
+
+// printOTP prints the current code of an OTP secret.
+func printOTP(c *ishell.Context, payload []byte) {
+	entity := model.OTP{}
+	if err := json.Unmarshal(payload, &entity); err != nil {
+		c.Println("Unexpected error:", err)
+		return
+	}
+
+	key, err := otp.Parse(entity.URI)
+	if err != nil {
+		c.Println("Unexpected error:", err)
+		return
+	}
+
+	now := time.Now()
+	if key.Issuer != "" {
+		c.Println("Issuer: ", key.Issuer)
+	}
+	c.Println("Account: ", key.Account)
+	c.Printf("Code: %s (valid for %ds)\n", key.Code(now), int(key.Remaining(now).Seconds()))
+}
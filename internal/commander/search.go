@@ -0,0 +1,121 @@
+package commander
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/abiosoft/ishell/v2"
+	"github.com/nbvehbq/go-password-keeper/internal/client"
+	"github.com/nbvehbq/go-password-keeper/internal/model"
+	"github.com/nbvehbq/go-password-keeper/internal/otp"
+	"github.com/nbvehbq/go-password-keeper/internal/search"
+)
+
+// searchCmd searches the decrypted vault. The index is built on the client
+// for every search and never leaves it.
+func searchCmd(ctx context.Context, keeper Keeper) *ishell.Cmd {
+	return &ishell.Cmd{
+		Name: "search",
+		Help: "Search secrets, e.g. search mail login:alice type:login",
+		Func: func(c *ishell.Context) {
+			c.ShowPrompt(false)
+			defer c.ShowPrompt(true)
+
+			query, err := search.Parse(readArg(c, "Query: "))
+			if err != nil {
+				c.Println("Invalid query:", err)
+				return
+			}
+
+			docs, err := searchDocuments(ctx, keeper)
+			if err != nil {
+				switch {
+				case errors.Is(err, client.ErrUnauthorized):
+					c.Println("Please login first.")
+				default:
+					c.Println("Unexpected error:", err)
+				}
+				return
+			}
+
+			results := search.NewIndex(docs).Match(query)
+			if len(results) == 0 {
+				c.Println("No resources found.")
+				return
+			}
+
+			text := make([]string, len(results))
+			for i, v := range results {
+				text[i] = fmt.Sprintf("| %4d | %10s | %s | %5.2f | %s |", v.ID, v.Name,
+					resorces[v.Type], v.Score, strings.Join(v.Fields, ", "))
+			}
+			c.ShowPaged(strings.Join(text, "\n"))
+		},
+	}
+}
+
+// searchDocuments decrypts the vault into documents to search. Names of
+// folders & tags are left out while offline.
+func searchDocuments(ctx context.Context, keeper Keeper) ([]search.Document, error) {
+	list, err := keeper.OpenSecrets(ctx, model.SecretFilter{})
+	if err != nil {
+		return nil, err
+	}
+
+	var paths map[int64]string
+	tagNames := make(map[int64]string)
+	if !keeper.Offline() {
+		if folders, err := keeper.ListFolders(ctx); err == nil {
+			paths = folderPaths(folders)
+		}
+		if tags, err := keeper.ListTags(ctx); err == nil {
+			for _, v := range tags {
+				tagNames[v.ID] = string(v.Name)
+			}
+		}
+	}
+
+	docs := make([]search.Document, 0, len(list))
+	for _, v := range list {
+		doc := search.Document{
+			ID:     v.ID,
+			Type:   v.Type,
+			Name:   v.Name,
+			Meta:   string(v.Meta),
+			Folder: paths[v.FolderID],
+		}
+		for _, id := range v.Tags {
+			if name, ok := tagNames[id]; ok {
+				doc.Tags = append(doc.Tags, name)
+			}
+		}
+
+		// payloads that fail to parse are still found by name & meta
+		switch v.Type {
+		case model.LoginPasswordType:
+			var entity model.LoginPassword
+			if json.Unmarshal(v.Payload, &entity) == nil {
+				doc.Login, doc.URL = entity.Login, entity.URL
+			}
+		case model.BankCardType:
+			var entity model.BankCard
+			if json.Unmarshal(v.Payload, &entity) == nil {
+				doc.Login = strings.TrimSpace(entity.Name + " " + entity.Surname)
+			}
+		case model.OTPType:
+			var entity model.OTP
+			if json.Unmarshal(v.Payload, &entity) == nil {
+				if key, err := otp.Parse(entity.URI); err == nil {
+					doc.Login, doc.URL = key.Account, key.Issuer
+				}
+			}
+		}
+
+		docs = append(docs, doc)
+	}
+
+	return docs, nil
+}
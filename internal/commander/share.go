@@ -0,0 +1,270 @@
+package commander
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/abiosoft/ishell/v2"
+	"github.com/nbvehbq/go-password-keeper/internal/client"
+	"github.com/nbvehbq/go-password-keeper/internal/model"
+	"github.com/nbvehbq/go-password-keeper/internal/storage"
+)
+
+var permissions = []string{"Read only", "Read & edit"}
+
+// printShareError prints errors of the sharing commands.
+func printShareError(c *ishell.Context, err error) {
+	switch {
+	case errors.Is(err, client.ErrUnauthorized):
+		c.Println("Please login first.")
+	case errors.Is(err, client.ErrOffline):
+		c.Println("Secrets can't be shared while offline.")
+	case errors.Is(err, client.ErrPendingChanges):
+		c.Println("Secret has changes not synced yet. Run sync first.")
+	case errors.Is(err, client.ErrRecipientNotFound):
+		c.Println("User not found or can't receive shared secrets.")
+	case errors.Is(err, client.ErrRecipientKeyChanged):
+		c.Println("The public key of this user has changed. Share with them again to review it.")
+	case errors.Is(err, storage.ErrSecretNotFound):
+		c.Println("Secret not found")
+	case errors.Is(err, storage.ErrShareNotFound):
+		c.Println("Secret is not shared with this user.")
+	case errors.Is(err, storage.ErrReadOnly):
+		c.Println("Secret is shared with you read-only.")
+	case errors.Is(err, client.ErrConflict):
+		c.Println("Someone changed this secret in the meantime. Review it & try again.")
+	default:
+		c.Println("Unexpected error:", err)
+	}
+}
+
+func permissionName(canEdit bool) string {
+	if canEdit {
+		return permissions[1]
+	}
+	return permissions[0]
+}
+
+// confirmRecipientKey shows the fingerprint of the public key of login when
+// it isn't trusted yet and pins it once the user has confirmed it. It
+// reports whether the key may be used.
+func confirmRecipientKey(ctx context.Context, c *ishell.Context, keeper Keeper, login string) (bool, error) {
+	key, err := keeper.RecipientKey(ctx, login)
+	if err != nil {
+		return false, err
+	}
+	if key.Trusted {
+		return true, nil
+	}
+
+	if key.Changed {
+		c.Printf("WARNING: the public key of %s differs from the one you trusted before.\n", login)
+	}
+	c.Printf("Key fingerprint of %s:\n  %s\n", login, key.Fingerprint)
+	c.Printf("Ask %s to run fingerprint and compare. Do they match? (y/N): ", login)
+	if answer := strings.ToLower(strings.TrimSpace(c.ReadLine())); answer != "y" && answer != "yes" {
+		return false, nil
+	}
+
+	return true, keeper.TrustRecipientKey(ctx, login, key.Fingerprint)
+}
+
+// fingerprintCmd shows the fingerprint of the user's public key.
+func fingerprintCmd(keeper Keeper) *ishell.Cmd {
+	return &ishell.Cmd{
+		Name: "fingerprint",
+		Help: "Show the fingerprint of your public key",
+		Func: func(c *ishell.Context) {
+			fingerprint, err := keeper.KeyFingerprint()
+			if err != nil {
+				printShareError(c, err)
+				return
+			}
+
+			c.Println(fingerprint)
+		},
+	}
+}
+
+// shareCmd shares a secret with another user. The data key of the secret
+// is wrapped with the recipient's public key on this machine.
+func shareCmd(ctx context.Context, keeper Keeper) *ishell.Cmd {
+	return &ishell.Cmd{
+		Name: "share",
+		Help: "Share a secret with another user",
+		Func: func(c *ishell.Context) {
+			c.ShowPrompt(false)
+			defer c.ShowPrompt(true)
+
+			c.Print("ID: ")
+			ID, err := strconv.ParseInt(c.ReadLine(), 10, 64)
+			if err != nil {
+				c.Println("Unexpected error:", err)
+				return
+			}
+			c.Print("Login: ")
+			login := strings.TrimSpace(c.ReadLine())
+
+			ok, err := confirmRecipientKey(ctx, c, keeper, login)
+			if err != nil {
+				printShareError(c, err)
+				return
+			}
+			if !ok {
+				c.Println("Secret not shared.")
+				return
+			}
+
+			choice := c.MultiChoice(permissions, "Witch permission you want to grant?")
+			if choice < 0 {
+				return
+			}
+
+			if err := keeper.ShareSecret(ctx, ID, login, choice == 1); err != nil {
+				printShareError(c, err)
+				return
+			}
+
+			c.Printf("Secret shared with %s (%s).\n", login, strings.ToLower(permissions[choice]))
+		},
+	}
+}
+
+// unshareCmd lists the recipients of a secret and revokes one of them.
+func unshareCmd(ctx context.Context, keeper Keeper) *ishell.Cmd {
+	return &ishell.Cmd{
+		Name: "unshare",
+		Help: "Revoke access of a user to a shared secret",
+		Func: func(c *ishell.Context) {
+			c.ShowPrompt(false)
+			defer c.ShowPrompt(true)
+
+			c.Print("ID: ")
+			ID, err := strconv.ParseInt(c.ReadLine(), 10, 64)
+			if err != nil {
+				c.Println("Unexpected error:", err)
+				return
+			}
+
+			list, err := keeper.ListShares(ctx, ID)
+			if err != nil {
+				printShareError(c, err)
+				return
+			}
+			if len(list) == 0 {
+				c.Println("Secret is not shared.")
+				return
+			}
+
+			for _, v := range list {
+				c.Printf("| %10s | %11s | %s |\n", v.Login, permissionName(v.CanEdit),
+					v.CreatedAt.Local().Format(time.DateTime))
+			}
+
+			c.Print("Login to revoke (empty to skip): ")
+			login := strings.TrimSpace(c.ReadLine())
+			if login == "" {
+				return
+			}
+
+			if err := keeper.UnshareSecret(ctx, ID, login); err != nil {
+				printShareError(c, err)
+				return
+			}
+
+			c.Println("Access revoked.")
+		},
+	}
+}
+
+// sharedCmd lists the secrets shared with the user, shows one of them and
+// updates it if it is shared for editing.
+func sharedCmd(ctx context.Context, keeper Keeper) *ishell.Cmd {
+	return &ishell.Cmd{
+		Name: "shared",
+		Help: "List & show secrets shared with you",
+		Func: func(c *ishell.Context) {
+			c.ShowPrompt(false)
+			defer c.ShowPrompt(true)
+
+			list, err := keeper.ListSharedSecrets(ctx)
+			if err != nil {
+				printShareError(c, err)
+				return
+			}
+			if len(list) == 0 {
+				c.Println("No secrets are shared with you.")
+				return
+			}
+
+			for _, v := range list {
+				c.Printf("| %4d | %10s | %10s | %11s |\n", v.ID, v.Name, v.Owner, permissionName(v.CanEdit))
+			}
+
+			c.Print("ID to show (empty to skip): ")
+			line := strings.TrimSpace(c.ReadLine())
+			if line == "" {
+				return
+			}
+			ID, err := strconv.ParseInt(line, 10, 64)
+			if err != nil {
+				c.Println("Unexpected error:", err)
+				return
+			}
+
+			var secret *model.SharedSecret
+			for i := range list {
+				if list[i].ID == ID {
+					secret = &list[i]
+				}
+			}
+			if secret == nil {
+				c.Println("Secret not found")
+				return
+			}
+
+			c.Println("ID: ", secret.ID)
+			c.Println("Name: ", secret.Name)
+			c.Println("Owner: ", secret.Owner)
+			printPayload(c, secret.Type, secret.Meta, secret.Payload)
+
+			if secret.Type == model.BinaryType {
+				saveFile(ctx, c, keeper, &secret.Secret)
+				return
+			}
+			if !secret.CanEdit || c.MultiChoice([]string{"No", "Yes"}, "Edit this secret?") != 1 {
+				return
+			}
+
+			payload, err := editPayload(c, secret.Type, secret.Payload)
+			if err != nil {
+				c.Println("Unexpected error:", err)
+				return
+			}
+			c.Print("Metadata (EOF to finish, empty keeps current): ")
+			meta := secret.Meta
+			if value := c.ReadMultiLines("EOF"); value != "" {
+				meta = []byte(value)
+			}
+
+			_, err = keeper.UpdateSharedSecret(ctx, ID, &model.Secret{
+				Name:     secret.Name,
+				Type:     secret.Type,
+				Payload:  payload,
+				Meta:     meta,
+				Key:      secret.Key,
+				UUID:     secret.UUID,
+				Revision: secret.Revision,
+			})
+			if err != nil {
+				printShareError(c, err)
+				return
+			}
+
+			c.Println("Secret updated")
+		},
+	}
+}
@@ -0,0 +1,52 @@
+package commander
+
+import (
+	"context"
+	"errors"
+
+	"github.com/abiosoft/ishell/v2"
+	"github.com/nbvehbq/go-password-keeper/internal/client"
+)
+
+// syncCmd pushes changes made offline & refreshes the local vault.
+func syncCmd(ctx context.Context, keeper Keeper) *ishell.Cmd {
+	return &ishell.Cmd{
+		Name: "sync",
+		Help: "Synchronize the local copy of the vault with the server",
+		Func: func(c *ishell.Context) {
+			report, err := keeper.Sync(ctx)
+			if report != nil {
+				for _, v := range report.Conflicts {
+					if v.CopyID != 0 {
+						c.Printf("Conflict: %q (ID %d) %s, local version saved as ID %d\n", v.Name, v.ID, v.Reason, v.CopyID)
+					} else {
+						c.Printf("Conflict: %q (ID %d) %s\n", v.Name, v.ID, v.Reason)
+					}
+				}
+			}
+			if err != nil {
+				switch {
+				case errors.Is(err, client.ErrUnauthorized):
+					c.Println("Please login first.")
+				case errors.Is(err, client.ErrNoCache):
+					c.Println("No local copy of the vault. Please login first.")
+				case errors.Is(err, client.ErrOffline):
+					c.Println("Server is unreachable. Try later.")
+				default:
+					c.Println("Unexpected error:", err)
+				}
+				return
+			}
+
+			c.Printf("Synchronized: %d change(s) pushed, %d secret(s) pulled, %d removed.\n",
+				report.Pushed, report.Pulled, report.Deleted)
+		},
+	}
+}
+
+// printOffline tells the user a change was only saved locally.
+func printOffline(c *ishell.Context, keeper Keeper) {
+	if keeper.Offline() {
+		c.Println("Server is unreachable: the change is saved locally, run \"sync\" later.")
+	}
+}
@@ -0,0 +1,112 @@
+package commander
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/abiosoft/ishell/v2"
+	"github.com/nbvehbq/go-password-keeper/internal/client"
+)
+
+// codeAttempts is how many times the second factor is asked on login.
+const codeAttempts = 3
+
+// readSecondFactor completes a login that requires a second factor.
+func readSecondFactor(ctx context.Context, c *ishell.Context, keeper Keeper) error {
+	var err error
+	for i := 0; i < codeAttempts; i++ {
+		c.Print("Two-factor code (or recovery code): ")
+		code := strings.TrimSpace(c.ReadLine())
+
+		if err = keeper.LoginTwoFactor(ctx, code); !errors.Is(err, client.ErrInvalidCode) {
+			return err
+		}
+		c.Println("Invalid code.")
+	}
+
+	return err
+}
+
+// twoFactorCmd enables or disables two-factor authentication of the
+// current user.
+func twoFactorCmd(ctx context.Context, keeper Keeper) *ishell.Cmd {
+	return &ishell.Cmd{
+		Name: "2fa",
+		Help: "Enable or disable two-factor authentication with an authenticator app",
+		Func: func(c *ishell.Context) {
+			choice := c.MultiChoice([]string{"Enable", "Disable"}, "What do you want to do?")
+			c.ShowPrompt(false)
+			defer c.ShowPrompt(true)
+
+			if choice == 0 {
+				enableTwoFactor(ctx, c, keeper)
+			} else {
+				disableTwoFactor(ctx, c, keeper)
+			}
+		},
+	}
+}
+
+func enableTwoFactor(ctx context.Context, c *ishell.Context, keeper Keeper) {
+	enrollment, err := keeper.EnrollTOTP(ctx)
+	if err != nil {
+		switch {
+		case errors.Is(err, client.ErrUnauthorized):
+			c.Println("Please login first.")
+		case errors.Is(err, client.ErrTwoFactorState):
+			c.Println("Two-factor authentication is already enabled.")
+		default:
+			c.Println("Unexpected error:", err)
+		}
+		return
+	}
+
+	c.Println("Add this key to your authenticator app:")
+	c.Println("Secret: ", enrollment.Secret)
+	c.Println("URI: ", enrollment.URI)
+	c.Print("Code from the app: ")
+	code := strings.TrimSpace(c.ReadLine())
+
+	codes, err := keeper.VerifyTOTP(ctx, code)
+	if err != nil {
+		switch {
+		case errors.Is(err, client.ErrUnauthorized):
+			c.Println("Please login first.")
+		case errors.Is(err, client.ErrInvalidCode):
+			c.Println("Invalid code. Two-factor authentication is not enabled.")
+		case errors.Is(err, client.ErrTwoFactorState):
+			c.Println("Two-factor authentication is already enabled.")
+		default:
+			c.Println("Unexpected error:", err)
+		}
+		return
+	}
+
+	c.Println("Two-factor authentication enabled.")
+	c.Println("Recovery codes, each can be used once instead of a code. Keep them safe, they are shown only now:")
+	for _, v := range codes {
+		c.Println("  ", v)
+	}
+}
+
+func disableTwoFactor(ctx context.Context, c *ishell.Context, keeper Keeper) {
+	c.Print("Two-factor code (or recovery code): ")
+	code := strings.TrimSpace(c.ReadLine())
+
+	if err := keeper.DisableTOTP(ctx, code); err != nil {
+		switch {
+		case errors.Is(err, client.ErrUnauthorized):
+			c.Println("Please login first.")
+		case errors.Is(err, client.ErrInvalidCode):
+			c.Println("Invalid code.")
+		case errors.Is(err, client.ErrTwoFactorState):
+			c.Println("Two-factor authentication is not enabled.")
+		default:
+			c.Println("Unexpected error:", err)
+		}
+		return
+	}
+
+	c.Println("Two-factor authentication disabled.")
+}
@@ -0,0 +1,196 @@
+// Package generator creates random passwords and diceware passphrases and
+// estimates their entropy.
+//
+// Passphrases use the EFF large wordlist (https://www.eff.org/dice), licensed
+// under CC BY 3.0.
+package generator
+
+import (
+	"bufio"
+	"crypto/rand"
+	_ "embed"
+	"errors"
+	"math"
+	"math/big"
+	"strings"
+	"sync"
+)
+
+const (
+	lower   = "abcdefghijklmnopqrstuvwxyz"
+	upper   = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	digits  = "0123456789"
+	symbols = "!#$%&*+-=?@^_~()[]{}<>.,:;/\\|'\"`"
+
+	// ambiguous characters are easily confused when read or typed.
+	ambiguous = "Il1O0o|`'\".,:;"
+
+	MinLength = 4
+	MaxLength = 1024
+	MaxWords  = 64
+)
+
+var (
+	ErrNoClasses = errors.New("at least one character class is required")
+	ErrLength    = errors.New("password length is out of range")
+	ErrWords     = errors.New("word count is out of range")
+)
+
+//go:embed eff_large_wordlist.txt
+var wordlist string
+
+// words parses the embedded wordlist once. Lines are "<dice roll>\t<word>".
+var words = sync.OnceValue(func() []string {
+	list := make([]string, 0, 7776)
+	scanner := bufio.NewScanner(strings.NewReader(wordlist))
+	for scanner.Scan() {
+		if _, word, ok := strings.Cut(scanner.Text(), "\t"); ok {
+			list = append(list, word)
+		}
+	}
+	return list
+})
+
+// Policy describes the passwords to generate.
+type Policy struct {
+	Length           int
+	Lower            bool
+	Upper            bool
+	Digits           bool
+	Symbols          bool
+	ExcludeAmbiguous bool
+}
+
+// DefaultPolicy is a 20 characters password of all classes.
+var DefaultPolicy = Policy{Length: 20, Lower: true, Upper: true, Digits: true, Symbols: true}
+
+// classes returns the character sets enabled by the policy.
+func (p Policy) classes() []string {
+	var list []string
+	for _, v := range []struct {
+		on    bool
+		chars string
+	}{{p.Lower, lower}, {p.Upper, upper}, {p.Digits, digits}, {p.Symbols, symbols}} {
+		if !v.on {
+			continue
+		}
+
+		chars := v.chars
+		if p.ExcludeAmbiguous {
+			chars = strings.Map(func(r rune) rune {
+				if strings.ContainsRune(ambiguous, r) {
+					return -1
+				}
+				return r
+			}, chars)
+		}
+		list = append(list, chars)
+	}
+
+	return list
+}
+
+// Entropy estimates the strength of the generated passwords in bits.
+func (p Policy) Entropy() float64 {
+	size := len(strings.Join(p.classes(), ""))
+	if size == 0 {
+		return 0
+	}
+	return float64(p.Length) * math.Log2(float64(size))
+}
+
+// Password generates a password of the policy. It contains at least one
+// character of every enabled class.
+func Password(p Policy) (string, error) {
+	classes := p.classes()
+	if len(classes) == 0 {
+		return "", ErrNoClasses
+	}
+	if p.Length < MinLength || p.Length > MaxLength || p.Length < len(classes) {
+		return "", ErrLength
+	}
+
+	all := strings.Join(classes, "")
+	password := make([]byte, p.Length)
+	for i := range password {
+		c, err := pick(all)
+		if err != nil {
+			return "", err
+		}
+		password[i] = c
+	}
+
+	// put one character of each class at a random distinct position
+	positions, err := permutation(p.Length)
+	if err != nil {
+		return "", err
+	}
+	for i, class := range classes {
+		c, err := pick(class)
+		if err != nil {
+			return "", err
+		}
+		password[positions[i]] = c
+	}
+
+	return string(password), nil
+}
+
+// Passphrase generates a diceware passphrase of count words joined by
+// separator.
+func Passphrase(count int, separator string) (string, error) {
+	if count < 1 || count > MaxWords {
+		return "", ErrWords
+	}
+
+	list := words()
+	phrase := make([]string, count)
+	for i := range phrase {
+		n, err := randInt(len(list))
+		if err != nil {
+			return "", err
+		}
+		phrase[i] = list[n]
+	}
+
+	return strings.Join(phrase, separator), nil
+}
+
+// PassphraseEntropy returns the strength of a passphrase of count words in
+// bits.
+func PassphraseEntropy(count int) float64 {
+	return float64(count) * math.Log2(float64(len(words())))
+}
+
+func pick(chars string) (byte, error) {
+	n, err := randInt(len(chars))
+	if err != nil {
+		return 0, err
+	}
+	return chars[n], nil
+}
+
+// permutation returns a random permutation of [0, n) (Fisher-Yates).
+func permutation(n int) ([]int, error) {
+	list := make([]int, n)
+	for i := range list {
+		list[i] = i
+	}
+	for i := n - 1; i > 0; i-- {
+		j, err := randInt(i + 1)
+		if err != nil {
+			return nil, err
+		}
+		list[i], list[j] = list[j], list[i]
+	}
+	return list, nil
+}
+
+// randInt returns a uniform random number in [0, n).
+func randInt(n int) (int, error) {
+	v, err := rand.Int(rand.Reader, big.NewInt(int64(n)))
+	if err != nil {
+		return 0, err
+	}
+	return int(v.Int64()), nil
+}
@@ -0,0 +1,138 @@
+package generator
+
+import (
+	"errors"
+	"math"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestPassword(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy Policy
+		want   []string
+	}{
+		{name: "default", policy: DefaultPolicy, want: []string{lower, upper, digits, symbols}},
+		{name: "minimum length", policy: Policy{Length: MinLength, Lower: true, Upper: true, Digits: true, Symbols: true},
+			want: []string{lower, upper, digits, symbols}},
+		{name: "lower", policy: Policy{Length: 12, Lower: true}, want: []string{lower}},
+		{name: "digits", policy: Policy{Length: 6, Digits: true}, want: []string{digits}},
+		{name: "letters", policy: Policy{Length: 32, Lower: true, Upper: true}, want: []string{lower, upper}},
+		{name: "maximum length", policy: Policy{Length: MaxLength, Symbols: true}, want: []string{symbols}},
+		{name: "no ambiguous", policy: Policy{Length: 64, Lower: true, Upper: true, Digits: true, Symbols: true,
+			ExcludeAmbiguous: true}, want: []string{lower, upper, digits, symbols}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			charset := strings.Join(tt.want, "")
+
+			// classes must all be present even in the shortest passwords
+			for range 100 {
+				password, err := Password(tt.policy)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(password) != tt.policy.Length {
+					t.Fatalf("length %d, want %d", len(password), tt.policy.Length)
+				}
+
+				for _, c := range password {
+					if !strings.ContainsRune(charset, c) {
+						t.Fatalf("%q has %q, not of the policy", password, c)
+					}
+					if tt.policy.ExcludeAmbiguous && strings.ContainsRune(ambiguous, c) {
+						t.Fatalf("%q has ambiguous %q", password, c)
+					}
+				}
+				for _, class := range tt.want {
+					if !strings.ContainsAny(password, class) {
+						t.Fatalf("%q lacks a character of %q", password, class)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestPasswordErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  Policy
+		wantErr error
+	}{
+		{name: "no classes", policy: Policy{Length: 20}, wantErr: ErrNoClasses},
+		{name: "too short", policy: Policy{Length: MinLength - 1, Lower: true}, wantErr: ErrLength},
+		{name: "too long", policy: Policy{Length: MaxLength + 1, Lower: true}, wantErr: ErrLength},
+		{name: "negative", policy: Policy{Length: -1, Lower: true}, wantErr: ErrLength},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Password(tt.policy); !errors.Is(err, tt.wantErr) {
+				t.Errorf("got %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPassphrase(t *testing.T) {
+	list := words()
+	if len(list) != 7776 {
+		t.Fatalf("wordlist has %d words, want 7776", len(list))
+	}
+
+	for _, count := range []int{1, 4, 6, MaxWords} {
+		phrase, err := Passphrase(count, " ")
+		if err != nil {
+			t.Fatalf("%d words: %v", count, err)
+		}
+
+		got := strings.Split(phrase, " ")
+		if len(got) != count {
+			t.Errorf("%q has %d words, want %d", phrase, len(got), count)
+		}
+		for _, word := range got {
+			if !slices.Contains(list, word) {
+				t.Errorf("%q is not in the wordlist", word)
+			}
+		}
+	}
+
+	phrase, err := Passphrase(5, ".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(phrase, "."); n != 4 {
+		t.Errorf("%q has %d separators, want 4", phrase, n)
+	}
+
+	for _, count := range []int{-1, 0, MaxWords + 1} {
+		if _, err := Passphrase(count, " "); !errors.Is(err, ErrWords) {
+			t.Errorf("%d words: got %v, want %v", count, err, ErrWords)
+		}
+	}
+}
+
+func TestEntropy(t *testing.T) {
+	tests := []struct {
+		name string
+		got  float64
+		want float64
+	}{
+		{name: "digits", got: Policy{Length: 6, Digits: true}.Entropy(), want: 6 * math.Log2(10)},
+		{name: "letters", got: Policy{Length: 10, Lower: true, Upper: true}.Entropy(), want: 10 * math.Log2(52)},
+		{name: "no ambiguous digits", got: Policy{Length: 4, Digits: true, ExcludeAmbiguous: true}.Entropy(),
+			want: 4 * math.Log2(8)},
+		{name: "no classes", got: Policy{Length: 20}.Entropy(), want: 0},
+		{name: "passphrase", got: PassphraseEntropy(6), want: 6 * math.Log2(7776)},
+	}
+
+	for _, tt := range tests {
+		if math.Abs(tt.got-tt.want) > 1e-9 {
+			t.Errorf("%s: entropy %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+}
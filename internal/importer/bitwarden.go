@@ -0,0 +1,106 @@
+package importer
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Bitwarden item types.
+const (
+	bitwardenLogin = 1
+	bitwardenNote  = 2
+	bitwardenCard  = 3
+)
+
+type bitwardenExport struct {
+	Encrypted bool            `json:"encrypted"`
+	Items     []bitwardenItem `json:"items"`
+}
+
+type bitwardenItem struct {
+	Type   int    `json:"type"`
+	Name   string `json:"name"`
+	Notes  string `json:"notes"`
+	Fields []struct {
+		Name  string `json:"name"`
+		Value string `json:"value"`
+	} `json:"fields"`
+	Login *struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+		TOTP     string `json:"totp"`
+		URIs     []struct {
+			URI string `json:"uri"`
+		} `json:"uris"`
+	} `json:"login"`
+	Card *struct {
+		CardholderName string `json:"cardholderName"`
+		Brand          string `json:"brand"`
+		Number         string `json:"number"`
+		ExpMonth       string `json:"expMonth"`
+		ExpYear        string `json:"expYear"`
+		Code           string `json:"code"`
+	} `json:"card"`
+}
+
+func readBitwarden(path string, res *Result) error {
+	var export bitwardenExport
+	if err := readJSON(path, &export); err != nil {
+		return fmt.Errorf("read Bitwarden JSON: %w", err)
+	}
+	if export.Encrypted {
+		return ErrEncrypted
+	}
+
+	for i, v := range export.Items {
+		entry := strconv.Itoa(i + 1)
+		if v.Name != "" {
+			entry = v.Name
+		}
+
+		fields := make([]string, 0, len(v.Fields)+1)
+		for _, f := range v.Fields {
+			fields = append(fields, metaLine(f.Name, f.Value))
+		}
+		meta := joinMeta(append(fields, v.Notes)...)
+
+		switch {
+		case v.Type == bitwardenLogin && v.Login != nil:
+			var url string
+			var extra []string
+			for i, u := range v.Login.URIs {
+				if i == 0 {
+					url = u.URI
+					continue
+				}
+				extra = append(extra, metaLine("URL", u.URI))
+			}
+			res.addLogin(entry, v.Name, v.Login.Username, v.Login.Password, url, joinMeta(append(extra, meta)...))
+			res.addOTP(entry, v.Name, v.Login.TOTP)
+		case v.Type == bitwardenCard && v.Card != nil:
+			c := v.Card
+			meta = joinMeta(metaLine("Brand", c.Brand), metaLine("CVV", c.Code), meta)
+			res.addCard(entry, v.Name, c.Number, expireAt(c.ExpMonth, c.ExpYear), c.CardholderName, meta)
+		case v.Type == bitwardenNote:
+			res.addText(entry, v.Name, v.Notes, joinMeta(fields...))
+		default:
+			res.skip(entry, "unsupported item type")
+		}
+	}
+
+	return nil
+}
+
+// expireAt formats a card expiry as MM/YY.
+func expireAt(month, year string) string {
+	if month == "" && year == "" {
+		return ""
+	}
+	if len(month) == 1 {
+		month = "0" + month
+	}
+	if len(year) == 4 {
+		year = year[2:]
+	}
+	return month + "/" + year
+}
@@ -0,0 +1,147 @@
+package importer
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// lastPassNote is the URL LastPass gives secure notes.
+const lastPassNote = "http://sn"
+
+// csvColumns maps the fields of an entry to the header names used by the
+// exports, lowercased.
+var csvColumns = map[string][]string{
+	"name":     {"name", "title"},
+	"url":      {"url", "website", "login_uri"},
+	"login":    {"username", "login_username", "login name"},
+	"password": {"password", "login_password"},
+	"otp":      {"otpauth", "totp", "one-time password"},
+	"notes":    {"notes", "note", "extra"},
+	"group":    {"grouping", "folder"},
+}
+
+// csvRow is a row of a CSV export with columns found by name.
+type csvRow struct {
+	index  map[string]int
+	record []string
+}
+
+func (r csvRow) get(field string) string {
+	for _, name := range csvColumns[field] {
+		if i, ok := r.index[name]; ok && i < len(r.record) {
+			return strings.TrimSpace(r.record[i])
+		}
+	}
+	return ""
+}
+
+func readCSV(format, path string, res *Result) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	reader := csv.NewReader(f)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return fmt.Errorf("read CSV header: %w", err)
+	}
+
+	index := make(map[string]int, len(header))
+	for i, v := range header {
+		// strip the byte order mark some exports start with
+		v = strings.TrimPrefix(v, "\ufeff")
+		index[strings.ToLower(strings.TrimSpace(v))] = i
+	}
+	if _, ok := index["password"]; !ok {
+		if _, ok := index["login_password"]; !ok {
+			return fmt.Errorf("read CSV header: no password column")
+		}
+	}
+
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		entry := "row " + strconv.Itoa(line)
+		if err != nil {
+			res.skip(entry, err.Error())
+			continue
+		}
+
+		row := csvRow{index: index, record: record}
+		if format == FormatLastPassCSV && row.get("url") == lastPassNote {
+			readLastPassNote(entry, row, res)
+			continue
+		}
+
+		name := row.get("name")
+		if name == "" {
+			name = row.get("url")
+		}
+		meta := joinMeta(metaLine("Folder", row.get("group")), row.get("notes"))
+		res.addLogin(entry, name, row.get("login"), row.get("password"), row.get("url"), meta)
+		res.addOTP(entry, name, row.get("otp"))
+	}
+
+	return nil
+}
+
+// readLastPassNote reads a LastPass secure note. Notes of a type keep
+// their fields as "Key:Value" lines, starting with NoteType.
+func readLastPassNote(entry string, row csvRow, res *Result) {
+	name, text := row.get("name"), row.get("notes")
+
+	if !strings.HasPrefix(text, "NoteType:") {
+		res.addText(entry, name, text, metaLine("Folder", row.get("group")))
+		return
+	}
+
+	fields := make(map[string]string)
+	lines := strings.Split(text, "\n")
+	for i, v := range lines {
+		key, value, _ := strings.Cut(v, ":")
+		if key == "Notes" {
+			// notes are last and may span lines
+			fields[key] = strings.Join(append([]string{value}, lines[i+1:]...), "\n")
+			break
+		}
+		fields[key] = value
+	}
+
+	if fields["NoteType"] != "Credit Card" {
+		res.addText(entry, name, text, metaLine("Folder", row.get("group")))
+		return
+	}
+
+	meta := joinMeta(
+		metaLine("Type", fields["Type"]),
+		metaLine("CVV", fields["Security Code"]),
+		fields["Notes"],
+	)
+	res.addCard(entry, name, fields["Number"], lastPassExpiry(fields["Expiration Date"]), fields["Name on Card"], meta)
+}
+
+// lastPassExpiry converts a "January,2025" expiry to MM/YY.
+func lastPassExpiry(value string) string {
+	month, year, ok := strings.Cut(value, ",")
+	if !ok {
+		return value
+	}
+
+	t, err := time.Parse("January", month)
+	if err != nil {
+		return value
+	}
+	return expireAt(strconv.Itoa(int(t.Month())), year)
+}
@@ -0,0 +1,215 @@
+package importer
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/nbvehbq/go-password-keeper/internal/model"
+	"github.com/nbvehbq/go-password-keeper/internal/otp"
+)
+
+// wantItem is an expected item with its decoded payload.
+type wantItem struct {
+	name    string
+	typ     model.ResourceType
+	meta    string
+	payload any
+}
+
+// payloadOf decodes the payload of an item into the model type of its
+// secret type. The password age is checked and cleared, it counts from
+// the import.
+func payloadOf(t *testing.T, item Item) any {
+	t.Helper()
+
+	var v any
+	switch item.Type {
+	case model.LoginPasswordType:
+		v = &model.LoginPassword{}
+	case model.TextType:
+		v = &model.Text{}
+	case model.BankCardType:
+		v = &model.BankCard{}
+	case model.OTPType:
+		v = &model.OTP{}
+	default:
+		t.Fatalf("%s: unexpected type %d", item.Name, item.Type)
+	}
+	if err := json.Unmarshal(item.Payload, v); err != nil {
+		t.Fatalf("%s: %v", item.Name, err)
+	}
+
+	if login, ok := v.(*model.LoginPassword); ok {
+		if age := time.Since(login.PasswordChangedAt); age < 0 || age > time.Minute {
+			t.Errorf("%s: password changed at %s, want now", item.Name, login.PasswordChangedAt)
+		}
+		login.PasswordChangedAt = time.Time{}
+	}
+
+	return reflect.ValueOf(v).Elem().Interface()
+}
+
+// otpURI is the URI of a base32 TOTP secret imported for account.
+func otpURI(t *testing.T, account, secret string) string {
+	t.Helper()
+
+	seed, err := otp.DecodeSecret(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := &otp.Key{Account: account, Secret: seed, Algorithm: otp.SHA1, Digits: otp.DefaultDigits,
+		Period: otp.DefaultPeriod}
+	return key.URI()
+}
+
+func readTestCSV(t *testing.T, format, data string) *Result {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "export.csv")
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := ReadFile(format, path)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	return res
+}
+
+func checkItems(t *testing.T, res *Result, want []wantItem, wantSkipped []Skipped) {
+	t.Helper()
+
+	if len(res.Items) != len(want) {
+		t.Errorf("%d items, want %d: %+v", len(res.Items), len(want), res.Items)
+	}
+	for i := 0; i < len(res.Items) && i < len(want); i++ {
+		item, w := res.Items[i], want[i]
+		if item.Name != w.name || item.Type != w.typ || item.Meta != w.meta {
+			t.Errorf("item %d: %q type %d meta %q, want %q type %d meta %q",
+				i, item.Name, item.Type, item.Meta, w.name, w.typ, w.meta)
+		}
+		if got := payloadOf(t, item); !reflect.DeepEqual(got, w.payload) {
+			t.Errorf("item %d %q: payload %+v, want %+v", i, item.Name, got, w.payload)
+		}
+	}
+
+	if !reflect.DeepEqual(res.Skipped, wantSkipped) {
+		t.Errorf("skipped %+v, want %+v", res.Skipped, wantSkipped)
+	}
+}
+
+func TestReadChromeCSV(t *testing.T) {
+	const data = "name,url,username,password,note\n" +
+		"GitHub,https://github.com/login,alice,s3cret,work account\n" +
+		",https://example.com,bob,hunter2,\n" +
+		"GitHub,https://github.com,carol,pw,\n" +
+		"Empty,,,,\n"
+
+	res := readTestCSV(t, FormatChromeCSV, data)
+	checkItems(t, res, []wantItem{
+		{name: "GitHub", typ: model.LoginPasswordType, meta: "work account",
+			payload: model.LoginPassword{Login: "alice", Password: "s3cret", URL: "https://github.com/login"}},
+		{name: "https://example.com", typ: model.LoginPasswordType,
+			payload: model.LoginPassword{Login: "bob", Password: "hunter2", URL: "https://example.com"}},
+	}, []Skipped{
+		{Entry: "row 4", Reason: `duplicate name "GitHub"`},
+		{Entry: "row 5", Reason: "empty entry"},
+	})
+}
+
+func TestReadLastPassCSV(t *testing.T) {
+	const card = "NoteType:Credit Card\nLanguage:en-US\nName on Card:Alice Smith\nType:Visa\n" +
+		"Number:4111 1111 1111 1111\nSecurity Code:123\nStart Date:,\nExpiration Date:January,2030\n" +
+		"Notes:line one\nline two"
+	const data = "url,username,password,totp,extra,name,grouping,fav\n" +
+		"https://mail.example.com,alice,pw1,JBSWY3DPEHPK3PXP,recovery email,Mail,Personal\\Mail,0\n" +
+		"http://sn,,,,just a note,Note,Personal,0\n" +
+		"http://sn,,,,\"" + card + "\",Visa,Cards,1\n" +
+		"https://x.example.com,bob,pw2,!!!,,X,,0\n"
+
+	res := readTestCSV(t, FormatLastPassCSV, data)
+	checkItems(t, res, []wantItem{
+		{name: "Mail", typ: model.LoginPasswordType, meta: "Folder: Personal\\Mail\nrecovery email",
+			payload: model.LoginPassword{Login: "alice", Password: "pw1", URL: "https://mail.example.com"}},
+		{name: "Mail (TOTP)", typ: model.OTPType,
+			payload: model.OTP{URI: otpURI(t, "Mail", "JBSWY3DPEHPK3PXP")}},
+		{name: "Note", typ: model.TextType, meta: "Folder: Personal",
+			payload: model.Text{Value: "just a note"}},
+		{name: "Visa", typ: model.BankCardType, meta: "Type: Visa\nCVV: 123\nline one\nline two",
+			payload: model.BankCard{Number: "4111111111111111", ExpireAt: "01/30", Name: "Alice", Surname: "Smith"}},
+		{name: "X", typ: model.LoginPasswordType,
+			payload: model.LoginPassword{Login: "bob", Password: "pw2", URL: "https://x.example.com"}},
+	}, []Skipped{
+		{Entry: "row 5", Reason: "invalid TOTP: " + invalidURIError(t, "!!!")},
+	})
+}
+
+func TestRead1PasswordCSV(t *testing.T) {
+	const uri = "otpauth://totp/Example:alice?secret=JBSWY3DPEHPK3PXP&issuer=Example"
+	const data = "\ufeffTitle,Website,Username,Password,One-time password,Favorite status,Archived status,Tags,Notes\n" +
+		"Example,https://example.com,alice,pw,\"" + uri + "\",Not favorite,Not archived,,\"two\nlines\"\n" +
+		"Server,ssh://host,root,toor,,Favorite,Not archived,infra,\n"
+
+	key, err := otp.Parse(uri)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res := readTestCSV(t, Format1PasswordCSV, data)
+	checkItems(t, res, []wantItem{
+		{name: "Example", typ: model.LoginPasswordType, meta: "two\nlines",
+			payload: model.LoginPassword{Login: "alice", Password: "pw", URL: "https://example.com"}},
+		{name: "Example (TOTP)", typ: model.OTPType, payload: model.OTP{URI: key.URI()}},
+		{name: "Server", typ: model.LoginPasswordType,
+			payload: model.LoginPassword{Login: "root", Password: "toor", URL: "ssh://host"}},
+	}, nil)
+}
+
+func TestReadCSVErrors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "export.csv")
+	if err := os.WriteFile(path, []byte("name,url,username\nGitHub,https://github.com,alice\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadFile(FormatChromeCSV, path); err == nil {
+		t.Error("read an export without a password column")
+	}
+
+	if _, err := ReadFile("csv", path); !errors.Is(err, ErrUnknownFormat) {
+		t.Errorf("unknown format: got %v, want %v", err, ErrUnknownFormat)
+	}
+}
+
+func TestLastPassExpiry(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"January,2030", "01/30"},
+		{"December,2031", "12/31"},
+		{",", ","},
+		{"12/30", "12/30"},
+	}
+
+	for _, tt := range tests {
+		if got := lastPassExpiry(tt.value); got != tt.want {
+			t.Errorf("lastPassExpiry(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+// invalidURIError is the error of parsing value as an otpauth URI.
+func invalidURIError(t *testing.T, value string) string {
+	t.Helper()
+
+	_, err := otp.Parse(value)
+	if err == nil {
+		t.Fatalf("%q parsed as an otpauth URI", value)
+	}
+	return err.Error()
+}
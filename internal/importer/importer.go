@@ -0,0 +1,245 @@
+// Package importer reads the exports of other password managers and maps
+// them onto keeper secrets. Exports are read locally; items are encrypted
+// by the client like any other secret when they are saved.
+package importer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/nbvehbq/go-password-keeper/internal/model"
+	"github.com/nbvehbq/go-password-keeper/internal/otp"
+)
+
+// Supported export formats.
+const (
+	FormatKeePassXML    = "keepass-xml"
+	FormatBitwardenJSON = "bitwarden-json"
+	Format1PUX          = "1password-1pux"
+	Format1PasswordCSV  = "1password-csv"
+	FormatLastPassCSV   = "lastpass-csv"
+	FormatChromeCSV     = "chrome-csv"
+)
+
+// Formats lists the supported formats with their descriptions.
+var Formats = []struct {
+	Name        string
+	Description string
+}{
+	{FormatKeePassXML, "KeePass XML"},
+	{FormatBitwardenJSON, "Bitwarden JSON (unencrypted)"},
+	{Format1PUX, "1Password 1PUX"},
+	{Format1PasswordCSV, "1Password CSV"},
+	{FormatLastPassCSV, "LastPass CSV"},
+	{FormatChromeCSV, "Chrome CSV"},
+}
+
+var (
+	ErrUnknownFormat = errors.New("unknown import format")
+	ErrEncrypted     = errors.New("encrypted exports are not supported, export unencrypted")
+)
+
+// Item is a secret to create. File holds the contents of a Binary item,
+// FileName its original name; Payload is empty for them.
+type Item struct {
+	Name     string
+	Type     model.ResourceType
+	Payload  []byte
+	Meta     string
+	FileName string
+	File     []byte
+}
+
+// Skipped is an entry of the export that was not imported. Entry is the
+// row number or name of the entry.
+type Skipped struct {
+	Entry  string
+	Reason string
+}
+
+// Result of reading an export. Items have unique names.
+type Result struct {
+	Items   []Item
+	Skipped []Skipped
+
+	names map[string]bool
+}
+
+// ReadFile reads the export at path in the given format.
+func ReadFile(format, path string) (*Result, error) {
+	res := &Result{names: make(map[string]bool)}
+
+	var err error
+	switch format {
+	case FormatKeePassXML:
+		err = readKeePass(path, res)
+	case FormatBitwardenJSON:
+		err = readBitwarden(path, res)
+	case Format1PUX:
+		err = read1PUX(path, res)
+	case Format1PasswordCSV, FormatLastPassCSV, FormatChromeCSV:
+		err = readCSV(format, path, res)
+	default:
+		err = ErrUnknownFormat
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// add appends an item unless one with the same name was already added.
+func (r *Result) add(entry string, item Item) {
+	item.Name = strings.TrimSpace(item.Name)
+	if item.Name == "" {
+		r.skip(entry, "no name")
+		return
+	}
+	if r.names[item.Name] {
+		r.skip(entry, fmt.Sprintf("duplicate name %q", item.Name))
+		return
+	}
+
+	r.names[item.Name] = true
+	r.Items = append(r.Items, item)
+}
+
+func (r *Result) skip(entry, reason string) {
+	r.Skipped = append(r.Skipped, Skipped{Entry: entry, Reason: reason})
+}
+
+// addLogin adds a login, or a note when the entry has no credentials.
+func (r *Result) addLogin(entry, name, login, password, url, meta string) {
+	if login == "" && password == "" {
+		if meta == "" && url == "" {
+			r.skip(entry, "empty entry")
+			return
+		}
+		r.addText(entry, name, joinMeta(metaLine("URL", url), meta), "")
+		return
+	}
+
+	// the export doesn't tell how old the password is, it counts from now
+	payload, err := json.Marshal(&model.LoginPassword{
+		Login:             login,
+		Password:          password,
+		URL:               url,
+		PasswordChangedAt: time.Now().UTC(),
+	})
+	if err != nil {
+		r.skip(entry, err.Error())
+		return
+	}
+
+	r.add(entry, Item{Name: name, Type: model.LoginPasswordType, Payload: payload, Meta: meta})
+}
+
+func (r *Result) addText(entry, name, text, meta string) {
+	payload, err := json.Marshal(&model.Text{Value: text})
+	if err != nil {
+		r.skip(entry, err.Error())
+		return
+	}
+
+	r.add(entry, Item{Name: name, Type: model.TextType, Payload: payload, Meta: meta})
+}
+
+// addCard adds a bank card. The holder's name is split into name and
+// surname at the last space.
+func (r *Result) addCard(entry, name, number, expireAt, holder, meta string) {
+	if number == "" {
+		r.skip(entry, "card without number")
+		return
+	}
+
+	card := model.BankCard{Number: strings.ReplaceAll(number, " ", ""), ExpireAt: expireAt}
+	holder = strings.TrimSpace(holder)
+	if i := strings.LastIndex(holder, " "); i > 0 {
+		card.Name, card.Surname = holder[:i], holder[i+1:]
+	} else {
+		card.Name = holder
+	}
+
+	payload, err := json.Marshal(&card)
+	if err != nil {
+		r.skip(entry, err.Error())
+		return
+	}
+
+	r.add(entry, Item{Name: name, Type: model.BankCardType, Payload: payload, Meta: meta})
+}
+
+func (r *Result) addFile(entry, name, fileName string, data []byte) {
+	if fileName = filepath.Base(fileName); fileName == "." || fileName == "/" {
+		fileName = name
+	}
+	r.add(entry, Item{Name: name, Type: model.BinaryType, FileName: fileName, File: data})
+}
+
+// addOTP adds the TOTP seed of an entry, given as an otpauth:// URI or a
+// base32 secret, as a separate secret named after the entry.
+func (r *Result) addOTP(entry, name, value string) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return
+	}
+
+	key, err := otp.Parse(value)
+	if err != nil {
+		secret, decodeErr := otp.DecodeSecret(value)
+		if decodeErr != nil || len(secret) == 0 {
+			r.skip(entry, "invalid TOTP: "+err.Error())
+			return
+		}
+		key = &otp.Key{
+			Account:   name,
+			Secret:    secret,
+			Algorithm: otp.SHA1,
+			Digits:    otp.DefaultDigits,
+			Period:    otp.DefaultPeriod,
+		}
+	}
+
+	payload, err := json.Marshal(&model.OTP{URI: key.URI()})
+	if err != nil {
+		r.skip(entry, err.Error())
+		return
+	}
+
+	r.add(entry, Item{Name: name + " (TOTP)", Type: model.OTPType, Payload: payload})
+}
+
+// metaLine formats a field kept in the metadata, empty for empty values.
+func metaLine(name, value string) string {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return ""
+	}
+	return name + ": " + value
+}
+
+// joinMeta joins the non-empty parts of the metadata by lines.
+func joinMeta(parts ...string) string {
+	lines := make([]string, 0, len(parts))
+	for _, v := range parts {
+		if v = strings.TrimSpace(v); v != "" {
+			lines = append(lines, v)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// readJSON decodes a JSON file.
+func readJSON(path string, v any) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
@@ -0,0 +1,138 @@
+package importer
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// keePassFile is the XML export of KeePass 2.x. History entries are not
+// decoded, only current versions are imported.
+type keePassFile struct {
+	Meta struct {
+		RecycleBinUUID string `xml:"RecycleBinUUID"`
+		Binaries       []struct {
+			ID         string `xml:"ID,attr"`
+			Compressed bool   `xml:"Compressed,attr"`
+			Value      string `xml:",chardata"`
+		} `xml:"Binaries>Binary"`
+	} `xml:"Meta"`
+	Root struct {
+		Groups []keePassGroup `xml:"Group"`
+	} `xml:"Root"`
+}
+
+type keePassGroup struct {
+	UUID    string         `xml:"UUID"`
+	Name    string         `xml:"Name"`
+	Groups  []keePassGroup `xml:"Group"`
+	Entries []keePassEntry `xml:"Entry"`
+}
+
+type keePassEntry struct {
+	Strings []struct {
+		Key   string `xml:"Key"`
+		Value string `xml:"Value"`
+	} `xml:"String"`
+	Binaries []struct {
+		Key   string `xml:"Key"`
+		Value struct {
+			Ref string `xml:"Ref,attr"`
+		} `xml:"Value"`
+	} `xml:"Binary"`
+}
+
+// keePassStandard are the fields of every entry; others are custom.
+var keePassStandard = map[string]bool{
+	"Title": true, "UserName": true, "Password": true, "URL": true, "Notes": true,
+	"otp": true, "TimeOtp-Secret-Base32": true,
+}
+
+func readKeePass(path string, res *Result) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var file keePassFile
+	if err := xml.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("read KeePass XML: %w", err)
+	}
+
+	binaries := make(map[string][]byte, len(file.Meta.Binaries))
+	for _, v := range file.Meta.Binaries {
+		if b, err := keePassBinary(v.Value, v.Compressed); err == nil {
+			binaries[v.ID] = b
+		}
+	}
+
+	var walk func(g keePassGroup)
+	walk = func(g keePassGroup) {
+		if g.UUID != "" && g.UUID == file.Meta.RecycleBinUUID {
+			return
+		}
+		for _, e := range g.Entries {
+			readKeePassEntry(e, binaries, res)
+		}
+		for _, v := range g.Groups {
+			walk(v)
+		}
+	}
+	for _, g := range file.Root.Groups {
+		walk(g)
+	}
+
+	return nil
+}
+
+func readKeePassEntry(e keePassEntry, binaries map[string][]byte, res *Result) {
+	fields := make(map[string]string, len(e.Strings))
+	var custom []string
+	for _, v := range e.Strings {
+		fields[v.Key] = v.Value
+		if !keePassStandard[v.Key] {
+			custom = append(custom, metaLine(v.Key, v.Value))
+		}
+	}
+
+	name := fields["Title"]
+	meta := joinMeta(append(custom, fields["Notes"])...)
+	res.addLogin(name, name, fields["UserName"], fields["Password"], fields["URL"], meta)
+
+	otpValue := fields["otp"]
+	if otpValue == "" {
+		otpValue = fields["TimeOtp-Secret-Base32"]
+	}
+	res.addOTP(name, name, otpValue)
+
+	for _, v := range e.Binaries {
+		data, ok := binaries[v.Value.Ref]
+		if !ok {
+			res.skip(name, fmt.Sprintf("attachment %q not found", v.Key))
+			continue
+		}
+		res.addFile(name, name+" - "+v.Key, v.Key, data)
+	}
+}
+
+// keePassBinary decodes an attachment, stored base64 and optionally
+// gzipped.
+func keePassBinary(value string, compressed bool) ([]byte, error) {
+	data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(value))
+	if err != nil || !compressed {
+		return data, err
+	}
+
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	return io.ReadAll(r)
+}
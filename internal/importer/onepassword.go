@@ -0,0 +1,186 @@
+package importer
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+)
+
+// 1Password item categories.
+const (
+	onePasswordLogin    = "001"
+	onePasswordCard     = "002"
+	onePasswordNote     = "003"
+	onePasswordPassword = "005"
+	onePasswordDocument = "006"
+)
+
+// onePUXExport is export.data of a 1PUX archive.
+type onePUXExport struct {
+	Accounts []struct {
+		Vaults []struct {
+			Items []onePUXItem `json:"items"`
+		} `json:"vaults"`
+	} `json:"accounts"`
+}
+
+type onePUXItem struct {
+	CategoryUUID string `json:"categoryUuid"`
+	Overview     struct {
+		Title string `json:"title"`
+		URL   string `json:"url"`
+	} `json:"overview"`
+	Details struct {
+		LoginFields []struct {
+			Value       string `json:"value"`
+			Designation string `json:"designation"`
+		} `json:"loginFields"`
+		NotesPlain string `json:"notesPlain"`
+		Password   string `json:"password"`
+		Sections   []struct {
+			Fields []struct {
+				Title string                     `json:"title"`
+				ID    string                     `json:"id"`
+				Value map[string]json.RawMessage `json:"value"`
+			} `json:"fields"`
+		} `json:"sections"`
+		DocumentAttributes *struct {
+			FileName   string `json:"fileName"`
+			DocumentID string `json:"documentId"`
+		} `json:"documentAttributes"`
+	} `json:"details"`
+}
+
+func read1PUX(name string, res *Result) error {
+	archive, err := zip.OpenReader(name)
+	if err != nil {
+		return fmt.Errorf("read 1PUX: %w", err)
+	}
+	defer archive.Close()
+
+	files := make(map[string]*zip.File, len(archive.File))
+	for _, f := range archive.File {
+		files[f.Name] = f
+	}
+
+	data, err := readZipFile(files["export.data"])
+	if err != nil {
+		return fmt.Errorf("read 1PUX: %w", err)
+	}
+
+	var export onePUXExport
+	if err := json.Unmarshal(data, &export); err != nil {
+		return fmt.Errorf("read 1PUX: %w", err)
+	}
+
+	for _, account := range export.Accounts {
+		for _, vault := range account.Vaults {
+			for _, item := range vault.Items {
+				read1PUXItem(item, files, res)
+			}
+		}
+	}
+
+	return nil
+}
+
+func read1PUXItem(item onePUXItem, files map[string]*zip.File, res *Result) {
+	name := item.Overview.Title
+	d := item.Details
+
+	// section fields by id, the others are kept in the metadata
+	fields := make(map[string]string)
+	var extra []string
+	var totp string
+	for _, s := range d.Sections {
+		for _, f := range s.Fields {
+			kind, value := onePUXValue(f.Value)
+			switch {
+			case kind == "totp":
+				totp = value
+			case item.CategoryUUID == onePasswordCard && f.ID != "":
+				fields[f.ID] = value
+			default:
+				extra = append(extra, metaLine(f.Title, value))
+			}
+		}
+	}
+
+	switch item.CategoryUUID {
+	case onePasswordLogin, onePasswordPassword:
+		var login, password string
+		for _, f := range d.LoginFields {
+			switch f.Designation {
+			case "username":
+				login = f.Value
+			case "password":
+				password = f.Value
+			}
+		}
+		if password == "" {
+			password = d.Password
+		}
+		meta := joinMeta(append(extra, d.NotesPlain)...)
+		res.addLogin(name, name, login, password, item.Overview.URL, meta)
+		res.addOTP(name, name, totp)
+	case onePasswordCard:
+		meta := joinMeta(metaLine("Type", fields["type"]), metaLine("CVV", fields["cvv"]), d.NotesPlain)
+		res.addCard(name, name, fields["ccnum"], fields["expiry"], fields["cardholder"], joinMeta(append(extra, meta)...))
+	case onePasswordNote:
+		res.addText(name, name, d.NotesPlain, joinMeta(extra...))
+	case onePasswordDocument:
+		doc := d.DocumentAttributes
+		if doc == nil {
+			res.skip(name, "document without attachment")
+			return
+		}
+		data, err := readZipFile(files[path.Join("files", doc.DocumentID+"__"+doc.FileName)])
+		if err != nil {
+			res.skip(name, "attachment: "+err.Error())
+			return
+		}
+		res.addFile(name, name, doc.FileName, data)
+	default:
+		res.skip(name, "unsupported item category "+item.CategoryUUID)
+	}
+}
+
+// onePUXValue returns the kind and text of a field value, an object with a
+// single key naming the kind.
+func onePUXValue(value map[string]json.RawMessage) (string, string) {
+	for kind, raw := range value {
+		var s string
+		if err := json.Unmarshal(raw, &s); err == nil {
+			return kind, s
+		}
+
+		var n int64
+		if err := json.Unmarshal(raw, &n); err == nil {
+			// monthYear values are YYYYMM
+			if kind == "monthYear" && n > 99999 {
+				return kind, expireAt(strconv.FormatInt(n%100, 10), strconv.FormatInt(n/100, 10))
+			}
+			return kind, strconv.FormatInt(n, 10)
+		}
+
+		return kind, string(raw)
+	}
+	return "", ""
+}
+
+func readZipFile(f *zip.File) ([]byte, error) {
+	if f == nil {
+		return nil, fmt.Errorf("file not found in archive")
+	}
+
+	r, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	return io.ReadAll(r)
+}
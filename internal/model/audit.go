@@ -0,0 +1,77 @@
+package model
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"time"
+)
+
+// Audit actions.
+const (
+	AuditLogin              = "user.login"
+	AuditLoginFailed        = "user.login_failed"
+	AuditSessionRevoked     = "session.revoked"
+	AuditSecretCreated      = "secret.created"
+	AuditSecretRead         = "secret.read"
+	AuditSecretUpdated      = "secret.updated"
+	AuditSecretDeleted      = "secret.deleted"
+	AuditSecretRestored     = "secret.restored"
+	AuditSecretOrganized    = "secret.organized"
+	AuditSecretRekeyed      = "secret.rekeyed"
+	AuditSecretShared       = "secret.shared"
+	AuditSecretUnshared     = "secret.unshared"
+	AuditEmergencyRequested = "emergency.requested"
+	AuditEmergencyRejected  = "emergency.rejected"
+	AuditEmergencyAccessed  = "emergency.accessed"
+)
+
+// AuditEvent records an action on the account of UserID, done by ActorID.
+// The events of a user form a hash chain: PrevHash is the Hash of the
+// previous event, empty for the first one, so altering or removing an
+// event breaks the chain after it.
+type AuditEvent struct {
+	ID        int64     `db:"id" json:"id"`
+	UserID    int64     `db:"user_id" json:"user_id"`
+	ActorID   int64     `db:"actor_id" json:"actor_id"`
+	Action    string    `db:"action" json:"action"`
+	Detail    string    `db:"detail" json:"detail,omitempty"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+	PrevHash  []byte    `db:"prev_hash" json:"prev_hash"`
+	Hash      []byte    `db:"hash" json:"hash"`
+}
+
+// Digest returns the hash of the event chained to PrevHash. CreatedAt
+// counts in microseconds, the precision it is stored with.
+func (e *AuditEvent) Digest() []byte {
+	h := sha256.New()
+	h.Write(e.PrevHash)
+
+	var buf [8]byte
+	for _, v := range []int64{e.UserID, e.ActorID, e.CreatedAt.UnixMicro()} {
+		binary.BigEndian.PutUint64(buf[:], uint64(v))
+		h.Write(buf[:])
+	}
+	for _, v := range []string{e.Action, e.Detail} {
+		binary.BigEndian.PutUint64(buf[:], uint64(len(v)))
+		h.Write(buf[:])
+		h.Write([]byte(v))
+	}
+
+	return h.Sum(nil)
+}
+
+// VerifyAuditChain checks consecutive events of a user, oldest first. It
+// returns the index of the first event whose hash or link to the previous
+// one doesn't match, -1 if there is none.
+func VerifyAuditChain(events []AuditEvent) int {
+	for i := range events {
+		if !bytes.Equal(events[i].Hash, events[i].Digest()) {
+			return i
+		}
+		if i > 0 && !bytes.Equal(events[i].PrevHash, events[i-1].Hash) {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,121 @@
+package model
+
+import (
+	"bytes"
+	"slices"
+	"testing"
+	"time"
+)
+
+// auditChain returns n chained events of a user, oldest first.
+func auditChain(n int) []AuditEvent {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	actions := []string{AuditLogin, AuditSecretCreated, AuditSecretRead, AuditSecretUpdated, AuditSecretDeleted}
+
+	events := make([]AuditEvent, n)
+	var prev []byte
+	for i := range events {
+		e := AuditEvent{
+			ID:        int64(i + 1),
+			UserID:    7,
+			ActorID:   7,
+			Action:    actions[i%len(actions)],
+			Detail:    "secret " + string(rune('a'+i)),
+			CreatedAt: start.Add(time.Duration(i) * time.Minute),
+			PrevHash:  prev,
+		}
+		e.Hash = e.Digest()
+		prev = e.Hash
+		events[i] = e
+	}
+	return events
+}
+
+func TestAuditEventDigest(t *testing.T) {
+	base := auditChain(2)[1]
+
+	tests := []struct {
+		name   string
+		change func(e *AuditEvent)
+	}{
+		{"user", func(e *AuditEvent) { e.UserID++ }},
+		{"actor", func(e *AuditEvent) { e.ActorID = 8 }},
+		{"action", func(e *AuditEvent) { e.Action = AuditSecretDeleted }},
+		{"detail", func(e *AuditEvent) { e.Detail += "!" }},
+		{"time", func(e *AuditEvent) { e.CreatedAt = e.CreatedAt.Add(time.Microsecond) }},
+		{"previous hash", func(e *AuditEvent) { e.PrevHash = nil }},
+		// lengths are hashed, so text can't move between fields
+		{"action and detail boundary", func(e *AuditEvent) {
+			e.Action, e.Detail = e.Action+e.Detail[:1], e.Detail[1:]
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := base
+			tt.change(&e)
+			if bytes.Equal(e.Digest(), base.Digest()) {
+				t.Error("digest unchanged")
+			}
+		})
+	}
+
+	// the ID is assigned by the database after hashing, and CreatedAt only
+	// counts to the microsecond it is stored with
+	e := base
+	e.ID = 99
+	e.CreatedAt = e.CreatedAt.Add(time.Nanosecond)
+	if !bytes.Equal(e.Digest(), base.Digest()) {
+		t.Error("digest depends on the ID or sub-microsecond time")
+	}
+}
+
+func TestVerifyAuditChain(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(events []AuditEvent) []AuditEvent
+		want   int
+	}{
+		{name: "intact", change: func(events []AuditEvent) []AuditEvent { return events }, want: -1},
+		{name: "empty", change: func([]AuditEvent) []AuditEvent { return nil }, want: -1},
+		{name: "page", change: func(events []AuditEvent) []AuditEvent { return events[2:4] }, want: -1},
+		{name: "detail modified", change: func(events []AuditEvent) []AuditEvent {
+			events[2].Detail = "nothing to see"
+			return events
+		}, want: 2},
+		{name: "first event modified", change: func(events []AuditEvent) []AuditEvent {
+			events[0].Action = AuditLoginFailed
+			return events
+		}, want: 0},
+		{name: "modified and rehashed", change: func(events []AuditEvent) []AuditEvent {
+			events[2].Detail = "nothing to see"
+			events[2].Hash = events[2].Digest()
+			return events
+		}, want: 3},
+		{name: "removed", change: func(events []AuditEvent) []AuditEvent {
+			return slices.Delete(events, 2, 3)
+		}, want: 2},
+		{name: "removed and relinked", change: func(events []AuditEvent) []AuditEvent {
+			events = slices.Delete(events, 2, 3)
+			events[2].PrevHash = events[1].Hash
+			return events
+		}, want: 2},
+		{name: "reordered", change: func(events []AuditEvent) []AuditEvent {
+			events[1], events[2] = events[2], events[1]
+			return events
+		}, want: 1},
+		{name: "hash replaced", change: func(events []AuditEvent) []AuditEvent {
+			events[4].Hash = bytes.Repeat([]byte{0}, 32)
+			return events
+		}, want: 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events := tt.change(auditChain(5))
+			if got := VerifyAuditChain(events); got != tt.want {
+				t.Errorf("VerifyAuditChain = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
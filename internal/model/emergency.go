@@ -0,0 +1,42 @@
+package model
+
+import "time"
+
+// EmergencyGrant lets the grantee take over the vault of the grantor, if
+// the grantor doesn't reject a request within WaitDays. Key is a data key
+// wrapped for the grantee and VaultKey the grantor's private key sealed
+// with it; both are only released once the wait period has passed.
+type EmergencyGrant struct {
+	ID           int64  `db:"id" json:"id"`
+	GrantorID    int64  `db:"grantor_id" json:"grantor_id"`
+	GrantorLogin string `db:"grantor_login" json:"grantor_login"`
+	GranteeID    int64  `db:"grantee_id" json:"grantee_id"`
+	GranteeLogin string `db:"grantee_login" json:"grantee_login"`
+	WaitDays     int    `db:"wait_days" json:"wait_days"`
+	// RequestedAt is set while a request of the grantee is pending or
+	// released, nil otherwise.
+	RequestedAt *time.Time `db:"requested_at" json:"requested_at,omitempty"`
+	Key         []byte     `db:"key" json:"key,omitempty"`
+	VaultKey    []byte     `db:"vault_key" json:"vault_key,omitempty"`
+	CreatedAt   time.Time  `db:"created_at" json:"created_at"`
+}
+
+// ReleasesAt returns when a pending request is released, zero without a
+// request.
+func (g *EmergencyGrant) ReleasesAt() time.Time {
+	if g.RequestedAt == nil {
+		return time.Time{}
+	}
+	return g.RequestedAt.Add(time.Duration(g.WaitDays) * 24 * time.Hour)
+}
+
+// Released reports whether the grantee has access at now.
+func (g *EmergencyGrant) Released(now time.Time) bool {
+	return g.RequestedAt != nil && !now.Before(g.ReleasesAt())
+}
+
+// EmergencyVault is the vault of a grantor released to the grantee.
+type EmergencyVault struct {
+	Grant   EmergencyGrant `json:"grant"`
+	Secrets []Secret       `json:"secrets"`
+}
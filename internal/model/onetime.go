@@ -0,0 +1,16 @@
+package model
+
+import "time"
+
+// OneTimeSecret is a copy of a secret handed out by a link to someone
+// without an account. Payload is encrypted with a key carried only in the
+// fragment of the link, so the server stores ciphertext it can't read. It
+// is deleted once read MaxViews times or at ExpiresAt.
+type OneTimeSecret struct {
+	ID        string    `db:"-" json:"id,omitempty"`
+	UserID    int64     `db:"user_id" json:"-"`
+	Payload   []byte    `db:"payload" json:"payload"`
+	MaxViews  int       `db:"max_views" json:"max_views"`
+	Views     int       `db:"views" json:"views"`
+	ExpiresAt time.Time `db:"expires_at" json:"expires_at"`
+}
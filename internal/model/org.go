@@ -0,0 +1,87 @@
+package model
+
+import "time"
+
+// Role is the access level of an organization member. Higher roles include
+// the permissions of the lower ones.
+type Role uint8
+
+const (
+	// RoleReadOnly reads the secrets of the organization.
+	RoleReadOnly Role = iota + 1
+	// RoleMember also creates, updates and deletes secrets.
+	RoleMember
+	// RoleAdmin also manages collections, members and read-only members.
+	RoleAdmin
+	// RoleOwner also manages admins & owners and deletes the organization.
+	RoleOwner
+)
+
+// Valid reports whether r is one of the defined roles.
+func (r Role) Valid() bool {
+	return r >= RoleReadOnly && r <= RoleOwner
+}
+
+func (r Role) String() string {
+	switch r {
+	case RoleReadOnly:
+		return "read-only"
+	case RoleMember:
+		return "member"
+	case RoleAdmin:
+		return "admin"
+	case RoleOwner:
+		return "owner"
+	default:
+		return "unknown"
+	}
+}
+
+// Organization owns collections of secrets shared by its members. Secrets
+// are sealed for PublicKey; every member keeps the matching private key
+// wrapped with their own public key. Key and Role are those of the member
+// the organization is returned to.
+type Organization struct {
+	ID        int64     `db:"id" json:"id"`
+	Name      string    `db:"name" json:"name"`
+	PublicKey []byte    `db:"public_key" json:"public_key"`
+	Key       []byte    `db:"key" json:"key,omitempty"`
+	Role      Role      `db:"role" json:"role,omitempty"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+}
+
+// Member is a user of an organization. Key is the private key of the
+// organization wrapped for the user, only set for the member themselves.
+type Member struct {
+	OrgID     int64     `db:"org_id" json:"org_id"`
+	UserID    int64     `db:"user_id" json:"user_id"`
+	Login     string    `db:"login" json:"login"`
+	Role      Role      `db:"role" json:"role"`
+	Key       []byte    `db:"key" json:"key,omitempty"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+}
+
+// Collection groups the secrets of an organization.
+type Collection struct {
+	ID    int64  `db:"id" json:"id"`
+	OrgID int64  `db:"org_id" json:"org_id"`
+	Name  string `db:"name" json:"name"`
+}
+
+// OrgSecret is a secret of an organization. Key is its data key wrapped
+// with the public key of the organization; revisions work as for Secret.
+type OrgSecret struct {
+	ID           int64        `db:"id" json:"id"`
+	OrgID        int64        `db:"org_id" json:"org_id"`
+	CollectionID int64        `db:"collection_id" json:"collection_id"`
+	Name         string       `db:"name" json:"name"`
+	Type         ResourceType `db:"type" json:"type"`
+	Payload      []byte       `db:"payload" json:"payload"`
+	Meta         []byte       `db:"meta" json:"meta"`
+	Key          []byte       `db:"key" json:"key,omitempty"`
+	Revision     int64        `db:"revision" json:"revision"`
+	// UpdatedBy is the member who wrote the current revision, zero once
+	// their account is gone.
+	UpdatedBy int64     `db:"updated_by" json:"updated_by,omitempty"`
+	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
+}
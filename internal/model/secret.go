@@ -1,5 +1,7 @@
 package model
 
+import "time"
+
 type ResourceType uint8
 
 const (
@@ -7,6 +9,7 @@ const (
 	TextType
 	BinaryType
 	BankCardType
+	OTPType
 )
 
 type BankCard struct {
@@ -19,15 +22,30 @@ type BankCard struct {
 type LoginPassword struct {
 	Login    string `json:"login"`
 	Password string `json:"password"`
+	URL      string `json:"url,omitempty"`
+	// PasswordChangedAt is when the password was last set. It is zero for
+	// logins saved before it was recorded.
+	PasswordChangedAt time.Time `json:"password_changed_at"`
+}
+
+// OTP is the seed of a time-based one-time password, kept as an
+// otpauth://totp/ URI. Codes are generated by the client.
+type OTP struct {
+	URI string `json:"uri"`
 }
 
 type Text struct {
 	Value string `json:"value"`
 }
 
+// Binary is a file. Small files written by older clients are stored inline
+// in Value; otherwise the contents are uploaded separately as a blob of
+// Size bytes, encrypted in chunks with Key.
 type Binary struct {
 	Name  string `json:"name"`
-	Value []byte `json:"value"`
+	Value []byte `json:"value,omitempty"`
+	Size  int64  `json:"size,omitempty"`
+	Key   []byte `json:"key,omitempty"`
 }
 
 type Secret struct {
@@ -37,6 +55,79 @@ type Secret struct {
 	Type    ResourceType `db:"type" json:"type"`
 	Payload []byte       `db:"payload" json:"payload"`
 	Meta    []byte       `db:"meta" json:"meta"`
+	// Key is the per-secret data key wrapped by the owner's key. Empty for
+	// secrets encrypted by the legacy scheme.
+	Key []byte `db:"key" json:"key,omitempty"`
+	// UUID is picked by the client when sealing the secret and binds payload
+	// & meta to it. Empty for secrets sealed before.
+	UUID string `db:"uuid" json:"uuid,omitempty"`
+	// Revision is incremented on every update and used for optimistic
+	// concurrency control.
+	Revision  int64     `db:"revision" json:"revision"`
+	UpdatedAt time.Time `db:"updated_at" json:"updated_at"`
+	// FolderID is the folder holding the secret, zero for none.
+	FolderID int64   `db:"folder_id" json:"folder_id,omitempty"`
+	Favorite bool    `db:"favorite" json:"favorite,omitempty"`
+	Tags     []int64 `db:"-" json:"tags,omitempty"`
+}
+
+// SecretFilter narrows a list of secrets, zero values match everything.
+// FolderID matches secrets of the folder and of its subfolders.
+type SecretFilter struct {
+	Type     ResourceType
+	FolderID int64
+	TagID    int64
+	Favorite bool
+}
+
+// SecretPatch changes how a secret is organized without touching its
+// contents or revision. Nil fields are left as they are; a zero FolderID
+// takes the secret out of its folder.
+type SecretPatch struct {
+	FolderID *int64  `json:"folder_id,omitempty"`
+	Favorite *bool   `json:"favorite,omitempty"`
+	Tags     []int64 `json:"tags"`
+	// SetTags replaces the tags of the secret with Tags.
+	SetTags bool `json:"set_tags,omitempty"`
+}
+
+// Folder groups secrets, folders nest through ParentID (zero for top-level
+// folders). Name is encrypted like a secret's payload with a data key of
+// its own, wrapped in Key.
+type Folder struct {
+	ID       int64  `db:"id" json:"id"`
+	UserID   int64  `db:"user_id" json:"-"`
+	ParentID int64  `db:"parent_id" json:"parent_id,omitempty"`
+	Name     []byte `db:"name" json:"name"`
+	Key      []byte `db:"key" json:"key"`
+}
+
+// Tag labels secrets. Name is encrypted like Folder's.
+type Tag struct {
+	ID     int64  `db:"id" json:"id"`
+	UserID int64  `db:"user_id" json:"-"`
+	Name   []byte `db:"name" json:"name"`
+	Key    []byte `db:"key" json:"key"`
+}
+
+// Changes lists what changed in a vault after a sync cursor.
+type Changes struct {
+	Cursor  int64    `json:"cursor"`
+	Updated []Secret `json:"updated"`
+	Deleted []int64  `json:"deleted"`
+}
+
+// SecretVersion is a previous revision of a secret kept on update.
+type SecretVersion struct {
+	SecretID  int64        `db:"secret_id" json:"secret_id"`
+	Version   int64        `db:"version" json:"version"`
+	Name      string       `db:"name" json:"name"`
+	Type      ResourceType `db:"type" json:"type"`
+	Payload   []byte       `db:"payload" json:"payload,omitempty"`
+	Meta      []byte       `db:"meta" json:"meta,omitempty"`
+	Key       []byte       `db:"key" json:"key,omitempty"`
+	UUID      string       `db:"uuid" json:"uuid,omitempty"`
+	CreatedAt time.Time    `db:"created_at" json:"created_at"`
 }
 
 func ValidateParam(type_ string) (ResourceType, bool) {
@@ -49,6 +140,8 @@ func ValidateParam(type_ string) (ResourceType, bool) {
 		return BinaryType, true
 	case "4":
 		return BankCardType, true
+	case "5":
+		return OTPType, true
 	default:
 		return 0, false
 	}
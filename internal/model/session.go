@@ -0,0 +1,13 @@
+package model
+
+import "time"
+
+// Session describes an active login of a user.
+type Session struct {
+	ID         int64     `db:"id" json:"id"`
+	UserAgent  string    `db:"user_agent" json:"user_agent"`
+	CreatedAt  time.Time `db:"created_at" json:"created_at"`
+	LastSeenAt time.Time `db:"last_seen_at" json:"last_seen_at"`
+	ExpiresAt  time.Time `db:"expires_at" json:"expires_at"`
+	Current    bool      `db:"current" json:"current"`
+}
@@ -0,0 +1,44 @@
+package model
+
+import "time"
+
+// PublicKey is the published key of a user that secrets are shared with.
+type PublicKey struct {
+	UserID int64  `json:"user_id"`
+	Login  string `json:"login"`
+	Key    []byte `json:"key"`
+}
+
+// Share grants another user access to a secret. Key is the data key of the
+// secret wrapped with the public key of the recipient, so the server can't
+// read shared secrets any more than own ones.
+type Share struct {
+	SecretID  int64     `db:"secret_id" json:"secret_id"`
+	UserID    int64     `db:"user_id" json:"user_id"`
+	Login     string    `db:"login" json:"login"`
+	Key       []byte    `db:"key" json:"key,omitempty"`
+	CanEdit   bool      `db:"can_edit" json:"can_edit"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+}
+
+// SharedSecret is a secret shared with the user by its Owner. Key is the
+// data key wrapped for the user, the folder, tags and favorite of the owner
+// are left out.
+type SharedSecret struct {
+	Secret
+	Owner   string `db:"owner" json:"owner"`
+	CanEdit bool   `db:"can_edit" json:"can_edit"`
+}
+
+// SecretRekey replaces the data key of a secret and revokes the shares of
+// the users in Revoke: payload & meta sealed with the new key, the key
+// wrapped for the owner and for every remaining recipient in Shares.
+type SecretRekey struct {
+	Revision int64   `json:"revision"`
+	Payload  []byte  `json:"payload"`
+	Meta     []byte  `json:"meta"`
+	Key      []byte  `json:"key"`
+	UUID     string  `json:"uuid"`
+	Shares   []Share `json:"shares"`
+	Revoke   []int64 `json:"revoke,omitempty"`
+}
@@ -3,10 +3,32 @@ package model
 type RegisterDTO struct {
 	Login    string `json:"login"`
 	Password string `json:"password"`
+	// Salt, VaultKey and PublicKey are produced by the client: VaultKey is
+	// the user's private key encrypted under a key derived from the master
+	// password and Salt, so the server never sees it in the clear.
+	Salt      []byte `json:"salt,omitempty"`
+	VaultKey  []byte `json:"vault_key,omitempty"`
+	PublicKey []byte `json:"public_key,omitempty"`
 }
 
 type User struct {
 	ID           int64  `db:"id" json:"id"`
 	Login        string `db:"login" json:"login"`
 	PasswordHash string `db:"password_hash" json:"-"`
+	Salt         []byte `db:"kdf_salt" json:"salt,omitempty"`
+	VaultKey     []byte `db:"vault_key" json:"vault_key,omitempty"`
+	PublicKey    []byte `db:"public_key" json:"public_key,omitempty"`
+
+	// TOTPSecret is set on enrollment, TOTPEnabled once a code confirmed
+	// it. TOTPLastStep is the time step of the last accepted code.
+	TOTPSecret   []byte `db:"totp_secret" json:"-"`
+	TOTPEnabled  bool   `db:"totp_enabled" json:"-"`
+	TOTPLastStep int64  `db:"totp_last_step" json:"-"`
+}
+
+// TOTPEnrollment is a new second factor to be added to an authenticator
+// app, either by the base32 Secret or by scanning the URI as a QR code.
+type TOTPEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
 }
@@ -0,0 +1,219 @@
+// Package otp implements time-based one-time passwords (RFC 6238), as used
+// by authenticator apps.
+package otp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Supported HMAC algorithms.
+const (
+	SHA1   = "SHA1"
+	SHA256 = "SHA256"
+	SHA512 = "SHA512"
+)
+
+// Defaults understood by every authenticator app.
+const (
+	DefaultDigits = 6
+	DefaultPeriod = 30
+
+	secretSize = 20
+)
+
+// ErrInvalidURI is returned by Parse for URIs it can't use.
+var ErrInvalidURI = errors.New("invalid otpauth URI")
+
+// encoding is the base32 flavour of otpauth:// URIs.
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// Key is a TOTP seed together with its parameters.
+type Key struct {
+	Issuer    string
+	Account   string
+	Secret    []byte
+	Algorithm string
+	Digits    int
+	Period    int
+}
+
+// NewKey generates a random key with the default parameters.
+func NewKey(issuer, account string) (*Key, error) {
+	secret := make([]byte, secretSize)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+
+	return &Key{
+		Issuer:    issuer,
+		Account:   account,
+		Secret:    secret,
+		Algorithm: SHA1,
+		Digits:    DefaultDigits,
+		Period:    DefaultPeriod,
+	}, nil
+}
+
+// EncodeSecret returns secret in the base32 form users type in.
+func EncodeSecret(secret []byte) string {
+	return encoding.EncodeToString(secret)
+}
+
+// DecodeSecret parses a base32 secret, ignoring case, spaces and padding.
+func DecodeSecret(s string) ([]byte, error) {
+	s = strings.ToUpper(strings.ReplaceAll(s, " ", ""))
+	return encoding.DecodeString(strings.TrimRight(s, "="))
+}
+
+// Parse reads a key from an otpauth://totp/ URI, as encoded in the QR codes
+// of authenticator apps. Missing parameters take the defaults.
+func Parse(uri string) (*Key, error) {
+	u, err := url.Parse(strings.TrimSpace(uri))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidURI, err)
+	}
+	if u.Scheme != "otpauth" || u.Host != "totp" {
+		return nil, fmt.Errorf("%w: only otpauth://totp/ is supported", ErrInvalidURI)
+	}
+
+	k := &Key{Algorithm: SHA1, Digits: DefaultDigits, Period: DefaultPeriod}
+
+	label := strings.TrimPrefix(u.Path, "/")
+	if issuer, account, ok := strings.Cut(label, ":"); ok {
+		k.Issuer, k.Account = issuer, strings.TrimSpace(account)
+	} else {
+		k.Account = label
+	}
+
+	q := u.Query()
+	if issuer := q.Get("issuer"); issuer != "" {
+		k.Issuer = issuer
+	}
+
+	if k.Secret, err = DecodeSecret(q.Get("secret")); err != nil || len(k.Secret) == 0 {
+		return nil, fmt.Errorf("%w: bad secret", ErrInvalidURI)
+	}
+
+	if alg := q.Get("algorithm"); alg != "" {
+		k.Algorithm = strings.ToUpper(alg)
+		switch k.Algorithm {
+		case SHA1, SHA256, SHA512:
+		default:
+			return nil, fmt.Errorf("%w: unknown algorithm %s", ErrInvalidURI, alg)
+		}
+	}
+
+	if digits := q.Get("digits"); digits != "" {
+		if k.Digits, err = strconv.Atoi(digits); err != nil || k.Digits < 6 || k.Digits > 8 {
+			return nil, fmt.Errorf("%w: bad digits", ErrInvalidURI)
+		}
+	}
+
+	if period := q.Get("period"); period != "" {
+		if k.Period, err = strconv.Atoi(period); err != nil || k.Period <= 0 {
+			return nil, fmt.Errorf("%w: bad period", ErrInvalidURI)
+		}
+	}
+
+	return k, nil
+}
+
+// URI returns the otpauth:// URI of the key, usually shown as a QR code.
+func (k *Key) URI() string {
+	label := k.Account
+	if k.Issuer != "" {
+		label = k.Issuer + ":" + k.Account
+	}
+
+	q := url.Values{}
+	q.Set("secret", EncodeSecret(k.Secret))
+	if k.Issuer != "" {
+		q.Set("issuer", k.Issuer)
+	}
+	q.Set("algorithm", k.Algorithm)
+	q.Set("digits", strconv.Itoa(k.Digits))
+	q.Set("period", strconv.Itoa(k.Period))
+
+	u := url.URL{Scheme: "otpauth", Host: "totp", Path: "/" + label, RawQuery: q.Encode()}
+	return u.String()
+}
+
+// Step returns the time step t falls into.
+func (k *Key) Step(t time.Time) int64 {
+	return t.Unix() / int64(k.Period)
+}
+
+// Remaining returns how long the code of time t stays valid.
+func (k *Key) Remaining(t time.Time) time.Duration {
+	period := int64(k.Period)
+	return time.Duration(period-t.Unix()%period) * time.Second
+}
+
+// Code returns the code for time t.
+func (k *Key) Code(t time.Time) string {
+	return k.code(k.Step(t))
+}
+
+// Verify checks code against the steps around t, allowing for one step of
+// clock skew, and returns the matching step. Callers should reject steps
+// that were already used, so a code can't be replayed.
+func (k *Key) Verify(code string, t time.Time) (int64, bool) {
+	code = strings.TrimSpace(code)
+	if len(code) != k.Digits {
+		return 0, false
+	}
+
+	step := k.Step(t)
+	for _, s := range []int64{step, step - 1, step + 1} {
+		if subtle.ConstantTimeCompare([]byte(k.code(s)), []byte(code)) == 1 {
+			return s, true
+		}
+	}
+
+	return 0, false
+}
+
+// code implements HOTP (RFC 4226) for counter step.
+func (k *Key) code(step int64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(step))
+
+	mac := hmac.New(k.hash(), k.Secret)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:]) & 0x7fffffff
+
+	mod := uint32(1)
+	for i := 0; i < k.Digits; i++ {
+		mod *= 10
+	}
+
+	return fmt.Sprintf("%0*d", k.Digits, value%mod)
+}
+
+func (k *Key) hash() func() hash.Hash {
+	switch k.Algorithm {
+	case SHA256:
+		return sha256.New
+	case SHA512:
+		return sha512.New
+	default:
+		return sha1.New
+	}
+}
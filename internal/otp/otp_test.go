@@ -0,0 +1,186 @@
+package otp
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Seeds of the test vectors of RFC 6238, Appendix B.
+const (
+	seedSHA1   = "12345678901234567890"
+	seedSHA256 = "12345678901234567890123456789012"
+	seedSHA512 = "1234567890123456789012345678901234567890123456789012345678901234"
+)
+
+func TestCodeRFC6238(t *testing.T) {
+	tests := []struct {
+		unix   int64
+		sha1   string
+		sha256 string
+		sha512 string
+	}{
+		{59, "94287082", "46119246", "90693936"},
+		{1111111109, "07081804", "68084774", "25091201"},
+		{1111111111, "14050471", "67062674", "99943326"},
+		{1234567890, "89005924", "91819424", "93441116"},
+		{2000000000, "69279037", "90698825", "38618901"},
+		{20000000000, "65353130", "77737706", "47863826"},
+	}
+
+	keys := []struct {
+		algorithm string
+		seed      string
+		want      func(i int) string
+	}{
+		{SHA1, seedSHA1, func(i int) string { return tests[i].sha1 }},
+		{SHA256, seedSHA256, func(i int) string { return tests[i].sha256 }},
+		{SHA512, seedSHA512, func(i int) string { return tests[i].sha512 }},
+	}
+
+	for _, key := range keys {
+		k := &Key{Secret: []byte(key.seed), Algorithm: key.algorithm, Digits: 8, Period: DefaultPeriod}
+		for i, tt := range tests {
+			now := time.Unix(tt.unix, 0)
+			want := key.want(i)
+
+			if got := k.Code(now); got != want {
+				t.Errorf("%s at %d: code %s, want %s", key.algorithm, tt.unix, got, want)
+			}
+			if step, ok := k.Verify(want, now); !ok || step != k.Step(now) {
+				t.Errorf("%s at %d: Verify = %d, %v", key.algorithm, tt.unix, step, ok)
+			}
+		}
+	}
+}
+
+func TestVerify(t *testing.T) {
+	k := &Key{Secret: []byte(seedSHA1), Algorithm: SHA1, Digits: DefaultDigits, Period: DefaultPeriod}
+	now := time.Unix(1111111111, 0)
+	step := k.Step(now)
+
+	tests := []struct {
+		name     string
+		code     string
+		wantStep int64
+		wantOK   bool
+	}{
+		{name: "current", code: k.code(step), wantStep: step, wantOK: true},
+		{name: "previous", code: k.code(step - 1), wantStep: step - 1, wantOK: true},
+		{name: "next", code: k.code(step + 1), wantStep: step + 1, wantOK: true},
+		{name: "spaces", code: " " + k.code(step) + "\n", wantStep: step, wantOK: true},
+		{name: "too old", code: k.code(step - 2)},
+		{name: "too new", code: k.code(step + 2)},
+		{name: "wrong length", code: k.code(step)[1:]},
+		{name: "empty"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := k.Verify(tt.code, now)
+			if ok != tt.wantOK || got != tt.wantStep {
+				t.Errorf("Verify(%q) = %d, %v, want %d, %v", tt.code, got, ok, tt.wantStep, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestURIRoundTrip(t *testing.T) {
+	keys := []*Key{
+		{Issuer: "Example", Account: "alice@example.com", Secret: []byte(seedSHA1), Algorithm: SHA1,
+			Digits: DefaultDigits, Period: DefaultPeriod},
+		{Issuer: "ACME Co", Account: "bob smith", Secret: []byte(seedSHA512), Algorithm: SHA512,
+			Digits: 8, Period: 60},
+		{Account: "carol", Secret: []byte(seedSHA256), Algorithm: SHA256, Digits: 7, Period: 15},
+	}
+
+	for _, want := range keys {
+		uri := want.URI()
+		if !strings.HasPrefix(uri, "otpauth://totp/") {
+			t.Errorf("URI %s: bad prefix", uri)
+		}
+
+		got, err := Parse(uri)
+		if err != nil {
+			t.Errorf("Parse(%s): %v", uri, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Parse(%s) = %+v, want %+v", uri, got, want)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		uri     string
+		want    *Key
+		wantErr bool
+	}{
+		{
+			name: "defaults",
+			uri:  "otpauth://totp/Example:alice@example.com?secret=JBSWY3DPEHPK3PXP&issuer=Example",
+			want: &Key{Issuer: "Example", Account: "alice@example.com", Secret: []byte("Hello!\xde\xad\xbe\xef"),
+				Algorithm: SHA1, Digits: DefaultDigits, Period: DefaultPeriod},
+		},
+		{
+			name: "issuer parameter wins",
+			uri:  "otpauth://totp/Old:%20alice?secret=jbswy3dpehpk3pxp&issuer=New&algorithm=sha256&digits=8&period=60",
+			want: &Key{Issuer: "New", Account: "alice", Secret: []byte("Hello!\xde\xad\xbe\xef"),
+				Algorithm: SHA256, Digits: 8, Period: 60},
+		},
+		{
+			name: "no issuer",
+			uri:  "otpauth://totp/alice?secret=JBSWY3DPEHPK3PXP",
+			want: &Key{Account: "alice", Secret: []byte("Hello!\xde\xad\xbe\xef"), Algorithm: SHA1,
+				Digits: DefaultDigits, Period: DefaultPeriod},
+		},
+		{name: "hotp", uri: "otpauth://hotp/alice?secret=JBSWY3DPEHPK3PXP&counter=1", wantErr: true},
+		{name: "other scheme", uri: "https://totp/alice?secret=JBSWY3DPEHPK3PXP", wantErr: true},
+		{name: "no secret", uri: "otpauth://totp/alice", wantErr: true},
+		{name: "bad secret", uri: "otpauth://totp/alice?secret=!!!", wantErr: true},
+		{name: "bad algorithm", uri: "otpauth://totp/alice?secret=JBSWY3DPEHPK3PXP&algorithm=MD5", wantErr: true},
+		{name: "too few digits", uri: "otpauth://totp/alice?secret=JBSWY3DPEHPK3PXP&digits=4", wantErr: true},
+		{name: "too many digits", uri: "otpauth://totp/alice?secret=JBSWY3DPEHPK3PXP&digits=9", wantErr: true},
+		{name: "bad period", uri: "otpauth://totp/alice?secret=JBSWY3DPEHPK3PXP&period=0", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.uri)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidURI) {
+					t.Errorf("Parse(%s) error = %v, want %v", tt.uri, err, ErrInvalidURI)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse(%s): %v", tt.uri, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse(%s) = %+v, want %+v", tt.uri, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeSecret(t *testing.T) {
+	want := []byte("Hello!\xde\xad\xbe\xef")
+	for _, s := range []string{"JBSWY3DPEHPK3PXP", "jbsw y3dp ehpk 3pxp", "JBSWY3DPEHPK3PXP===="} {
+		got, err := DecodeSecret(s)
+		if err != nil {
+			t.Errorf("DecodeSecret(%q): %v", s, err)
+			continue
+		}
+		if string(got) != string(want) {
+			t.Errorf("DecodeSecret(%q) = %x, want %x", s, got, want)
+		}
+	}
+
+	if s := EncodeSecret(want); s != "JBSWY3DPEHPK3PXP" {
+		t.Errorf("EncodeSecret = %s, want JBSWY3DPEHPK3PXP", s)
+	}
+}
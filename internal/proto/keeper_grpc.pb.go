@@ -0,0 +1,1776 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: keeper.proto
+
+package proto
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+	emptypb "google.golang.org/protobuf/types/known/emptypb"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Keeper_Register_FullMethodName               = "/keeper.Keeper/Register"
+	Keeper_Prelogin_FullMethodName               = "/keeper.Keeper/Prelogin"
+	Keeper_Login_FullMethodName                  = "/keeper.Keeper/Login"
+	Keeper_LoginTwoFactor_FullMethodName         = "/keeper.Keeper/LoginTwoFactor"
+	Keeper_EnrollTOTP_FullMethodName             = "/keeper.Keeper/EnrollTOTP"
+	Keeper_VerifyTOTP_FullMethodName             = "/keeper.Keeper/VerifyTOTP"
+	Keeper_DisableTOTP_FullMethodName            = "/keeper.Keeper/DisableTOTP"
+	Keeper_UpdateKeys_FullMethodName             = "/keeper.Keeper/UpdateKeys"
+	Keeper_Logout_FullMethodName                 = "/keeper.Keeper/Logout"
+	Keeper_ListSessions_FullMethodName           = "/keeper.Keeper/ListSessions"
+	Keeper_RevokeSession_FullMethodName          = "/keeper.Keeper/RevokeSession"
+	Keeper_ListAuditEvents_FullMethodName        = "/keeper.Keeper/ListAuditEvents"
+	Keeper_CreateSecret_FullMethodName           = "/keeper.Keeper/CreateSecret"
+	Keeper_ListSecrets_FullMethodName            = "/keeper.Keeper/ListSecrets"
+	Keeper_GetSecret_FullMethodName              = "/keeper.Keeper/GetSecret"
+	Keeper_UpdateSecret_FullMethodName           = "/keeper.Keeper/UpdateSecret"
+	Keeper_DeleteSecret_FullMethodName           = "/keeper.Keeper/DeleteSecret"
+	Keeper_PatchSecret_FullMethodName            = "/keeper.Keeper/PatchSecret"
+	Keeper_ListFolders_FullMethodName            = "/keeper.Keeper/ListFolders"
+	Keeper_CreateFolder_FullMethodName           = "/keeper.Keeper/CreateFolder"
+	Keeper_DeleteFolder_FullMethodName           = "/keeper.Keeper/DeleteFolder"
+	Keeper_ListTags_FullMethodName               = "/keeper.Keeper/ListTags"
+	Keeper_CreateTag_FullMethodName              = "/keeper.Keeper/CreateTag"
+	Keeper_GetPublicKey_FullMethodName           = "/keeper.Keeper/GetPublicKey"
+	Keeper_ShareSecret_FullMethodName            = "/keeper.Keeper/ShareSecret"
+	Keeper_ListShares_FullMethodName             = "/keeper.Keeper/ListShares"
+	Keeper_RekeySecret_FullMethodName            = "/keeper.Keeper/RekeySecret"
+	Keeper_ListSharedSecrets_FullMethodName      = "/keeper.Keeper/ListSharedSecrets"
+	Keeper_UpdateSharedSecret_FullMethodName     = "/keeper.Keeper/UpdateSharedSecret"
+	Keeper_CreateOneTimeSecret_FullMethodName    = "/keeper.Keeper/CreateOneTimeSecret"
+	Keeper_GetOneTimeSecret_FullMethodName       = "/keeper.Keeper/GetOneTimeSecret"
+	Keeper_ListEmergencyGrants_FullMethodName    = "/keeper.Keeper/ListEmergencyGrants"
+	Keeper_CreateEmergencyGrant_FullMethodName   = "/keeper.Keeper/CreateEmergencyGrant"
+	Keeper_DeleteEmergencyGrant_FullMethodName   = "/keeper.Keeper/DeleteEmergencyGrant"
+	Keeper_RequestEmergencyAccess_FullMethodName = "/keeper.Keeper/RequestEmergencyAccess"
+	Keeper_RejectEmergencyAccess_FullMethodName  = "/keeper.Keeper/RejectEmergencyAccess"
+	Keeper_GetEmergencyVault_FullMethodName      = "/keeper.Keeper/GetEmergencyVault"
+	Keeper_ListVersions_FullMethodName           = "/keeper.Keeper/ListVersions"
+	Keeper_GetVersion_FullMethodName             = "/keeper.Keeper/GetVersion"
+	Keeper_RestoreVersion_FullMethodName         = "/keeper.Keeper/RestoreVersion"
+	Keeper_Sync_FullMethodName                   = "/keeper.Keeper/Sync"
+	Keeper_PutBlob_FullMethodName                = "/keeper.Keeper/PutBlob"
+	Keeper_GetBlob_FullMethodName                = "/keeper.Keeper/GetBlob"
+)
+
+// KeeperClient is the client API for Keeper service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Keeper mirrors the REST API of the server. Except for Register, Prelogin,
+// Login, LoginTwoFactor and GetOneTimeSecret every call must carry the
+// session ID in the "authorization" metadata. Secrets are encrypted by the
+// client, the server never sees their payload.
+type KeeperClient interface {
+	Register(ctx context.Context, in *RegisterRequest, opts ...grpc.CallOption) (*AuthResponse, error)
+	Prelogin(ctx context.Context, in *PreloginRequest, opts ...grpc.CallOption) (*PreloginResponse, error)
+	// Login returns a challenge instead of a session when two-factor
+	// authentication is enabled, LoginTwoFactor answers it with a TOTP or
+	// recovery code.
+	Login(ctx context.Context, in *LoginRequest, opts ...grpc.CallOption) (*AuthResponse, error)
+	LoginTwoFactor(ctx context.Context, in *LoginTwoFactorRequest, opts ...grpc.CallOption) (*AuthResponse, error)
+	EnrollTOTP(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*EnrollTOTPResponse, error)
+	VerifyTOTP(ctx context.Context, in *TOTPCode, opts ...grpc.CallOption) (*RecoveryCodes, error)
+	DisableTOTP(ctx context.Context, in *TOTPCode, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	UpdateKeys(ctx context.Context, in *UpdateKeysRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	Logout(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	ListSessions(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*ListSessionsResponse, error)
+	RevokeSession(ctx context.Context, in *RevokeSessionRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	// ListAuditEvents returns the audit trail of the user, oldest first.
+	ListAuditEvents(ctx context.Context, in *AuditRequest, opts ...grpc.CallOption) (*ListAuditEventsResponse, error)
+	CreateSecret(ctx context.Context, in *Secret, opts ...grpc.CallOption) (*CreateSecretResponse, error)
+	ListSecrets(ctx context.Context, in *ListSecretsRequest, opts ...grpc.CallOption) (*ListSecretsResponse, error)
+	GetSecret(ctx context.Context, in *SecretRequest, opts ...grpc.CallOption) (*Secret, error)
+	// UpdateSecret and DeleteSecret require the revision the change is based
+	// on and fail with FAILED_PRECONDITION and a RevisionConflict detail when
+	// it is stale.
+	UpdateSecret(ctx context.Context, in *Secret, opts ...grpc.CallOption) (*UpdateSecretResponse, error)
+	DeleteSecret(ctx context.Context, in *DeleteSecretRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	// PatchSecret moves a secret between folders, marks it as favorite or
+	// replaces its tags without changing its revision.
+	PatchSecret(ctx context.Context, in *SecretPatch, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	// Folders and tags have names encrypted by the client like secrets.
+	ListFolders(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*ListFoldersResponse, error)
+	CreateFolder(ctx context.Context, in *Folder, opts ...grpc.CallOption) (*CreateFolderResponse, error)
+	DeleteFolder(ctx context.Context, in *FolderRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	ListTags(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*ListTagsResponse, error)
+	CreateTag(ctx context.Context, in *Tag, opts ...grpc.CallOption) (*CreateTagResponse, error)
+	// Sharing. Data keys are wrapped by the client with the public key of
+	// the recipient. UpdateSharedSecret keeps the data key and the type and
+	// fails with PERMISSION_DENIED for secrets shared read-only. RekeySecret
+	// revokes shares together with replacing the data key and fails with
+	// ABORTED when the recipients have changed meanwhile.
+	GetPublicKey(ctx context.Context, in *PublicKeyRequest, opts ...grpc.CallOption) (*PublicKey, error)
+	ShareSecret(ctx context.Context, in *Share, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	ListShares(ctx context.Context, in *SecretRequest, opts ...grpc.CallOption) (*ListSharesResponse, error)
+	RekeySecret(ctx context.Context, in *SecretRekey, opts ...grpc.CallOption) (*UpdateSecretResponse, error)
+	ListSharedSecrets(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*ListSharedSecretsResponse, error)
+	UpdateSharedSecret(ctx context.Context, in *Secret, opts ...grpc.CallOption) (*UpdateSecretResponse, error)
+	// One-time secrets are encrypted with a key only the link carries.
+	// GetOneTimeSecret counts a view and deletes the secret on its last one.
+	CreateOneTimeSecret(ctx context.Context, in *OneTimeSecret, opts ...grpc.CallOption) (*OneTimeSecret, error)
+	GetOneTimeSecret(ctx context.Context, in *OneTimeSecretRequest, opts ...grpc.CallOption) (*OneTimeSecret, error)
+	// Emergency access. The grantor is told about requests through their
+	// audit trail and may reject them until the wait period has passed.
+	ListEmergencyGrants(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*ListEmergencyGrantsResponse, error)
+	CreateEmergencyGrant(ctx context.Context, in *EmergencyGrant, opts ...grpc.CallOption) (*CreateEmergencyGrantResponse, error)
+	DeleteEmergencyGrant(ctx context.Context, in *EmergencyRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	RequestEmergencyAccess(ctx context.Context, in *EmergencyRequest, opts ...grpc.CallOption) (*EmergencyGrant, error)
+	RejectEmergencyAccess(ctx context.Context, in *EmergencyRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	GetEmergencyVault(ctx context.Context, in *EmergencyRequest, opts ...grpc.CallOption) (*EmergencyVault, error)
+	ListVersions(ctx context.Context, in *SecretRequest, opts ...grpc.CallOption) (*ListVersionsResponse, error)
+	GetVersion(ctx context.Context, in *VersionRequest, opts ...grpc.CallOption) (*SecretVersion, error)
+	RestoreVersion(ctx context.Context, in *VersionRequest, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	// Sync streams the secrets changed after the cursor, then the IDs of the
+	// deleted ones and finally the new cursor.
+	Sync(ctx context.Context, in *SyncRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[SyncEvent], error)
+	// PutBlob replaces the file contents of a binary secret together with its
+	// payload. The first chunk carries the secret ID and the sealed secret,
+	// whose revision must be the current one as for UpdateSecret.
+	PutBlob(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[BlobChunk, PutBlobResponse], error)
+	GetBlob(ctx context.Context, in *SecretRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[BlobChunk], error)
+}
+
+type keeperClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewKeeperClient(cc grpc.ClientConnInterface) KeeperClient {
+	return &keeperClient{cc}
+}
+
+func (c *keeperClient) Register(ctx context.Context, in *RegisterRequest, opts ...grpc.CallOption) (*AuthResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(AuthResponse)
+	err := c.cc.Invoke(ctx, Keeper_Register_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *keeperClient) Prelogin(ctx context.Context, in *PreloginRequest, opts ...grpc.CallOption) (*PreloginResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(PreloginResponse)
+	err := c.cc.Invoke(ctx, Keeper_Prelogin_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *keeperClient) Login(ctx context.Context, in *LoginRequest, opts ...grpc.CallOption) (*AuthResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(AuthResponse)
+	err := c.cc.Invoke(ctx, Keeper_Login_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *keeperClient) LoginTwoFactor(ctx context.Context, in *LoginTwoFactorRequest, opts ...grpc.CallOption) (*AuthResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(AuthResponse)
+	err := c.cc.Invoke(ctx, Keeper_LoginTwoFactor_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *keeperClient) EnrollTOTP(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*EnrollTOTPResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(EnrollTOTPResponse)
+	err := c.cc.Invoke(ctx, Keeper_EnrollTOTP_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *keeperClient) VerifyTOTP(ctx context.Context, in *TOTPCode, opts ...grpc.CallOption) (*RecoveryCodes, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RecoveryCodes)
+	err := c.cc.Invoke(ctx, Keeper_VerifyTOTP_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *keeperClient) DisableTOTP(ctx context.Context, in *TOTPCode, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, Keeper_DisableTOTP_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *keeperClient) UpdateKeys(ctx context.Context, in *UpdateKeysRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, Keeper_UpdateKeys_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *keeperClient) Logout(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, Keeper_Logout_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *keeperClient) ListSessions(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*ListSessionsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListSessionsResponse)
+	err := c.cc.Invoke(ctx, Keeper_ListSessions_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *keeperClient) RevokeSession(ctx context.Context, in *RevokeSessionRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, Keeper_RevokeSession_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *keeperClient) ListAuditEvents(ctx context.Context, in *AuditRequest, opts ...grpc.CallOption) (*ListAuditEventsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListAuditEventsResponse)
+	err := c.cc.Invoke(ctx, Keeper_ListAuditEvents_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *keeperClient) CreateSecret(ctx context.Context, in *Secret, opts ...grpc.CallOption) (*CreateSecretResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CreateSecretResponse)
+	err := c.cc.Invoke(ctx, Keeper_CreateSecret_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *keeperClient) ListSecrets(ctx context.Context, in *ListSecretsRequest, opts ...grpc.CallOption) (*ListSecretsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListSecretsResponse)
+	err := c.cc.Invoke(ctx, Keeper_ListSecrets_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *keeperClient) GetSecret(ctx context.Context, in *SecretRequest, opts ...grpc.CallOption) (*Secret, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Secret)
+	err := c.cc.Invoke(ctx, Keeper_GetSecret_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *keeperClient) UpdateSecret(ctx context.Context, in *Secret, opts ...grpc.CallOption) (*UpdateSecretResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(UpdateSecretResponse)
+	err := c.cc.Invoke(ctx, Keeper_UpdateSecret_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *keeperClient) DeleteSecret(ctx context.Context, in *DeleteSecretRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, Keeper_DeleteSecret_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *keeperClient) PatchSecret(ctx context.Context, in *SecretPatch, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, Keeper_PatchSecret_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *keeperClient) ListFolders(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*ListFoldersResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListFoldersResponse)
+	err := c.cc.Invoke(ctx, Keeper_ListFolders_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *keeperClient) CreateFolder(ctx context.Context, in *Folder, opts ...grpc.CallOption) (*CreateFolderResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CreateFolderResponse)
+	err := c.cc.Invoke(ctx, Keeper_CreateFolder_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *keeperClient) DeleteFolder(ctx context.Context, in *FolderRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, Keeper_DeleteFolder_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *keeperClient) ListTags(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*ListTagsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListTagsResponse)
+	err := c.cc.Invoke(ctx, Keeper_ListTags_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *keeperClient) CreateTag(ctx context.Context, in *Tag, opts ...grpc.CallOption) (*CreateTagResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CreateTagResponse)
+	err := c.cc.Invoke(ctx, Keeper_CreateTag_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *keeperClient) GetPublicKey(ctx context.Context, in *PublicKeyRequest, opts ...grpc.CallOption) (*PublicKey, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(PublicKey)
+	err := c.cc.Invoke(ctx, Keeper_GetPublicKey_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *keeperClient) ShareSecret(ctx context.Context, in *Share, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, Keeper_ShareSecret_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *keeperClient) ListShares(ctx context.Context, in *SecretRequest, opts ...grpc.CallOption) (*ListSharesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListSharesResponse)
+	err := c.cc.Invoke(ctx, Keeper_ListShares_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *keeperClient) RekeySecret(ctx context.Context, in *SecretRekey, opts ...grpc.CallOption) (*UpdateSecretResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(UpdateSecretResponse)
+	err := c.cc.Invoke(ctx, Keeper_RekeySecret_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *keeperClient) ListSharedSecrets(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*ListSharedSecretsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListSharedSecretsResponse)
+	err := c.cc.Invoke(ctx, Keeper_ListSharedSecrets_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *keeperClient) UpdateSharedSecret(ctx context.Context, in *Secret, opts ...grpc.CallOption) (*UpdateSecretResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(UpdateSecretResponse)
+	err := c.cc.Invoke(ctx, Keeper_UpdateSharedSecret_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *keeperClient) CreateOneTimeSecret(ctx context.Context, in *OneTimeSecret, opts ...grpc.CallOption) (*OneTimeSecret, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(OneTimeSecret)
+	err := c.cc.Invoke(ctx, Keeper_CreateOneTimeSecret_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *keeperClient) GetOneTimeSecret(ctx context.Context, in *OneTimeSecretRequest, opts ...grpc.CallOption) (*OneTimeSecret, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(OneTimeSecret)
+	err := c.cc.Invoke(ctx, Keeper_GetOneTimeSecret_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *keeperClient) ListEmergencyGrants(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*ListEmergencyGrantsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListEmergencyGrantsResponse)
+	err := c.cc.Invoke(ctx, Keeper_ListEmergencyGrants_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *keeperClient) CreateEmergencyGrant(ctx context.Context, in *EmergencyGrant, opts ...grpc.CallOption) (*CreateEmergencyGrantResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CreateEmergencyGrantResponse)
+	err := c.cc.Invoke(ctx, Keeper_CreateEmergencyGrant_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *keeperClient) DeleteEmergencyGrant(ctx context.Context, in *EmergencyRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, Keeper_DeleteEmergencyGrant_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *keeperClient) RequestEmergencyAccess(ctx context.Context, in *EmergencyRequest, opts ...grpc.CallOption) (*EmergencyGrant, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(EmergencyGrant)
+	err := c.cc.Invoke(ctx, Keeper_RequestEmergencyAccess_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *keeperClient) RejectEmergencyAccess(ctx context.Context, in *EmergencyRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, Keeper_RejectEmergencyAccess_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *keeperClient) GetEmergencyVault(ctx context.Context, in *EmergencyRequest, opts ...grpc.CallOption) (*EmergencyVault, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(EmergencyVault)
+	err := c.cc.Invoke(ctx, Keeper_GetEmergencyVault_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *keeperClient) ListVersions(ctx context.Context, in *SecretRequest, opts ...grpc.CallOption) (*ListVersionsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListVersionsResponse)
+	err := c.cc.Invoke(ctx, Keeper_ListVersions_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *keeperClient) GetVersion(ctx context.Context, in *VersionRequest, opts ...grpc.CallOption) (*SecretVersion, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SecretVersion)
+	err := c.cc.Invoke(ctx, Keeper_GetVersion_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *keeperClient) RestoreVersion(ctx context.Context, in *VersionRequest, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, Keeper_RestoreVersion_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *keeperClient) Sync(ctx context.Context, in *SyncRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[SyncEvent], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Keeper_ServiceDesc.Streams[0], Keeper_Sync_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[SyncRequest, SyncEvent]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Keeper_SyncClient = grpc.ServerStreamingClient[SyncEvent]
+
+func (c *keeperClient) PutBlob(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[BlobChunk, PutBlobResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Keeper_ServiceDesc.Streams[1], Keeper_PutBlob_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[BlobChunk, PutBlobResponse]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Keeper_PutBlobClient = grpc.ClientStreamingClient[BlobChunk, PutBlobResponse]
+
+func (c *keeperClient) GetBlob(ctx context.Context, in *SecretRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[BlobChunk], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Keeper_ServiceDesc.Streams[2], Keeper_GetBlob_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[SecretRequest, BlobChunk]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Keeper_GetBlobClient = grpc.ServerStreamingClient[BlobChunk]
+
+// KeeperServer is the server API for Keeper service.
+// All implementations must embed UnimplementedKeeperServer
+// for forward compatibility.
+//
+// Keeper mirrors the REST API of the server. Except for Register, Prelogin,
+// Login, LoginTwoFactor and GetOneTimeSecret every call must carry the
+// session ID in the "authorization" metadata. Secrets are encrypted by the
+// client, the server never sees their payload.
+type KeeperServer interface {
+	Register(context.Context, *RegisterRequest) (*AuthResponse, error)
+	Prelogin(context.Context, *PreloginRequest) (*PreloginResponse, error)
+	// Login returns a challenge instead of a session when two-factor
+	// authentication is enabled, LoginTwoFactor answers it with a TOTP or
+	// recovery code.
+	Login(context.Context, *LoginRequest) (*AuthResponse, error)
+	LoginTwoFactor(context.Context, *LoginTwoFactorRequest) (*AuthResponse, error)
+	EnrollTOTP(context.Context, *emptypb.Empty) (*EnrollTOTPResponse, error)
+	VerifyTOTP(context.Context, *TOTPCode) (*RecoveryCodes, error)
+	DisableTOTP(context.Context, *TOTPCode) (*emptypb.Empty, error)
+	UpdateKeys(context.Context, *UpdateKeysRequest) (*emptypb.Empty, error)
+	Logout(context.Context, *emptypb.Empty) (*emptypb.Empty, error)
+	ListSessions(context.Context, *emptypb.Empty) (*ListSessionsResponse, error)
+	RevokeSession(context.Context, *RevokeSessionRequest) (*emptypb.Empty, error)
+	// ListAuditEvents returns the audit trail of the user, oldest first.
+	ListAuditEvents(context.Context, *AuditRequest) (*ListAuditEventsResponse, error)
+	CreateSecret(context.Context, *Secret) (*CreateSecretResponse, error)
+	ListSecrets(context.Context, *ListSecretsRequest) (*ListSecretsResponse, error)
+	GetSecret(context.Context, *SecretRequest) (*Secret, error)
+	// UpdateSecret and DeleteSecret require the revision the change is based
+	// on and fail with FAILED_PRECONDITION and a RevisionConflict detail when
+	// it is stale.
+	UpdateSecret(context.Context, *Secret) (*UpdateSecretResponse, error)
+	DeleteSecret(context.Context, *DeleteSecretRequest) (*emptypb.Empty, error)
+	// PatchSecret moves a secret between folders, marks it as favorite or
+	// replaces its tags without changing its revision.
+	PatchSecret(context.Context, *SecretPatch) (*emptypb.Empty, error)
+	// Folders and tags have names encrypted by the client like secrets.
+	ListFolders(context.Context, *emptypb.Empty) (*ListFoldersResponse, error)
+	CreateFolder(context.Context, *Folder) (*CreateFolderResponse, error)
+	DeleteFolder(context.Context, *FolderRequest) (*emptypb.Empty, error)
+	ListTags(context.Context, *emptypb.Empty) (*ListTagsResponse, error)
+	CreateTag(context.Context, *Tag) (*CreateTagResponse, error)
+	// Sharing. Data keys are wrapped by the client with the public key of
+	// the recipient. UpdateSharedSecret keeps the data key and the type and
+	// fails with PERMISSION_DENIED for secrets shared read-only. RekeySecret
+	// revokes shares together with replacing the data key and fails with
+	// ABORTED when the recipients have changed meanwhile.
+	GetPublicKey(context.Context, *PublicKeyRequest) (*PublicKey, error)
+	ShareSecret(context.Context, *Share) (*emptypb.Empty, error)
+	ListShares(context.Context, *SecretRequest) (*ListSharesResponse, error)
+	RekeySecret(context.Context, *SecretRekey) (*UpdateSecretResponse, error)
+	ListSharedSecrets(context.Context, *emptypb.Empty) (*ListSharedSecretsResponse, error)
+	UpdateSharedSecret(context.Context, *Secret) (*UpdateSecretResponse, error)
+	// One-time secrets are encrypted with a key only the link carries.
+	// GetOneTimeSecret counts a view and deletes the secret on its last one.
+	CreateOneTimeSecret(context.Context, *OneTimeSecret) (*OneTimeSecret, error)
+	GetOneTimeSecret(context.Context, *OneTimeSecretRequest) (*OneTimeSecret, error)
+	// Emergency access. The grantor is told about requests through their
+	// audit trail and may reject them until the wait period has passed.
+	ListEmergencyGrants(context.Context, *emptypb.Empty) (*ListEmergencyGrantsResponse, error)
+	CreateEmergencyGrant(context.Context, *EmergencyGrant) (*CreateEmergencyGrantResponse, error)
+	DeleteEmergencyGrant(context.Context, *EmergencyRequest) (*emptypb.Empty, error)
+	RequestEmergencyAccess(context.Context, *EmergencyRequest) (*EmergencyGrant, error)
+	RejectEmergencyAccess(context.Context, *EmergencyRequest) (*emptypb.Empty, error)
+	GetEmergencyVault(context.Context, *EmergencyRequest) (*EmergencyVault, error)
+	ListVersions(context.Context, *SecretRequest) (*ListVersionsResponse, error)
+	GetVersion(context.Context, *VersionRequest) (*SecretVersion, error)
+	RestoreVersion(context.Context, *VersionRequest) (*emptypb.Empty, error)
+	// Sync streams the secrets changed after the cursor, then the IDs of the
+	// deleted ones and finally the new cursor.
+	Sync(*SyncRequest, grpc.ServerStreamingServer[SyncEvent]) error
+	// PutBlob replaces the file contents of a binary secret together with its
+	// payload. The first chunk carries the secret ID and the sealed secret,
+	// whose revision must be the current one as for UpdateSecret.
+	PutBlob(grpc.ClientStreamingServer[BlobChunk, PutBlobResponse]) error
+	GetBlob(*SecretRequest, grpc.ServerStreamingServer[BlobChunk]) error
+	mustEmbedUnimplementedKeeperServer()
+}
+
+// UnimplementedKeeperServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedKeeperServer struct{}
+
+func (UnimplementedKeeperServer) Register(context.Context, *RegisterRequest) (*AuthResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Register not implemented")
+}
+func (UnimplementedKeeperServer) Prelogin(context.Context, *PreloginRequest) (*PreloginResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Prelogin not implemented")
+}
+func (UnimplementedKeeperServer) Login(context.Context, *LoginRequest) (*AuthResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Login not implemented")
+}
+func (UnimplementedKeeperServer) LoginTwoFactor(context.Context, *LoginTwoFactorRequest) (*AuthResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method LoginTwoFactor not implemented")
+}
+func (UnimplementedKeeperServer) EnrollTOTP(context.Context, *emptypb.Empty) (*EnrollTOTPResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method EnrollTOTP not implemented")
+}
+func (UnimplementedKeeperServer) VerifyTOTP(context.Context, *TOTPCode) (*RecoveryCodes, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method VerifyTOTP not implemented")
+}
+func (UnimplementedKeeperServer) DisableTOTP(context.Context, *TOTPCode) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DisableTOTP not implemented")
+}
+func (UnimplementedKeeperServer) UpdateKeys(context.Context, *UpdateKeysRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateKeys not implemented")
+}
+func (UnimplementedKeeperServer) Logout(context.Context, *emptypb.Empty) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Logout not implemented")
+}
+func (UnimplementedKeeperServer) ListSessions(context.Context, *emptypb.Empty) (*ListSessionsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListSessions not implemented")
+}
+func (UnimplementedKeeperServer) RevokeSession(context.Context, *RevokeSessionRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RevokeSession not implemented")
+}
+func (UnimplementedKeeperServer) ListAuditEvents(context.Context, *AuditRequest) (*ListAuditEventsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListAuditEvents not implemented")
+}
+func (UnimplementedKeeperServer) CreateSecret(context.Context, *Secret) (*CreateSecretResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateSecret not implemented")
+}
+func (UnimplementedKeeperServer) ListSecrets(context.Context, *ListSecretsRequest) (*ListSecretsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListSecrets not implemented")
+}
+func (UnimplementedKeeperServer) GetSecret(context.Context, *SecretRequest) (*Secret, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetSecret not implemented")
+}
+func (UnimplementedKeeperServer) UpdateSecret(context.Context, *Secret) (*UpdateSecretResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateSecret not implemented")
+}
+func (UnimplementedKeeperServer) DeleteSecret(context.Context, *DeleteSecretRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteSecret not implemented")
+}
+func (UnimplementedKeeperServer) PatchSecret(context.Context, *SecretPatch) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method PatchSecret not implemented")
+}
+func (UnimplementedKeeperServer) ListFolders(context.Context, *emptypb.Empty) (*ListFoldersResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListFolders not implemented")
+}
+func (UnimplementedKeeperServer) CreateFolder(context.Context, *Folder) (*CreateFolderResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateFolder not implemented")
+}
+func (UnimplementedKeeperServer) DeleteFolder(context.Context, *FolderRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteFolder not implemented")
+}
+func (UnimplementedKeeperServer) ListTags(context.Context, *emptypb.Empty) (*ListTagsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListTags not implemented")
+}
+func (UnimplementedKeeperServer) CreateTag(context.Context, *Tag) (*CreateTagResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateTag not implemented")
+}
+func (UnimplementedKeeperServer) GetPublicKey(context.Context, *PublicKeyRequest) (*PublicKey, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetPublicKey not implemented")
+}
+func (UnimplementedKeeperServer) ShareSecret(context.Context, *Share) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ShareSecret not implemented")
+}
+func (UnimplementedKeeperServer) ListShares(context.Context, *SecretRequest) (*ListSharesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListShares not implemented")
+}
+func (UnimplementedKeeperServer) RekeySecret(context.Context, *SecretRekey) (*UpdateSecretResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RekeySecret not implemented")
+}
+func (UnimplementedKeeperServer) ListSharedSecrets(context.Context, *emptypb.Empty) (*ListSharedSecretsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListSharedSecrets not implemented")
+}
+func (UnimplementedKeeperServer) UpdateSharedSecret(context.Context, *Secret) (*UpdateSecretResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateSharedSecret not implemented")
+}
+func (UnimplementedKeeperServer) CreateOneTimeSecret(context.Context, *OneTimeSecret) (*OneTimeSecret, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateOneTimeSecret not implemented")
+}
+func (UnimplementedKeeperServer) GetOneTimeSecret(context.Context, *OneTimeSecretRequest) (*OneTimeSecret, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetOneTimeSecret not implemented")
+}
+func (UnimplementedKeeperServer) ListEmergencyGrants(context.Context, *emptypb.Empty) (*ListEmergencyGrantsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListEmergencyGrants not implemented")
+}
+func (UnimplementedKeeperServer) CreateEmergencyGrant(context.Context, *EmergencyGrant) (*CreateEmergencyGrantResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateEmergencyGrant not implemented")
+}
+func (UnimplementedKeeperServer) DeleteEmergencyGrant(context.Context, *EmergencyRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteEmergencyGrant not implemented")
+}
+func (UnimplementedKeeperServer) RequestEmergencyAccess(context.Context, *EmergencyRequest) (*EmergencyGrant, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RequestEmergencyAccess not implemented")
+}
+func (UnimplementedKeeperServer) RejectEmergencyAccess(context.Context, *EmergencyRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RejectEmergencyAccess not implemented")
+}
+func (UnimplementedKeeperServer) GetEmergencyVault(context.Context, *EmergencyRequest) (*EmergencyVault, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetEmergencyVault not implemented")
+}
+func (UnimplementedKeeperServer) ListVersions(context.Context, *SecretRequest) (*ListVersionsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListVersions not implemented")
+}
+func (UnimplementedKeeperServer) GetVersion(context.Context, *VersionRequest) (*SecretVersion, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetVersion not implemented")
+}
+func (UnimplementedKeeperServer) RestoreVersion(context.Context, *VersionRequest) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RestoreVersion not implemented")
+}
+func (UnimplementedKeeperServer) Sync(*SyncRequest, grpc.ServerStreamingServer[SyncEvent]) error {
+	return status.Errorf(codes.Unimplemented, "method Sync not implemented")
+}
+func (UnimplementedKeeperServer) PutBlob(grpc.ClientStreamingServer[BlobChunk, PutBlobResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method PutBlob not implemented")
+}
+func (UnimplementedKeeperServer) GetBlob(*SecretRequest, grpc.ServerStreamingServer[BlobChunk]) error {
+	return status.Errorf(codes.Unimplemented, "method GetBlob not implemented")
+}
+func (UnimplementedKeeperServer) mustEmbedUnimplementedKeeperServer() {}
+func (UnimplementedKeeperServer) testEmbeddedByValue()                {}
+
+// UnsafeKeeperServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to KeeperServer will
+// result in compilation errors.
+type UnsafeKeeperServer interface {
+	mustEmbedUnimplementedKeeperServer()
+}
+
+func RegisterKeeperServer(s grpc.ServiceRegistrar, srv KeeperServer) {
+	// If the following call pancis, it indicates UnimplementedKeeperServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Keeper_ServiceDesc, srv)
+}
+
+func _Keeper_Register_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RegisterRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KeeperServer).Register(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Keeper_Register_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KeeperServer).Register(ctx, req.(*RegisterRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Keeper_Prelogin_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PreloginRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KeeperServer).Prelogin(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Keeper_Prelogin_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KeeperServer).Prelogin(ctx, req.(*PreloginRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Keeper_Login_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LoginRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KeeperServer).Login(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Keeper_Login_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KeeperServer).Login(ctx, req.(*LoginRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Keeper_LoginTwoFactor_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LoginTwoFactorRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KeeperServer).LoginTwoFactor(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Keeper_LoginTwoFactor_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KeeperServer).LoginTwoFactor(ctx, req.(*LoginTwoFactorRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Keeper_EnrollTOTP_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(emptypb.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KeeperServer).EnrollTOTP(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Keeper_EnrollTOTP_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KeeperServer).EnrollTOTP(ctx, req.(*emptypb.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Keeper_VerifyTOTP_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TOTPCode)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KeeperServer).VerifyTOTP(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Keeper_VerifyTOTP_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KeeperServer).VerifyTOTP(ctx, req.(*TOTPCode))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Keeper_DisableTOTP_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TOTPCode)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KeeperServer).DisableTOTP(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Keeper_DisableTOTP_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KeeperServer).DisableTOTP(ctx, req.(*TOTPCode))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Keeper_UpdateKeys_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateKeysRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KeeperServer).UpdateKeys(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Keeper_UpdateKeys_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KeeperServer).UpdateKeys(ctx, req.(*UpdateKeysRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Keeper_Logout_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(emptypb.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KeeperServer).Logout(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Keeper_Logout_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KeeperServer).Logout(ctx, req.(*emptypb.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Keeper_ListSessions_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(emptypb.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KeeperServer).ListSessions(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Keeper_ListSessions_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KeeperServer).ListSessions(ctx, req.(*emptypb.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Keeper_RevokeSession_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RevokeSessionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KeeperServer).RevokeSession(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Keeper_RevokeSession_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KeeperServer).RevokeSession(ctx, req.(*RevokeSessionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Keeper_ListAuditEvents_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AuditRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KeeperServer).ListAuditEvents(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Keeper_ListAuditEvents_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KeeperServer).ListAuditEvents(ctx, req.(*AuditRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Keeper_CreateSecret_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Secret)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KeeperServer).CreateSecret(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Keeper_CreateSecret_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KeeperServer).CreateSecret(ctx, req.(*Secret))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Keeper_ListSecrets_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListSecretsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KeeperServer).ListSecrets(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Keeper_ListSecrets_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KeeperServer).ListSecrets(ctx, req.(*ListSecretsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Keeper_GetSecret_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SecretRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KeeperServer).GetSecret(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Keeper_GetSecret_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KeeperServer).GetSecret(ctx, req.(*SecretRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Keeper_UpdateSecret_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Secret)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KeeperServer).UpdateSecret(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Keeper_UpdateSecret_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KeeperServer).UpdateSecret(ctx, req.(*Secret))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Keeper_DeleteSecret_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteSecretRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KeeperServer).DeleteSecret(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Keeper_DeleteSecret_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KeeperServer).DeleteSecret(ctx, req.(*DeleteSecretRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Keeper_PatchSecret_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SecretPatch)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KeeperServer).PatchSecret(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Keeper_PatchSecret_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KeeperServer).PatchSecret(ctx, req.(*SecretPatch))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Keeper_ListFolders_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(emptypb.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KeeperServer).ListFolders(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Keeper_ListFolders_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KeeperServer).ListFolders(ctx, req.(*emptypb.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Keeper_CreateFolder_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Folder)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KeeperServer).CreateFolder(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Keeper_CreateFolder_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KeeperServer).CreateFolder(ctx, req.(*Folder))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Keeper_DeleteFolder_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FolderRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KeeperServer).DeleteFolder(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Keeper_DeleteFolder_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KeeperServer).DeleteFolder(ctx, req.(*FolderRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Keeper_ListTags_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(emptypb.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KeeperServer).ListTags(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Keeper_ListTags_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KeeperServer).ListTags(ctx, req.(*emptypb.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Keeper_CreateTag_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Tag)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KeeperServer).CreateTag(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Keeper_CreateTag_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KeeperServer).CreateTag(ctx, req.(*Tag))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Keeper_GetPublicKey_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PublicKeyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KeeperServer).GetPublicKey(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Keeper_GetPublicKey_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KeeperServer).GetPublicKey(ctx, req.(*PublicKeyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Keeper_ShareSecret_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Share)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KeeperServer).ShareSecret(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Keeper_ShareSecret_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KeeperServer).ShareSecret(ctx, req.(*Share))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Keeper_ListShares_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SecretRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KeeperServer).ListShares(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Keeper_ListShares_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KeeperServer).ListShares(ctx, req.(*SecretRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Keeper_RekeySecret_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SecretRekey)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KeeperServer).RekeySecret(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Keeper_RekeySecret_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KeeperServer).RekeySecret(ctx, req.(*SecretRekey))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Keeper_ListSharedSecrets_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(emptypb.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KeeperServer).ListSharedSecrets(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Keeper_ListSharedSecrets_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KeeperServer).ListSharedSecrets(ctx, req.(*emptypb.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Keeper_UpdateSharedSecret_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Secret)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KeeperServer).UpdateSharedSecret(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Keeper_UpdateSharedSecret_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KeeperServer).UpdateSharedSecret(ctx, req.(*Secret))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Keeper_CreateOneTimeSecret_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(OneTimeSecret)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KeeperServer).CreateOneTimeSecret(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Keeper_CreateOneTimeSecret_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KeeperServer).CreateOneTimeSecret(ctx, req.(*OneTimeSecret))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Keeper_GetOneTimeSecret_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(OneTimeSecretRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KeeperServer).GetOneTimeSecret(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Keeper_GetOneTimeSecret_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KeeperServer).GetOneTimeSecret(ctx, req.(*OneTimeSecretRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Keeper_ListEmergencyGrants_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(emptypb.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KeeperServer).ListEmergencyGrants(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Keeper_ListEmergencyGrants_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KeeperServer).ListEmergencyGrants(ctx, req.(*emptypb.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Keeper_CreateEmergencyGrant_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(EmergencyGrant)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KeeperServer).CreateEmergencyGrant(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Keeper_CreateEmergencyGrant_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KeeperServer).CreateEmergencyGrant(ctx, req.(*EmergencyGrant))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Keeper_DeleteEmergencyGrant_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(EmergencyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KeeperServer).DeleteEmergencyGrant(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Keeper_DeleteEmergencyGrant_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KeeperServer).DeleteEmergencyGrant(ctx, req.(*EmergencyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Keeper_RequestEmergencyAccess_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(EmergencyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KeeperServer).RequestEmergencyAccess(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Keeper_RequestEmergencyAccess_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KeeperServer).RequestEmergencyAccess(ctx, req.(*EmergencyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Keeper_RejectEmergencyAccess_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(EmergencyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KeeperServer).RejectEmergencyAccess(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Keeper_RejectEmergencyAccess_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KeeperServer).RejectEmergencyAccess(ctx, req.(*EmergencyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Keeper_GetEmergencyVault_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(EmergencyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KeeperServer).GetEmergencyVault(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Keeper_GetEmergencyVault_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KeeperServer).GetEmergencyVault(ctx, req.(*EmergencyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Keeper_ListVersions_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SecretRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KeeperServer).ListVersions(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Keeper_ListVersions_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KeeperServer).ListVersions(ctx, req.(*SecretRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Keeper_GetVersion_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(VersionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KeeperServer).GetVersion(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Keeper_GetVersion_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KeeperServer).GetVersion(ctx, req.(*VersionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Keeper_RestoreVersion_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(VersionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(KeeperServer).RestoreVersion(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Keeper_RestoreVersion_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(KeeperServer).RestoreVersion(ctx, req.(*VersionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Keeper_Sync_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SyncRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(KeeperServer).Sync(m, &grpc.GenericServerStream[SyncRequest, SyncEvent]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Keeper_SyncServer = grpc.ServerStreamingServer[SyncEvent]
+
+func _Keeper_PutBlob_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(KeeperServer).PutBlob(&grpc.GenericServerStream[BlobChunk, PutBlobResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Keeper_PutBlobServer = grpc.ClientStreamingServer[BlobChunk, PutBlobResponse]
+
+func _Keeper_GetBlob_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(SecretRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(KeeperServer).GetBlob(m, &grpc.GenericServerStream[SecretRequest, BlobChunk]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Keeper_GetBlobServer = grpc.ServerStreamingServer[BlobChunk]
+
+// Keeper_ServiceDesc is the grpc.ServiceDesc for Keeper service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Keeper_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "keeper.Keeper",
+	HandlerType: (*KeeperServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Register",
+			Handler:    _Keeper_Register_Handler,
+		},
+		{
+			MethodName: "Prelogin",
+			Handler:    _Keeper_Prelogin_Handler,
+		},
+		{
+			MethodName: "Login",
+			Handler:    _Keeper_Login_Handler,
+		},
+		{
+			MethodName: "LoginTwoFactor",
+			Handler:    _Keeper_LoginTwoFactor_Handler,
+		},
+		{
+			MethodName: "EnrollTOTP",
+			Handler:    _Keeper_EnrollTOTP_Handler,
+		},
+		{
+			MethodName: "VerifyTOTP",
+			Handler:    _Keeper_VerifyTOTP_Handler,
+		},
+		{
+			MethodName: "DisableTOTP",
+			Handler:    _Keeper_DisableTOTP_Handler,
+		},
+		{
+			MethodName: "UpdateKeys",
+			Handler:    _Keeper_UpdateKeys_Handler,
+		},
+		{
+			MethodName: "Logout",
+			Handler:    _Keeper_Logout_Handler,
+		},
+		{
+			MethodName: "ListSessions",
+			Handler:    _Keeper_ListSessions_Handler,
+		},
+		{
+			MethodName: "RevokeSession",
+			Handler:    _Keeper_RevokeSession_Handler,
+		},
+		{
+			MethodName: "ListAuditEvents",
+			Handler:    _Keeper_ListAuditEvents_Handler,
+		},
+		{
+			MethodName: "CreateSecret",
+			Handler:    _Keeper_CreateSecret_Handler,
+		},
+		{
+			MethodName: "ListSecrets",
+			Handler:    _Keeper_ListSecrets_Handler,
+		},
+		{
+			MethodName: "GetSecret",
+			Handler:    _Keeper_GetSecret_Handler,
+		},
+		{
+			MethodName: "UpdateSecret",
+			Handler:    _Keeper_UpdateSecret_Handler,
+		},
+		{
+			MethodName: "DeleteSecret",
+			Handler:    _Keeper_DeleteSecret_Handler,
+		},
+		{
+			MethodName: "PatchSecret",
+			Handler:    _Keeper_PatchSecret_Handler,
+		},
+		{
+			MethodName: "ListFolders",
+			Handler:    _Keeper_ListFolders_Handler,
+		},
+		{
+			MethodName: "CreateFolder",
+			Handler:    _Keeper_CreateFolder_Handler,
+		},
+		{
+			MethodName: "DeleteFolder",
+			Handler:    _Keeper_DeleteFolder_Handler,
+		},
+		{
+			MethodName: "ListTags",
+			Handler:    _Keeper_ListTags_Handler,
+		},
+		{
+			MethodName: "CreateTag",
+			Handler:    _Keeper_CreateTag_Handler,
+		},
+		{
+			MethodName: "GetPublicKey",
+			Handler:    _Keeper_GetPublicKey_Handler,
+		},
+		{
+			MethodName: "ShareSecret",
+			Handler:    _Keeper_ShareSecret_Handler,
+		},
+		{
+			MethodName: "ListShares",
+			Handler:    _Keeper_ListShares_Handler,
+		},
+		{
+			MethodName: "RekeySecret",
+			Handler:    _Keeper_RekeySecret_Handler,
+		},
+		{
+			MethodName: "ListSharedSecrets",
+			Handler:    _Keeper_ListSharedSecrets_Handler,
+		},
+		{
+			MethodName: "UpdateSharedSecret",
+			Handler:    _Keeper_UpdateSharedSecret_Handler,
+		},
+		{
+			MethodName: "CreateOneTimeSecret",
+			Handler:    _Keeper_CreateOneTimeSecret_Handler,
+		},
+		{
+			MethodName: "GetOneTimeSecret",
+			Handler:    _Keeper_GetOneTimeSecret_Handler,
+		},
+		{
+			MethodName: "ListEmergencyGrants",
+			Handler:    _Keeper_ListEmergencyGrants_Handler,
+		},
+		{
+			MethodName: "CreateEmergencyGrant",
+			Handler:    _Keeper_CreateEmergencyGrant_Handler,
+		},
+		{
+			MethodName: "DeleteEmergencyGrant",
+			Handler:    _Keeper_DeleteEmergencyGrant_Handler,
+		},
+		{
+			MethodName: "RequestEmergencyAccess",
+			Handler:    _Keeper_RequestEmergencyAccess_Handler,
+		},
+		{
+			MethodName: "RejectEmergencyAccess",
+			Handler:    _Keeper_RejectEmergencyAccess_Handler,
+		},
+		{
+			MethodName: "GetEmergencyVault",
+			Handler:    _Keeper_GetEmergencyVault_Handler,
+		},
+		{
+			MethodName: "ListVersions",
+			Handler:    _Keeper_ListVersions_Handler,
+		},
+		{
+			MethodName: "GetVersion",
+			Handler:    _Keeper_GetVersion_Handler,
+		},
+		{
+			MethodName: "RestoreVersion",
+			Handler:    _Keeper_RestoreVersion_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Sync",
+			Handler:       _Keeper_Sync_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "PutBlob",
+			Handler:       _Keeper_PutBlob_Handler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "GetBlob",
+			Handler:       _Keeper_GetBlob_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "keeper.proto",
+}
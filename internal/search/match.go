@@ -0,0 +1,147 @@
+package search
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// Scores of the ways a term matches a field, from 1 for the whole field
+// down to a subsequence of its letters.
+const (
+	scoreExact       = 1.0
+	scoreWord        = 0.9
+	scorePrefix      = 0.75
+	scoreSubstring   = 0.6
+	scoreFuzzy       = 0.4
+	scoreSubsequence = 0.2
+)
+
+// text is an indexed field value, lower case and split into words.
+type text struct {
+	value string
+	words []string
+}
+
+func newText(s string) text {
+	value := strings.ToLower(s)
+	words := strings.FieldsFunc(value, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	return text{value: value, words: words}
+}
+
+// match scores how well the lower case term matches the text, 0 if it
+// doesn't match at all.
+func (t text) match(term string) float64 {
+	switch {
+	case t.value == term:
+		return scoreExact
+	case slices.Contains(t.words, term):
+		return scoreWord
+	case hasWordPrefix(t.words, term) || strings.HasPrefix(t.value, term):
+		return scorePrefix
+	case strings.Contains(t.value, term):
+		return scoreSubstring
+	}
+
+	if d := t.distance(term); d > 0 {
+		// one typo scores scoreFuzzy, every other one a bit less
+		return scoreFuzzy - 0.1*float64(d-1)
+	}
+
+	if len([]rune(term)) >= 3 && isSubsequence(term, t.value) {
+		return scoreSubsequence
+	}
+
+	return 0
+}
+
+// distance returns the smallest number of typos between term and a word of
+// the text, or a prefix of a word as long as term, if it is within the
+// number allowed for the length of term. Otherwise it returns 0.
+func (t text) distance(term string) int {
+	allowed := maxTypos(term)
+	if allowed == 0 {
+		return 0
+	}
+
+	best := allowed + 1
+	for _, word := range t.words {
+		candidates := []string{word}
+		if r := []rune(word); len(r) > len([]rune(term)) {
+			candidates = append(candidates, string(r[:len([]rune(term))]))
+		}
+		for _, c := range candidates {
+			if d := editDistance(term, c); d < best {
+				best = d
+			}
+		}
+	}
+
+	if best > allowed {
+		return 0
+	}
+	return best
+}
+
+// maxTypos is the number of typos tolerated in a term, none in short ones.
+func maxTypos(term string) int {
+	switch n := len([]rune(term)); {
+	case n >= 8:
+		return 2
+	case n >= 4:
+		return 1
+	}
+	return 0
+}
+
+func hasWordPrefix(words []string, term string) bool {
+	for _, w := range words {
+		if strings.HasPrefix(w, term) {
+			return true
+		}
+	}
+	return false
+}
+
+// isSubsequence reports whether the letters of term appear in s in order.
+func isSubsequence(term, s string) bool {
+	r := []rune(term)
+	i := 0
+	for _, c := range s {
+		if i < len(r) && c == r[i] {
+			i++
+		}
+	}
+	return i == len(r)
+}
+
+// editDistance returns the edit distance between a and b, counting swapped
+// neighbouring letters as a single typo (optimal string alignment).
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	d := make([][]int, len(ra)+1)
+	for i := range d {
+		d[i] = make([]int, len(rb)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			d[i][j] = min(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				d[i][j] = min(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+
+	return d[len(ra)][len(rb)]
+}
@@ -0,0 +1,91 @@
+package search
+
+import "testing"
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"abc", "", 3},
+		{"", "abc", 3},
+		{"mail", "mail", 0},
+		{"kitten", "sitting", 3},
+		{"ab", "ba", 1},
+		{"gmail", "gmial", 1},
+		{"facebok", "facebook", 1},
+		// a swap isn't edited again, unlike in the full Damerau distance
+		{"ca", "abc", 3},
+		{"пароль", "пороль", 1},
+	}
+
+	for _, tt := range tests {
+		if got := editDistance(tt.a, tt.b); got != tt.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+		if got := editDistance(tt.b, tt.a); got != tt.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", tt.b, tt.a, got, tt.want)
+		}
+	}
+}
+
+func TestTextMatch(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		term  string
+		want  float64
+	}{
+		{"exact", "Mail", "mail", scoreExact},
+		{"word", "My Bank account", "bank", scoreWord},
+		{"word prefix", "My Bank account", "acc", scorePrefix},
+		{"value prefix", "github.com", "github.c", scorePrefix},
+		{"substring", "facebook", "ebo", scoreSubstring},
+		{"swapped letters", "gmail", "gmial", scoreFuzzy},
+		{"missing letter", "facebook", "facebok", scoreFuzzy},
+		{"typo in a prefix", "bitwarden vault", "bitwsr", scoreFuzzy},
+		{"two typos in a long term", "bitwarden", "bitwrdan", scoreFuzzy - 0.1},
+		{"subsequence", "github", "gthb", scoreSubsequence},
+		{"no match", "github", "gitlab", 0},
+
+		// typo tolerance depends on the length of the term
+		{"typo in a short term", "abd", "abc", 0},
+		{"two typos in a mid term", "gmail", "gmaxx", 0},
+		{"three typos in a long term", "bitwarden", "bxtwxrdxn", 0},
+		{"short subsequence", "github", "gb", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := newText(tt.value).match(tt.term); !almostEqual(got, tt.want) {
+				t.Errorf("match(%q, %q) = %v, want %v", tt.value, tt.term, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMaxTypos(t *testing.T) {
+	tests := []struct {
+		term string
+		want int
+	}{
+		{"", 0},
+		{"abc", 0},
+		{"abcd", 1},
+		{"abcdefg", 1},
+		{"abcdefgh", 2},
+		{"пароль", 1},
+	}
+
+	for _, tt := range tests {
+		if got := maxTypos(tt.term); got != tt.want {
+			t.Errorf("maxTypos(%q) = %d, want %d", tt.term, got, tt.want)
+		}
+	}
+}
+
+func almostEqual(a, b float64) bool {
+	const eps = 1e-9
+	return a-b < eps && b-a < eps
+}
@@ -0,0 +1,251 @@
+// Package search ranks decrypted secrets against a query. The index lives
+// in memory on the client only, so the server never sees what is searched
+// for nor what is found.
+//
+// A query is a list of terms separated by spaces. Terms can be quoted to
+// include spaces and qualified by a field, like login:alice or type:card.
+// Unqualified terms match any field. All terms must match for a secret to
+// be found; terms match exactly, by prefix, as a substring or fuzzily with
+// a few typos, and better matches in more important fields rank higher.
+package search
+
+import (
+	"errors"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/nbvehbq/go-password-keeper/internal/model"
+)
+
+// Fields of a document, usable as qualifiers of terms.
+const (
+	FieldName   = "name"
+	FieldLogin  = "login"
+	FieldURL    = "url"
+	FieldMeta   = "meta"
+	FieldFolder = "folder"
+	FieldTag    = "tag"
+	FieldType   = "type"
+)
+
+// weights of the fields, a match of the name ranks above a match of meta
+var weights = map[string]float64{
+	FieldName:   4,
+	FieldLogin:  3,
+	FieldURL:    3,
+	FieldTag:    2,
+	FieldFolder: 2,
+	FieldMeta:   1,
+}
+
+// typeNames are the values of the type qualifier.
+var typeNames = map[string]model.ResourceType{
+	"login":    model.LoginPasswordType,
+	"password": model.LoginPasswordType,
+	"text":     model.TextType,
+	"note":     model.TextType,
+	"file":     model.BinaryType,
+	"binary":   model.BinaryType,
+	"card":     model.BankCardType,
+	"otp":      model.OTPType,
+	"totp":     model.OTPType,
+}
+
+var (
+	ErrEmptyQuery  = errors.New("empty query")
+	ErrInvalidType = errors.New("unknown type, expected login, text, file, card or otp")
+)
+
+// Document is a decrypted secret to search.
+type Document struct {
+	ID     int64
+	Type   model.ResourceType
+	Name   string
+	Login  string
+	URL    string
+	Meta   string
+	Folder string
+	Tags   []string
+}
+
+// values returns the values of a field of the document.
+func (d *Document) values(name string) []string {
+	switch name {
+	case FieldName:
+		return []string{d.Name}
+	case FieldLogin:
+		return []string{d.Login}
+	case FieldURL:
+		return []string{d.URL}
+	case FieldMeta:
+		return []string{d.Meta}
+	case FieldFolder:
+		return []string{d.Folder}
+	case FieldTag:
+		return d.Tags
+	}
+	return nil
+}
+
+// Term is a part of a query. Field is empty for terms matching any field.
+type Term struct {
+	Field string
+	Value string
+}
+
+// Query is a parsed query. Type is 0 unless it is restricted by a type
+// qualifier.
+type Query struct {
+	Terms []Term
+	Type  model.ResourceType
+}
+
+// Parse parses a query. Qualifiers of unknown fields are searched for as
+// they are, so "http://host" is a plain term.
+func Parse(q string) (Query, error) {
+	var query Query
+
+	for _, word := range splitQuery(q) {
+		term := Term{Value: word}
+		if field, value, ok := strings.Cut(word, ":"); ok && value != "" {
+			field = strings.ToLower(field)
+			if field == FieldType {
+				rtype, ok := typeNames[strings.ToLower(value)]
+				if !ok {
+					return query, ErrInvalidType
+				}
+				query.Type = rtype
+				continue
+			}
+			if _, ok := weights[field]; ok {
+				term = Term{Field: field, Value: value}
+			}
+		}
+
+		term.Value = strings.ToLower(term.Value)
+		query.Terms = append(query.Terms, term)
+	}
+
+	if len(query.Terms) == 0 && query.Type == 0 {
+		return query, ErrEmptyQuery
+	}
+
+	return query, nil
+}
+
+// splitQuery splits q at spaces outside of double quotes.
+func splitQuery(q string) []string {
+	var words []string
+	var word strings.Builder
+	quoted := false
+
+	for _, r := range q {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case unicode.IsSpace(r) && !quoted:
+			if word.Len() > 0 {
+				words = append(words, word.String())
+				word.Reset()
+			}
+		default:
+			word.WriteRune(r)
+		}
+	}
+	if word.Len() > 0 {
+		words = append(words, word.String())
+	}
+
+	return words
+}
+
+// Result is a found document. Fields lists the fields that matched.
+type Result struct {
+	Document
+	Score  float64
+	Fields []string
+}
+
+// Index is an in-memory index of decrypted secrets.
+type Index struct {
+	docs    []Document
+	entries []map[string][]text
+}
+
+// NewIndex indexes the fields of docs.
+func NewIndex(docs []Document) *Index {
+	ix := &Index{docs: docs, entries: make([]map[string][]text, len(docs))}
+	for i := range docs {
+		fields := make(map[string][]text, len(weights))
+		for field := range weights {
+			for _, v := range docs[i].values(field) {
+				if v != "" {
+					fields[field] = append(fields[field], newText(v))
+				}
+			}
+		}
+		ix.entries[i] = fields
+	}
+	return ix
+}
+
+// Search returns the documents matching query q, best matches first.
+func (ix *Index) Search(q string) ([]Result, error) {
+	query, err := Parse(q)
+	if err != nil {
+		return nil, err
+	}
+
+	return ix.Match(query), nil
+}
+
+// Match returns the documents matching query, best matches first.
+func (ix *Index) Match(query Query) []Result {
+	var results []Result
+
+next:
+	for i, doc := range ix.docs {
+		if query.Type != 0 && doc.Type != query.Type {
+			continue
+		}
+
+		result := Result{Document: doc}
+		for _, term := range query.Terms {
+			fields := []string{term.Field}
+			if term.Field == "" {
+				fields = []string{FieldName, FieldLogin, FieldURL, FieldTag, FieldFolder, FieldMeta}
+			}
+
+			var best float64
+			var bestField string
+			for _, field := range fields {
+				for _, value := range ix.entries[i][field] {
+					if score := weights[field] * value.match(term.Value); score > best {
+						best, bestField = score, field
+					}
+				}
+			}
+			if best == 0 {
+				continue next
+			}
+
+			result.Score += best
+			if !slices.Contains(result.Fields, bestField) {
+				result.Fields = append(result.Fields, bestField)
+			}
+		}
+
+		results = append(results, result)
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return strings.ToLower(results[i].Name) < strings.ToLower(results[j].Name)
+	})
+
+	return results
+}
@@ -0,0 +1,164 @@
+package search
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/nbvehbq/go-password-keeper/internal/model"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		q       string
+		want    Query
+		wantErr error
+	}{
+		{
+			name: "plain terms",
+			q:    "  GitHub  work ",
+			want: Query{Terms: []Term{{Value: "github"}, {Value: "work"}}},
+		},
+		{
+			name: "qualifiers",
+			q:    "login:Alice URL:github tag:work",
+			want: Query{Terms: []Term{{Field: FieldLogin, Value: "alice"}, {Field: FieldURL, Value: "github"},
+				{Field: FieldTag, Value: "work"}}},
+		},
+		{
+			name: "quoted terms",
+			q:    `"my bank" folder:"Family Stuff"`,
+			want: Query{Terms: []Term{{Value: "my bank"}, {Field: FieldFolder, Value: "family stuff"}}},
+		},
+		{
+			name: "unterminated quote",
+			q:    `"my bank`,
+			want: Query{Terms: []Term{{Value: "my bank"}}},
+		},
+		{
+			name: "url is a plain term",
+			q:    "http://host",
+			want: Query{Terms: []Term{{Value: "http://host"}}},
+		},
+		{
+			name: "unknown field is a plain term",
+			q:    "color:red",
+			want: Query{Terms: []Term{{Value: "color:red"}}},
+		},
+		{
+			name: "empty qualifier is a plain term",
+			q:    "login:",
+			want: Query{Terms: []Term{{Value: "login:"}}},
+		},
+		{
+			name: "type",
+			q:    "type:Card visa",
+			want: Query{Terms: []Term{{Value: "visa"}}, Type: model.BankCardType},
+		},
+		{
+			name: "type alone",
+			q:    "type:note",
+			want: Query{Type: model.TextType},
+		},
+		{
+			name:    "unknown type",
+			q:       "type:car visa",
+			wantErr: ErrInvalidType,
+		},
+		{
+			name:    "empty",
+			q:       " \t",
+			wantErr: ErrEmptyQuery,
+		},
+		{
+			name:    "empty quotes",
+			q:       `""`,
+			wantErr: ErrEmptyQuery,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.q)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Parse(%q) error = %v, want %v", tt.q, err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse(%q) = %+v, want %+v", tt.q, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIndexMatch(t *testing.T) {
+	ix := NewIndex([]Document{
+		{ID: 1, Type: model.LoginPasswordType, Name: "GitHub", Login: "alice", URL: "https://github.com"},
+		{ID: 2, Type: model.LoginPasswordType, Name: "Work mail", Login: "github", Tags: []string{"work"}},
+		{ID: 3, Type: model.TextType, Name: "Recovery codes", Meta: "backup of github tokens"},
+		{ID: 4, Type: model.BankCardType, Name: "Visa", Meta: "github sponsors", Folder: "Work"},
+		{ID: 5, Type: model.LoginPasswordType, Name: "GitLab", Login: "alice"},
+		{ID: 6, Type: model.LoginPasswordType, Name: "Bitbucket", Login: "alice", Tags: []string{"work"}},
+	})
+
+	tests := []struct {
+		name string
+		q    string
+		want []int64
+	}{
+		// the name outranks login and url, which outrank meta; equal
+		// scores are ordered by name
+		{name: "field weights", q: "github", want: []int64{1, 2, 3, 4}},
+		{name: "exact before prefix", q: "gitlab", want: []int64{5}},
+		{name: "prefix", q: "git", want: []int64{1, 5, 2, 3, 4}},
+		{name: "typo", q: "githib", want: []int64{1, 2, 3, 4}},
+		{name: "all terms must match", q: "alice work", want: []int64{6}},
+		{name: "qualifier", q: "login:github", want: []int64{2}},
+		{name: "tag qualifier", q: "tag:work", want: []int64{6, 2}},
+		{name: "type", q: "type:card github", want: []int64{4}},
+		{name: "type alone", q: "type:login", want: []int64{6, 1, 5, 2}},
+		{name: "none", q: "dropbox", want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := Parse(tt.q)
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tt.q, err)
+			}
+
+			var got []int64
+			for _, r := range ix.Match(query) {
+				got = append(got, r.ID)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Match(%q) = %v, want %v", tt.q, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIndexMatchFields(t *testing.T) {
+	ix := NewIndex([]Document{
+		{ID: 1, Name: "GitHub", Login: "alice", Tags: []string{"work", "code"}},
+	})
+
+	results, err := ix.Search("alice git code")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("got %d results, want 1", len(results))
+	}
+
+	want := []string{FieldLogin, FieldName, FieldTag}
+	if !reflect.DeepEqual(results[0].Fields, want) {
+		t.Errorf("fields = %v, want %v", results[0].Fields, want)
+	}
+	if score := 3*scoreExact + 4*scorePrefix + 2*scoreExact; !almostEqual(results[0].Score, score) {
+		t.Errorf("score = %v, want %v", results[0].Score, score)
+	}
+}
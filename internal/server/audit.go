@@ -0,0 +1,103 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/nbvehbq/go-password-keeper/internal/logger"
+	"github.com/nbvehbq/go-password-keeper/internal/model"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+// audit appends an event to the trail of userID for actions the storage
+// doesn't record itself. The action has already happened, so a failure is
+// logged instead of failing the request.
+func audit(ctx context.Context, repo Repository, userID, actorID int64, action, detail string) {
+	err := repo.AddAuditEvent(ctx, &model.AuditEvent{
+		UserID:  userID,
+		ActorID: actorID,
+		Action:  action,
+		Detail:  detail,
+	})
+	if err != nil {
+		logger.Log.Error("add audit event", zap.String("action", action), zap.Int64("user", userID), zap.Error(err))
+	}
+}
+
+// origin describes the client of a request for the audit trail.
+func origin(req *http.Request) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		host = req.RemoteAddr
+	}
+	return fmt.Sprintf("from %s (%s)", host, req.UserAgent())
+}
+
+// auditLimit checks the page size of an audit trail request, zero picks
+// the default.
+func auditLimit(limit int) (int, error) {
+	switch {
+	case limit == 0:
+		return defaultAuditLimit, nil
+	case limit < 0 || limit > maxAuditLimit:
+		return 0, fmt.Errorf("limit must be 1 to %d", maxAuditLimit)
+	default:
+		return limit, nil
+	}
+}
+
+// listAuditHandler returns the audit trail of the caller, oldest first. It
+// takes the page size in "limit" and pages backwards with "before", the
+// ID of the oldest event already seen.
+func (s *Server) listAuditHandler(res http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	query := req.URL.Query()
+
+	var before int64
+	if v := query.Get("before"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || id < 0 {
+			JSONError(res, "invalid before", http.StatusBadRequest)
+			return
+		}
+		before = id
+	}
+
+	var limit int
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			JSONError(res, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	limit, err := auditLimit(limit)
+	if err != nil {
+		JSONError(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	list, err := s.storage.ListAuditEvents(ctx, UID(ctx), before, limit)
+	if err != nil {
+		JSONError(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(res).Encode(list); err != nil {
+		JSONError(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+}
@@ -3,13 +3,23 @@ package server
 import (
 	"context"
 	"net/http"
+	"strconv"
 
+	"github.com/go-chi/chi/v5"
+	"github.com/nbvehbq/go-password-keeper/internal/model"
+	"github.com/nbvehbq/go-password-keeper/internal/storage"
 	"github.com/pkg/errors"
 )
 
 type contextKeyType string
 
-const uidKey contextKeyType = "uid"
+const (
+	uidKey contextKeyType = "uid"
+	sidKey contextKeyType = "sid"
+
+	orgKey  contextKeyType = "org"
+	roleKey contextKeyType = "role"
+)
 
 func Authenticator(s SessionStorage) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
@@ -30,11 +40,68 @@ func Authenticator(s SessionStorage) func(http.Handler) http.Handler {
 				return
 			}
 
+			// sliding expiration: every authenticated request extends the session
+			if err := s.Refresh(r.Context(), sid); err != nil {
+				http.Error(w, "session not found", http.StatusUnauthorized)
+				return
+			}
+			setCookie(w, sid)
+
 			ctx := r.Context()
 			ctx = context.WithValue(ctx, uidKey, uid)
+			ctx = context.WithValue(ctx, sidKey, sid)
 
 			next.ServeHTTP(w, r.WithContext(ctx))
 		}
 		return http.HandlerFunc(fn)
 	}
 }
+
+// Authorizer lets members of the organization in the {org} URL parameter
+// through if their role is at least min. It must follow Authenticator.
+// Users outside the organization get 404, so its existence isn't revealed.
+func Authorizer(repo Repository, min model.Role) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+
+			orgID, err := strconv.ParseInt(chi.URLParam(r, "org"), 10, 64)
+			if err != nil {
+				JSONError(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			member, err := repo.GetMember(ctx, orgID, UID(ctx))
+			if err != nil {
+				switch {
+				case errors.Is(err, storage.ErrMemberNotFound):
+					JSONError(w, storage.ErrOrgNotFound.Error(), http.StatusNotFound)
+				default:
+					JSONError(w, err.Error(), http.StatusInternalServerError)
+				}
+				return
+			}
+			if member.Role < min {
+				JSONError(w, "requires the "+min.String()+" role", http.StatusForbidden)
+				return
+			}
+
+			ctx = context.WithValue(ctx, orgKey, orgID)
+			ctx = context.WithValue(ctx, roleKey, member.Role)
+
+			next.ServeHTTP(w, r.WithContext(ctx))
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// OrgID returns the organization authorized by Authorizer.
+func OrgID(ctx context.Context) int64 {
+	return ctx.Value(orgKey).(int64)
+}
+
+// Role returns the role of the caller in the organization authorized by
+// Authorizer.
+func Role(ctx context.Context) model.Role {
+	return ctx.Value(roleKey).(model.Role)
+}
@@ -0,0 +1,134 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/nbvehbq/go-password-keeper/internal/logger"
+	"github.com/nbvehbq/go-password-keeper/internal/model"
+	"github.com/nbvehbq/go-password-keeper/internal/storage"
+	"go.uber.org/zap"
+)
+
+// putBlobHandler replaces the file contents of a binary secret together
+// with its sealed payload. The body is multipart: a "secret" part with the
+// secret as for updateSecretHandler, then a "blob" part streamed to storage.
+// Like updateSecretHandler it requires If-Match, and the body is limited to
+// Config.MaxBlobSize.
+func (s *Server) putBlobHandler(res http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	var dto model.Secret
+
+	idParam := chi.URLParam(req, "id")
+
+	id, err := strconv.Atoi(idParam)
+	if err != nil {
+		JSONError(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	revision, err := ifMatch(req)
+	if err != nil {
+		JSONError(res, err.Error(), http.StatusPreconditionRequired)
+		return
+	}
+
+	if req.ContentLength > s.maxBlobSize {
+		JSONError(res, "blob too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	req.Body = http.MaxBytesReader(res, req.Body, s.maxBlobSize)
+	parts, err := req.MultipartReader()
+	if err != nil {
+		JSONError(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	part, err := parts.NextPart()
+	if err != nil || part.FormName() != "secret" {
+		JSONError(res, "secret part expected", http.StatusBadRequest)
+		return
+	}
+	if err := json.NewDecoder(part).Decode(&dto); err != nil {
+		JSONError(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+	dto.Revision = revision
+
+	blob, err := parts.NextPart()
+	if err != nil || blob.FormName() != "blob" {
+		JSONError(res, "blob part expected", http.StatusBadRequest)
+		return
+	}
+
+	ret, size, err := s.storage.PutBlob(ctx, UID(ctx), int64(id), &dto, blob)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		switch {
+		case errors.As(err, &tooLarge):
+			JSONError(res, "blob too large", http.StatusRequestEntityTooLarge)
+		case errors.Is(err, storage.ErrSecretNotFound):
+			JSONError(res, err.Error(), http.StatusNotFound)
+		case errors.Is(err, storage.ErrNotBinary):
+			JSONError(res, err.Error(), http.StatusUnprocessableEntity)
+		case errors.Is(err, storage.ErrRevisionMismatch):
+			s.revisionConflict(res, req, int64(id))
+		default:
+			JSONError(res, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	res.Header().Set("ETag", etag(ret))
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(http.StatusOK)
+
+	value := struct {
+		ID       int64 `json:"id"`
+		Revision int64 `json:"revision"`
+		Size     int64 `json:"size"`
+	}{ID: int64(id), Revision: ret, Size: size}
+
+	if err := json.NewEncoder(res).Encode(value); err != nil {
+		JSONError(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+}
+
+// getBlobHandler streams the file contents of a binary secret.
+func (s *Server) getBlobHandler(res http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	idParam := chi.URLParam(req, "id")
+
+	id, err := strconv.Atoi(idParam)
+	if err != nil {
+		JSONError(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	blob, err := s.storage.GetBlob(ctx, UID(ctx), int64(id))
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrSecretNotFound), errors.Is(err, storage.ErrBlobNotFound):
+			JSONError(res, err.Error(), http.StatusNotFound)
+		default:
+			JSONError(res, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	defer blob.Close()
+
+	res.Header().Set("Content-Type", "application/octet-stream")
+	res.WriteHeader(http.StatusOK)
+
+	// the status is already sent, a failure can only cut the stream short;
+	// the client detects it when decrypting
+	if _, err := io.Copy(res, blob); err != nil {
+		logger.Log.Error("failed to stream blob", zap.Error(err))
+	}
+}
@@ -0,0 +1,246 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/nbvehbq/go-password-keeper/internal/model"
+	"github.com/nbvehbq/go-password-keeper/internal/storage"
+)
+
+const maxEmergencyWaitDays = 90
+
+var errInvalidGrant = fmt.Errorf("grantee, keys and a wait of 1 to %d days required", maxEmergencyWaitDays)
+
+func emergencyError(res http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, storage.ErrEmergencyNotFound):
+		JSONError(res, err.Error(), http.StatusNotFound)
+	case errors.Is(err, storage.ErrEmergencyExists), errors.Is(err, storage.ErrEmergencyNotPending):
+		JSONError(res, err.Error(), http.StatusConflict)
+	case errors.Is(err, storage.ErrEmergencyWaiting):
+		JSONError(res, err.Error(), http.StatusForbidden)
+	case errors.Is(err, storage.ErrUserNotFound):
+		JSONError(res, err.Error(), http.StatusUnprocessableEntity)
+	default:
+		JSONError(res, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// validGrant reports whether grant can be given by grantorID.
+func validGrant(grantorID int64, grant *model.EmergencyGrant) bool {
+	return grant.GranteeID != 0 && grant.GranteeID != grantorID &&
+		grant.WaitDays >= 1 && grant.WaitDays <= maxEmergencyWaitDays &&
+		len(grant.Key) > 0 && len(grant.VaultKey) > 0
+}
+
+// requestEmergency starts the wait period of a grant received by granteeID
+// and tells the grantor through their audit trail.
+func requestEmergency(ctx context.Context, repo Repository, granteeID, id int64) (*model.EmergencyGrant, error) {
+	grant, err := repo.RequestEmergencyAccess(ctx, granteeID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	err = repo.AddAuditEvent(ctx, &model.AuditEvent{
+		UserID:  grant.GrantorID,
+		ActorID: granteeID,
+		Action:  model.AuditEmergencyRequested,
+		Detail: fmt.Sprintf("%s requested emergency access, released at %s", grant.GranteeLogin,
+			grant.ReleasesAt().UTC().Format(http.TimeFormat)),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return grant, nil
+}
+
+// rejectEmergency cancels a pending request to a grant given by grantorID.
+func rejectEmergency(ctx context.Context, repo Repository, grantorID, id int64) error {
+	if err := repo.RejectEmergencyAccess(ctx, grantorID, id); err != nil {
+		return err
+	}
+
+	return repo.AddAuditEvent(ctx, &model.AuditEvent{
+		UserID:  grantorID,
+		ActorID: grantorID,
+		Action:  model.AuditEmergencyRejected,
+		Detail:  fmt.Sprintf("emergency request %d rejected", id),
+	})
+}
+
+// emergencyVault returns the secrets of the grantor together with the
+// released keys. File contents stay on the server, only their metadata is
+// part of the vault.
+func emergencyVault(ctx context.Context, repo Repository, granteeID, id int64) (*model.EmergencyVault, error) {
+	grant, err := repo.GetEmergencyAccess(ctx, granteeID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	secrets, err := repo.ListSecrets(ctx, grant.GrantorID, model.SecretFilter{})
+	if err != nil {
+		return nil, err
+	}
+
+	err = repo.AddAuditEvent(ctx, &model.AuditEvent{
+		UserID:  grant.GrantorID,
+		ActorID: granteeID,
+		Action:  model.AuditEmergencyAccessed,
+		Detail:  fmt.Sprintf("%s accessed the vault", grant.GranteeLogin),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.EmergencyVault{Grant: *grant, Secrets: secrets}, nil
+}
+
+func (s *Server) listEmergencyHandler(res http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	list, err := s.storage.ListEmergencyGrants(ctx, UID(ctx))
+	if err != nil {
+		JSONError(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(res).Encode(list); err != nil {
+		JSONError(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+}
+
+// createEmergencyHandler makes another user an emergency contact of the
+// caller. The client sends a data key wrapped for the grantee and its own
+// private key sealed with it.
+func (s *Server) createEmergencyHandler(res http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	var dto model.EmergencyGrant
+	if err := json.NewDecoder(req.Body).Decode(&dto); err != nil {
+		JSONError(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !validGrant(UID(ctx), &dto) {
+		JSONError(res, errInvalidGrant.Error(), http.StatusBadRequest)
+		return
+	}
+
+	id, err := s.storage.CreateEmergencyGrant(ctx, &model.EmergencyGrant{
+		GrantorID: UID(ctx),
+		GranteeID: dto.GranteeID,
+		WaitDays:  dto.WaitDays,
+		Key:       dto.Key,
+		VaultKey:  dto.VaultKey,
+	})
+	if err != nil {
+		emergencyError(res, err)
+		return
+	}
+
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(http.StatusCreated)
+
+	value := struct {
+		ID int64 `json:"id"`
+	}{ID: id}
+
+	if err := json.NewEncoder(res).Encode(value); err != nil {
+		JSONError(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+}
+
+// deleteEmergencyHandler ends a grant, on behalf of either party.
+func (s *Server) deleteEmergencyHandler(res http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	id, err := strconv.Atoi(chi.URLParam(req, "id"))
+	if err != nil {
+		JSONError(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := s.storage.DeleteEmergencyGrant(ctx, UID(ctx), int64(id)); err != nil {
+		emergencyError(res, err)
+		return
+	}
+
+	res.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) requestEmergencyHandler(res http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	id, err := strconv.Atoi(chi.URLParam(req, "id"))
+	if err != nil {
+		JSONError(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	grant, err := requestEmergency(ctx, s.storage, UID(ctx), int64(id))
+	if err != nil {
+		emergencyError(res, err)
+		return
+	}
+
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(res).Encode(grant); err != nil {
+		JSONError(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+}
+
+func (s *Server) rejectEmergencyHandler(res http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	id, err := strconv.Atoi(chi.URLParam(req, "id"))
+	if err != nil {
+		JSONError(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := rejectEmergency(ctx, s.storage, UID(ctx), int64(id)); err != nil {
+		emergencyError(res, err)
+		return
+	}
+
+	res.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) emergencyVaultHandler(res http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	id, err := strconv.Atoi(chi.URLParam(req, "id"))
+	if err != nil {
+		JSONError(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	vault, err := emergencyVault(ctx, s.storage, UID(ctx), int64(id))
+	if err != nil {
+		emergencyError(res, err)
+		return
+	}
+
+	res.Header().Set("Cache-Control", "no-store")
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(res).Encode(vault); err != nil {
+		JSONError(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+}
@@ -0,0 +1,198 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nbvehbq/go-password-keeper/internal/model"
+	"github.com/nbvehbq/go-password-keeper/internal/storage"
+)
+
+// fakeEmergencyRepo keeps emergency grants and the audit trail in memory.
+// Methods the tests don't use panic through the nil embedded interface.
+type fakeEmergencyRepo struct {
+	Repository
+
+	mu     sync.Mutex
+	grants map[int64]*model.EmergencyGrant
+	events []model.AuditEvent
+}
+
+func (r *fakeEmergencyRepo) CreateEmergencyGrant(_ context.Context, grant *model.EmergencyGrant) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, g := range r.grants {
+		if g.GrantorID == grant.GrantorID && g.GranteeID == grant.GranteeID {
+			return 0, storage.ErrEmergencyExists
+		}
+	}
+	copied := *grant
+	copied.ID = int64(len(r.grants) + 1)
+	r.grants[copied.ID] = &copied
+	return copied.ID, nil
+}
+
+func (r *fakeEmergencyRepo) RequestEmergencyAccess(_ context.Context, granteeID, id int64) (*model.EmergencyGrant, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	grant, ok := r.grants[id]
+	if !ok || grant.GranteeID != granteeID {
+		return nil, storage.ErrEmergencyNotFound
+	}
+	if grant.RequestedAt == nil {
+		now := time.Now()
+		grant.RequestedAt = &now
+	}
+	copied := *grant
+	return &copied, nil
+}
+
+func (r *fakeEmergencyRepo) RejectEmergencyAccess(_ context.Context, grantorID, id int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	grant, ok := r.grants[id]
+	switch {
+	case !ok || grant.GrantorID != grantorID:
+		return storage.ErrEmergencyNotFound
+	case grant.RequestedAt == nil || grant.Released(time.Now()):
+		return storage.ErrEmergencyNotPending
+	}
+	grant.RequestedAt = nil
+	return nil
+}
+
+func (r *fakeEmergencyRepo) GetEmergencyAccess(_ context.Context, granteeID, id int64) (*model.EmergencyGrant, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	grant, ok := r.grants[id]
+	switch {
+	case !ok || grant.GranteeID != granteeID:
+		return nil, storage.ErrEmergencyNotFound
+	case !grant.Released(time.Now()):
+		return nil, storage.ErrEmergencyWaiting
+	}
+	copied := *grant
+	return &copied, nil
+}
+
+func (r *fakeEmergencyRepo) ListSecrets(_ context.Context, userID int64, _ model.SecretFilter) ([]model.Secret, error) {
+	return []model.Secret{{ID: 10, UserID: userID, Name: "mail", Type: model.TextType}}, nil
+}
+
+func (r *fakeEmergencyRepo) AddAuditEvent(_ context.Context, event *model.AuditEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.events = append(r.events, *event)
+	return nil
+}
+
+// The grantee gets the vault only once a request has waited out its days,
+// and the grantor learns of every step.
+func TestEmergencyAccess(t *testing.T) {
+	const (
+		alice = 1
+		bob   = 2
+		carol = 3
+	)
+
+	repo := &fakeEmergencyRepo{grants: make(map[int64]*model.EmergencyGrant)}
+	s, sids := newTestServer(t, repo, alice, bob, carol)
+
+	for _, body := range []string{
+		`{"grantee_id":1,"wait_days":1,"key":"aw==","vault_key":"dms="}`,
+		`{"grantee_id":2,"wait_days":0,"key":"aw==","vault_key":"dms="}`,
+		`{"grantee_id":2,"wait_days":91,"key":"aw==","vault_key":"dms="}`,
+		`{"grantee_id":2,"wait_days":1}`,
+	} {
+		if res := serve(s, sids[0], http.MethodPost, "/api/emergency", body, nil); res.Code != http.StatusBadRequest {
+			t.Errorf("grant %s: got %d, want %d", body, res.Code, http.StatusBadRequest)
+		}
+	}
+
+	grant := `{"grantee_id":2,"wait_days":1,"key":"aw==","vault_key":"dms="}`
+	if res := serve(s, sids[0], http.MethodPost, "/api/emergency", grant, nil); res.Code != http.StatusCreated {
+		t.Fatalf("grant: got %d, want %d", res.Code, http.StatusCreated)
+	}
+	if res := serve(s, sids[0], http.MethodPost, "/api/emergency", grant, nil); res.Code != http.StatusConflict {
+		t.Errorf("grant twice: got %d, want %d", res.Code, http.StatusConflict)
+	}
+
+	// backdate moves a pending request past its wait period
+	backdate := func() {
+		repo.mu.Lock()
+		defer repo.mu.Unlock()
+
+		past := time.Now().Add(-48 * time.Hour)
+		repo.grants[1].RequestedAt = &past
+	}
+
+	steps := []struct {
+		name   string
+		sid    string
+		method string
+		target string
+		before func()
+		want   int
+		action string
+	}{
+		{name: "grantor requests", sid: sids[0], method: http.MethodPost, target: "/api/emergency/1/request",
+			want: http.StatusNotFound},
+		{name: "vault before a request", sid: sids[1], method: http.MethodGet, target: "/api/emergency/1/vault",
+			want: http.StatusForbidden},
+		{name: "reject without a request", sid: sids[0], method: http.MethodPost, target: "/api/emergency/1/reject",
+			want: http.StatusConflict},
+		{name: "request", sid: sids[1], method: http.MethodPost, target: "/api/emergency/1/request",
+			want: http.StatusOK, action: model.AuditEmergencyRequested},
+		{name: "vault while waiting", sid: sids[1], method: http.MethodGet, target: "/api/emergency/1/vault",
+			want: http.StatusForbidden},
+		{name: "grantee rejects", sid: sids[1], method: http.MethodPost, target: "/api/emergency/1/reject",
+			want: http.StatusNotFound},
+		{name: "reject", sid: sids[0], method: http.MethodPost, target: "/api/emergency/1/reject",
+			want: http.StatusNoContent, action: model.AuditEmergencyRejected},
+		{name: "request again", sid: sids[1], method: http.MethodPost, target: "/api/emergency/1/request",
+			want: http.StatusOK, action: model.AuditEmergencyRequested},
+		{name: "vault of another user", sid: sids[2], method: http.MethodGet, target: "/api/emergency/1/vault",
+			before: backdate, want: http.StatusNotFound},
+		{name: "vault", sid: sids[1], method: http.MethodGet, target: "/api/emergency/1/vault",
+			want: http.StatusOK, action: model.AuditEmergencyAccessed},
+		{name: "reject after release", sid: sids[0], method: http.MethodPost, target: "/api/emergency/1/reject",
+			want: http.StatusConflict},
+	}
+
+	for _, step := range steps {
+		if step.before != nil {
+			step.before()
+		}
+
+		events := len(repo.events)
+		res := serve(s, step.sid, step.method, step.target, "", nil)
+		if res.Code != step.want {
+			t.Fatalf("%s: got %d, want %d: %s", step.name, res.Code, step.want, res.Body)
+		}
+
+		switch {
+		case step.action == "" && len(repo.events) != events:
+			t.Errorf("%s: audited %+v", step.name, repo.events[events:])
+		case step.action != "" && (len(repo.events) != events+1 || repo.events[events].Action != step.action ||
+			repo.events[events].UserID != alice):
+			t.Errorf("%s: audited %+v, want %s for the grantor", step.name, repo.events[events:], step.action)
+		}
+	}
+
+	res := serve(s, sids[1], http.MethodGet, "/api/emergency/1/vault", "", nil)
+	if cc := res.Header().Get("Cache-Control"); cc != "no-store" {
+		t.Errorf("vault: Cache-Control %q, want no-store", cc)
+	}
+	if body := res.Body.String(); !strings.Contains(body, `"vault_key":"dms="`) || !strings.Contains(body, `"mail"`) {
+		t.Errorf("vault: %s", body)
+	}
+}
@@ -0,0 +1,208 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/nbvehbq/go-password-keeper/internal/model"
+	"github.com/nbvehbq/go-password-keeper/internal/storage"
+)
+
+// parseSecretFilter reads the filter of GET /api/secret from the query:
+// type, folder and tag IDs and favorite.
+func parseSecretFilter(query url.Values) (model.SecretFilter, error) {
+	var filter model.SecretFilter
+
+	if type_ := query.Get("type"); type_ != "" {
+		paramType, ok := model.ValidateParam(type_)
+		if !ok {
+			return filter, errors.New("invalid type")
+		}
+		filter.Type = paramType
+	}
+
+	var err error
+	if param := query.Get("folder"); param != "" {
+		if filter.FolderID, err = strconv.ParseInt(param, 10, 64); err != nil {
+			return filter, errors.New("invalid folder")
+		}
+	}
+	if param := query.Get("tag"); param != "" {
+		if filter.TagID, err = strconv.ParseInt(param, 10, 64); err != nil {
+			return filter, errors.New("invalid tag")
+		}
+	}
+	if param := query.Get("favorite"); param != "" {
+		if filter.Favorite, err = strconv.ParseBool(param); err != nil {
+			return filter, errors.New("invalid favorite")
+		}
+	}
+
+	return filter, nil
+}
+
+// placementError answers errors of secrets referring to folders or tags.
+func placementError(res http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, storage.ErrSecretNotFound):
+		JSONError(res, err.Error(), http.StatusNotFound)
+	case errors.Is(err, storage.ErrFolderNotFound), errors.Is(err, storage.ErrTagNotFound):
+		JSONError(res, err.Error(), http.StatusUnprocessableEntity)
+	case errors.Is(err, storage.ErrSecretExists):
+		JSONError(res, err.Error(), http.StatusConflict)
+	default:
+		JSONError(res, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (s *Server) patchSecretHandler(res http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	id, err := strconv.Atoi(chi.URLParam(req, "id"))
+	if err != nil {
+		JSONError(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var dto model.SecretPatch
+	if err := json.NewDecoder(req.Body).Decode(&dto); err != nil {
+		JSONError(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := s.storage.PatchSecret(ctx, UID(ctx), int64(id), &dto); err != nil {
+		placementError(res, err)
+		return
+	}
+
+	res.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) listFoldersHandler(res http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	list, err := s.storage.ListFolders(ctx, UID(ctx))
+	if err != nil {
+		JSONError(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(res).Encode(list); err != nil {
+		JSONError(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+}
+
+func (s *Server) createFolderHandler(res http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	var dto model.Folder
+	if err := json.NewDecoder(req.Body).Decode(&dto); err != nil {
+		JSONError(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	id, err := s.storage.CreateFolder(ctx, &model.Folder{
+		UserID:   UID(ctx),
+		ParentID: dto.ParentID,
+		Name:     dto.Name,
+		Key:      dto.Key,
+	})
+	if err != nil {
+		placementError(res, err)
+		return
+	}
+
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(http.StatusCreated)
+
+	value := struct {
+		ID int64 `json:"id"`
+	}{ID: id}
+
+	if err := json.NewEncoder(res).Encode(value); err != nil {
+		JSONError(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+}
+
+func (s *Server) deleteFolderHandler(res http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	id, err := strconv.Atoi(chi.URLParam(req, "id"))
+	if err != nil {
+		JSONError(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := s.storage.DeleteFolder(ctx, UID(ctx), int64(id)); err != nil {
+		switch {
+		case errors.Is(err, storage.ErrFolderNotFound):
+			JSONError(res, err.Error(), http.StatusNotFound)
+		case errors.Is(err, storage.ErrFolderNotEmpty):
+			JSONError(res, err.Error(), http.StatusConflict)
+		default:
+			JSONError(res, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	res.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) listTagsHandler(res http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	list, err := s.storage.ListTags(ctx, UID(ctx))
+	if err != nil {
+		JSONError(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(res).Encode(list); err != nil {
+		JSONError(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+}
+
+func (s *Server) createTagHandler(res http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	var dto model.Tag
+	if err := json.NewDecoder(req.Body).Decode(&dto); err != nil {
+		JSONError(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	id, err := s.storage.CreateTag(ctx, &model.Tag{
+		UserID: UID(ctx),
+		Name:   dto.Name,
+		Key:    dto.Key,
+	})
+	if err != nil {
+		JSONError(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(http.StatusCreated)
+
+	value := struct {
+		ID int64 `json:"id"`
+	}{ID: id}
+
+	if err := json.NewEncoder(res).Encode(value); err != nil {
+		JSONError(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+}
@@ -0,0 +1,349 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/nbvehbq/go-password-keeper/internal/logger"
+	"github.com/nbvehbq/go-password-keeper/internal/model"
+	pb "github.com/nbvehbq/go-password-keeper/internal/proto"
+	"github.com/nbvehbq/go-password-keeper/internal/storage"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// publicMethods can be called without a session.
+var publicMethods = map[string]bool{
+	pb.Keeper_Register_FullMethodName:         true,
+	pb.Keeper_Prelogin_FullMethodName:         true,
+	pb.Keeper_Login_FullMethodName:            true,
+	pb.Keeper_LoginTwoFactor_FullMethodName:   true,
+	pb.Keeper_GetOneTimeSecret_FullMethodName: true,
+}
+
+// GRPCServer serves the gRPC API. It shares storage and sessions with the
+// REST server.
+type GRPCServer struct {
+	pb.UnimplementedKeeperServer
+
+	srv     *grpc.Server
+	addr    string
+	storage Repository
+	session SessionStorage
+
+	maxBlobSize int64
+}
+
+// NewGRPCServer creates a new gRPC server
+func NewGRPCServer(storage Repository, session SessionStorage, cfg *Config) (*GRPCServer, error) {
+	s := &GRPCServer{
+		addr:    cfg.GRPCAddress,
+		storage: storage,
+		session: session,
+
+		maxBlobSize: cfg.MaxBlobSize,
+	}
+
+	opts := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(s.unaryAuth),
+		grpc.ChainStreamInterceptor(s.streamAuth),
+	}
+
+	tlsConf, err := tlsConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if tlsConf != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConf)))
+	}
+
+	s.srv = grpc.NewServer(opts...)
+	pb.RegisterKeeperServer(s.srv, s)
+
+	return s, nil
+}
+
+// Run runs the server
+func (s *GRPCServer) Run() error {
+	lis, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return err
+	}
+
+	if err := s.srv.Serve(lis); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+		return err
+	}
+
+	return nil
+}
+
+// Shutdown waits for running calls to finish, or stops the server at once
+// when ctx is done first.
+func (s *GRPCServer) Shutdown(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		s.srv.GracefulStop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		s.srv.Stop()
+	}
+
+	return nil
+}
+
+// authenticate resolves the session ID passed in the "authorization"
+// metadata and extends the session, like Authenticator does for REST.
+func (s *GRPCServer) authenticate(ctx context.Context) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	var sid string
+	if values := md.Get("authorization"); len(values) > 0 {
+		sid = values[0]
+	}
+
+	uid, ok := s.session.Get(ctx, sid)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "session not found")
+	}
+
+	if err := s.session.Refresh(ctx, sid); err != nil {
+		return nil, status.Error(codes.Unauthenticated, "session not found")
+	}
+
+	ctx = context.WithValue(ctx, uidKey, uid)
+	ctx = context.WithValue(ctx, sidKey, sid)
+
+	return ctx, nil
+}
+
+func (s *GRPCServer) unaryAuth(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if publicMethods[info.FullMethod] {
+		return handler(ctx, req)
+	}
+
+	ctx, err := s.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return handler(ctx, req)
+}
+
+func (s *GRPCServer) streamAuth(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := s.authenticate(ss.Context())
+	if err != nil {
+		return err
+	}
+
+	return handler(srv, &authStream{ServerStream: ss, ctx: ctx})
+}
+
+// authStream passes the authenticated context to stream handlers.
+type authStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authStream) Context() context.Context {
+	return s.ctx
+}
+
+// userAgent returns the user agent the client sent with a call.
+func userAgent(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	return strings.Join(md.Get("user-agent"), " ")
+}
+
+// grpcOrigin describes the client of a call for the audit trail, see
+// origin.
+func grpcOrigin(ctx context.Context) string {
+	host := "unknown"
+	if p, ok := peer.FromContext(ctx); ok {
+		host = p.Addr.String()
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+	}
+	return fmt.Sprintf("from %s (%s)", host, userAgent(ctx))
+}
+
+// grpcError maps storage errors to gRPC status errors.
+func grpcError(err error) error {
+	switch {
+	case errors.Is(err, storage.ErrSecretNotFound),
+		errors.Is(err, storage.ErrVersionNotFound),
+		errors.Is(err, storage.ErrSessionNotFound),
+		errors.Is(err, storage.ErrBlobNotFound),
+		errors.Is(err, storage.ErrOneTimeNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, storage.ErrSecretExists), errors.Is(err, storage.ErrUserExists):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, storage.ErrFolderNotFound), errors.Is(err, storage.ErrTagNotFound):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, storage.ErrFolderNotEmpty):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, storage.ErrShareNotFound), errors.Is(err, storage.ErrUserNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, storage.ErrReadOnly):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.Is(err, storage.ErrSharesChanged):
+		return status.Error(codes.Aborted, err.Error())
+	case errors.Is(err, storage.ErrEmergencyNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, storage.ErrEmergencyExists):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, storage.ErrEmergencyNotPending):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, storage.ErrEmergencyWaiting):
+		return status.Error(codes.PermissionDenied, err.Error())
+	default:
+		logger.Log.Error("grpc call failed", zap.Error(err))
+		return status.Error(codes.Internal, err.Error())
+	}
+}
+
+// revisionConflict reports a stale revision together with the current one,
+// so the client can reload the secret.
+func (s *GRPCServer) revisionConflict(ctx context.Context, id int64) error {
+	secret, err := s.storage.GetSecret(ctx, UID(ctx), id)
+	if err != nil {
+		return grpcError(err)
+	}
+
+	return conflictStatus(secret.Revision)
+}
+
+// sharedRevisionConflict is revisionConflict for a secret shared with the
+// user.
+func (s *GRPCServer) sharedRevisionConflict(ctx context.Context, id int64) error {
+	list, err := s.storage.ListSharedSecrets(ctx, UID(ctx))
+	if err != nil {
+		return grpcError(err)
+	}
+
+	for _, v := range list {
+		if v.ID == id {
+			return conflictStatus(v.Revision)
+		}
+	}
+
+	return grpcError(storage.ErrSecretNotFound)
+}
+
+func conflictStatus(revision int64) error {
+	st, err := status.New(codes.FailedPrecondition, storage.ErrRevisionMismatch.Error()).
+		WithDetails(&pb.RevisionConflict{Revision: revision})
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+
+	return st.Err()
+}
+
+func secretToProto(secret *model.Secret) *pb.Secret {
+	return &pb.Secret{
+		Id:        secret.ID,
+		Name:      secret.Name,
+		Type:      uint32(secret.Type),
+		Payload:   secret.Payload,
+		Meta:      secret.Meta,
+		Key:       secret.Key,
+		Uuid:      secret.UUID,
+		Revision:  secret.Revision,
+		UpdatedAt: timestamppb.New(secret.UpdatedAt),
+		FolderId:  secret.FolderID,
+		Favorite:  secret.Favorite,
+		Tags:      secret.Tags,
+	}
+}
+
+func shareToProto(share *model.Share) *pb.Share {
+	return &pb.Share{
+		SecretId:  share.SecretID,
+		UserId:    share.UserID,
+		Login:     share.Login,
+		Key:       share.Key,
+		CanEdit:   share.CanEdit,
+		CreatedAt: timestamppb.New(share.CreatedAt),
+	}
+}
+
+func oneTimeToProto(secret *model.OneTimeSecret) *pb.OneTimeSecret {
+	return &pb.OneTimeSecret{
+		Id:        secret.ID,
+		Payload:   secret.Payload,
+		MaxViews:  int32(secret.MaxViews),
+		Views:     int32(secret.Views),
+		ExpiresAt: timestamppb.New(secret.ExpiresAt),
+	}
+}
+
+func emergencyToProto(grant *model.EmergencyGrant) *pb.EmergencyGrant {
+	res := &pb.EmergencyGrant{
+		Id:           grant.ID,
+		GrantorId:    grant.GrantorID,
+		GrantorLogin: grant.GrantorLogin,
+		GranteeId:    grant.GranteeID,
+		GranteeLogin: grant.GranteeLogin,
+		WaitDays:     int32(grant.WaitDays),
+		Key:          grant.Key,
+		VaultKey:     grant.VaultKey,
+		CreatedAt:    timestamppb.New(grant.CreatedAt),
+	}
+	if grant.RequestedAt != nil {
+		res.RequestedAt = timestamppb.New(*grant.RequestedAt)
+	}
+
+	return res
+}
+
+func auditToProto(event *model.AuditEvent) *pb.AuditEvent {
+	return &pb.AuditEvent{
+		Id:        event.ID,
+		UserId:    event.UserID,
+		ActorId:   event.ActorID,
+		Action:    event.Action,
+		Detail:    event.Detail,
+		CreatedAt: timestamppb.New(event.CreatedAt),
+		PrevHash:  event.PrevHash,
+		Hash:      event.Hash,
+	}
+}
+
+func versionToProto(v *model.SecretVersion) *pb.SecretVersion {
+	return &pb.SecretVersion{
+		SecretId:  v.SecretID,
+		Version:   v.Version,
+		Name:      v.Name,
+		Type:      uint32(v.Type),
+		Payload:   v.Payload,
+		Meta:      v.Meta,
+		Key:       v.Key,
+		Uuid:      v.UUID,
+		CreatedAt: timestamppb.New(v.CreatedAt),
+	}
+}
+
+func sessionToProto(session *model.Session) *pb.Session {
+	return &pb.Session{
+		Id:         session.ID,
+		UserAgent:  session.UserAgent,
+		CreatedAt:  timestamppb.New(session.CreatedAt),
+		LastSeenAt: timestamppb.New(session.LastSeenAt),
+		ExpiresAt:  timestamppb.New(session.ExpiresAt),
+		Current:    session.Current,
+	}
+}
@@ -0,0 +1,795 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/nbvehbq/go-password-keeper/internal/model"
+	"github.com/nbvehbq/go-password-keeper/internal/otp"
+	pb "github.com/nbvehbq/go-password-keeper/internal/proto"
+	"github.com/nbvehbq/go-password-keeper/internal/storage"
+	"golang.org/x/crypto/bcrypt"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// grpcChunkSize is the size of blob chunks sent to clients.
+const grpcChunkSize = 64 << 10
+
+func (s *GRPCServer) Register(ctx context.Context, req *pb.RegisterRequest) (*pb.AuthResponse, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "hash password")
+	}
+
+	userID, err := s.storage.CreateUser(ctx, &model.User{
+		Login:        req.Login,
+		PasswordHash: string(hash),
+		Salt:         req.Salt,
+		VaultKey:     req.VaultKey,
+		PublicKey:    req.PublicKey,
+	})
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	sid, err := s.session.Set(ctx, userID, userAgent(ctx))
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	return &pb.AuthResponse{Sid: sid}, nil
+}
+
+// Prelogin returns the key derivation salt of a user, see preloginHandler.
+func (s *GRPCServer) Prelogin(ctx context.Context, req *pb.PreloginRequest) (*pb.PreloginResponse, error) {
+	user, err := s.storage.GetUserByLogin(ctx, req.Login)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			return nil, status.Error(codes.Unauthenticated, err.Error())
+		}
+		return nil, grpcError(err)
+	}
+
+	return &pb.PreloginResponse{Salt: user.Salt}, nil
+}
+
+func (s *GRPCServer) Login(ctx context.Context, req *pb.LoginRequest) (*pb.AuthResponse, error) {
+	user, err := s.storage.GetUserByLogin(ctx, req.Login)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			return nil, status.Error(codes.Unauthenticated, err.Error())
+		}
+		return nil, grpcError(err)
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)); err != nil {
+		audit(ctx, s.storage, user.ID, user.ID, model.AuditLoginFailed, "wrong password "+grpcOrigin(ctx))
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+
+	if user.TOTPEnabled {
+		challenge, err := newChallenge(ctx, s.storage, user.ID)
+		if err != nil {
+			return nil, grpcError(err)
+		}
+		return &pb.AuthResponse{Challenge: challenge}, nil
+	}
+
+	return s.startSession(ctx, user)
+}
+
+// LoginTwoFactor exchanges a login challenge and a TOTP or recovery code
+// for a session, see loginTwoFactorHandler.
+func (s *GRPCServer) LoginTwoFactor(ctx context.Context, req *pb.LoginTwoFactorRequest) (*pb.AuthResponse, error) {
+	user, err := answerChallenge(ctx, s.storage, req.Challenge, req.Code, grpcOrigin(ctx))
+	if err != nil {
+		return nil, twoFactorError(err)
+	}
+
+	return s.startSession(ctx, user)
+}
+
+func (s *GRPCServer) startSession(ctx context.Context, user *model.User) (*pb.AuthResponse, error) {
+	sid, err := s.session.Set(ctx, user.ID, userAgent(ctx))
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	audit(ctx, s.storage, user.ID, user.ID, model.AuditLogin, grpcOrigin(ctx))
+
+	return &pb.AuthResponse{Sid: sid, VaultKey: user.VaultKey}, nil
+}
+
+func (s *GRPCServer) EnrollTOTP(ctx context.Context, _ *emptypb.Empty) (*pb.EnrollTOTPResponse, error) {
+	key, err := enrollTOTP(ctx, s.storage, UID(ctx))
+	if err != nil {
+		return nil, twoFactorError(err)
+	}
+
+	return &pb.EnrollTOTPResponse{Secret: otp.EncodeSecret(key.Secret), Uri: key.URI()}, nil
+}
+
+func (s *GRPCServer) VerifyTOTP(ctx context.Context, req *pb.TOTPCode) (*pb.RecoveryCodes, error) {
+	list, err := confirmTOTP(ctx, s.storage, UID(ctx), req.Code)
+	if err != nil {
+		return nil, twoFactorError(err)
+	}
+
+	return &pb.RecoveryCodes{Codes: list}, nil
+}
+
+func (s *GRPCServer) DisableTOTP(ctx context.Context, req *pb.TOTPCode) (*emptypb.Empty, error) {
+	if err := disableTOTP(ctx, s.storage, UID(ctx), req.Code); err != nil {
+		return nil, twoFactorError(err)
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+// twoFactorError maps the errors of the second factor like twoFactorStatus.
+// A rejected code is PERMISSION_DENIED, so it can't be mistaken for a missing
+// session.
+func twoFactorError(err error) error {
+	switch twoFactorStatus(err) {
+	case http.StatusUnauthorized:
+		return status.Error(codes.PermissionDenied, err.Error())
+	case http.StatusConflict:
+		return status.Error(codes.FailedPrecondition, err.Error())
+	default:
+		return grpcError(err)
+	}
+}
+
+// UpdateKeys replaces the password and key material of the current user,
+// see updateKeysHandler.
+func (s *GRPCServer) UpdateKeys(ctx context.Context, req *pb.UpdateKeysRequest) (*emptypb.Empty, error) {
+	if req.Password == "" || len(req.Salt) == 0 || len(req.VaultKey) == 0 || len(req.PublicKey) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "password, salt, vault key and public key are required")
+	}
+
+	user, err := s.storage.GetUser(ctx, UID(ctx))
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			return nil, status.Error(codes.Unauthenticated, "unauthorized")
+		}
+		return nil, grpcError(err)
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.CurrentPassword)); err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, status.Error(codes.Internal, "hash password")
+	}
+
+	user.PasswordHash = string(hash)
+	user.Salt = req.Salt
+	user.VaultKey = req.VaultKey
+	user.PublicKey = req.PublicKey
+
+	if err := s.storage.UpdateUserKeys(ctx, user, SID(ctx)); err != nil {
+		return nil, grpcError(err)
+	}
+
+	if err := s.session.RevokeOthers(ctx, user.ID, SID(ctx)); err != nil {
+		return nil, grpcError(err)
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+func (s *GRPCServer) Logout(ctx context.Context, _ *emptypb.Empty) (*emptypb.Empty, error) {
+	if err := s.session.Delete(ctx, SID(ctx)); err != nil {
+		if errors.Is(err, storage.ErrSessionNotFound) {
+			return nil, status.Error(codes.Unauthenticated, err.Error())
+		}
+		return nil, grpcError(err)
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+func (s *GRPCServer) ListSessions(ctx context.Context, _ *emptypb.Empty) (*pb.ListSessionsResponse, error) {
+	list, err := s.session.List(ctx, UID(ctx), SID(ctx))
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	res := &pb.ListSessionsResponse{Sessions: make([]*pb.Session, len(list))}
+	for i := range list {
+		res.Sessions[i] = sessionToProto(&list[i])
+	}
+
+	return res, nil
+}
+
+func (s *GRPCServer) RevokeSession(ctx context.Context, req *pb.RevokeSessionRequest) (*emptypb.Empty, error) {
+	if err := s.session.Revoke(ctx, UID(ctx), req.Id); err != nil {
+		return nil, grpcError(err)
+	}
+
+	audit(ctx, s.storage, UID(ctx), UID(ctx), model.AuditSessionRevoked, fmt.Sprintf("session %d", req.Id))
+
+	return &emptypb.Empty{}, nil
+}
+
+func (s *GRPCServer) ListAuditEvents(ctx context.Context, req *pb.AuditRequest) (*pb.ListAuditEventsResponse, error) {
+	limit, err := auditLimit(int(req.Limit))
+	if err != nil || req.Before < 0 {
+		return nil, status.Error(codes.InvalidArgument, "invalid before or limit")
+	}
+
+	list, err := s.storage.ListAuditEvents(ctx, UID(ctx), req.Before, limit)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	res := &pb.ListAuditEventsResponse{Events: make([]*pb.AuditEvent, len(list))}
+	for i := range list {
+		res.Events[i] = auditToProto(&list[i])
+	}
+
+	return res, nil
+}
+
+func (s *GRPCServer) CreateSecret(ctx context.Context, req *pb.Secret) (*pb.CreateSecretResponse, error) {
+	id, err := s.storage.CreateSecret(ctx, &model.Secret{
+		UserID:   UID(ctx),
+		Name:     req.Name,
+		Type:     model.ResourceType(req.Type),
+		Payload:  req.Payload,
+		Meta:     req.Meta,
+		Key:      req.Key,
+		UUID:     req.Uuid,
+		FolderID: req.FolderId,
+		Favorite: req.Favorite,
+		Tags:     req.Tags,
+	})
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	return &pb.CreateSecretResponse{Id: id}, nil
+}
+
+func (s *GRPCServer) ListSecrets(ctx context.Context, req *pb.ListSecretsRequest) (*pb.ListSecretsResponse, error) {
+	var paramType model.ResourceType
+	if req.Type != 0 {
+		var ok bool
+		if paramType, ok = model.ValidateParam(strconv.Itoa(int(req.Type))); !ok {
+			return nil, status.Error(codes.InvalidArgument, "invalid type")
+		}
+	}
+
+	list, err := s.storage.ListSecrets(ctx, UID(ctx), model.SecretFilter{
+		Type:     paramType,
+		FolderID: req.FolderId,
+		TagID:    req.TagId,
+		Favorite: req.Favorite,
+	})
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	res := &pb.ListSecretsResponse{Secrets: make([]*pb.Secret, len(list))}
+	for i := range list {
+		res.Secrets[i] = secretToProto(&list[i])
+	}
+
+	return res, nil
+}
+
+func (s *GRPCServer) GetSecret(ctx context.Context, req *pb.SecretRequest) (*pb.Secret, error) {
+	secret, err := s.storage.GetSecret(ctx, UID(ctx), req.Id)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	audit(ctx, s.storage, UID(ctx), UID(ctx), model.AuditSecretRead, fmt.Sprintf("secret %d", req.Id))
+
+	return secretToProto(secret), nil
+}
+
+func (s *GRPCServer) UpdateSecret(ctx context.Context, req *pb.Secret) (*pb.UpdateSecretResponse, error) {
+	if req.Revision <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "revision required")
+	}
+
+	revision, err := s.storage.UpdateSecret(ctx, UID(ctx), req.Id, &model.Secret{
+		Type:     model.ResourceType(req.Type),
+		Payload:  req.Payload,
+		Meta:     req.Meta,
+		Key:      req.Key,
+		UUID:     req.Uuid,
+		Revision: req.Revision,
+	})
+	if err != nil {
+		if errors.Is(err, storage.ErrRevisionMismatch) {
+			return nil, s.revisionConflict(ctx, req.Id)
+		}
+		return nil, grpcError(err)
+	}
+
+	return &pb.UpdateSecretResponse{Id: req.Id, Revision: revision}, nil
+}
+
+func (s *GRPCServer) DeleteSecret(ctx context.Context, req *pb.DeleteSecretRequest) (*emptypb.Empty, error) {
+	if req.Revision <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "revision required")
+	}
+
+	if err := s.storage.DeleteSecret(ctx, UID(ctx), req.Id, req.Revision); err != nil {
+		if errors.Is(err, storage.ErrRevisionMismatch) {
+			return nil, s.revisionConflict(ctx, req.Id)
+		}
+		return nil, grpcError(err)
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+func (s *GRPCServer) PatchSecret(ctx context.Context, req *pb.SecretPatch) (*emptypb.Empty, error) {
+	err := s.storage.PatchSecret(ctx, UID(ctx), req.Id, &model.SecretPatch{
+		FolderID: req.FolderId,
+		Favorite: req.Favorite,
+		Tags:     req.Tags,
+		SetTags:  req.SetTags,
+	})
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+func (s *GRPCServer) ListFolders(ctx context.Context, _ *emptypb.Empty) (*pb.ListFoldersResponse, error) {
+	list, err := s.storage.ListFolders(ctx, UID(ctx))
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	res := &pb.ListFoldersResponse{Folders: make([]*pb.Folder, len(list))}
+	for i, v := range list {
+		res.Folders[i] = &pb.Folder{Id: v.ID, ParentId: v.ParentID, Name: v.Name, Key: v.Key}
+	}
+
+	return res, nil
+}
+
+func (s *GRPCServer) CreateFolder(ctx context.Context, req *pb.Folder) (*pb.CreateFolderResponse, error) {
+	id, err := s.storage.CreateFolder(ctx, &model.Folder{
+		UserID:   UID(ctx),
+		ParentID: req.ParentId,
+		Name:     req.Name,
+		Key:      req.Key,
+	})
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	return &pb.CreateFolderResponse{Id: id}, nil
+}
+
+func (s *GRPCServer) DeleteFolder(ctx context.Context, req *pb.FolderRequest) (*emptypb.Empty, error) {
+	if err := s.storage.DeleteFolder(ctx, UID(ctx), req.Id); err != nil {
+		if errors.Is(err, storage.ErrFolderNotFound) {
+			return nil, status.Error(codes.NotFound, err.Error())
+		}
+		return nil, grpcError(err)
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+func (s *GRPCServer) ListTags(ctx context.Context, _ *emptypb.Empty) (*pb.ListTagsResponse, error) {
+	list, err := s.storage.ListTags(ctx, UID(ctx))
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	res := &pb.ListTagsResponse{Tags: make([]*pb.Tag, len(list))}
+	for i, v := range list {
+		res.Tags[i] = &pb.Tag{Id: v.ID, Name: v.Name, Key: v.Key}
+	}
+
+	return res, nil
+}
+
+func (s *GRPCServer) CreateTag(ctx context.Context, req *pb.Tag) (*pb.CreateTagResponse, error) {
+	id, err := s.storage.CreateTag(ctx, &model.Tag{UserID: UID(ctx), Name: req.Name, Key: req.Key})
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	return &pb.CreateTagResponse{Id: id}, nil
+}
+
+func (s *GRPCServer) GetPublicKey(ctx context.Context, req *pb.PublicKeyRequest) (*pb.PublicKey, error) {
+	user, err := s.storage.GetUserByLogin(ctx, req.Login)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	if len(user.PublicKey) == 0 {
+		return nil, grpcError(storage.ErrUserNotFound)
+	}
+
+	return &pb.PublicKey{UserId: user.ID, Login: user.Login, Key: user.PublicKey}, nil
+}
+
+func (s *GRPCServer) ShareSecret(ctx context.Context, req *pb.Share) (*emptypb.Empty, error) {
+	if req.UserId == UID(ctx) || len(req.Key) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "invalid recipient or key")
+	}
+
+	_, err := s.storage.GetUser(ctx, req.UserId)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		return nil, grpcError(err)
+	}
+
+	err = s.storage.ShareSecret(ctx, UID(ctx), &model.Share{
+		SecretID: req.SecretId,
+		UserID:   req.UserId,
+		Key:      req.Key,
+		CanEdit:  req.CanEdit,
+	})
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+func (s *GRPCServer) ListShares(ctx context.Context, req *pb.SecretRequest) (*pb.ListSharesResponse, error) {
+	list, err := s.storage.ListShares(ctx, UID(ctx), req.Id)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	res := &pb.ListSharesResponse{Shares: make([]*pb.Share, len(list))}
+	for i := range list {
+		res.Shares[i] = shareToProto(&list[i])
+	}
+
+	return res, nil
+}
+
+func (s *GRPCServer) RekeySecret(ctx context.Context, req *pb.SecretRekey) (*pb.UpdateSecretResponse, error) {
+	if req.Revision <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "revision required")
+	}
+
+	rekey := &model.SecretRekey{
+		Revision: req.Revision,
+		Payload:  req.Payload,
+		Meta:     req.Meta,
+		Key:      req.Key,
+		UUID:     req.Uuid,
+		Shares:   make([]model.Share, len(req.Shares)),
+		Revoke:   req.Revoke,
+	}
+	for i, v := range req.Shares {
+		rekey.Shares[i] = model.Share{SecretID: req.Id, UserID: v.UserId, Key: v.Key}
+	}
+
+	revision, err := s.storage.RekeySecret(ctx, UID(ctx), req.Id, rekey)
+	if err != nil {
+		if errors.Is(err, storage.ErrRevisionMismatch) {
+			return nil, s.revisionConflict(ctx, req.Id)
+		}
+		return nil, grpcError(err)
+	}
+
+	return &pb.UpdateSecretResponse{Id: req.Id, Revision: revision}, nil
+}
+
+func (s *GRPCServer) ListSharedSecrets(ctx context.Context, _ *emptypb.Empty) (*pb.ListSharedSecretsResponse, error) {
+	list, err := s.storage.ListSharedSecrets(ctx, UID(ctx))
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	res := &pb.ListSharedSecretsResponse{Secrets: make([]*pb.SharedSecret, len(list))}
+	for i := range list {
+		res.Secrets[i] = &pb.SharedSecret{
+			Secret:  secretToProto(&list[i].Secret),
+			Owner:   list[i].Owner,
+			CanEdit: list[i].CanEdit,
+		}
+	}
+
+	return res, nil
+}
+
+func (s *GRPCServer) UpdateSharedSecret(ctx context.Context, req *pb.Secret) (*pb.UpdateSecretResponse, error) {
+	if req.Revision <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "revision required")
+	}
+
+	revision, err := s.storage.UpdateSharedSecret(ctx, UID(ctx), req.Id, &model.Secret{
+		Payload:  req.Payload,
+		Meta:     req.Meta,
+		UUID:     req.Uuid,
+		Revision: req.Revision,
+	})
+	if err != nil {
+		if errors.Is(err, storage.ErrRevisionMismatch) {
+			return nil, s.sharedRevisionConflict(ctx, req.Id)
+		}
+		return nil, grpcError(err)
+	}
+
+	return &pb.UpdateSecretResponse{Id: req.Id, Revision: revision}, nil
+}
+
+func (s *GRPCServer) CreateOneTimeSecret(ctx context.Context, req *pb.OneTimeSecret) (*pb.OneTimeSecret, error) {
+	secret, err := newOneTimeSecret(UID(ctx), &model.OneTimeSecret{
+		Payload:   req.Payload,
+		MaxViews:  int(req.MaxViews),
+		ExpiresAt: req.ExpiresAt.AsTime(),
+	})
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	if err := s.storage.CreateOneTimeSecret(ctx, secret); err != nil {
+		return nil, grpcError(err)
+	}
+
+	// the payload is not echoed back
+	secret.Payload = nil
+	return oneTimeToProto(secret), nil
+}
+
+func (s *GRPCServer) GetOneTimeSecret(ctx context.Context, req *pb.OneTimeSecretRequest) (*pb.OneTimeSecret, error) {
+	secret, err := s.storage.TakeOneTimeSecret(ctx, req.Id)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	return oneTimeToProto(secret), nil
+}
+
+func (s *GRPCServer) ListEmergencyGrants(ctx context.Context, _ *emptypb.Empty) (*pb.ListEmergencyGrantsResponse, error) {
+	list, err := s.storage.ListEmergencyGrants(ctx, UID(ctx))
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	res := &pb.ListEmergencyGrantsResponse{Grants: make([]*pb.EmergencyGrant, len(list))}
+	for i := range list {
+		res.Grants[i] = emergencyToProto(&list[i])
+	}
+
+	return res, nil
+}
+
+func (s *GRPCServer) CreateEmergencyGrant(ctx context.Context, req *pb.EmergencyGrant) (*pb.CreateEmergencyGrantResponse, error) {
+	grant := &model.EmergencyGrant{
+		GrantorID: UID(ctx),
+		GranteeID: req.GranteeId,
+		WaitDays:  int(req.WaitDays),
+		Key:       req.Key,
+		VaultKey:  req.VaultKey,
+	}
+	if !validGrant(UID(ctx), grant) {
+		return nil, status.Error(codes.InvalidArgument, errInvalidGrant.Error())
+	}
+
+	id, err := s.storage.CreateEmergencyGrant(ctx, grant)
+	if err != nil {
+		if errors.Is(err, storage.ErrUserNotFound) {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		return nil, grpcError(err)
+	}
+
+	return &pb.CreateEmergencyGrantResponse{Id: id}, nil
+}
+
+func (s *GRPCServer) DeleteEmergencyGrant(ctx context.Context, req *pb.EmergencyRequest) (*emptypb.Empty, error) {
+	if err := s.storage.DeleteEmergencyGrant(ctx, UID(ctx), req.Id); err != nil {
+		return nil, grpcError(err)
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+func (s *GRPCServer) RequestEmergencyAccess(ctx context.Context, req *pb.EmergencyRequest) (*pb.EmergencyGrant, error) {
+	grant, err := requestEmergency(ctx, s.storage, UID(ctx), req.Id)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	return emergencyToProto(grant), nil
+}
+
+func (s *GRPCServer) RejectEmergencyAccess(ctx context.Context, req *pb.EmergencyRequest) (*emptypb.Empty, error) {
+	if err := rejectEmergency(ctx, s.storage, UID(ctx), req.Id); err != nil {
+		return nil, grpcError(err)
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+func (s *GRPCServer) GetEmergencyVault(ctx context.Context, req *pb.EmergencyRequest) (*pb.EmergencyVault, error) {
+	vault, err := emergencyVault(ctx, s.storage, UID(ctx), req.Id)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	res := &pb.EmergencyVault{
+		Grant:   emergencyToProto(&vault.Grant),
+		Secrets: make([]*pb.Secret, len(vault.Secrets)),
+	}
+	for i := range vault.Secrets {
+		res.Secrets[i] = secretToProto(&vault.Secrets[i])
+	}
+
+	return res, nil
+}
+
+func (s *GRPCServer) ListVersions(ctx context.Context, req *pb.SecretRequest) (*pb.ListVersionsResponse, error) {
+	list, err := s.storage.ListSecretVersions(ctx, UID(ctx), req.Id)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	res := &pb.ListVersionsResponse{Versions: make([]*pb.SecretVersion, len(list))}
+	for i := range list {
+		res.Versions[i] = versionToProto(&list[i])
+	}
+
+	return res, nil
+}
+
+func (s *GRPCServer) GetVersion(ctx context.Context, req *pb.VersionRequest) (*pb.SecretVersion, error) {
+	v, err := s.storage.GetSecretVersion(ctx, UID(ctx), req.Id, req.Version)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	return versionToProto(v), nil
+}
+
+func (s *GRPCServer) RestoreVersion(ctx context.Context, req *pb.VersionRequest) (*emptypb.Empty, error) {
+	if err := s.storage.RestoreSecretVersion(ctx, UID(ctx), req.Id, req.Version); err != nil {
+		return nil, grpcError(err)
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+// Sync streams the changes of the vault after req.Since, ending with the
+// new cursor.
+func (s *GRPCServer) Sync(req *pb.SyncRequest, stream pb.Keeper_SyncServer) error {
+	ctx := stream.Context()
+
+	if req.Since < 0 {
+		return status.Error(codes.InvalidArgument, "invalid cursor")
+	}
+
+	changes, err := s.storage.Changes(ctx, UID(ctx), req.Since)
+	if err != nil {
+		return grpcError(err)
+	}
+
+	for i := range changes.Updated {
+		event := &pb.SyncEvent{Event: &pb.SyncEvent_Updated{Updated: secretToProto(&changes.Updated[i])}}
+		if err := stream.Send(event); err != nil {
+			return err
+		}
+	}
+
+	for _, id := range changes.Deleted {
+		if err := stream.Send(&pb.SyncEvent{Event: &pb.SyncEvent_Deleted{Deleted: id}}); err != nil {
+			return err
+		}
+	}
+
+	return stream.Send(&pb.SyncEvent{Event: &pb.SyncEvent_Cursor{Cursor: changes.Cursor}})
+}
+
+// PutBlob stores the streamed file contents of a binary secret along with
+// the secret sent in the first chunk, limited to Config.MaxBlobSize like the
+// REST endpoint.
+func (s *GRPCServer) PutBlob(stream pb.Keeper_PutBlobServer) error {
+	ctx := stream.Context()
+
+	first, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	if first.Secret == nil || first.Secret.Revision <= 0 {
+		return status.Error(codes.InvalidArgument, "secret and revision required")
+	}
+
+	r := &blobStream{stream: stream, data: first.Data, limit: s.maxBlobSize}
+	revision, size, err := s.storage.PutBlob(ctx, UID(ctx), first.Id, &model.Secret{
+		Type:     model.ResourceType(first.Secret.Type),
+		Payload:  first.Secret.Payload,
+		Meta:     first.Secret.Meta,
+		Key:      first.Secret.Key,
+		UUID:     first.Secret.Uuid,
+		Revision: first.Secret.Revision,
+	}, r)
+	if err != nil {
+		switch {
+		case errors.Is(err, errBlobTooLarge):
+			return status.Error(codes.ResourceExhausted, err.Error())
+		case errors.Is(err, storage.ErrNotBinary):
+			return status.Error(codes.InvalidArgument, err.Error())
+		case errors.Is(err, storage.ErrRevisionMismatch):
+			return s.revisionConflict(ctx, first.Id)
+		}
+		return grpcError(err)
+	}
+
+	return stream.SendAndClose(&pb.PutBlobResponse{Id: first.Id, Size: size, Revision: revision})
+}
+
+func (s *GRPCServer) GetBlob(req *pb.SecretRequest, stream pb.Keeper_GetBlobServer) error {
+	ctx := stream.Context()
+
+	blob, err := s.storage.GetBlob(ctx, UID(ctx), req.Id)
+	if err != nil {
+		return grpcError(err)
+	}
+	defer blob.Close()
+
+	buf := make([]byte, grpcChunkSize)
+	for {
+		n, err := io.ReadFull(blob, buf)
+		if n > 0 {
+			if err := stream.Send(&pb.BlobChunk{Id: req.Id, Data: buf[:n]}); err != nil {
+				return err
+			}
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil
+		}
+		if err != nil {
+			return grpcError(err)
+		}
+	}
+}
+
+var errBlobTooLarge = errors.New("blob too large")
+
+// blobStream reads the data of streamed blob chunks.
+type blobStream struct {
+	stream pb.Keeper_PutBlobServer
+	data   []byte
+	size   int64
+	limit  int64
+}
+
+func (b *blobStream) Read(p []byte) (int, error) {
+	for len(b.data) == 0 {
+		chunk, err := b.stream.Recv()
+		if err != nil {
+			return 0, err
+		}
+		b.data = chunk.Data
+	}
+
+	n := copy(p, b.data)
+	b.data = b.data[n:]
+
+	b.size += int64(n)
+	if b.size > b.limit {
+		return 0, errBlobTooLarge
+	}
+
+	return n, nil
+}
@@ -0,0 +1,144 @@
+package server
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/nbvehbq/go-password-keeper/internal/model"
+	pb "github.com/nbvehbq/go-password-keeper/internal/proto"
+	"github.com/nbvehbq/go-password-keeper/internal/session"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// newTestGRPCServer serves repo over gRPC on a loopback port and returns a
+// client of it together with sessions of the given users, in order.
+func newTestGRPCServer(t *testing.T, repo Repository, users ...int64) (pb.KeeperClient, []string) {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	sessions := session.NewSessionStorage(ctx)
+	s, err := NewGRPCServer(repo, sessions, &Config{})
+	if err != nil {
+		t.Fatalf("NewGRPCServer: %v", err)
+	}
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	go s.srv.Serve(lis)
+	t.Cleanup(s.srv.Stop)
+
+	conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	sids := make([]string, len(users))
+	for i, uid := range users {
+		if sids[i], err = sessions.Set(ctx, uid, "test"); err != nil {
+			t.Fatalf("session: %v", err)
+		}
+	}
+
+	return pb.NewKeeperClient(conn), sids
+}
+
+func withSID(sid string) context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), "authorization", sid)
+}
+
+// The gRPC API authenticates, scopes and checks revisions like the REST
+// one.
+func TestGRPCSecrets(t *testing.T) {
+	const (
+		alice = 1
+		bob   = 2
+	)
+
+	repo := newFakeRepo(model.Secret{ID: 10, UserID: alice, Name: "mail", Type: model.TextType,
+		Payload: []byte("p"), Revision: 1})
+	client, sids := newTestGRPCServer(t, repo, alice, bob)
+
+	if _, err := client.GetSecret(context.Background(), &pb.SecretRequest{Id: 10}); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("without a session: got %v, want %v", err, codes.Unauthenticated)
+	}
+	if _, err := client.GetSecret(withSID("unknown"), &pb.SecretRequest{Id: 10}); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("unknown session: got %v, want %v", err, codes.Unauthenticated)
+	}
+
+	secret, err := client.GetSecret(withSID(sids[0]), &pb.SecretRequest{Id: 10})
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if secret.Name != "mail" || string(secret.Payload) != "p" || secret.Revision != 1 {
+		t.Errorf("secret %+v", secret)
+	}
+
+	tests := []struct {
+		name     string
+		sid      string
+		call     func(ctx context.Context) error
+		wantCode codes.Code
+	}{
+		{name: "get by another user", sid: sids[1], wantCode: codes.NotFound, call: func(ctx context.Context) error {
+			_, err := client.GetSecret(ctx, &pb.SecretRequest{Id: 10})
+			return err
+		}},
+		{name: "update by another user", sid: sids[1], wantCode: codes.NotFound, call: func(ctx context.Context) error {
+			_, err := client.UpdateSecret(ctx, &pb.Secret{Id: 10, Payload: []byte("x"), Revision: 1})
+			return err
+		}},
+		{name: "update without revision", sid: sids[0], wantCode: codes.InvalidArgument, call: func(ctx context.Context) error {
+			_, err := client.UpdateSecret(ctx, &pb.Secret{Id: 10, Payload: []byte("x")})
+			return err
+		}},
+		{name: "delete by another user", sid: sids[1], wantCode: codes.NotFound, call: func(ctx context.Context) error {
+			_, err := client.DeleteSecret(ctx, &pb.DeleteSecretRequest{Id: 10, Revision: 1})
+			return err
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.call(withSID(tt.sid)); status.Code(err) != tt.wantCode {
+				t.Errorf("got %v, want %v", err, tt.wantCode)
+			}
+		})
+	}
+
+	res, err := client.UpdateSecret(withSID(sids[0]), &pb.Secret{Id: 10, Payload: []byte("x"), Revision: 1})
+	if err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if res.Revision != 2 {
+		t.Errorf("revision %d after update, want 2", res.Revision)
+	}
+
+	_, err = client.DeleteSecret(withSID(sids[0]), &pb.DeleteSecretRequest{Id: 10, Revision: 1})
+	st := status.Convert(err)
+	if st.Code() != codes.FailedPrecondition {
+		t.Fatalf("stale delete: got %v, want %v", err, codes.FailedPrecondition)
+	}
+	var current int64
+	for _, detail := range st.Details() {
+		if conflict, ok := detail.(*pb.RevisionConflict); ok {
+			current = conflict.Revision
+		}
+	}
+	if current != 2 {
+		t.Errorf("stale delete: current revision %d, want 2", current)
+	}
+
+	if _, err := client.DeleteSecret(withSID(sids[0]), &pb.DeleteSecretRequest{Id: 10, Revision: 2}); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+}
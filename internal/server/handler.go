@@ -4,8 +4,10 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/nbvehbq/go-password-keeper/internal/logger"
@@ -37,7 +39,13 @@ func (s *Server) registerHandler(res http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	userID, err := s.storage.CreateUser(ctx, dto.Login, string(hash))
+	userID, err := s.storage.CreateUser(ctx, &model.User{
+		Login:        dto.Login,
+		PasswordHash: string(hash),
+		Salt:         dto.Salt,
+		VaultKey:     dto.VaultKey,
+		PublicKey:    dto.PublicKey,
+	})
 	if err != nil {
 		switch {
 		case errors.Is(err, storage.ErrUserExists):
@@ -48,7 +56,7 @@ func (s *Server) registerHandler(res http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	sid, err := s.session.Set(req.Context(), userID)
+	sid, err := s.session.Set(req.Context(), userID, req.UserAgent())
 	if err != nil {
 		JSONError(res, err.Error(), http.StatusInternalServerError)
 		return
@@ -91,16 +99,47 @@ func (s *Server) loginHandler(res http.ResponseWriter, req *http.Request) {
 	}
 
 	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(dto.Password)); err != nil {
+		audit(ctx, s.storage, user.ID, user.ID, model.AuditLoginFailed, "wrong password "+origin(req))
 		JSONError(res, err.Error(), http.StatusUnauthorized)
 		return
 	}
 
-	sid, err := s.session.Set(req.Context(), user.ID)
+	// with two-factor authentication the session is opened by
+	// loginTwoFactorHandler
+	if user.TOTPEnabled {
+		challenge, err := newChallenge(ctx, s.storage, user.ID)
+		if err != nil {
+			JSONError(res, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		res.Header().Set("Content-Type", "application/json")
+		res.WriteHeader(http.StatusOK)
+
+		value := struct {
+			Challenge string `json:"challenge"`
+		}{Challenge: challenge}
+
+		if err := json.NewEncoder(res).Encode(value); err != nil {
+			JSONError(res, err.Error(), http.StatusBadRequest)
+		}
+		return
+	}
+
+	s.startSession(res, req, user)
+}
+
+// startSession opens a session for an authenticated user and returns it
+// together with the encrypted vault key.
+func (s *Server) startSession(res http.ResponseWriter, req *http.Request, user *model.User) {
+	sid, err := s.session.Set(req.Context(), user.ID, req.UserAgent())
 	if err != nil {
 		JSONError(res, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	audit(req.Context(), s.storage, user.ID, user.ID, model.AuditLogin, origin(req))
+
 	setCookie(res, sid)
 	res.Header().Set("Authorization", sid)
 
@@ -108,8 +147,9 @@ func (s *Server) loginHandler(res http.ResponseWriter, req *http.Request) {
 	res.WriteHeader(http.StatusOK)
 
 	value := struct {
-		SID string `json:"sid"`
-	}{SID: sid}
+		SID      string `json:"sid"`
+		VaultKey []byte `json:"vault_key,omitempty"`
+	}{SID: sid, VaultKey: user.VaultKey}
 
 	if err := json.NewEncoder(res).Encode(value); err != nil {
 		JSONError(res, err.Error(), http.StatusBadRequest)
@@ -117,32 +157,130 @@ func (s *Server) loginHandler(res http.ResponseWriter, req *http.Request) {
 	}
 }
 
-func (s *Server) createSecretHandler(res http.ResponseWriter, req *http.Request) {
+// preloginHandler returns the key derivation salt of a user, so the client
+// can derive the master key before authenticating. Legacy accounts have no
+// salt and log in with the plain password.
+func (s *Server) preloginHandler(res http.ResponseWriter, req *http.Request) {
 	ctx := req.Context()
 
-	var dto model.Secret
+	var dto model.RegisterDTO
 	if err := json.NewDecoder(req.Body).Decode(&dto); err != nil {
 		JSONError(res, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	id, err := s.storage.CreateSecret(ctx, &model.Secret{
-		UserID:  UID(ctx),
-		Name:    dto.Name,
-		Type:    dto.Type,
-		Payload: dto.Payload,
-		Meta:    dto.Meta,
-	})
+	user, err := s.storage.GetUserByLogin(ctx, dto.Login)
 	if err != nil {
 		switch {
-		case errors.Is(err, storage.ErrSecretExists):
-			JSONError(res, err.Error(), http.StatusConflict)
+		case errors.Is(err, storage.ErrUserNotFound):
+			JSONError(res, err.Error(), http.StatusUnauthorized)
+		default:
+			JSONError(res, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(http.StatusOK)
+
+	value := struct {
+		Salt []byte `json:"salt,omitempty"`
+	}{Salt: user.Salt}
+
+	if err := json.NewEncoder(res).Encode(value); err != nil {
+		JSONError(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+}
+
+// updateKeysHandler replaces the password and key material of the current
+// user. It is used to migrate legacy accounts and to change the master
+// password, and requires the current password. All other sessions of the
+// user are ended, the login in the request is ignored.
+func (s *Server) updateKeysHandler(res http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	var dto struct {
+		model.RegisterDTO
+		CurrentPassword string `json:"current_password"`
+	}
+	if err := json.NewDecoder(req.Body).Decode(&dto); err != nil {
+		JSONError(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if dto.Password == "" || len(dto.Salt) == 0 || len(dto.VaultKey) == 0 || len(dto.PublicKey) == 0 {
+		JSONError(res, "password, salt, vault key and public key are required", http.StatusBadRequest)
+		return
+	}
+
+	user, err := s.storage.GetUser(ctx, UID(ctx))
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrUserNotFound):
+			JSONError(res, "unauthorized", http.StatusUnauthorized)
 		default:
 			JSONError(res, err.Error(), http.StatusInternalServerError)
 		}
 		return
 	}
 
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(dto.CurrentPassword)); err != nil {
+		JSONError(res, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(dto.Password), bcrypt.DefaultCost)
+	if err != nil {
+		JSONError(res, "hash password", http.StatusInternalServerError)
+		return
+	}
+
+	user.PasswordHash = string(hash)
+	user.Salt = dto.Salt
+	user.VaultKey = dto.VaultKey
+	user.PublicKey = dto.PublicKey
+
+	if err := s.storage.UpdateUserKeys(ctx, user, SID(ctx)); err != nil {
+		JSONError(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// sessions kept outside of the repository
+	if err := s.session.RevokeOthers(ctx, user.ID, SID(ctx)); err != nil {
+		JSONError(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	res.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) createSecretHandler(res http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	var dto model.Secret
+	if err := json.NewDecoder(req.Body).Decode(&dto); err != nil {
+		JSONError(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	id, err := s.storage.CreateSecret(ctx, &model.Secret{
+		UserID:   UID(ctx),
+		Name:     dto.Name,
+		Type:     dto.Type,
+		Payload:  dto.Payload,
+		Meta:     dto.Meta,
+		Key:      dto.Key,
+		UUID:     dto.UUID,
+		FolderID: dto.FolderID,
+		Favorite: dto.Favorite,
+		Tags:     dto.Tags,
+	})
+	if err != nil {
+		placementError(res, err)
+		return
+	}
+
 	res.Header().Set("Content-Type", "application/json")
 	res.WriteHeader(http.StatusCreated)
 
@@ -158,14 +296,13 @@ func (s *Server) createSecretHandler(res http.ResponseWriter, req *http.Request)
 
 func (s *Server) listSecretHandler(res http.ResponseWriter, req *http.Request) {
 	ctx := req.Context()
-	type_ := req.URL.Query().Get("type")
-	paramType, ok := model.ValidateParam(type_)
-	if !ok && type_ != "" {
-		JSONError(res, "invalid type", http.StatusBadRequest)
+	filter, err := parseSecretFilter(req.URL.Query())
+	if err != nil {
+		JSONError(res, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	list, err := s.storage.ListSecrets(ctx, UID(ctx), uint8(paramType))
+	list, err := s.storage.ListSecrets(ctx, UID(ctx), filter)
 	if err != nil {
 		JSONError(res, err.Error(), http.StatusInternalServerError)
 		return
@@ -190,7 +327,7 @@ func (s *Server) getSecretHandler(res http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	secret, err := s.storage.GetSecret(ctx, int64(id))
+	secret, err := s.storage.GetSecret(ctx, UID(ctx), int64(id))
 	if err != nil {
 		switch {
 		case errors.Is(err, storage.ErrSecretNotFound):
@@ -201,6 +338,9 @@ func (s *Server) getSecretHandler(res http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	audit(ctx, s.storage, UID(ctx), UID(ctx), model.AuditSecretRead, fmt.Sprintf("secret %d", id))
+
+	res.Header().Set("ETag", etag(secret.Revision))
 	res.Header().Set("Content-Type", "application/json")
 	res.WriteHeader(http.StatusOK)
 
@@ -211,6 +351,7 @@ func (s *Server) getSecretHandler(res http.ResponseWriter, req *http.Request) {
 }
 
 func (s *Server) updateSecretHandler(res http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
 	var dto model.Secret
 
 	idParam := chi.URLParam(req, "id")
@@ -221,23 +362,39 @@ func (s *Server) updateSecretHandler(res http.ResponseWriter, req *http.Request)
 		return
 	}
 
+	revision, err := ifMatch(req)
+	if err != nil {
+		JSONError(res, err.Error(), http.StatusPreconditionRequired)
+		return
+	}
+
 	if err := json.NewDecoder(req.Body).Decode(&dto); err != nil {
 		JSONError(res, err.Error(), http.StatusBadRequest)
 		return
 	}
+	dto.Revision = revision
 
-	ret, err := s.storage.UpdateSecret(req.Context(), int64(id), &dto)
+	ret, err := s.storage.UpdateSecret(ctx, UID(ctx), int64(id), &dto)
 	if err != nil {
-		JSONError(res, err.Error(), http.StatusInternalServerError)
+		switch {
+		case errors.Is(err, storage.ErrSecretNotFound):
+			JSONError(res, err.Error(), http.StatusNotFound)
+		case errors.Is(err, storage.ErrRevisionMismatch):
+			s.revisionConflict(res, req, int64(id))
+		default:
+			JSONError(res, err.Error(), http.StatusInternalServerError)
+		}
 		return
 	}
 
+	res.Header().Set("ETag", etag(ret))
 	res.Header().Set("Content-Type", "application/json")
 	res.WriteHeader(http.StatusOK)
 
 	value := struct {
-		ID int64 `json:"id"`
-	}{ID: ret}
+		ID       int64 `json:"id"`
+		Revision int64 `json:"revision"`
+	}{ID: int64(id), Revision: ret}
 
 	if err := json.NewEncoder(res).Encode(value); err != nil {
 		JSONError(res, err.Error(), http.StatusBadRequest)
@@ -251,19 +408,270 @@ func (s *Server) deleteSecretHandler(res http.ResponseWriter, req *http.Request)
 
 	id, err := strconv.Atoi(idParam)
 	if err != nil {
-		http.Error(res, err.Error(), http.StatusBadRequest)
+		JSONError(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	revision, err := ifMatch(req)
+	if err != nil {
+		JSONError(res, err.Error(), http.StatusPreconditionRequired)
 		return
 	}
 
-	err = s.storage.DeleteSecret(ctx, int64(id))
+	err = s.storage.DeleteSecret(ctx, UID(ctx), int64(id), revision)
 	if err != nil {
-		http.Error(res, err.Error(), http.StatusInternalServerError)
+		switch {
+		case errors.Is(err, storage.ErrSecretNotFound):
+			JSONError(res, err.Error(), http.StatusNotFound)
+		case errors.Is(err, storage.ErrRevisionMismatch):
+			s.revisionConflict(res, req, int64(id))
+		default:
+			JSONError(res, err.Error(), http.StatusInternalServerError)
+		}
 		return
 	}
 
 	res.WriteHeader(http.StatusNoContent)
 }
 
+// revisionConflict answers 412 with the current revision of the secret, so
+// the client can reload it.
+func (s *Server) revisionConflict(res http.ResponseWriter, req *http.Request, id int64) {
+	ctx := req.Context()
+
+	secret, err := s.storage.GetSecret(ctx, UID(ctx), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrSecretNotFound):
+			JSONError(res, err.Error(), http.StatusNotFound)
+		default:
+			JSONError(res, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	res.Header().Set("ETag", etag(secret.Revision))
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(http.StatusPreconditionFailed)
+
+	value := struct {
+		Err      string `json:"error"`
+		Revision int64  `json:"revision"`
+	}{Err: storage.ErrRevisionMismatch.Error(), Revision: secret.Revision}
+
+	json.NewEncoder(res).Encode(value)
+}
+
+func (s *Server) listVersionsHandler(res http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	idParam := chi.URLParam(req, "id")
+
+	id, err := strconv.Atoi(idParam)
+	if err != nil {
+		JSONError(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	list, err := s.storage.ListSecretVersions(ctx, UID(ctx), int64(id))
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrSecretNotFound):
+			JSONError(res, err.Error(), http.StatusNotFound)
+		default:
+			JSONError(res, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(res).Encode(list); err != nil {
+		JSONError(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+}
+
+func (s *Server) getVersionHandler(res http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	id, err := strconv.Atoi(chi.URLParam(req, "id"))
+	if err != nil {
+		JSONError(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	version, err := strconv.Atoi(chi.URLParam(req, "version"))
+	if err != nil {
+		JSONError(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	v, err := s.storage.GetSecretVersion(ctx, UID(ctx), int64(id), int64(version))
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrVersionNotFound):
+			JSONError(res, err.Error(), http.StatusNotFound)
+		default:
+			JSONError(res, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(res).Encode(v); err != nil {
+		JSONError(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+}
+
+func (s *Server) restoreVersionHandler(res http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	id, err := strconv.Atoi(chi.URLParam(req, "id"))
+	if err != nil {
+		JSONError(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	version, err := strconv.Atoi(chi.URLParam(req, "version"))
+	if err != nil {
+		JSONError(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := s.storage.RestoreSecretVersion(ctx, UID(ctx), int64(id), int64(version)); err != nil {
+		switch {
+		case errors.Is(err, storage.ErrVersionNotFound), errors.Is(err, storage.ErrSecretNotFound):
+			JSONError(res, err.Error(), http.StatusNotFound)
+		default:
+			JSONError(res, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	res.WriteHeader(http.StatusNoContent)
+}
+
+// syncHandler returns changes of the vault after the "since" cursor: created
+// and updated secrets with payloads, and IDs of deleted ones.
+func (s *Server) syncHandler(res http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	var since int64
+	if param := req.URL.Query().Get("since"); param != "" {
+		var err error
+		if since, err = strconv.ParseInt(param, 10, 64); err != nil || since < 0 {
+			JSONError(res, "invalid cursor", http.StatusBadRequest)
+			return
+		}
+	}
+
+	changes, err := s.storage.Changes(ctx, UID(ctx), since)
+	if err != nil {
+		JSONError(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(res).Encode(changes); err != nil {
+		JSONError(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+}
+
+func (s *Server) logoutHandler(res http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	if err := s.session.Delete(ctx, SID(ctx)); err != nil {
+		switch {
+		case errors.Is(err, storage.ErrSessionNotFound):
+			JSONError(res, err.Error(), http.StatusUnauthorized)
+		default:
+			JSONError(res, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	http.SetCookie(res, &http.Cookie{
+		Name:     "session",
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	res.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) listSessionsHandler(res http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	list, err := s.session.List(ctx, UID(ctx), SID(ctx))
+	if err != nil {
+		JSONError(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(res).Encode(list); err != nil {
+		JSONError(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+}
+
+func (s *Server) revokeSessionHandler(res http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	idParam := chi.URLParam(req, "id")
+
+	id, err := strconv.Atoi(idParam)
+	if err != nil {
+		JSONError(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := s.session.Revoke(ctx, UID(ctx), int64(id)); err != nil {
+		switch {
+		case errors.Is(err, storage.ErrSessionNotFound):
+			JSONError(res, err.Error(), http.StatusNotFound)
+		default:
+			JSONError(res, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	audit(ctx, s.storage, UID(ctx), UID(ctx), model.AuditSessionRevoked, fmt.Sprintf("session %d", id))
+
+	res.WriteHeader(http.StatusNoContent)
+}
+
+func etag(revision int64) string {
+	return fmt.Sprintf(`"%d"`, revision)
+}
+
+// ifMatch extracts the expected secret revision from the If-Match header.
+func ifMatch(req *http.Request) (int64, error) {
+	value := strings.TrimPrefix(strings.TrimSpace(req.Header.Get("If-Match")), "W/")
+	if value == "" {
+		return 0, errors.New("If-Match header required")
+	}
+
+	revision, err := strconv.ParseInt(strings.Trim(value, `"`), 10, 64)
+	if err != nil || revision <= 0 {
+		return 0, errors.New("invalid If-Match header")
+	}
+
+	return revision, nil
+}
+
 func setCookie(w http.ResponseWriter, payload string) {
 	cookie := &http.Cookie{
 		Name:     "session",
@@ -283,6 +691,11 @@ func UID(ctx context.Context) int64 {
 	return uid
 }
 
+func SID(ctx context.Context) string {
+	sid := ctx.Value(sidKey).(string)
+	return sid
+}
+
 // JSONError sends an error message in JSON format
 func JSONError(w http.ResponseWriter, msg string, code int) {
 	res := struct {
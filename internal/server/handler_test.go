@@ -0,0 +1,400 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/nbvehbq/go-password-keeper/internal/model"
+	"github.com/nbvehbq/go-password-keeper/internal/session"
+	"github.com/nbvehbq/go-password-keeper/internal/storage"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// fakeRepo keeps secrets in memory and scopes them by owner like the
+// Repository contract requires. Methods the tests don't use panic through
+// the nil embedded interface.
+type fakeRepo struct {
+	Repository
+
+	mu      sync.Mutex
+	users   map[int64]*model.User
+	secrets map[int64]*model.Secret
+}
+
+func newFakeRepo(secrets ...model.Secret) *fakeRepo {
+	r := &fakeRepo{users: make(map[int64]*model.User), secrets: make(map[int64]*model.Secret)}
+	for i := range secrets {
+		r.secrets[secrets[i].ID] = &secrets[i]
+	}
+	return r
+}
+
+func (r *fakeRepo) GetUser(_ context.Context, id int64) (*model.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[id]
+	if !ok {
+		return nil, storage.ErrUserNotFound
+	}
+	copied := *user
+	return &copied, nil
+}
+
+// UpdateUserKeys leaves sessions to the session storage of the test server.
+func (r *fakeRepo) UpdateUserKeys(_ context.Context, user *model.User, _ string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.users[user.ID]; !ok {
+		return storage.ErrUserNotFound
+	}
+	copied := *user
+	r.users[user.ID] = &copied
+	return nil
+}
+
+func (r *fakeRepo) owned(userID, id int64) (*model.Secret, error) {
+	secret, ok := r.secrets[id]
+	if !ok || secret.UserID != userID {
+		return nil, storage.ErrSecretNotFound
+	}
+	return secret, nil
+}
+
+func (r *fakeRepo) GetSecret(_ context.Context, userID, id int64) (*model.Secret, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	secret, err := r.owned(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	copied := *secret
+	return &copied, nil
+}
+
+func (r *fakeRepo) UpdateSecret(_ context.Context, userID, id int64, data *model.Secret) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	secret, err := r.owned(userID, id)
+	if err != nil {
+		return 0, err
+	}
+	if data.Revision != secret.Revision {
+		return 0, storage.ErrRevisionMismatch
+	}
+	secret.Payload, secret.Meta, secret.Key, secret.UUID = data.Payload, data.Meta, data.Key, data.UUID
+	secret.Revision++
+	return secret.Revision, nil
+}
+
+func (r *fakeRepo) DeleteSecret(_ context.Context, userID, id, revision int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	secret, err := r.owned(userID, id)
+	if err != nil {
+		return err
+	}
+	if revision != secret.Revision {
+		return storage.ErrRevisionMismatch
+	}
+	delete(r.secrets, id)
+	return nil
+}
+
+func (r *fakeRepo) AddAuditEvent(context.Context, *model.AuditEvent) error {
+	return nil
+}
+
+// newTestServer returns a server over repo together with sessions of the
+// given users, in order.
+func newTestServer(t *testing.T, repo Repository, users ...int64) (*Server, []string) {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	sessions := session.NewSessionStorage(ctx)
+	s, err := NewServer(repo, sessions, &Config{})
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+
+	sids := make([]string, len(users))
+	for i, uid := range users {
+		if sids[i], err = sessions.Set(ctx, uid, "test"); err != nil {
+			t.Fatalf("session: %v", err)
+		}
+	}
+
+	return s, sids
+}
+
+func serve(s *Server, sid, method, target, body string, header map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set("Authorization", sid)
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+
+	res := httptest.NewRecorder()
+	s.srv.Handler.ServeHTTP(res, req)
+	return res
+}
+
+// Another user's secret must be indistinguishable from a missing one.
+func TestSecretOfOtherUserIsNotFound(t *testing.T) {
+	const (
+		alice = 1
+		bob   = 2
+	)
+
+	owned := model.Secret{ID: 10, UserID: alice, Name: "mail", Type: model.TextType, Payload: []byte("p"), Revision: 1}
+	repo := newFakeRepo(owned)
+	s, sids := newTestServer(t, repo, alice, bob)
+
+	ifMatch := map[string]string{"If-Match": `"1"`}
+	tests := []struct {
+		name   string
+		method string
+		body   string
+		header map[string]string
+	}{
+		{name: "get", method: http.MethodGet},
+		{name: "update", method: http.MethodPut, body: `{"payload":"eA=="}`, header: ifMatch},
+		{name: "update with stale revision", method: http.MethodPut, body: `{"payload":"eA=="}`,
+			header: map[string]string{"If-Match": `"7"`}},
+		{name: "delete", method: http.MethodDelete, header: ifMatch},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			missing := serve(s, sids[1], tt.method, "/api/secret/999", tt.body, tt.header)
+			foreign := serve(s, sids[1], tt.method, "/api/secret/10", tt.body, tt.header)
+
+			if missing.Code != http.StatusNotFound {
+				t.Fatalf("missing secret: status %d, want %d", missing.Code, http.StatusNotFound)
+			}
+			if foreign.Code != missing.Code {
+				t.Errorf("foreign secret: status %d, want %d", foreign.Code, missing.Code)
+			}
+			if foreign.Body.String() != missing.Body.String() {
+				t.Errorf("foreign secret: body %q, want %q", foreign.Body.String(), missing.Body.String())
+			}
+			if etag := foreign.Header().Get("ETag"); etag != "" {
+				t.Errorf("foreign secret: ETag %s leaks the revision", etag)
+			}
+		})
+	}
+
+	// the owner's secret is untouched
+	got, err := repo.GetSecret(context.Background(), alice, owned.ID)
+	if err != nil {
+		t.Fatalf("owner lost the secret: %v", err)
+	}
+	if got.Revision != 1 || string(got.Payload) != "p" {
+		t.Errorf("secret changed by another user: revision %d, payload %q", got.Revision, got.Payload)
+	}
+
+	res := serve(s, sids[0], http.MethodGet, "/api/secret/10", "", nil)
+	if res.Code != http.StatusOK {
+		body, _ := io.ReadAll(res.Body)
+		t.Fatalf("owner: status %d: %s", res.Code, body)
+	}
+	if etag := res.Header().Get("ETag"); etag != `"1"` {
+		t.Errorf("owner: ETag %s, want %q", etag, `"1"`)
+	}
+}
+
+// Updates and deletes apply only to the revision the client has seen; a
+// stale one gets the current revision back to reload from.
+func TestSecretRevisionPreconditions(t *testing.T) {
+	const alice = 1
+
+	repo := newFakeRepo(model.Secret{ID: 10, UserID: alice, Name: "mail", Type: model.TextType,
+		Payload: []byte("p"), Revision: 1})
+	s, sids := newTestServer(t, repo, alice)
+
+	steps := []struct {
+		name     string
+		method   string
+		ifMatch  string
+		wantCode int
+		wantETag string
+		wantRev  int64
+	}{
+		{name: "update without If-Match", method: http.MethodPut, wantCode: http.StatusPreconditionRequired},
+		{name: "update with invalid If-Match", method: http.MethodPut, ifMatch: `"x"`,
+			wantCode: http.StatusPreconditionRequired},
+		{name: "update stale revision", method: http.MethodPut, ifMatch: `"7"`,
+			wantCode: http.StatusPreconditionFailed, wantETag: `"1"`, wantRev: 1},
+		{name: "update", method: http.MethodPut, ifMatch: `"1"`, wantCode: http.StatusOK, wantETag: `"2"`, wantRev: 2},
+		{name: "update same revision again", method: http.MethodPut, ifMatch: `"1"`,
+			wantCode: http.StatusPreconditionFailed, wantETag: `"2"`, wantRev: 2},
+		{name: "delete without If-Match", method: http.MethodDelete, wantCode: http.StatusPreconditionRequired},
+		{name: "delete stale revision", method: http.MethodDelete, ifMatch: `"1"`,
+			wantCode: http.StatusPreconditionFailed, wantETag: `"2"`, wantRev: 2},
+		{name: "delete", method: http.MethodDelete, ifMatch: `W/"2"`, wantCode: http.StatusNoContent},
+		{name: "delete again", method: http.MethodDelete, ifMatch: `"2"`, wantCode: http.StatusNotFound},
+	}
+
+	for _, step := range steps {
+		header := map[string]string{}
+		if step.ifMatch != "" {
+			header["If-Match"] = step.ifMatch
+		}
+
+		res := serve(s, sids[0], step.method, "/api/secret/10", `{"type":2,"payload":"eA=="}`, header)
+		if res.Code != step.wantCode {
+			t.Fatalf("%s: status %d, want %d: %s", step.name, res.Code, step.wantCode, res.Body)
+		}
+		if etag := res.Header().Get("ETag"); etag != step.wantETag {
+			t.Errorf("%s: ETag %s, want %s", step.name, etag, step.wantETag)
+		}
+		if step.wantRev == 0 {
+			continue
+		}
+
+		var body struct {
+			Revision int64 `json:"revision"`
+		}
+		if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+			t.Fatalf("%s: decode: %v", step.name, err)
+		}
+		if body.Revision != step.wantRev {
+			t.Errorf("%s: revision %d, want %d", step.name, body.Revision, step.wantRev)
+		}
+	}
+}
+
+func TestSyncRejectsInvalidCursor(t *testing.T) {
+	s, sids := newTestServer(t, newFakeRepo(), 1)
+
+	for _, since := range []string{"-1", "x"} {
+		res := serve(s, sids[0], http.MethodGet, "/api/sync?since="+since, "", nil)
+		if res.Code != http.StatusBadRequest {
+			t.Errorf("since=%s: status %d, want %d", since, res.Code, http.StatusBadRequest)
+		}
+	}
+}
+
+// Changing the keys ends all other sessions of the caller and applies to
+// the caller only, whatever login the request names.
+func TestUpdateKeysRevokesOtherSessions(t *testing.T) {
+	const (
+		alice = 1
+		bob   = 2
+	)
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("old"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := newFakeRepo()
+	repo.users[alice] = &model.User{ID: alice, Login: "alice", PasswordHash: string(hash)}
+	repo.users[bob] = &model.User{ID: bob, Login: "bob", PasswordHash: string(hash)}
+
+	// two sessions of alice, one of bob
+	s, sids := newTestServer(t, repo, alice, alice, bob)
+
+	body := `{"login":"bob","current_password":"old","password":"new","salt":"cw==","vault_key":"dg==","public_key":"cA=="}`
+	if res := serve(s, sids[0], http.MethodPut, "/api/user/keys", body, nil); res.Code != http.StatusNoContent {
+		t.Fatalf("update keys: status %d: %s", res.Code, res.Body.String())
+	}
+
+	if got := repo.users[alice].Salt; string(got) != "s" {
+		t.Errorf("keys of the caller not changed: salt %q", got)
+	}
+	if got := repo.users[bob]; got.Salt != nil || got.PasswordHash != string(hash) {
+		t.Error("keys of the user named in the request changed")
+	}
+
+	tests := []struct {
+		name string
+		sid  string
+		want bool
+	}{
+		{name: "current session", sid: sids[0], want: true},
+		{name: "other session", sid: sids[1], want: false},
+		{name: "session of another user", sid: sids[2], want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, ok := s.session.Get(context.Background(), tt.sid); ok != tt.want {
+				t.Errorf("session alive %v, want %v", ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestSessionEndpoints(t *testing.T) {
+	const (
+		alice = 1
+		bob   = 2
+	)
+
+	s, sids := newTestServer(t, newFakeRepo(), alice, alice, bob)
+
+	list := func(sid string) []model.Session {
+		t.Helper()
+
+		res := serve(s, sid, http.MethodGet, "/api/user/sessions", "", nil)
+		if res.Code != http.StatusOK {
+			t.Fatalf("list sessions: status %d", res.Code)
+		}
+		var sessions []model.Session
+		if err := json.NewDecoder(res.Body).Decode(&sessions); err != nil {
+			t.Fatalf("list sessions: %v", err)
+		}
+		return sessions
+	}
+
+	sessions := list(sids[0])
+	if len(sessions) != 2 || !sessions[0].Current || sessions[1].Current {
+		t.Fatalf("sessions of alice: %+v", sessions)
+	}
+	bobs := list(sids[2])
+
+	revoke := func(sid string, id int64) int {
+		return serve(s, sid, http.MethodDelete, fmt.Sprintf("/api/user/sessions/%d", id), "", nil).Code
+	}
+	if code := revoke(sids[0], bobs[0].ID); code != http.StatusNotFound {
+		t.Errorf("revoke a session of another user: status %d, want %d", code, http.StatusNotFound)
+	}
+	if code := revoke(sids[0], sessions[1].ID); code != http.StatusNoContent {
+		t.Fatalf("revoke: status %d", code)
+	}
+
+	if code := serve(s, sids[0], http.MethodPost, "/api/user/logout", "", nil).Code; code != http.StatusNoContent {
+		t.Fatalf("logout: status %d", code)
+	}
+
+	tests := []struct {
+		name string
+		sid  string
+		want int
+	}{
+		{name: "after logout", sid: sids[0], want: http.StatusUnauthorized},
+		{name: "revoked", sid: sids[1], want: http.StatusUnauthorized},
+		{name: "other user", sid: sids[2], want: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code := serve(s, tt.sid, http.MethodGet, "/api/user/sessions", "", nil).Code; code != tt.want {
+				t.Errorf("status %d, want %d", code, tt.want)
+			}
+		})
+	}
+}
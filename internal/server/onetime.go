@@ -0,0 +1,134 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	gonanoid "github.com/matoous/go-nanoid/v2"
+	"github.com/nbvehbq/go-password-keeper/internal/logger"
+	"github.com/nbvehbq/go-password-keeper/internal/model"
+	"github.com/nbvehbq/go-password-keeper/internal/storage"
+	"go.uber.org/zap"
+)
+
+const (
+	maxOneTimeViews = 100
+	maxOneTimeTTL   = time.Hour * 24 * 7
+	maxOneTimeSize  = 64 << 10
+
+	// reapInterval is how often ReapOneTimeSecrets deletes expired secrets.
+	reapInterval = time.Minute * 10
+)
+
+// newOneTimeSecret checks a one-time secret to be created by userID and
+// gives it a random ID.
+func newOneTimeSecret(userID int64, dto *model.OneTimeSecret) (*model.OneTimeSecret, error) {
+	now := time.Now()
+
+	switch {
+	case len(dto.Payload) == 0 || len(dto.Payload) > maxOneTimeSize:
+		return nil, fmt.Errorf("payload must be 1 to %d bytes", maxOneTimeSize)
+	case dto.MaxViews < 1 || dto.MaxViews > maxOneTimeViews:
+		return nil, fmt.Errorf("max views must be 1 to %d", maxOneTimeViews)
+	case !dto.ExpiresAt.After(now) || dto.ExpiresAt.After(now.Add(maxOneTimeTTL)):
+		return nil, fmt.Errorf("expiry must be within %s", maxOneTimeTTL)
+	}
+
+	id, err := gonanoid.New()
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.OneTimeSecret{
+		ID:        id,
+		UserID:    userID,
+		Payload:   dto.Payload,
+		MaxViews:  dto.MaxViews,
+		ExpiresAt: dto.ExpiresAt,
+	}, nil
+}
+
+func (s *Server) createOneTimeHandler(res http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	var dto model.OneTimeSecret
+	if err := json.NewDecoder(http.MaxBytesReader(res, req.Body, 2*maxOneTimeSize)).Decode(&dto); err != nil {
+		JSONError(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	secret, err := newOneTimeSecret(UID(ctx), &dto)
+	if err != nil {
+		JSONError(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := s.storage.CreateOneTimeSecret(ctx, secret); err != nil {
+		JSONError(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(http.StatusCreated)
+
+	value := struct {
+		ID        string    `json:"id"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}{ID: secret.ID, ExpiresAt: secret.ExpiresAt}
+
+	if err := json.NewEncoder(res).Encode(value); err != nil {
+		JSONError(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+}
+
+// getOneTimeHandler serves a one-time secret without a session. Every
+// request counts as a view, the last one deletes the secret.
+func (s *Server) getOneTimeHandler(res http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	secret, err := s.storage.TakeOneTimeSecret(ctx, chi.URLParam(req, "id"))
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrOneTimeNotFound):
+			JSONError(res, err.Error(), http.StatusNotFound)
+		default:
+			JSONError(res, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	res.Header().Set("Cache-Control", "no-store")
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(res).Encode(secret); err != nil {
+		JSONError(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+}
+
+// ReapOneTimeSecrets deletes expired one-time secrets every reapInterval
+// until ctx is done. Expired secrets can't be read in the meantime either.
+func ReapOneTimeSecrets(ctx context.Context, repo Repository) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(reapInterval):
+			n, err := repo.DeleteExpiredOneTimeSecrets(ctx)
+			if err != nil {
+				logger.Log.Error("reap one-time secrets", zap.Error(err))
+				continue
+			}
+			if n > 0 {
+				logger.Log.Info("reaped one-time secrets", zap.Int64("count", n))
+			}
+		}
+	}
+}
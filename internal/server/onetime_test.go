@@ -0,0 +1,136 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nbvehbq/go-password-keeper/internal/model"
+	"github.com/nbvehbq/go-password-keeper/internal/storage"
+)
+
+// fakeOneTimeRepo keeps one-time secrets in memory. Methods the tests don't
+// use panic through the nil embedded interface.
+type fakeOneTimeRepo struct {
+	Repository
+
+	mu      sync.Mutex
+	secrets map[string]*model.OneTimeSecret
+}
+
+func (r *fakeOneTimeRepo) CreateOneTimeSecret(_ context.Context, secret *model.OneTimeSecret) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	copied := *secret
+	r.secrets[secret.ID] = &copied
+	return nil
+}
+
+func (r *fakeOneTimeRepo) TakeOneTimeSecret(_ context.Context, id string) (*model.OneTimeSecret, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	secret, ok := r.secrets[id]
+	if !ok {
+		return nil, storage.ErrOneTimeNotFound
+	}
+	secret.Views++
+	if secret.Views >= secret.MaxViews {
+		delete(r.secrets, id)
+	}
+	copied := *secret
+	return &copied, nil
+}
+
+func TestNewOneTimeSecret(t *testing.T) {
+	hour := time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name    string
+		dto     model.OneTimeSecret
+		wantErr bool
+	}{
+		{name: "valid", dto: model.OneTimeSecret{Payload: []byte("p"), MaxViews: 1, ExpiresAt: hour}},
+		{name: "most views", dto: model.OneTimeSecret{Payload: []byte("p"), MaxViews: maxOneTimeViews, ExpiresAt: hour}},
+		{name: "no payload", dto: model.OneTimeSecret{MaxViews: 1, ExpiresAt: hour}, wantErr: true},
+		{name: "large payload", dto: model.OneTimeSecret{Payload: make([]byte, maxOneTimeSize+1), MaxViews: 1,
+			ExpiresAt: hour}, wantErr: true},
+		{name: "no views", dto: model.OneTimeSecret{Payload: []byte("p"), ExpiresAt: hour}, wantErr: true},
+		{name: "too many views", dto: model.OneTimeSecret{Payload: []byte("p"), MaxViews: maxOneTimeViews + 1,
+			ExpiresAt: hour}, wantErr: true},
+		{name: "expired", dto: model.OneTimeSecret{Payload: []byte("p"), MaxViews: 1,
+			ExpiresAt: time.Now().Add(-time.Second)}, wantErr: true},
+		{name: "too late", dto: model.OneTimeSecret{Payload: []byte("p"), MaxViews: 1,
+			ExpiresAt: time.Now().Add(maxOneTimeTTL + time.Hour)}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			secret, err := newOneTimeSecret(1, &tt.dto)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if secret.ID == "" || secret.UserID != 1 || secret.Views != 0 {
+				t.Errorf("secret %+v", secret)
+			}
+		})
+	}
+}
+
+// A one-time secret is created with a session and read without one until
+// its views are used up.
+func TestOneTimeSecret(t *testing.T) {
+	const alice = 1
+
+	repo := &fakeOneTimeRepo{secrets: make(map[string]*model.OneTimeSecret)}
+	s, sids := newTestServer(t, repo, alice)
+
+	body := fmt.Sprintf(`{"payload":"c2VhbGVk","max_views":2,"expires_at":%q}`,
+		time.Now().Add(time.Hour).Format(time.RFC3339))
+
+	if res := serve(s, "", http.MethodPost, "/api/onetime", body, nil); res.Code != http.StatusUnauthorized {
+		t.Errorf("create without a session: got %d, want %d", res.Code, http.StatusUnauthorized)
+	}
+
+	res := serve(s, sids[0], http.MethodPost, "/api/onetime", body, nil)
+	if res.Code != http.StatusCreated {
+		t.Fatalf("create: got %d, want %d: %s", res.Code, http.StatusCreated, res.Body)
+	}
+	var created model.OneTimeSecret
+	if err := json.NewDecoder(res.Body).Decode(&created); err != nil || created.ID == "" {
+		t.Fatalf("create: %+v, %v", created, err)
+	}
+	if repo.secrets[created.ID].UserID != alice {
+		t.Errorf("created by user %d, want %d", repo.secrets[created.ID].UserID, alice)
+	}
+
+	for i := 1; i <= 2; i++ {
+		res := serve(s, "", http.MethodGet, "/api/onetime/"+created.ID, "", nil)
+		if res.Code != http.StatusOK {
+			t.Fatalf("view %d: got %d, want %d", i, res.Code, http.StatusOK)
+		}
+		if cc := res.Header().Get("Cache-Control"); cc != "no-store" {
+			t.Errorf("view %d: Cache-Control %q, want no-store", i, cc)
+		}
+		if !strings.Contains(res.Body.String(), `"c2VhbGVk"`) {
+			t.Errorf("view %d: body %s", i, res.Body)
+		}
+	}
+	if res := serve(s, "", http.MethodGet, "/api/onetime/"+created.ID, "", nil); res.Code != http.StatusNotFound {
+		t.Errorf("view after the last one: got %d, want %d", res.Code, http.StatusNotFound)
+	}
+
+	res = serve(s, sids[0], http.MethodPost, "/api/onetime", `{"payload":"cA==","max_views":0}`, nil)
+	if res.Code != http.StatusBadRequest {
+		t.Errorf("create without views: got %d, want %d", res.Code, http.StatusBadRequest)
+	}
+}
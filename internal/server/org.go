@@ -0,0 +1,508 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/nbvehbq/go-password-keeper/internal/model"
+	"github.com/nbvehbq/go-password-keeper/internal/storage"
+)
+
+// orgError answers errors of the organization routes.
+func orgError(res http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, storage.ErrOrgNotFound), errors.Is(err, storage.ErrMemberNotFound),
+		errors.Is(err, storage.ErrCollectionNotFound), errors.Is(err, storage.ErrSecretNotFound):
+		JSONError(res, err.Error(), http.StatusNotFound)
+	case errors.Is(err, storage.ErrMemberExists), errors.Is(err, storage.ErrCollectionExists),
+		errors.Is(err, storage.ErrCollectionNotEmpty), errors.Is(err, storage.ErrSecretExists),
+		errors.Is(err, storage.ErrLastOwner):
+		JSONError(res, err.Error(), http.StatusConflict)
+	case errors.Is(err, storage.ErrUserNotFound):
+		JSONError(res, err.Error(), http.StatusUnprocessableEntity)
+	default:
+		JSONError(res, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// canManage reports whether a member with role caller may grant role or
+// change members having it: admins manage members and read-only members,
+// owners manage everyone.
+func canManage(caller, role model.Role) bool {
+	return caller == model.RoleOwner || role < model.RoleAdmin
+}
+
+func (s *Server) listOrgsHandler(res http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	list, err := s.storage.ListOrganizations(ctx, UID(ctx))
+	if err != nil {
+		JSONError(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(res).Encode(list); err != nil {
+		JSONError(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+}
+
+// createOrgHandler creates an organization owned by the caller. The client
+// generates its key pair and sends the private key wrapped for the caller.
+func (s *Server) createOrgHandler(res http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	var dto model.Organization
+	if err := json.NewDecoder(req.Body).Decode(&dto); err != nil {
+		JSONError(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if dto.Name == "" || len(dto.PublicKey) == 0 || len(dto.Key) == 0 {
+		JSONError(res, "name, public key and key required", http.StatusBadRequest)
+		return
+	}
+
+	id, err := s.storage.CreateOrganization(ctx, &model.Organization{
+		Name:      dto.Name,
+		PublicKey: dto.PublicKey,
+		Key:       dto.Key,
+	}, UID(ctx))
+	if err != nil {
+		orgError(res, err)
+		return
+	}
+
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(http.StatusCreated)
+
+	value := struct {
+		ID int64 `json:"id"`
+	}{ID: id}
+
+	if err := json.NewEncoder(res).Encode(value); err != nil {
+		JSONError(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+}
+
+func (s *Server) getOrgHandler(res http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	org, err := s.storage.GetOrganization(ctx, OrgID(ctx), UID(ctx))
+	if err != nil {
+		orgError(res, err)
+		return
+	}
+
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(res).Encode(org); err != nil {
+		JSONError(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+}
+
+func (s *Server) deleteOrgHandler(res http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	if err := s.storage.DeleteOrganization(ctx, OrgID(ctx)); err != nil {
+		orgError(res, err)
+		return
+	}
+
+	res.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) listMembersHandler(res http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	list, err := s.storage.ListMembers(ctx, OrgID(ctx))
+	if err != nil {
+		JSONError(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(res).Encode(list); err != nil {
+		JSONError(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+}
+
+// addMemberHandler adds a user with the organization key wrapped for their
+// public key.
+func (s *Server) addMemberHandler(res http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	var dto model.Member
+	if err := json.NewDecoder(req.Body).Decode(&dto); err != nil {
+		JSONError(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !dto.Role.Valid() || len(dto.Key) == 0 {
+		JSONError(res, "invalid role or key", http.StatusBadRequest)
+		return
+	}
+	if !canManage(Role(ctx), dto.Role) {
+		JSONError(res, "requires the owner role", http.StatusForbidden)
+		return
+	}
+
+	err := s.storage.AddMember(ctx, &model.Member{
+		OrgID:  OrgID(ctx),
+		UserID: dto.UserID,
+		Role:   dto.Role,
+		Key:    dto.Key,
+	})
+	if err != nil {
+		orgError(res, err)
+		return
+	}
+
+	res.WriteHeader(http.StatusNoContent)
+}
+
+// memberParam returns the member in the {user} URL parameter if the caller
+// may manage them, answering the request otherwise.
+func (s *Server) memberParam(res http.ResponseWriter, req *http.Request) (*model.Member, bool) {
+	ctx := req.Context()
+
+	userID, err := strconv.Atoi(chi.URLParam(req, "user"))
+	if err != nil {
+		JSONError(res, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+
+	member, err := s.storage.GetMember(ctx, OrgID(ctx), int64(userID))
+	if err != nil {
+		orgError(res, err)
+		return nil, false
+	}
+	if !canManage(Role(ctx), member.Role) {
+		JSONError(res, "requires the owner role", http.StatusForbidden)
+		return nil, false
+	}
+
+	return member, true
+}
+
+func (s *Server) updateMemberHandler(res http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	member, ok := s.memberParam(res, req)
+	if !ok {
+		return
+	}
+
+	var dto model.Member
+	if err := json.NewDecoder(req.Body).Decode(&dto); err != nil {
+		JSONError(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !dto.Role.Valid() {
+		JSONError(res, "invalid role", http.StatusBadRequest)
+		return
+	}
+	if !canManage(Role(ctx), dto.Role) {
+		JSONError(res, "requires the owner role", http.StatusForbidden)
+		return
+	}
+
+	if err := s.storage.UpdateMemberRole(ctx, OrgID(ctx), member.UserID, dto.Role); err != nil {
+		orgError(res, err)
+		return
+	}
+
+	res.WriteHeader(http.StatusNoContent)
+}
+
+// deleteMemberHandler removes a member. Secrets they could read stay
+// readable with a kept copy of the organization key, rotating it is up to
+// the clients.
+func (s *Server) deleteMemberHandler(res http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	member, ok := s.memberParam(res, req)
+	if !ok {
+		return
+	}
+
+	if err := s.storage.DeleteMember(ctx, OrgID(ctx), member.UserID); err != nil {
+		orgError(res, err)
+		return
+	}
+
+	res.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) listCollectionsHandler(res http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	list, err := s.storage.ListCollections(ctx, OrgID(ctx))
+	if err != nil {
+		JSONError(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(res).Encode(list); err != nil {
+		JSONError(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+}
+
+func (s *Server) createCollectionHandler(res http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	var dto model.Collection
+	if err := json.NewDecoder(req.Body).Decode(&dto); err != nil {
+		JSONError(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if dto.Name == "" {
+		JSONError(res, "name required", http.StatusBadRequest)
+		return
+	}
+
+	id, err := s.storage.CreateCollection(ctx, &model.Collection{OrgID: OrgID(ctx), Name: dto.Name})
+	if err != nil {
+		orgError(res, err)
+		return
+	}
+
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(http.StatusCreated)
+
+	value := struct {
+		ID int64 `json:"id"`
+	}{ID: id}
+
+	if err := json.NewEncoder(res).Encode(value); err != nil {
+		JSONError(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+}
+
+func (s *Server) deleteCollectionHandler(res http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	id, err := strconv.Atoi(chi.URLParam(req, "id"))
+	if err != nil {
+		JSONError(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := s.storage.DeleteCollection(ctx, OrgID(ctx), int64(id)); err != nil {
+		orgError(res, err)
+		return
+	}
+
+	res.WriteHeader(http.StatusNoContent)
+}
+
+// listOrgSecretsHandler lists the secrets of an organization, of one
+// collection if the collection query parameter is set.
+func (s *Server) listOrgSecretsHandler(res http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	var collectionID int64
+	if param := req.URL.Query().Get("collection"); param != "" {
+		var err error
+		if collectionID, err = strconv.ParseInt(param, 10, 64); err != nil {
+			JSONError(res, "invalid collection", http.StatusBadRequest)
+			return
+		}
+	}
+
+	list, err := s.storage.ListOrgSecrets(ctx, OrgID(ctx), collectionID)
+	if err != nil {
+		JSONError(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(res).Encode(list); err != nil {
+		JSONError(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+}
+
+func (s *Server) getOrgSecretHandler(res http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	id, err := strconv.Atoi(chi.URLParam(req, "id"))
+	if err != nil {
+		JSONError(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	secret, err := s.storage.GetOrgSecret(ctx, OrgID(ctx), int64(id))
+	if err != nil {
+		orgError(res, err)
+		return
+	}
+
+	res.Header().Set("ETag", etag(secret.Revision))
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(res).Encode(secret); err != nil {
+		JSONError(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+}
+
+func (s *Server) createOrgSecretHandler(res http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	var dto model.OrgSecret
+	if err := json.NewDecoder(req.Body).Decode(&dto); err != nil {
+		JSONError(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	id, err := s.storage.CreateOrgSecret(ctx, &model.OrgSecret{
+		OrgID:        OrgID(ctx),
+		CollectionID: dto.CollectionID,
+		Name:         dto.Name,
+		Type:         dto.Type,
+		Payload:      dto.Payload,
+		Meta:         dto.Meta,
+		Key:          dto.Key,
+		UpdatedBy:    UID(ctx),
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrCollectionNotFound):
+			JSONError(res, err.Error(), http.StatusUnprocessableEntity)
+		default:
+			orgError(res, err)
+		}
+		return
+	}
+
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(http.StatusCreated)
+
+	value := struct {
+		ID int64 `json:"id"`
+	}{ID: id}
+
+	if err := json.NewEncoder(res).Encode(value); err != nil {
+		JSONError(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+}
+
+func (s *Server) updateOrgSecretHandler(res http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	var dto model.OrgSecret
+
+	id, err := strconv.Atoi(chi.URLParam(req, "id"))
+	if err != nil {
+		JSONError(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	revision, err := ifMatch(req)
+	if err != nil {
+		JSONError(res, err.Error(), http.StatusPreconditionRequired)
+		return
+	}
+
+	if err := json.NewDecoder(req.Body).Decode(&dto); err != nil {
+		JSONError(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+	dto.Revision = revision
+	dto.UpdatedBy = UID(ctx)
+
+	ret, err := s.storage.UpdateOrgSecret(ctx, OrgID(ctx), int64(id), &dto)
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrRevisionMismatch):
+			s.orgRevisionConflict(res, req, int64(id))
+		default:
+			orgError(res, err)
+		}
+		return
+	}
+
+	res.Header().Set("ETag", etag(ret))
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(http.StatusOK)
+
+	value := struct {
+		ID       int64 `json:"id"`
+		Revision int64 `json:"revision"`
+	}{ID: int64(id), Revision: ret}
+
+	if err := json.NewEncoder(res).Encode(value); err != nil {
+		JSONError(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+}
+
+func (s *Server) deleteOrgSecretHandler(res http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	id, err := strconv.Atoi(chi.URLParam(req, "id"))
+	if err != nil {
+		JSONError(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	revision, err := ifMatch(req)
+	if err != nil {
+		JSONError(res, err.Error(), http.StatusPreconditionRequired)
+		return
+	}
+
+	if err := s.storage.DeleteOrgSecret(ctx, OrgID(ctx), int64(id), revision); err != nil {
+		switch {
+		case errors.Is(err, storage.ErrRevisionMismatch):
+			s.orgRevisionConflict(res, req, int64(id))
+		default:
+			orgError(res, err)
+		}
+		return
+	}
+
+	res.WriteHeader(http.StatusNoContent)
+}
+
+// orgRevisionConflict answers 412 like revisionConflict for a secret of an
+// organization.
+func (s *Server) orgRevisionConflict(res http.ResponseWriter, req *http.Request, id int64) {
+	ctx := req.Context()
+
+	secret, err := s.storage.GetOrgSecret(ctx, OrgID(ctx), id)
+	if err != nil {
+		orgError(res, err)
+		return
+	}
+
+	res.Header().Set("ETag", etag(secret.Revision))
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(http.StatusPreconditionFailed)
+
+	value := struct {
+		Err      string `json:"error"`
+		Revision int64  `json:"revision"`
+	}{Err: storage.ErrRevisionMismatch.Error(), Revision: secret.Revision}
+
+	json.NewEncoder(res).Encode(value)
+}
@@ -0,0 +1,259 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/nbvehbq/go-password-keeper/internal/model"
+	"github.com/nbvehbq/go-password-keeper/internal/storage"
+)
+
+// fakeOrgRepo keeps a single organization with one secret in memory.
+// Methods the tests don't use panic through the nil embedded interface.
+type fakeOrgRepo struct {
+	Repository
+
+	mu      sync.Mutex
+	orgID   int64
+	members map[int64]model.Role
+	secret  model.OrgSecret
+}
+
+func newFakeOrgRepo(orgID int64, members map[int64]model.Role) *fakeOrgRepo {
+	return &fakeOrgRepo{
+		orgID:   orgID,
+		members: members,
+		secret:  model.OrgSecret{ID: 20, OrgID: orgID, Name: "deploy key", Type: model.TextType, Revision: 1},
+	}
+}
+
+func (r *fakeOrgRepo) GetMember(_ context.Context, orgID, userID int64) (*model.Member, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	role, ok := r.members[userID]
+	if orgID != r.orgID || !ok {
+		return nil, storage.ErrMemberNotFound
+	}
+	return &model.Member{OrgID: orgID, UserID: userID, Role: role}, nil
+}
+
+func (r *fakeOrgRepo) GetOrganization(_ context.Context, id, userID int64) (*model.Organization, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return &model.Organization{ID: id, Name: "team", Role: r.members[userID]}, nil
+}
+
+func (r *fakeOrgRepo) DeleteOrganization(context.Context, int64) error {
+	return nil
+}
+
+func (r *fakeOrgRepo) AddMember(_ context.Context, member *model.Member) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.members[member.UserID]; ok {
+		return storage.ErrMemberExists
+	}
+	r.members[member.UserID] = member.Role
+	return nil
+}
+
+// lastOwner reports whether userID is the only owner.
+func (r *fakeOrgRepo) lastOwner(userID int64) bool {
+	for id, role := range r.members {
+		if id != userID && role == model.RoleOwner {
+			return false
+		}
+	}
+	return r.members[userID] == model.RoleOwner
+}
+
+func (r *fakeOrgRepo) UpdateMemberRole(_ context.Context, _, userID int64, role model.Role) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if role != model.RoleOwner && r.lastOwner(userID) {
+		return storage.ErrLastOwner
+	}
+	r.members[userID] = role
+	return nil
+}
+
+func (r *fakeOrgRepo) DeleteMember(_ context.Context, _, userID int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.lastOwner(userID) {
+		return storage.ErrLastOwner
+	}
+	delete(r.members, userID)
+	return nil
+}
+
+func (r *fakeOrgRepo) CreateCollection(context.Context, *model.Collection) (int64, error) {
+	return 1, nil
+}
+
+func (r *fakeOrgRepo) CreateOrgSecret(context.Context, *model.OrgSecret) (int64, error) {
+	return 21, nil
+}
+
+func (r *fakeOrgRepo) GetOrgSecret(_ context.Context, _, id int64) (*model.OrgSecret, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if id != r.secret.ID {
+		return nil, storage.ErrSecretNotFound
+	}
+	secret := r.secret
+	return &secret, nil
+}
+
+func (r *fakeOrgRepo) UpdateOrgSecret(_ context.Context, _, id int64, data *model.OrgSecret) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if id != r.secret.ID {
+		return 0, storage.ErrSecretNotFound
+	}
+	if data.Revision != r.secret.Revision {
+		return 0, storage.ErrRevisionMismatch
+	}
+	r.secret.Revision++
+	return r.secret.Revision, nil
+}
+
+func (r *fakeOrgRepo) DeleteOrgSecret(_ context.Context, _, id, revision int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if id != r.secret.ID {
+		return storage.ErrSecretNotFound
+	}
+	if revision != r.secret.Revision {
+		return storage.ErrRevisionMismatch
+	}
+	return nil
+}
+
+// Every route of an organization requires a role: read-only members only
+// read, members also write secrets, admins also manage members below admin
+// and only owners manage owners & admins or delete the organization.
+// Users outside of it can't tell it exists.
+func TestOrgRoles(t *testing.T) {
+	const (
+		owner = iota + 1
+		admin
+		member
+		reader
+		outsider
+	)
+
+	roles := map[int64]model.Role{
+		owner:  model.RoleOwner,
+		admin:  model.RoleAdmin,
+		member: model.RoleMember,
+		reader: model.RoleReadOnly,
+	}
+	names := map[int64]string{owner: "owner", admin: "admin", member: "member", reader: "read-only",
+		outsider: "outsider"}
+
+	const (
+		ok        = http.StatusOK
+		created   = http.StatusCreated
+		noContent = http.StatusNoContent
+		forbidden = http.StatusForbidden
+		conflict  = http.StatusConflict
+	)
+	ifMatch := map[string]string{"If-Match": `"1"`}
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		body   string
+		header map[string]string
+		// want maps the caller to the expected status, outsiders always
+		// get 404
+		want map[int64]int
+	}{
+		{name: "get organization", method: http.MethodGet, target: "/api/org/7",
+			want: map[int64]int{reader: ok, member: ok, admin: ok, owner: ok}},
+		{name: "get secret", method: http.MethodGet, target: "/api/org/7/secret/20",
+			want: map[int64]int{reader: ok, member: ok, admin: ok, owner: ok}},
+		{name: "create secret", method: http.MethodPost, target: "/api/org/7/secret",
+			body: `{"name":"x","type":2,"payload":"eA=="}`,
+			want: map[int64]int{reader: forbidden, member: created, admin: created, owner: created}},
+		{name: "update secret", method: http.MethodPut, target: "/api/org/7/secret/20",
+			body: `{"type":2,"payload":"eA=="}`, header: ifMatch,
+			want: map[int64]int{reader: forbidden, member: ok, admin: ok, owner: ok}},
+		{name: "delete secret", method: http.MethodDelete, target: "/api/org/7/secret/20", header: ifMatch,
+			want: map[int64]int{reader: forbidden, member: noContent, admin: noContent, owner: noContent}},
+		{name: "create collection", method: http.MethodPost, target: "/api/org/7/collection", body: `{"name":"ops"}`,
+			want: map[int64]int{reader: forbidden, member: forbidden, admin: created, owner: created}},
+		{name: "add read-only member", method: http.MethodPost, target: "/api/org/7/member",
+			body: `{"user_id":9,"role":1,"key":"eA=="}`,
+			want: map[int64]int{reader: forbidden, member: forbidden, admin: noContent, owner: noContent}},
+		{name: "add admin", method: http.MethodPost, target: "/api/org/7/member",
+			body: `{"user_id":9,"role":3,"key":"eA=="}`,
+			want: map[int64]int{reader: forbidden, member: forbidden, admin: forbidden, owner: noContent}},
+		{name: "demote member", method: http.MethodPut, target: "/api/org/7/member/3", body: `{"role":1}`,
+			want: map[int64]int{reader: forbidden, member: forbidden, admin: noContent, owner: noContent}},
+		{name: "transfer ownership", method: http.MethodPut, target: "/api/org/7/member/3", body: `{"role":4}`,
+			want: map[int64]int{reader: forbidden, member: forbidden, admin: forbidden, owner: noContent}},
+		{name: "demote admin", method: http.MethodPut, target: "/api/org/7/member/2", body: `{"role":2}`,
+			want: map[int64]int{reader: forbidden, member: forbidden, admin: forbidden, owner: noContent}},
+		{name: "remove member", method: http.MethodDelete, target: "/api/org/7/member/3",
+			want: map[int64]int{reader: forbidden, member: forbidden, admin: noContent, owner: noContent}},
+		{name: "remove admin", method: http.MethodDelete, target: "/api/org/7/member/2",
+			want: map[int64]int{reader: forbidden, member: forbidden, admin: forbidden, owner: noContent}},
+		{name: "remove last owner", method: http.MethodDelete, target: "/api/org/7/member/1",
+			want: map[int64]int{reader: forbidden, member: forbidden, admin: forbidden, owner: conflict}},
+		{name: "delete organization", method: http.MethodDelete, target: "/api/org/7",
+			want: map[int64]int{reader: forbidden, member: forbidden, admin: forbidden, owner: noContent}},
+	}
+
+	for _, tt := range tests {
+		for _, caller := range []int64{owner, admin, member, reader, outsider} {
+			t.Run(tt.name+"/"+names[caller], func(t *testing.T) {
+				members := make(map[int64]model.Role, len(roles))
+				for id, role := range roles {
+					members[id] = role
+				}
+				s, sids := newTestServer(t, newFakeOrgRepo(7, members), caller)
+
+				want, ok := tt.want[caller]
+				if !ok {
+					want = http.StatusNotFound
+				}
+
+				res := serve(s, sids[0], tt.method, tt.target, tt.body, tt.header)
+				if res.Code != want {
+					t.Errorf("status %d, want %d: %s", res.Code, want, res.Body)
+				}
+			})
+		}
+	}
+}
+
+// An organization the caller isn't a member of answers like one that
+// doesn't exist.
+func TestOrgOfNonMemberIsNotFound(t *testing.T) {
+	const outsider = 5
+
+	s, sids := newTestServer(t, newFakeOrgRepo(7, map[int64]model.Role{1: model.RoleOwner}), outsider)
+
+	foreign := serve(s, sids[0], http.MethodGet, "/api/org/7/secret/20", "", nil)
+	missing := serve(s, sids[0], http.MethodGet, "/api/org/8/secret/20", "", nil)
+
+	if foreign.Code != http.StatusNotFound || foreign.Code != missing.Code {
+		t.Errorf("status %d for a foreign organization, %d for a missing one", foreign.Code, missing.Code)
+	}
+	if foreign.Body.String() != missing.Body.String() {
+		t.Errorf("body %q for a foreign organization, %q for a missing one", foreign.Body, missing.Body)
+	}
+}
@@ -2,7 +2,9 @@ package server
 
 import (
 	"context"
+	"io"
 	"net/http"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
@@ -11,19 +13,131 @@ import (
 )
 
 type Repository interface {
-	CreateUser(ctx context.Context, login, pass string) (int64, error)
+	CreateUser(ctx context.Context, user *model.User) (int64, error)
 	GetUserByLogin(ctx context.Context, login string) (*model.User, error)
+	GetUser(ctx context.Context, id int64) (*model.User, error)
+	// UpdateUserKeys also ends all sessions of the user but currentSID
+	// kept in the repository, in the same transaction.
+	UpdateUserKeys(ctx context.Context, user *model.User, currentSID string) error
 
+	// Two-factor authentication. Recovery codes and challenge tokens are
+	// passed in the clear and must be stored hashed.
+	SetTOTPSecret(ctx context.Context, userID int64, secret []byte) error
+	EnableTOTP(ctx context.Context, userID, step int64, recoveryCodes []string) error
+	DisableTOTP(ctx context.Context, userID int64) error
+	UseTOTPStep(ctx context.Context, userID, step int64) error
+	UseRecoveryCode(ctx context.Context, userID int64, code string) error
+	CreateChallenge(ctx context.Context, userID int64, token string, expiresAt time.Time) error
+	CheckChallenge(ctx context.Context, token string, maxAttempts int) (int64, error)
+	DeleteChallenge(ctx context.Context, token string) error
+
+	// CreateSecret and PatchSecret fail with storage.ErrFolderNotFound or
+	// storage.ErrTagNotFound for folders and tags of other users.
 	CreateSecret(ctx context.Context, data *model.Secret) (int64, error)
-	ListSecrets(ctx context.Context, userID int64, param uint8) ([]model.Secret, error)
-	GetSecret(ctx context.Context, id int64) (*model.Secret, error)
-	UpdateSecret(ctx context.Context, id int64, data *model.Secret) (int64, error)
-	DeleteSecret(ctx context.Context, id int64) error
+	ListSecrets(ctx context.Context, userID int64, filter model.SecretFilter) ([]model.Secret, error)
+
+	// GetSecret, UpdateSecret and DeleteSecret are scoped by owner: a secret
+	// belonging to another user must be reported as storage.ErrSecretNotFound.
+	// UpdateSecret and DeleteSecret fail with storage.ErrRevisionMismatch when
+	// the expected revision is stale.
+	GetSecret(ctx context.Context, userID, id int64) (*model.Secret, error)
+	UpdateSecret(ctx context.Context, userID, id int64, data *model.Secret) (int64, error)
+	DeleteSecret(ctx context.Context, userID, id, revision int64) error
+
+	PatchSecret(ctx context.Context, userID, id int64, patch *model.SecretPatch) error
+
+	CreateFolder(ctx context.Context, folder *model.Folder) (int64, error)
+	ListFolders(ctx context.Context, userID int64) ([]model.Folder, error)
+	DeleteFolder(ctx context.Context, userID, id int64) error
+	CreateTag(ctx context.Context, tag *model.Tag) (int64, error)
+	ListTags(ctx context.Context, userID int64) ([]model.Tag, error)
+
+	ListSecretVersions(ctx context.Context, userID, id int64) ([]model.SecretVersion, error)
+	GetSecretVersion(ctx context.Context, userID, id, version int64) (*model.SecretVersion, error)
+	RestoreSecretVersion(ctx context.Context, userID, id, version int64) error
+
+	Changes(ctx context.Context, userID, since int64) (*model.Changes, error)
+
+	// Sharing. ShareSecret, ListShares and RekeySecret are scoped by owner
+	// like GetSecret. UpdateSharedSecret fails with storage.ErrReadOnly for
+	// secrets shared without edit permission and RekeySecret with
+	// storage.ErrSharesChanged when the recipients differ. Shares are revoked
+	// by RekeySecret only, together with replacing the data key.
+	ShareSecret(ctx context.Context, ownerID int64, share *model.Share) error
+	ListShares(ctx context.Context, ownerID, secretID int64) ([]model.Share, error)
+	ListSharedSecrets(ctx context.Context, userID int64) ([]model.SharedSecret, error)
+	UpdateSharedSecret(ctx context.Context, userID, id int64, data *model.Secret) (int64, error)
+	RekeySecret(ctx context.Context, ownerID, id int64, rekey *model.SecretRekey) (int64, error)
+
+	// Organizations. Methods taking an organization ID are not scoped by
+	// user, the Authorizer middleware checks the membership of the caller
+	// first. UpdateMemberRole and DeleteMember fail with storage.ErrLastOwner
+	// when no owner would be left.
+	CreateOrganization(ctx context.Context, org *model.Organization, ownerID int64) (int64, error)
+	ListOrganizations(ctx context.Context, userID int64) ([]model.Organization, error)
+	GetOrganization(ctx context.Context, id, userID int64) (*model.Organization, error)
+	DeleteOrganization(ctx context.Context, id int64) error
+	GetMember(ctx context.Context, orgID, userID int64) (*model.Member, error)
+	ListMembers(ctx context.Context, orgID int64) ([]model.Member, error)
+	AddMember(ctx context.Context, member *model.Member) error
+	UpdateMemberRole(ctx context.Context, orgID, userID int64, role model.Role) error
+	DeleteMember(ctx context.Context, orgID, userID int64) error
+	CreateCollection(ctx context.Context, collection *model.Collection) (int64, error)
+	ListCollections(ctx context.Context, orgID int64) ([]model.Collection, error)
+	DeleteCollection(ctx context.Context, orgID, id int64) error
+	CreateOrgSecret(ctx context.Context, data *model.OrgSecret) (int64, error)
+	ListOrgSecrets(ctx context.Context, orgID, collectionID int64) ([]model.OrgSecret, error)
+	GetOrgSecret(ctx context.Context, orgID, id int64) (*model.OrgSecret, error)
+	UpdateOrgSecret(ctx context.Context, orgID, id int64, data *model.OrgSecret) (int64, error)
+	DeleteOrgSecret(ctx context.Context, orgID, id, revision int64) error
+
+	// One-time secrets are stored under the hash of their ID.
+	// TakeOneTimeSecret counts a view and deletes the secret on its last
+	// one, failing with storage.ErrOneTimeNotFound once it is gone.
+	CreateOneTimeSecret(ctx context.Context, secret *model.OneTimeSecret) error
+	TakeOneTimeSecret(ctx context.Context, id string) (*model.OneTimeSecret, error)
+	DeleteExpiredOneTimeSecrets(ctx context.Context) (int64, error)
+
+	// Emergency access. Grants are scoped by the party named in the method.
+	// RejectEmergencyAccess fails with storage.ErrEmergencyNotPending unless
+	// a request is waiting, GetEmergencyAccess with storage.ErrEmergencyWaiting
+	// until the wait period of a request has passed.
+	CreateEmergencyGrant(ctx context.Context, grant *model.EmergencyGrant) (int64, error)
+	ListEmergencyGrants(ctx context.Context, userID int64) ([]model.EmergencyGrant, error)
+	DeleteEmergencyGrant(ctx context.Context, userID, id int64) error
+	RequestEmergencyAccess(ctx context.Context, granteeID, id int64) (*model.EmergencyGrant, error)
+	RejectEmergencyAccess(ctx context.Context, grantorID, id int64) error
+	GetEmergencyAccess(ctx context.Context, granteeID, id int64) (*model.EmergencyGrant, error)
+
+	// Audit trail. AddAuditEvent chains the event to the previous one of
+	// the user; ListAuditEvents returns the newest events older than before
+	// (all for zero), oldest first. Changes of secrets, their shares and
+	// reads of them by other users are recorded by the methods doing them,
+	// in the trail of the owner.
+	AddAuditEvent(ctx context.Context, event *model.AuditEvent) error
+	ListAuditEvents(ctx context.Context, userID, before int64, limit int) ([]model.AuditEvent, error)
+
+	// PutBlob and GetBlob store and stream the file contents of a binary
+	// secret, scoped by owner like GetSecret. PutBlob replaces the payload
+	// along with the contents under the revision check of UpdateSecret and
+	// returns the new revision and the size stored; it fails with
+	// storage.ErrNotBinary for other secrets. GetBlob also serves secrets
+	// shared with the user.
+	PutBlob(ctx context.Context, userID, id int64, data *model.Secret, r io.Reader) (int64, int64, error)
+	GetBlob(ctx context.Context, userID, id int64) (io.ReadCloser, error)
 }
 
+// SessionStorage issues and resolves session IDs. Set receives the user ID
+// and the client's user agent. RevokeOthers ends all sessions of the user
+// but currentSID.
 type SessionStorage interface {
-	Set(context.Context, int64) (string, error)
+	Set(context.Context, int64, string) (string, error)
 	Get(context.Context, string) (int64, bool)
+	Refresh(context.Context, string) error
+	Delete(context.Context, string) error
+	List(ctx context.Context, userID int64, currentSID string) ([]model.Session, error)
+	Revoke(ctx context.Context, userID, id int64) error
+	RevokeOthers(ctx context.Context, userID int64, currentSID string) error
 }
 
 // Server is a keeper server
@@ -31,16 +145,25 @@ type Server struct {
 	srv     *http.Server
 	storage Repository
 	session SessionStorage
+
+	maxBlobSize int64
 }
 
 // NewServer creates a new server
 func NewServer(storage Repository, session SessionStorage, cfg *Config) (*Server, error) {
 	r := chi.NewRouter()
 
+	tlsConf, err := tlsConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
 	s := &Server{
-		srv:     &http.Server{Addr: cfg.Address, Handler: r},
+		srv:     &http.Server{Addr: cfg.Address, Handler: r, TLSConfig: tlsConf},
 		session: session,
 		storage: storage,
+
+		maxBlobSize: cfg.MaxBlobSize,
 	}
 
 	r.Use(logger.Middleware)
@@ -50,17 +173,93 @@ func NewServer(storage Repository, session SessionStorage, cfg *Config) (*Server
 	r.Group(func(r chi.Router) {
 		r.Post(`/api/user/register`, s.registerHandler)
 		r.Post(`/api/user/login`, s.loginHandler)
+		r.Post(`/api/user/prelogin`, s.preloginHandler)
+		r.Post(`/api/user/login/2fa`, s.loginTwoFactorHandler)
+		r.Get(`/api/onetime/{id}`, s.getOneTimeHandler)
 	})
 
 	// Private routes
 	r.Group(func(r chi.Router) {
 		r.Use(Authenticator(s.session))
 
+		r.Put(`/api/user/keys`, s.updateKeysHandler)
+		r.Post(`/api/user/logout`, s.logoutHandler)
+		r.Get(`/api/user/sessions`, s.listSessionsHandler)
+		r.Delete(`/api/user/sessions/{id}`, s.revokeSessionHandler)
+		r.Post(`/api/user/2fa/enroll`, s.enrollTOTPHandler)
+		r.Post(`/api/user/2fa/verify`, s.verifyTOTPHandler)
+		r.Post(`/api/user/2fa/disable`, s.disableTOTPHandler)
+		r.Get(`/api/user/{login}/key`, s.publicKeyHandler)
+		r.Get(`/api/user/audit`, s.listAuditHandler)
+
 		r.Post(`/api/secret`, s.createSecretHandler)
 		r.Get(`/api/secret`, s.listSecretHandler)
 		r.Get(`/api/secret/{id}`, s.getSecretHandler)
 		r.Put(`/api/secret/{id}`, s.updateSecretHandler)
 		r.Delete(`/api/secret/{id}`, s.deleteSecretHandler)
+		r.Patch(`/api/secret/{id}`, s.patchSecretHandler)
+		r.Get(`/api/secret/{id}/versions`, s.listVersionsHandler)
+		r.Get(`/api/secret/{id}/versions/{version}`, s.getVersionHandler)
+		r.Post(`/api/secret/{id}/versions/{version}/restore`, s.restoreVersionHandler)
+		r.Put(`/api/secret/{id}/blob`, s.putBlobHandler)
+		r.Get(`/api/secret/{id}/blob`, s.getBlobHandler)
+		r.Get(`/api/secret/{id}/share`, s.listSharesHandler)
+		r.Post(`/api/secret/{id}/share`, s.shareSecretHandler)
+		r.Post(`/api/secret/{id}/rekey`, s.rekeySecretHandler)
+		r.Get(`/api/secret/shared`, s.listSharedSecretsHandler)
+		r.Put(`/api/secret/shared/{id}`, s.updateSharedSecretHandler)
+
+		r.Get(`/api/sync`, s.syncHandler)
+
+		r.Post(`/api/onetime`, s.createOneTimeHandler)
+
+		r.Get(`/api/emergency`, s.listEmergencyHandler)
+		r.Post(`/api/emergency`, s.createEmergencyHandler)
+		r.Delete(`/api/emergency/{id}`, s.deleteEmergencyHandler)
+		r.Post(`/api/emergency/{id}/request`, s.requestEmergencyHandler)
+		r.Post(`/api/emergency/{id}/reject`, s.rejectEmergencyHandler)
+		r.Get(`/api/emergency/{id}/vault`, s.emergencyVaultHandler)
+
+		r.Get(`/api/folder`, s.listFoldersHandler)
+		r.Post(`/api/folder`, s.createFolderHandler)
+		r.Delete(`/api/folder/{id}`, s.deleteFolderHandler)
+		r.Get(`/api/tag`, s.listTagsHandler)
+		r.Post(`/api/tag`, s.createTagHandler)
+
+		r.Get(`/api/org`, s.listOrgsHandler)
+		r.Post(`/api/org`, s.createOrgHandler)
+
+		// Organization routes, each group requires at least its role
+		r.Group(func(r chi.Router) {
+			r.Use(Authorizer(s.storage, model.RoleReadOnly))
+
+			r.Get(`/api/org/{org}`, s.getOrgHandler)
+			r.Get(`/api/org/{org}/member`, s.listMembersHandler)
+			r.Get(`/api/org/{org}/collection`, s.listCollectionsHandler)
+			r.Get(`/api/org/{org}/secret`, s.listOrgSecretsHandler)
+			r.Get(`/api/org/{org}/secret/{id}`, s.getOrgSecretHandler)
+		})
+		r.Group(func(r chi.Router) {
+			r.Use(Authorizer(s.storage, model.RoleMember))
+
+			r.Post(`/api/org/{org}/secret`, s.createOrgSecretHandler)
+			r.Put(`/api/org/{org}/secret/{id}`, s.updateOrgSecretHandler)
+			r.Delete(`/api/org/{org}/secret/{id}`, s.deleteOrgSecretHandler)
+		})
+		r.Group(func(r chi.Router) {
+			r.Use(Authorizer(s.storage, model.RoleAdmin))
+
+			r.Post(`/api/org/{org}/member`, s.addMemberHandler)
+			r.Put(`/api/org/{org}/member/{user}`, s.updateMemberHandler)
+			r.Delete(`/api/org/{org}/member/{user}`, s.deleteMemberHandler)
+			r.Post(`/api/org/{org}/collection`, s.createCollectionHandler)
+			r.Delete(`/api/org/{org}/collection/{id}`, s.deleteCollectionHandler)
+		})
+		r.Group(func(r chi.Router) {
+			r.Use(Authorizer(s.storage, model.RoleOwner))
+
+			r.Delete(`/api/org/{org}`, s.deleteOrgHandler)
+		})
 	})
 
 	r.Mount("/debug", middleware.Profiler())
@@ -70,7 +269,14 @@ func NewServer(storage Repository, session SessionStorage, cfg *Config) (*Server
 
 // Run runs the server
 func (s *Server) Run() error {
-	if err := s.srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+	var err error
+	if s.srv.TLSConfig != nil {
+		// the certificate is already loaded into TLSConfig
+		err = s.srv.ListenAndServeTLS("", "")
+	} else {
+		err = s.srv.ListenAndServe()
+	}
+	if err != nil && err != http.ErrServerClosed {
 		return err
 	}
 
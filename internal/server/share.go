@@ -0,0 +1,279 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/nbvehbq/go-password-keeper/internal/model"
+	"github.com/nbvehbq/go-password-keeper/internal/storage"
+)
+
+// publicKeyHandler returns the public key of a user to share secrets with.
+// Accounts that never published a key are reported as not found.
+func (s *Server) publicKeyHandler(res http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	user, err := s.storage.GetUserByLogin(ctx, chi.URLParam(req, "login"))
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrUserNotFound):
+			JSONError(res, err.Error(), http.StatusNotFound)
+		default:
+			JSONError(res, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	if len(user.PublicKey) == 0 {
+		JSONError(res, storage.ErrUserNotFound.Error(), http.StatusNotFound)
+		return
+	}
+
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(http.StatusOK)
+
+	value := model.PublicKey{UserID: user.ID, Login: user.Login, Key: user.PublicKey}
+	if err := json.NewEncoder(res).Encode(value); err != nil {
+		JSONError(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+}
+
+func (s *Server) listSharesHandler(res http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	id, err := strconv.Atoi(chi.URLParam(req, "id"))
+	if err != nil {
+		JSONError(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	list, err := s.storage.ListShares(ctx, UID(ctx), int64(id))
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrSecretNotFound):
+			JSONError(res, err.Error(), http.StatusNotFound)
+		default:
+			JSONError(res, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(res).Encode(list); err != nil {
+		JSONError(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+}
+
+func (s *Server) shareSecretHandler(res http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	id, err := strconv.Atoi(chi.URLParam(req, "id"))
+	if err != nil {
+		JSONError(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var dto model.Share
+	if err := json.NewDecoder(req.Body).Decode(&dto); err != nil {
+		JSONError(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if dto.UserID == UID(ctx) || len(dto.Key) == 0 {
+		JSONError(res, "invalid recipient or key", http.StatusBadRequest)
+		return
+	}
+
+	_, err = s.storage.GetUser(ctx, dto.UserID)
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrUserNotFound):
+			JSONError(res, err.Error(), http.StatusUnprocessableEntity)
+		default:
+			JSONError(res, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	err = s.storage.ShareSecret(ctx, UID(ctx), &model.Share{
+		SecretID: int64(id),
+		UserID:   dto.UserID,
+		Key:      dto.Key,
+		CanEdit:  dto.CanEdit,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrSecretNotFound):
+			JSONError(res, err.Error(), http.StatusNotFound)
+		default:
+			JSONError(res, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	res.WriteHeader(http.StatusNoContent)
+}
+
+// rekeySecretHandler replaces the data key of a secret and of its shares
+// and revokes the shares listed in the request. It expects the current
+// revision in If-Match like updateSecretHandler.
+func (s *Server) rekeySecretHandler(res http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	id, err := strconv.Atoi(chi.URLParam(req, "id"))
+	if err != nil {
+		JSONError(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	revision, err := ifMatch(req)
+	if err != nil {
+		JSONError(res, err.Error(), http.StatusPreconditionRequired)
+		return
+	}
+
+	var dto model.SecretRekey
+	if err := json.NewDecoder(req.Body).Decode(&dto); err != nil {
+		JSONError(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+	dto.Revision = revision
+
+	ret, err := s.storage.RekeySecret(ctx, UID(ctx), int64(id), &dto)
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrSecretNotFound):
+			JSONError(res, err.Error(), http.StatusNotFound)
+		case errors.Is(err, storage.ErrSharesChanged):
+			JSONError(res, err.Error(), http.StatusConflict)
+		case errors.Is(err, storage.ErrRevisionMismatch):
+			s.revisionConflict(res, req, int64(id))
+		default:
+			JSONError(res, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	res.Header().Set("ETag", etag(ret))
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(http.StatusOK)
+
+	value := struct {
+		ID       int64 `json:"id"`
+		Revision int64 `json:"revision"`
+	}{ID: int64(id), Revision: ret}
+
+	if err := json.NewEncoder(res).Encode(value); err != nil {
+		JSONError(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+}
+
+func (s *Server) listSharedSecretsHandler(res http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	list, err := s.storage.ListSharedSecrets(ctx, UID(ctx))
+	if err != nil {
+		JSONError(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(http.StatusOK)
+
+	if err := json.NewEncoder(res).Encode(list); err != nil {
+		JSONError(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+}
+
+// updateSharedSecretHandler overwrites a secret shared with the user for
+// editing. The data key of the secret is kept.
+func (s *Server) updateSharedSecretHandler(res http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	var dto model.Secret
+
+	id, err := strconv.Atoi(chi.URLParam(req, "id"))
+	if err != nil {
+		JSONError(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	revision, err := ifMatch(req)
+	if err != nil {
+		JSONError(res, err.Error(), http.StatusPreconditionRequired)
+		return
+	}
+
+	if err := json.NewDecoder(req.Body).Decode(&dto); err != nil {
+		JSONError(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+	dto.Revision = revision
+
+	ret, err := s.storage.UpdateSharedSecret(ctx, UID(ctx), int64(id), &dto)
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrSecretNotFound):
+			JSONError(res, err.Error(), http.StatusNotFound)
+		case errors.Is(err, storage.ErrReadOnly):
+			JSONError(res, err.Error(), http.StatusForbidden)
+		case errors.Is(err, storage.ErrRevisionMismatch):
+			s.sharedRevisionConflict(res, req, int64(id))
+		default:
+			JSONError(res, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	res.Header().Set("ETag", etag(ret))
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(http.StatusOK)
+
+	value := struct {
+		ID       int64 `json:"id"`
+		Revision int64 `json:"revision"`
+	}{ID: int64(id), Revision: ret}
+
+	if err := json.NewEncoder(res).Encode(value); err != nil {
+		JSONError(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+}
+
+// sharedRevisionConflict answers 412 like revisionConflict for a secret
+// shared with the user.
+func (s *Server) sharedRevisionConflict(res http.ResponseWriter, req *http.Request, id int64) {
+	ctx := req.Context()
+
+	list, err := s.storage.ListSharedSecrets(ctx, UID(ctx))
+	if err != nil {
+		JSONError(res, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	for _, v := range list {
+		if v.ID != id {
+			continue
+		}
+
+		res.Header().Set("ETag", etag(v.Revision))
+		res.Header().Set("Content-Type", "application/json")
+		res.WriteHeader(http.StatusPreconditionFailed)
+
+		value := struct {
+			Err      string `json:"error"`
+			Revision int64  `json:"revision"`
+		}{Err: storage.ErrRevisionMismatch.Error(), Revision: v.Revision}
+
+		json.NewEncoder(res).Encode(value)
+		return
+	}
+
+	JSONError(res, storage.ErrSecretNotFound.Error(), http.StatusNotFound)
+}
@@ -0,0 +1,94 @@
+package server
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSelfSignedCertificate(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &Config{
+		Address:       "keeper.test:8080",
+		GRPCAddress:   "10.0.0.1:9090",
+		TLSCert:       filepath.Join(dir, "certs", "server.crt"),
+		TLSKey:        filepath.Join(dir, "certs", "server.key"),
+		TLSSelfSigned: true,
+	}
+
+	conf, err := tlsConfig(cfg)
+	if err != nil {
+		t.Fatalf("tlsConfig: %v", err)
+	}
+	if conf == nil || len(conf.Certificates) != 1 {
+		t.Fatalf("config without the generated certificate: %+v", conf)
+	}
+
+	cert, err := x509.ParseCertificate(conf.Certificates[0].Certificate[0])
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	for _, host := range []string{"localhost", "127.0.0.1", "keeper.test", "10.0.0.1"} {
+		if err := cert.VerifyHostname(host); err != nil {
+			t.Errorf("certificate not valid for %s: %v", host, err)
+		}
+	}
+
+	// a certificate that exists is kept
+	before, err := os.ReadFile(cfg.TLSCert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tlsConfig(cfg); err != nil {
+		t.Fatalf("tlsConfig: %v", err)
+	}
+	after, err := os.ReadFile(cfg.TLSCert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(before, after) {
+		t.Error("existing certificate replaced")
+	}
+
+	if info, err := os.Stat(cfg.TLSKey); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("private key mode: %v, %v", info.Mode(), err)
+	}
+}
+
+func TestTLSConfigClientCA(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &Config{
+		TLSCert:       filepath.Join(dir, "server.crt"),
+		TLSKey:        filepath.Join(dir, "server.key"),
+		TLSSelfSigned: true,
+	}
+
+	if conf, err := tlsConfig(&Config{}); err != nil || conf != nil {
+		t.Errorf("without a certificate: %v, %v, want no TLS", conf, err)
+	}
+
+	conf, err := tlsConfig(cfg)
+	if err != nil {
+		t.Fatalf("tlsConfig: %v", err)
+	}
+	if conf.ClientAuth != tls.NoClientCert {
+		t.Errorf("client auth %v without a client CA", conf.ClientAuth)
+	}
+
+	cfg.ClientCA = cfg.TLSCert
+	conf, err = tlsConfig(cfg)
+	if err != nil {
+		t.Fatalf("tlsConfig: %v", err)
+	}
+	if conf.ClientAuth != tls.RequireAndVerifyClientCert || conf.ClientCAs == nil {
+		t.Errorf("client certificates not required with a client CA")
+	}
+
+	cfg.ClientCA = filepath.Join(dir, "missing.crt")
+	if _, err := tlsConfig(cfg); err == nil {
+		t.Error("missing client CA accepted")
+	}
+}
@@ -0,0 +1,329 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	gonanoid "github.com/matoous/go-nanoid/v2"
+	"github.com/nbvehbq/go-password-keeper/internal/model"
+	"github.com/nbvehbq/go-password-keeper/internal/otp"
+	"github.com/nbvehbq/go-password-keeper/internal/storage"
+)
+
+const (
+	totpIssuer = "password-keeper"
+
+	// challengeTTL and challengeAttempts bound how long and how often the
+	// second factor of a login can be guessed.
+	challengeTTL      = 5 * time.Minute
+	challengeAttempts = 5
+
+	recoveryCodeCount = 10
+	recoveryCodeSize  = 10
+)
+
+var (
+	errInvalidCode     = errors.New("invalid code")
+	errTOTPEnabled     = errors.New("two-factor authentication is already enabled")
+	errTOTPNotEnrolled = errors.New("two-factor authentication is not enrolled")
+	errTOTPDisabled    = errors.New("two-factor authentication is not enabled")
+)
+
+// totpKey returns the key of the user's second factor.
+func totpKey(user *model.User) *otp.Key {
+	return &otp.Key{
+		Issuer:    totpIssuer,
+		Account:   user.Login,
+		Secret:    user.TOTPSecret,
+		Algorithm: otp.SHA1,
+		Digits:    otp.DefaultDigits,
+		Period:    otp.DefaultPeriod,
+	}
+}
+
+// normalizeRecoveryCode strips the separators & case of a recovery code.
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToUpper(code)
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
+
+// newRecoveryCodes generates recovery codes formatted as XXXX-XXXX-XXXX-XXXX.
+func newRecoveryCodes() ([]string, error) {
+	codes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		buf := make([]byte, recoveryCodeSize)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, err
+		}
+
+		code := base32.StdEncoding.EncodeToString(buf)
+		codes[i] = strings.Join([]string{code[0:4], code[4:8], code[8:12], code[12:16]}, "-")
+	}
+
+	return codes, nil
+}
+
+// isTOTPCode tells TOTP codes from recovery codes.
+func isTOTPCode(code string) bool {
+	if len(code) != otp.DefaultDigits {
+		return false
+	}
+	for _, r := range code {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// checkSecondFactor accepts a current TOTP code or an unused recovery code
+// of the user. Both can be used only once.
+func checkSecondFactor(ctx context.Context, repo Repository, user *model.User, code string) error {
+	code = strings.TrimSpace(code)
+
+	if isTOTPCode(code) {
+		step, ok := totpKey(user).Verify(code, time.Now())
+		if !ok {
+			return errInvalidCode
+		}
+		if err := repo.UseTOTPStep(ctx, user.ID, step); err != nil {
+			if errors.Is(err, storage.ErrCodeReused) {
+				return errInvalidCode
+			}
+			return err
+		}
+		return nil
+	}
+
+	if err := repo.UseRecoveryCode(ctx, user.ID, normalizeRecoveryCode(code)); err != nil {
+		if errors.Is(err, storage.ErrRecoveryCode) {
+			return errInvalidCode
+		}
+		return err
+	}
+
+	return nil
+}
+
+// newChallenge starts the second step of a login of a user whose password
+// was verified.
+func newChallenge(ctx context.Context, repo Repository, userID int64) (string, error) {
+	token, err := gonanoid.New()
+	if err != nil {
+		return "", err
+	}
+
+	if err := repo.CreateChallenge(ctx, userID, token, time.Now().Add(challengeTTL)); err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// answerChallenge completes a login with the second factor and returns the
+// user to open a session for. Wrong codes are recorded in the audit trail
+// of the user with origin.
+func answerChallenge(ctx context.Context, repo Repository, token, code, origin string) (*model.User, error) {
+	userID, err := repo.CheckChallenge(ctx, token, challengeAttempts)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := repo.GetUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkSecondFactor(ctx, repo, user, code); err != nil {
+		if errors.Is(err, errInvalidCode) {
+			audit(ctx, repo, user.ID, user.ID, model.AuditLoginFailed, "wrong second factor "+origin)
+		}
+		return nil, err
+	}
+
+	if err := repo.DeleteChallenge(ctx, token); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// enrollTOTP generates a new seed for the user. It takes effect once
+// confirmTOTP verified a code of it.
+func enrollTOTP(ctx context.Context, repo Repository, userID int64) (*otp.Key, error) {
+	user, err := repo.GetUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.TOTPEnabled {
+		return nil, errTOTPEnabled
+	}
+
+	key, err := otp.NewKey(totpIssuer, user.Login)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := repo.SetTOTPSecret(ctx, userID, key.Secret); err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+// confirmTOTP enables the enrolled seed after checking a code of it, and
+// returns fresh recovery codes. They are shown once and only their hashes
+// are kept.
+func confirmTOTP(ctx context.Context, repo Repository, userID int64, code string) ([]string, error) {
+	user, err := repo.GetUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.TOTPEnabled {
+		return nil, errTOTPEnabled
+	}
+	if len(user.TOTPSecret) == 0 {
+		return nil, errTOTPNotEnrolled
+	}
+
+	step, ok := totpKey(user).Verify(code, time.Now())
+	if !ok {
+		return nil, errInvalidCode
+	}
+
+	codes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	normalized := make([]string, len(codes))
+	for i, v := range codes {
+		normalized[i] = normalizeRecoveryCode(v)
+	}
+
+	if err := repo.EnableTOTP(ctx, userID, step, normalized); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// disableTOTP turns off the second factor, which takes a valid code.
+func disableTOTP(ctx context.Context, repo Repository, userID int64, code string) error {
+	user, err := repo.GetUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !user.TOTPEnabled {
+		return errTOTPDisabled
+	}
+
+	if err := checkSecondFactor(ctx, repo, user, code); err != nil {
+		return err
+	}
+
+	return repo.DisableTOTP(ctx, userID)
+}
+
+// twoFactorStatus maps the errors of the second factor to HTTP statuses.
+func twoFactorStatus(err error) int {
+	switch {
+	case errors.Is(err, errInvalidCode), errors.Is(err, storage.ErrChallengeExpired):
+		return http.StatusUnauthorized
+	case errors.Is(err, errTOTPEnabled), errors.Is(err, errTOTPDisabled), errors.Is(err, errTOTPNotEnrolled):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func (s *Server) enrollTOTPHandler(res http.ResponseWriter, req *http.Request) {
+	key, err := enrollTOTP(req.Context(), s.storage, UID(req.Context()))
+	if err != nil {
+		JSONError(res, err.Error(), twoFactorStatus(err))
+		return
+	}
+
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(http.StatusOK)
+
+	value := model.TOTPEnrollment{Secret: otp.EncodeSecret(key.Secret), URI: key.URI()}
+
+	if err := json.NewEncoder(res).Encode(value); err != nil {
+		JSONError(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+}
+
+func (s *Server) verifyTOTPHandler(res http.ResponseWriter, req *http.Request) {
+	var dto struct {
+		Code string `json:"code"`
+	}
+	if err := json.NewDecoder(req.Body).Decode(&dto); err != nil {
+		JSONError(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	codes, err := confirmTOTP(req.Context(), s.storage, UID(req.Context()), dto.Code)
+	if err != nil {
+		JSONError(res, err.Error(), twoFactorStatus(err))
+		return
+	}
+
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(http.StatusOK)
+
+	value := struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}{RecoveryCodes: codes}
+
+	if err := json.NewEncoder(res).Encode(value); err != nil {
+		JSONError(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+}
+
+func (s *Server) disableTOTPHandler(res http.ResponseWriter, req *http.Request) {
+	var dto struct {
+		Code string `json:"code"`
+	}
+	if err := json.NewDecoder(req.Body).Decode(&dto); err != nil {
+		JSONError(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := disableTOTP(req.Context(), s.storage, UID(req.Context()), dto.Code); err != nil {
+		JSONError(res, err.Error(), twoFactorStatus(err))
+		return
+	}
+
+	res.WriteHeader(http.StatusNoContent)
+}
+
+// loginTwoFactorHandler is the second step of a login with two-factor
+// authentication: it exchanges the challenge of loginHandler and a TOTP or
+// recovery code for a session.
+func (s *Server) loginTwoFactorHandler(res http.ResponseWriter, req *http.Request) {
+	var dto struct {
+		Challenge string `json:"challenge"`
+		Code      string `json:"code"`
+	}
+	if err := json.NewDecoder(req.Body).Decode(&dto); err != nil {
+		JSONError(res, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	user, err := answerChallenge(req.Context(), s.storage, dto.Challenge, dto.Code, origin(req))
+	if err != nil {
+		JSONError(res, err.Error(), twoFactorStatus(err))
+		return
+	}
+
+	s.startSession(res, req, user)
+}
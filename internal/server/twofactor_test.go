@@ -0,0 +1,336 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nbvehbq/go-password-keeper/internal/model"
+	"github.com/nbvehbq/go-password-keeper/internal/otp"
+	"github.com/nbvehbq/go-password-keeper/internal/storage"
+)
+
+type challenge struct {
+	userID    int64
+	expiresAt time.Time
+	attempts  int
+}
+
+// twoFactorRepo keeps the second factor of a single user in memory, with
+// the semantics of the postgres storage.
+type twoFactorRepo struct {
+	Repository
+
+	mu         sync.Mutex
+	user       model.User
+	recovery   map[string]bool
+	challenges map[string]*challenge
+	events     []model.AuditEvent
+}
+
+func newTwoFactorRepo(t *testing.T, recoveryCodes ...string) *twoFactorRepo {
+	t.Helper()
+
+	key, err := otp.NewKey(totpIssuer, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := &twoFactorRepo{
+		user:       model.User{ID: 1, Login: "alice", TOTPSecret: key.Secret, TOTPEnabled: true},
+		recovery:   make(map[string]bool),
+		challenges: make(map[string]*challenge),
+	}
+	for _, code := range recoveryCodes {
+		r.recovery[normalizeRecoveryCode(code)] = false
+	}
+	return r
+}
+
+func (r *twoFactorRepo) GetUser(_ context.Context, id int64) (*model.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if id != r.user.ID {
+		return nil, storage.ErrUserNotFound
+	}
+	user := r.user
+	return &user, nil
+}
+
+func (r *twoFactorRepo) UseTOTPStep(_ context.Context, _, step int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if step <= r.user.TOTPLastStep {
+		return storage.ErrCodeReused
+	}
+	r.user.TOTPLastStep = step
+	return nil
+}
+
+func (r *twoFactorRepo) UseRecoveryCode(_ context.Context, _ int64, code string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	used, ok := r.recovery[code]
+	if !ok || used {
+		return storage.ErrRecoveryCode
+	}
+	r.recovery[code] = true
+	return nil
+}
+
+func (r *twoFactorRepo) CreateChallenge(_ context.Context, userID int64, token string, expiresAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.challenges[token] = &challenge{userID: userID, expiresAt: expiresAt}
+	return nil
+}
+
+func (r *twoFactorRepo) CheckChallenge(_ context.Context, token string, maxAttempts int) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	c, ok := r.challenges[token]
+	if !ok || !time.Now().Before(c.expiresAt) || c.attempts >= maxAttempts {
+		return 0, storage.ErrChallengeExpired
+	}
+	c.attempts++
+	return c.userID, nil
+}
+
+func (r *twoFactorRepo) DeleteChallenge(_ context.Context, token string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.challenges, token)
+	return nil
+}
+
+func (r *twoFactorRepo) AddAuditEvent(_ context.Context, event *model.AuditEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.events = append(r.events, *event)
+	return nil
+}
+
+// code returns the TOTP code of the user at now+offset.
+func (r *twoFactorRepo) code(offset time.Duration) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return totpKey(&r.user).Code(time.Now().Add(offset))
+}
+
+func TestNormalizeRecoveryCode(t *testing.T) {
+	tests := []struct {
+		code string
+		want string
+	}{
+		{"ABCD-EFGH-IJKL-MNOP", "ABCDEFGHIJKLMNOP"},
+		{"abcd-efgh-ijkl-mnop", "ABCDEFGHIJKLMNOP"},
+		{"abcd efgh ijkl mnop", "ABCDEFGHIJKLMNOP"},
+		{"ABCDEFGHIJKLMNOP", "ABCDEFGHIJKLMNOP"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := normalizeRecoveryCode(tt.code); got != tt.want {
+			t.Errorf("normalizeRecoveryCode(%q) = %q, want %q", tt.code, got, tt.want)
+		}
+	}
+}
+
+func TestNewRecoveryCodes(t *testing.T) {
+	codes, err := newRecoveryCodes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(codes) != recoveryCodeCount {
+		t.Fatalf("got %d codes, want %d", len(codes), recoveryCodeCount)
+	}
+
+	seen := make(map[string]bool)
+	for _, code := range codes {
+		if len(code) != 19 || isTOTPCode(code) {
+			t.Errorf("malformed recovery code %q", code)
+		}
+		if seen[code] {
+			t.Errorf("duplicate recovery code %q", code)
+		}
+		seen[code] = true
+	}
+}
+
+func TestCheckSecondFactorTOTP(t *testing.T) {
+	ctx := context.Background()
+	repo := newTwoFactorRepo(t)
+	user, _ := repo.GetUser(ctx, 1)
+
+	code := repo.code(0)
+	wrong := "000000"
+	if code == wrong {
+		wrong = "111111"
+	}
+	if err := checkSecondFactor(ctx, repo, user, code); err != nil {
+		t.Fatalf("first use: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		code string
+	}{
+		{name: "same code", code: code},
+		{name: "same code with spaces", code: " " + code + " "},
+		{name: "previous step", code: repo.code(-otp.DefaultPeriod * time.Second)},
+		{name: "expired", code: repo.code(-3 * otp.DefaultPeriod * time.Second)},
+		{name: "wrong", code: wrong},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := checkSecondFactor(ctx, repo, user, tt.code); !errors.Is(err, errInvalidCode) {
+				t.Errorf("got %v, want %v", err, errInvalidCode)
+			}
+		})
+	}
+
+	// a code of a later step is still accepted
+	if err := checkSecondFactor(ctx, repo, user, repo.code(otp.DefaultPeriod*time.Second)); err != nil {
+		t.Errorf("next step: %v", err)
+	}
+}
+
+func TestCheckSecondFactorRecoveryCode(t *testing.T) {
+	ctx := context.Background()
+	repo := newTwoFactorRepo(t, "ABCD-EFGH-IJKL-MNOP", "QRST-UVWX-YZ23-4567")
+	user, _ := repo.GetUser(ctx, 1)
+
+	tests := []struct {
+		name    string
+		code    string
+		wantErr error
+	}{
+		{name: "first use", code: "abcd-efgh-ijkl-mnop"},
+		{name: "second use", code: "ABCD-EFGH-IJKL-MNOP", wantErr: errInvalidCode},
+		{name: "second use reformatted", code: "abcdefghijklmnop", wantErr: errInvalidCode},
+		{name: "other code", code: " qrst uvwx yz23 4567 "},
+		{name: "unknown", code: "AAAA-BBBB-CCCC-DDDD", wantErr: errInvalidCode},
+	}
+
+	for _, tt := range tests {
+		if err := checkSecondFactor(ctx, repo, user, tt.code); !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: got %v, want %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestAnswerChallenge(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("success", func(t *testing.T) {
+		repo := newTwoFactorRepo(t)
+		token, err := newChallenge(ctx, repo, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		c := repo.challenges[token]
+		if ttl := time.Until(c.expiresAt); ttl <= 0 || ttl > challengeTTL {
+			t.Errorf("challenge expires in %s, want at most %s", ttl, challengeTTL)
+		}
+
+		user, err := answerChallenge(ctx, repo, token, repo.code(0), "test")
+		if err != nil {
+			t.Fatalf("answer: %v", err)
+		}
+		if user.ID != 1 {
+			t.Errorf("user %d, want 1", user.ID)
+		}
+
+		// a challenge is good for a single login
+		if _, err := answerChallenge(ctx, repo, token, repo.code(otp.DefaultPeriod*time.Second), "test"); !errors.Is(err, storage.ErrChallengeExpired) {
+			t.Errorf("second answer: got %v, want %v", err, storage.ErrChallengeExpired)
+		}
+	})
+
+	t.Run("attempts", func(t *testing.T) {
+		repo := newTwoFactorRepo(t)
+		token, err := newChallenge(ctx, repo, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		wrong := "000000"
+		if repo.code(0) == wrong {
+			wrong = "111111"
+		}
+		for i := 0; i < challengeAttempts; i++ {
+			if _, err := answerChallenge(ctx, repo, token, wrong, "test"); !errors.Is(err, errInvalidCode) {
+				t.Fatalf("attempt %d: got %v, want %v", i+1, err, errInvalidCode)
+			}
+		}
+
+		if _, err := answerChallenge(ctx, repo, token, repo.code(0), "test"); !errors.Is(err, storage.ErrChallengeExpired) {
+			t.Errorf("right code after %d attempts: got %v, want %v", challengeAttempts, err, storage.ErrChallengeExpired)
+		}
+
+		if len(repo.events) != challengeAttempts {
+			t.Errorf("%d audit events, want %d", len(repo.events), challengeAttempts)
+		}
+		for _, event := range repo.events {
+			if event.Action != model.AuditLoginFailed || event.UserID != 1 {
+				t.Errorf("audit event %+v, want %s of user 1", event, model.AuditLoginFailed)
+			}
+		}
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		repo := newTwoFactorRepo(t)
+		token, err := newChallenge(ctx, repo, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		repo.challenges[token].expiresAt = time.Now().Add(-time.Second)
+
+		if _, err := answerChallenge(ctx, repo, token, repo.code(0), "test"); !errors.Is(err, storage.ErrChallengeExpired) {
+			t.Errorf("got %v, want %v", err, storage.ErrChallengeExpired)
+		}
+		if repo.user.TOTPLastStep != 0 {
+			t.Error("an expired challenge used up a TOTP step")
+		}
+	})
+
+	t.Run("unknown", func(t *testing.T) {
+		repo := newTwoFactorRepo(t)
+		if _, err := answerChallenge(ctx, repo, "nope", repo.code(0), "test"); !errors.Is(err, storage.ErrChallengeExpired) {
+			t.Errorf("got %v, want %v", err, storage.ErrChallengeExpired)
+		}
+	})
+}
+
+func TestTwoFactorStatus(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{errInvalidCode, http.StatusUnauthorized},
+		{storage.ErrChallengeExpired, http.StatusUnauthorized},
+		{errTOTPEnabled, http.StatusConflict},
+		{errTOTPDisabled, http.StatusConflict},
+		{errTOTPNotEnrolled, http.StatusConflict},
+		{errors.New("db down"), http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		if got := twoFactorStatus(tt.err); got != tt.want {
+			t.Errorf("twoFactorStatus(%v) = %d, want %d", tt.err, got, tt.want)
+		}
+	}
+}
@@ -2,10 +2,13 @@ package session
 
 import (
 	"context"
+	"sort"
 	"sync"
 	"time"
 
 	gonanoid "github.com/matoous/go-nanoid/v2"
+	"github.com/nbvehbq/go-password-keeper/internal/model"
+	"github.com/nbvehbq/go-password-keeper/internal/storage"
 	"github.com/pkg/errors"
 )
 
@@ -15,12 +18,17 @@ const (
 )
 
 type object struct {
-	id      int64
-	expires time.Time
+	id        int64
+	uid       int64
+	userAgent string
+	created   time.Time
+	lastSeen  time.Time
+	expires   time.Time
 }
 
 type Session struct {
 	mu      sync.RWMutex
+	seq     int64
 	storage map[string]object
 }
 
@@ -44,7 +52,7 @@ func NewSessionStorage(ctx context.Context) *Session {
 	return &s
 }
 
-func (s *Session) Set(_ context.Context, id int64) (string, error) {
+func (s *Session) Set(_ context.Context, id int64, userAgent string) (string, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -52,9 +60,16 @@ func (s *Session) Set(_ context.Context, id int64) (string, error) {
 	if err != nil {
 		return "", errors.Wrap(err, "generate sid")
 	}
+
+	s.seq++
+	now := time.Now()
 	s.storage[sid] = object{
-		id:      id,
-		expires: time.Now().Add(sessionTTL),
+		id:        s.seq,
+		uid:       id,
+		userAgent: userAgent,
+		created:   now,
+		lastSeen:  now,
+		expires:   now.Add(sessionTTL),
 	}
 
 	return sid, nil
@@ -65,17 +80,99 @@ func (s *Session) Get(_ context.Context, sid string) (int64, bool) {
 	defer s.mu.RUnlock()
 
 	o, ok := s.storage[sid]
-	if !ok {
+	if !ok || time.Now().After(o.expires) {
 		return 0, false
 	}
 
-	return o.id, true
+	return o.uid, true
 }
 
-func (s *Session) reduceSessions() {
+// Refresh extends the session TTL, sliding it from the current moment.
+func (s *Session) Refresh(_ context.Context, sid string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	o, ok := s.storage[sid]
+	if !ok || time.Now().After(o.expires) {
+		return storage.ErrSessionNotFound
+	}
+
+	o.lastSeen = time.Now()
+	o.expires = o.lastSeen.Add(sessionTTL)
+	s.storage[sid] = o
+
+	return nil
+}
+
+func (s *Session) Delete(_ context.Context, sid string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.storage[sid]; !ok {
+		return storage.ErrSessionNotFound
+	}
+	delete(s.storage, sid)
+
+	return nil
+}
+
+func (s *Session) List(_ context.Context, uid int64, current string) ([]model.Session, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
+	now := time.Now()
+	list := make([]model.Session, 0)
+	for k, v := range s.storage {
+		if v.uid != uid || now.After(v.expires) {
+			continue
+		}
+		list = append(list, model.Session{
+			ID:         v.id,
+			UserAgent:  v.userAgent,
+			CreatedAt:  v.created,
+			LastSeenAt: v.lastSeen,
+			ExpiresAt:  v.expires,
+			Current:    k == current,
+		})
+	}
+
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+
+	return list, nil
+}
+
+func (s *Session) Revoke(_ context.Context, uid, id int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for k, v := range s.storage {
+		if v.uid == uid && v.id == id {
+			delete(s.storage, k)
+			return nil
+		}
+	}
+
+	return storage.ErrSessionNotFound
+}
+
+// RevokeOthers deletes all sessions of the user but current.
+func (s *Session) RevokeOthers(_ context.Context, uid int64, current string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for k, v := range s.storage {
+		if v.uid == uid && k != current {
+			delete(s.storage, k)
+		}
+	}
+
+	return nil
+}
+
+func (s *Session) reduceSessions() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	now := time.Now()
 	for k, v := range s.storage {
 		if now.After(v.expires) {
@@ -0,0 +1,110 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nbvehbq/go-password-keeper/internal/storage"
+)
+
+func newTestStorage(t *testing.T) *Session {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	return NewSessionStorage(ctx)
+}
+
+func TestSessionLifecycle(t *testing.T) {
+	const (
+		alice = 1
+		bob   = 2
+	)
+
+	ctx := context.Background()
+	s := newTestStorage(t)
+
+	var sids []string
+	for _, uid := range []int64{alice, alice, bob} {
+		sid, err := s.Set(ctx, uid, "test")
+		if err != nil {
+			t.Fatalf("set: %v", err)
+		}
+		sids = append(sids, sid)
+	}
+
+	list, err := s.List(ctx, alice, sids[0])
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(list) != 2 || !list[0].Current || list[1].Current || list[0].ID >= list[1].ID {
+		t.Fatalf("sessions of alice: %+v", list)
+	}
+
+	bobs, err := s.List(ctx, bob, "")
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(bobs) != 1 {
+		t.Fatalf("sessions of bob: %+v", bobs)
+	}
+	if err := s.Revoke(ctx, alice, bobs[0].ID); !errors.Is(err, storage.ErrSessionNotFound) {
+		t.Errorf("revoke a session of another user: got %v, want %v", err, storage.ErrSessionNotFound)
+	}
+	if _, ok := s.Get(ctx, sids[2]); !ok {
+		t.Error("session of bob revoked by alice")
+	}
+
+	if err := s.Revoke(ctx, alice, list[1].ID); err != nil {
+		t.Fatalf("revoke: %v", err)
+	}
+	if _, ok := s.Get(ctx, sids[1]); ok {
+		t.Error("revoked session resolved")
+	}
+
+	if err := s.Delete(ctx, sids[0]); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, ok := s.Get(ctx, sids[0]); ok {
+		t.Error("deleted session resolved")
+	}
+	if err := s.Delete(ctx, sids[0]); !errors.Is(err, storage.ErrSessionNotFound) {
+		t.Errorf("delete twice: got %v, want %v", err, storage.ErrSessionNotFound)
+	}
+}
+
+func TestSessionRefresh(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStorage(t)
+
+	sid, err := s.Set(ctx, 1, "test")
+	if err != nil {
+		t.Fatalf("set: %v", err)
+	}
+
+	// about to expire
+	o := s.storage[sid]
+	o.expires = time.Now().Add(time.Second)
+	s.storage[sid] = o
+
+	if err := s.Refresh(ctx, sid); err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+	if left := time.Until(s.storage[sid].expires); left < sessionTTL-time.Minute {
+		t.Errorf("expires in %v after refresh, want %v", left, sessionTTL)
+	}
+
+	o = s.storage[sid]
+	o.expires = time.Now().Add(-time.Second)
+	s.storage[sid] = o
+
+	if err := s.Refresh(ctx, sid); !errors.Is(err, storage.ErrSessionNotFound) {
+		t.Errorf("refresh an expired session: got %v, want %v", err, storage.ErrSessionNotFound)
+	}
+	if _, ok := s.Get(ctx, sid); ok {
+		t.Error("expired session resolved")
+	}
+}
@@ -0,0 +1,102 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/nbvehbq/go-password-keeper/internal/model"
+	"github.com/nbvehbq/go-password-keeper/internal/storage"
+	"github.com/pkg/errors"
+)
+
+// AddAuditEvent appends an event to the audit trail of event.UserID and
+// chains it to the previous one. It sets CreatedAt, PrevHash and Hash.
+func (s *Storage) AddAuditEvent(ctx context.Context, event *model.AuditEvent) error {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "begin tx")
+	}
+	defer tx.Rollback()
+
+	if err := addAuditEvent(ctx, tx, event); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.Wrap(err, "commit tx")
+	}
+
+	return nil
+}
+
+// addAuditEvent appends an event like AddAuditEvent within tx, so it is
+// recorded if and only if the change it describes is.
+func addAuditEvent(ctx context.Context, tx *sqlx.Tx, event *model.AuditEvent) error {
+	if err := lockAuditTrail(ctx, tx, event.UserID); err != nil {
+		return err
+	}
+
+	var prev []byte
+	query := `SELECT hash FROM audit_event WHERE user_id = $1 ORDER BY id DESC LIMIT 1;`
+	if err := tx.GetContext(ctx, &prev, query, event.UserID); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return errors.Wrap(err, "get audit chain")
+	}
+
+	event.CreatedAt = time.Now().UTC().Truncate(time.Microsecond)
+	event.PrevHash = prev
+	event.Hash = event.Digest()
+
+	query = `INSERT INTO audit_event (user_id, actor_id, action, detail, created_at, prev_hash, hash)
+	VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id;`
+
+	if err := tx.QueryRowContext(ctx, query, event.UserID, event.ActorID, event.Action, event.Detail,
+		event.CreatedAt, event.PrevHash, event.Hash).Scan(&event.ID); err != nil {
+		return errors.Wrap(err, "add audit event")
+	}
+
+	return nil
+}
+
+// auditSecret records an action on a secret of ownerID done by actorID.
+func auditSecret(ctx context.Context, tx *sqlx.Tx, ownerID, actorID int64, action, detail string) error {
+	return addAuditEvent(ctx, tx, &model.AuditEvent{
+		UserID:  ownerID,
+		ActorID: actorID,
+		Action:  action,
+		Detail:  detail,
+	})
+}
+
+// ListAuditEvents returns up to limit events of the user, oldest first. With
+// before set only events older than it are returned, which pages backwards
+// through the trail.
+func (s *Storage) ListAuditEvents(ctx context.Context, userID, before int64, limit int) ([]model.AuditEvent, error) {
+	events := make([]model.AuditEvent, 0)
+
+	query := `SELECT * FROM (
+	    SELECT id, user_id, actor_id, action, detail, created_at, prev_hash, hash FROM audit_event
+	    WHERE user_id = $1 and ($2::bigint = 0 or id < $2) ORDER BY id DESC LIMIT $3
+	) e ORDER BY id;`
+
+	if err := s.db.SelectContext(ctx, &events, query, userID, before, limit); err != nil {
+		return nil, errors.Wrap(err, "list audit events")
+	}
+
+	return events, nil
+}
+
+// lockAuditTrail serializes appends to the trail of a user until tx ends.
+// The user row is locked without blocking inserts referencing it.
+func lockAuditTrail(ctx context.Context, tx *sqlx.Tx, userID int64) error {
+	var id int64
+	if err := tx.GetContext(ctx, &id, `SELECT id FROM "user" WHERE id = $1 FOR NO KEY UPDATE;`, userID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return storage.ErrUserNotFound
+		}
+		return errors.Wrap(err, "lock audit trail")
+	}
+
+	return nil
+}
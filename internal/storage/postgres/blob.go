@@ -0,0 +1,193 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/nbvehbq/go-password-keeper/internal/model"
+	"github.com/nbvehbq/go-password-keeper/internal/storage"
+	"github.com/pkg/errors"
+)
+
+// blobChunkSize is the size of a single secret_blob row.
+const blobChunkSize = 1 << 20
+
+// PutBlob replaces the file contents of a binary secret with what is read
+// from r and its payload, meta and key with those of data, in one
+// transaction. data.Revision must be the current revision. The replaced
+// contents stay with the archived version. It returns the new revision and
+// the number of bytes stored; the data is opaque to the server.
+func (s *Storage) PutBlob(ctx context.Context, userID, id int64, data *model.Secret, r io.Reader) (int64, int64, error) {
+	if data.Type != model.BinaryType {
+		return 0, 0, storage.ErrNotBinary
+	}
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, 0, errors.Wrap(err, "begin tx")
+	}
+	defer tx.Rollback()
+
+	if err := lockVault(ctx, tx, userID); err != nil {
+		return 0, 0, err
+	}
+
+	var typ model.ResourceType
+	query := `SELECT type FROM secret WHERE id = $1 and user_id = $2;`
+	if err := tx.GetContext(ctx, &typ, query, id, userID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, 0, storage.ErrSecretNotFound
+		}
+		return 0, 0, errors.Wrap(err, "get secret type")
+	}
+	if typ != model.BinaryType {
+		return 0, 0, storage.ErrNotBinary
+	}
+
+	if err := s.archiveSecret(ctx, tx, userID, id); err != nil {
+		return 0, 0, err
+	}
+
+	query = `UPDATE secret SET payload = $3, meta = $4, key = $5, uuid = $6, blob = nextval('secret_blob_seq'),
+	    revision = revision + 1, change_seq = nextval('secret_change_seq'), updated_at = now()
+	WHERE id = $1 and user_id = $2 and revision = $7 RETURNING revision, blob;`
+
+	var revision, blob int64
+	if err := tx.QueryRowContext(ctx, query, id, userID, data.Payload, data.Meta, data.Key, data.UUID,
+		data.Revision).Scan(&revision, &blob); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, 0, storage.ErrRevisionMismatch
+		}
+		return 0, 0, errors.Wrap(err, "update secret")
+	}
+
+	var size int64
+	buf := make([]byte, blobChunkSize)
+	for seq := 0; ; seq++ {
+		n, err := io.ReadFull(r, buf)
+		if n > 0 {
+			query := `INSERT INTO secret_blob (secret_id, blob, seq, data) VALUES ($1, $2, $3, $4);`
+			if _, err := tx.ExecContext(ctx, query, id, blob, seq, buf[:n]); err != nil {
+				return 0, 0, errors.Wrap(err, "insert blob chunk")
+			}
+			size += int64(n)
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			break
+		}
+		if err != nil {
+			return 0, 0, errors.Wrap(err, "read blob")
+		}
+	}
+
+	if err := auditSecret(ctx, tx, userID, userID, model.AuditSecretUpdated,
+		fmt.Sprintf("secret %d, revision %d", id, revision)); err != nil {
+		return 0, 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, 0, errors.Wrap(err, "commit tx")
+	}
+
+	return revision, size, nil
+}
+
+// GetBlob returns a reader over the file contents of a secret of the user
+// or shared with them and records the download in the trail of the owner.
+// Chunks are fetched from the database as the reader is consumed; the
+// caller must close it.
+func (s *Storage) GetBlob(ctx context.Context, userID, id int64) (io.ReadCloser, error) {
+	if err := s.auditRead(ctx, userID, id); err != nil {
+		return nil, err
+	}
+
+	query := `SELECT b.data FROM secret_blob b JOIN secret s ON s.id = b.secret_id and s.blob = b.blob
+	WHERE b.secret_id = $1 ORDER BY b.seq;`
+	rows, err := s.db.QueryxContext(ctx, query, id)
+	if err != nil {
+		return nil, errors.Wrap(err, "get blob")
+	}
+
+	blob := &blobReader{rows: rows}
+	if err := blob.next(); err != nil {
+		rows.Close()
+		if errors.Is(err, io.EOF) {
+			return nil, storage.ErrBlobNotFound
+		}
+		return nil, err
+	}
+
+	return blob, nil
+}
+
+// auditRead records a download of the contents of a secret by userID in
+// the trail of its owner. It reports storage.ErrSecretNotFound unless the
+// secret belongs to the user or is shared with them.
+func (s *Storage) auditRead(ctx context.Context, userID, id int64) error {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "begin tx")
+	}
+	defer tx.Rollback()
+
+	var ownerID int64
+	query := `SELECT user_id FROM secret WHERE id = $1
+	    and (user_id = $2 or exists(SELECT 1 FROM secret_share WHERE secret_id = $1 and user_id = $2));`
+	if err := tx.GetContext(ctx, &ownerID, query, id, userID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return storage.ErrSecretNotFound
+		}
+		return errors.Wrap(err, "check secret reader")
+	}
+
+	if err := auditSecret(ctx, tx, ownerID, userID, model.AuditSecretRead,
+		fmt.Sprintf("contents of secret %d", id)); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.Wrap(err, "commit tx")
+	}
+
+	return nil
+}
+
+type blobReader struct {
+	rows  *sqlx.Rows
+	chunk []byte
+}
+
+func (b *blobReader) next() error {
+	if !b.rows.Next() {
+		if err := b.rows.Err(); err != nil {
+			return errors.Wrap(err, "read blob chunk")
+		}
+		return io.EOF
+	}
+
+	if err := b.rows.Scan(&b.chunk); err != nil {
+		return errors.Wrap(err, "read blob chunk")
+	}
+
+	return nil
+}
+
+func (b *blobReader) Read(p []byte) (int, error) {
+	for len(b.chunk) == 0 {
+		if err := b.next(); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, b.chunk)
+	b.chunk = b.chunk[n:]
+
+	return n, nil
+}
+
+func (b *blobReader) Close() error {
+	return b.rows.Close()
+}
@@ -0,0 +1,177 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/nbvehbq/go-password-keeper/internal/model"
+	"github.com/nbvehbq/go-password-keeper/internal/storage"
+	"github.com/pkg/errors"
+)
+
+const (
+	emergencyColumns = `g.id, g.grantor_id, a.login AS grantor_login, g.grantee_id, b.login AS grantee_login,
+    g.wait_days, g.requested_at, g.created_at`
+	emergencyTables = `emergency_grant g JOIN "user" a ON a.id = g.grantor_id JOIN "user" b ON b.id = g.grantee_id`
+	// emergencyReleased holds for requests whose wait period has passed
+	emergencyReleased = `g.requested_at + g.wait_days * interval '1 day' <= now()`
+)
+
+// CreateEmergencyGrant makes grant.GranteeID an emergency contact of
+// grant.GrantorID. It fails with storage.ErrUserNotFound for unknown
+// grantees and storage.ErrEmergencyExists if they already are one.
+func (s *Storage) CreateEmergencyGrant(ctx context.Context, grant *model.EmergencyGrant) (int64, error) {
+	var id int64
+	query := `INSERT INTO emergency_grant (grantor_id, grantee_id, wait_days, key, vault_key)
+	VALUES ($1, $2, $3, $4, $5) RETURNING id;`
+
+	if err := s.db.QueryRowContext(ctx, query, grant.GrantorID, grant.GranteeID, grant.WaitDays, grant.Key,
+		grant.VaultKey).Scan(&id); err != nil {
+		var pqErr *pgconn.PgError
+		if errors.As(err, &pqErr) {
+			switch pqErr.Code {
+			case pgerrcode.UniqueViolation:
+				return 0, storage.ErrEmergencyExists
+			case pgerrcode.ForeignKeyViolation:
+				return 0, storage.ErrUserNotFound
+			}
+		}
+		return 0, errors.Wrap(err, "create emergency grant")
+	}
+
+	return id, nil
+}
+
+// ListEmergencyGrants returns the grants given and received by the user,
+// without keys.
+func (s *Storage) ListEmergencyGrants(ctx context.Context, userID int64) ([]model.EmergencyGrant, error) {
+	grants := make([]model.EmergencyGrant, 0)
+
+	query := `SELECT ` + emergencyColumns + ` FROM ` + emergencyTables + `
+	WHERE g.grantor_id = $1 or g.grantee_id = $1 ORDER BY g.id;`
+
+	if err := s.db.SelectContext(ctx, &grants, query, userID); err != nil {
+		return nil, errors.Wrap(err, "list emergency grants")
+	}
+
+	return grants, nil
+}
+
+// GetEmergencyGrant returns a grant given or received by the user, without
+// keys.
+func (s *Storage) GetEmergencyGrant(ctx context.Context, userID, id int64) (*model.EmergencyGrant, error) {
+	var grant model.EmergencyGrant
+
+	query := `SELECT ` + emergencyColumns + ` FROM ` + emergencyTables + `
+	WHERE g.id = $1 and (g.grantor_id = $2 or g.grantee_id = $2);`
+
+	if err := s.db.GetContext(ctx, &grant, query, id, userID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, storage.ErrEmergencyNotFound
+		}
+		return nil, errors.Wrap(err, "get emergency grant")
+	}
+
+	return &grant, nil
+}
+
+// DeleteEmergencyGrant ends a grant, on behalf of either party.
+func (s *Storage) DeleteEmergencyGrant(ctx context.Context, userID, id int64) error {
+	query := `DELETE FROM emergency_grant WHERE id = $1 and (grantor_id = $2 or grantee_id = $2);`
+
+	res, err := s.db.ExecContext(ctx, query, id, userID)
+	if err != nil {
+		return errors.Wrap(err, "delete emergency grant")
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err, "delete emergency grant")
+	}
+	if n == 0 {
+		return storage.ErrEmergencyNotFound
+	}
+
+	return nil
+}
+
+// RequestEmergencyAccess starts the wait period of a grant received by
+// granteeID. Requesting again keeps the original request time.
+func (s *Storage) RequestEmergencyAccess(ctx context.Context, granteeID, id int64) (*model.EmergencyGrant, error) {
+	query := `UPDATE emergency_grant SET requested_at = coalesce(requested_at, now())
+	WHERE id = $1 and grantee_id = $2;`
+
+	res, err := s.db.ExecContext(ctx, query, id, granteeID)
+	if err != nil {
+		return nil, errors.Wrap(err, "request emergency access")
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return nil, errors.Wrap(err, "request emergency access")
+	}
+	if n == 0 {
+		return nil, storage.ErrEmergencyNotFound
+	}
+
+	return s.GetEmergencyGrant(ctx, granteeID, id)
+}
+
+// RejectEmergencyAccess cancels a pending request to a grant given by
+// grantorID. Released requests can't be rejected any more, it fails with
+// storage.ErrEmergencyNotPending then.
+func (s *Storage) RejectEmergencyAccess(ctx context.Context, grantorID, id int64) error {
+	query := `UPDATE emergency_grant g SET requested_at = NULL
+	WHERE g.id = $1 and g.grantor_id = $2 and g.requested_at IS NOT NULL and not (` + emergencyReleased + `);`
+
+	res, err := s.db.ExecContext(ctx, query, id, grantorID)
+	if err != nil {
+		return errors.Wrap(err, "reject emergency access")
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err, "reject emergency access")
+	}
+	if n == 0 {
+		grant, err := s.GetEmergencyGrant(ctx, grantorID, id)
+		if err != nil {
+			return err
+		}
+		if grant.GrantorID != grantorID {
+			return storage.ErrEmergencyNotFound
+		}
+		return storage.ErrEmergencyNotPending
+	}
+
+	return nil
+}
+
+// GetEmergencyAccess returns a grant received by granteeID with its keys,
+// once a request has been released. Before it fails with
+// storage.ErrEmergencyWaiting.
+func (s *Storage) GetEmergencyAccess(ctx context.Context, granteeID, id int64) (*model.EmergencyGrant, error) {
+	var grant model.EmergencyGrant
+
+	query := `SELECT ` + emergencyColumns + `, g.key, g.vault_key FROM ` + emergencyTables + `
+	WHERE g.id = $1 and g.grantee_id = $2 and ` + emergencyReleased + `;`
+
+	if err := s.db.GetContext(ctx, &grant, query, id, granteeID); err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			return nil, errors.Wrap(err, "get emergency access")
+		}
+
+		pending, err := s.GetEmergencyGrant(ctx, granteeID, id)
+		if err != nil {
+			return nil, err
+		}
+		if pending.GranteeID != granteeID {
+			return nil, storage.ErrEmergencyNotFound
+		}
+		return nil, storage.ErrEmergencyWaiting
+	}
+
+	return &grant, nil
+}
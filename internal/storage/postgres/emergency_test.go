@@ -0,0 +1,104 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+
+	"github.com/nbvehbq/go-password-keeper/internal/model"
+	"github.com/nbvehbq/go-password-keeper/internal/storage"
+	"github.com/pkg/errors"
+)
+
+// Keys are released to the grantee alone, and only after the wait period
+// of a request.
+func TestEmergencyAccess(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+
+	alice := createTestUser(t, s, "alice")
+	bob := createTestUser(t, s, "bob")
+	carol := createTestUser(t, s, "carol")
+
+	id, err := s.CreateEmergencyGrant(ctx, &model.EmergencyGrant{GrantorID: alice, GranteeID: bob, WaitDays: 2,
+		Key: []byte("key"), VaultKey: []byte("vault key")})
+	if err != nil {
+		t.Fatalf("create grant: %v", err)
+	}
+	if _, err := s.CreateEmergencyGrant(ctx, &model.EmergencyGrant{GrantorID: alice, GranteeID: bob, WaitDays: 1,
+		Key: []byte("key"), VaultKey: []byte("vault key")}); !errors.Is(err, storage.ErrEmergencyExists) {
+		t.Errorf("grant twice: got %v, want %v", err, storage.ErrEmergencyExists)
+	}
+	if _, err := s.CreateEmergencyGrant(ctx, &model.EmergencyGrant{GrantorID: alice, GranteeID: carol + 1000000,
+		WaitDays: 1, Key: []byte("key"), VaultKey: []byte("vault key")}); !errors.Is(err, storage.ErrUserNotFound) {
+		t.Errorf("grant to an unknown user: got %v, want %v", err, storage.ErrUserNotFound)
+	}
+
+	for _, uid := range []int64{alice, bob} {
+		list, err := s.ListEmergencyGrants(ctx, uid)
+		if err != nil {
+			t.Fatalf("list: %v", err)
+		}
+		if len(list) != 1 || list[0].ID != id || list[0].Key != nil || list[0].VaultKey != nil {
+			t.Errorf("grants of user %d: %+v", uid, list)
+		}
+	}
+	if _, err := s.GetEmergencyGrant(ctx, carol, id); !errors.Is(err, storage.ErrEmergencyNotFound) {
+		t.Errorf("get by another user: got %v, want %v", err, storage.ErrEmergencyNotFound)
+	}
+
+	if _, err := s.RequestEmergencyAccess(ctx, alice, id); !errors.Is(err, storage.ErrEmergencyNotFound) {
+		t.Errorf("request by the grantor: got %v, want %v", err, storage.ErrEmergencyNotFound)
+	}
+	if err := s.RejectEmergencyAccess(ctx, alice, id); !errors.Is(err, storage.ErrEmergencyNotPending) {
+		t.Errorf("reject without a request: got %v, want %v", err, storage.ErrEmergencyNotPending)
+	}
+
+	grant, err := s.RequestEmergencyAccess(ctx, bob, id)
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	if grant.RequestedAt == nil || grant.GrantorLogin == "" {
+		t.Errorf("requested grant %+v", grant)
+	}
+	if _, err := s.GetEmergencyAccess(ctx, bob, id); !errors.Is(err, storage.ErrEmergencyWaiting) {
+		t.Errorf("access while waiting: got %v, want %v", err, storage.ErrEmergencyWaiting)
+	}
+	if err := s.RejectEmergencyAccess(ctx, bob, id); !errors.Is(err, storage.ErrEmergencyNotFound) {
+		t.Errorf("reject by the grantee: got %v, want %v", err, storage.ErrEmergencyNotFound)
+	}
+	if err := s.RejectEmergencyAccess(ctx, alice, id); err != nil {
+		t.Fatalf("reject: %v", err)
+	}
+
+	if _, err := s.RequestEmergencyAccess(ctx, bob, id); err != nil {
+		t.Fatalf("request again: %v", err)
+	}
+	if _, err := s.db.ExecContext(ctx, `UPDATE emergency_grant SET requested_at = now() - interval '3 days'
+	WHERE id = $1;`, id); err != nil {
+		t.Fatalf("backdate request: %v", err)
+	}
+
+	if _, err := s.GetEmergencyAccess(ctx, alice, id); !errors.Is(err, storage.ErrEmergencyNotFound) {
+		t.Errorf("access by the grantor: got %v, want %v", err, storage.ErrEmergencyNotFound)
+	}
+	grant, err = s.GetEmergencyAccess(ctx, bob, id)
+	if err != nil {
+		t.Fatalf("access: %v", err)
+	}
+	if string(grant.Key) != "key" || string(grant.VaultKey) != "vault key" {
+		t.Errorf("released keys %q, %q", grant.Key, grant.VaultKey)
+	}
+	if err := s.RejectEmergencyAccess(ctx, alice, id); !errors.Is(err, storage.ErrEmergencyNotPending) {
+		t.Errorf("reject after release: got %v, want %v", err, storage.ErrEmergencyNotPending)
+	}
+
+	if err := s.DeleteEmergencyGrant(ctx, carol, id); !errors.Is(err, storage.ErrEmergencyNotFound) {
+		t.Errorf("delete by another user: got %v, want %v", err, storage.ErrEmergencyNotFound)
+	}
+	if err := s.DeleteEmergencyGrant(ctx, bob, id); err != nil {
+		t.Fatalf("delete by the grantee: %v", err)
+	}
+	if _, err := s.GetEmergencyAccess(ctx, bob, id); !errors.Is(err, storage.ErrEmergencyNotFound) {
+		t.Errorf("access after delete: got %v, want %v", err, storage.ErrEmergencyNotFound)
+	}
+}
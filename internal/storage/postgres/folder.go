@@ -0,0 +1,235 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/nbvehbq/go-password-keeper/internal/model"
+	"github.com/nbvehbq/go-password-keeper/internal/storage"
+	"github.com/pkg/errors"
+)
+
+func (s *Storage) CreateFolder(ctx context.Context, folder *model.Folder) (int64, error) {
+	if folder.ParentID != 0 {
+		if err := checkFolder(ctx, s.db, folder.UserID, folder.ParentID); err != nil {
+			return 0, err
+		}
+	}
+
+	query := `INSERT INTO folder (user_id, parent_id, name, key) VALUES ($1, nullif($2, 0), $3, $4) RETURNING id;`
+
+	var id int64
+	if err := s.db.QueryRowContext(ctx, query, folder.UserID, folder.ParentID, folder.Name, folder.Key).
+		Scan(&id); err != nil {
+		return 0, errors.Wrap(err, "create folder")
+	}
+
+	return id, nil
+}
+
+func (s *Storage) ListFolders(ctx context.Context, userID int64) ([]model.Folder, error) {
+	folders := make([]model.Folder, 0)
+
+	query := `SELECT id, user_id, coalesce(parent_id, 0) AS parent_id, name, key FROM folder WHERE user_id = $1 ORDER BY id;`
+	if err := s.db.SelectContext(ctx, &folders, query, userID); err != nil {
+		return nil, errors.Wrap(err, "list folders")
+	}
+
+	return folders, nil
+}
+
+// DeleteFolder removes an empty folder. Folders holding secrets or other
+// folders are reported as storage.ErrFolderNotEmpty.
+func (s *Storage) DeleteFolder(ctx context.Context, userID, id int64) error {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "begin tx")
+	}
+	defer tx.Rollback()
+
+	if err := lockVault(ctx, tx, userID); err != nil {
+		return err
+	}
+
+	if err := checkFolder(ctx, tx, userID, id); err != nil {
+		return err
+	}
+
+	var used bool
+	query := `SELECT exists(SELECT 1 FROM folder WHERE parent_id = $1) or exists(SELECT 1 FROM secret WHERE folder_id = $1);`
+	if err := tx.GetContext(ctx, &used, query, id); err != nil {
+		return errors.Wrap(err, "check folder")
+	}
+	if used {
+		return storage.ErrFolderNotEmpty
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM folder WHERE id = $1;`, id); err != nil {
+		return errors.Wrap(err, "delete folder")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.Wrap(err, "commit tx")
+	}
+
+	return nil
+}
+
+func (s *Storage) CreateTag(ctx context.Context, tag *model.Tag) (int64, error) {
+	query := `INSERT INTO tag (user_id, name, key) VALUES ($1, $2, $3) RETURNING id;`
+
+	var id int64
+	if err := s.db.QueryRowContext(ctx, query, tag.UserID, tag.Name, tag.Key).Scan(&id); err != nil {
+		return 0, errors.Wrap(err, "create tag")
+	}
+
+	return id, nil
+}
+
+func (s *Storage) ListTags(ctx context.Context, userID int64) ([]model.Tag, error) {
+	tags := make([]model.Tag, 0)
+
+	query := `SELECT id, user_id, name, key FROM tag WHERE user_id = $1 ORDER BY id;`
+	if err := s.db.SelectContext(ctx, &tags, query, userID); err != nil {
+		return nil, errors.Wrap(err, "list tags")
+	}
+
+	return tags, nil
+}
+
+// PatchSecret moves a secret to another folder, marks it as favorite or
+// replaces its tags. The revision is kept, but the change is picked up by
+// incremental sync.
+func (s *Storage) PatchSecret(ctx context.Context, userID, id int64, patch *model.SecretPatch) error {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "begin tx")
+	}
+	defer tx.Rollback()
+
+	if err := lockVault(ctx, tx, userID); err != nil {
+		return err
+	}
+
+	var folderID int64
+	if patch.FolderID != nil {
+		folderID = *patch.FolderID
+		if folderID != 0 {
+			if err := checkFolder(ctx, tx, userID, folderID); err != nil {
+				return err
+			}
+		}
+	}
+
+	query := `UPDATE secret SET
+	    folder_id = CASE WHEN $3::boolean THEN nullif($4, 0) ELSE folder_id END,
+	    favorite = coalesce($5::boolean, favorite),
+	    change_seq = nextval('secret_change_seq'), updated_at = now()
+	WHERE id = $1 and user_id = $2;`
+
+	res, err := tx.ExecContext(ctx, query, id, userID, patch.FolderID != nil, folderID, patch.Favorite)
+	if err != nil {
+		return errors.Wrap(err, "patch secret")
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err, "patch secret")
+	}
+	if n == 0 {
+		return storage.ErrSecretNotFound
+	}
+
+	if patch.SetTags {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM secret_tag WHERE secret_id = $1;`, id); err != nil {
+			return errors.Wrap(err, "clear tags")
+		}
+		if err := setTags(ctx, tx, userID, id, patch.Tags); err != nil {
+			return err
+		}
+	}
+
+	if err := auditSecret(ctx, tx, userID, userID, model.AuditSecretOrganized, fmt.Sprintf("secret %d", id)); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.Wrap(err, "commit tx")
+	}
+
+	return nil
+}
+
+// checkFolder reports storage.ErrFolderNotFound unless the folder belongs
+// to the user.
+func checkFolder(ctx context.Context, q sqlx.QueryerContext, userID, id int64) error {
+	var exists bool
+
+	query := `SELECT exists(SELECT 1 FROM folder WHERE id = $1 and user_id = $2);`
+	if err := sqlx.GetContext(ctx, q, &exists, query, id, userID); err != nil {
+		return errors.Wrap(err, "check folder")
+	}
+	if !exists {
+		return storage.ErrFolderNotFound
+	}
+
+	return nil
+}
+
+// setTags adds tags of the user to a secret.
+func setTags(ctx context.Context, tx *sqlx.Tx, userID, secretID int64, tags []int64) error {
+	tags = slices.Compact(slices.Sorted(slices.Values(tags)))
+	if len(tags) == 0 {
+		return nil
+	}
+
+	var owned int
+	query := `SELECT count(*) FROM tag WHERE user_id = $1 and id = ANY($2);`
+	if err := tx.GetContext(ctx, &owned, query, userID, tags); err != nil {
+		return errors.Wrap(err, "check tags")
+	}
+	if owned != len(tags) {
+		return storage.ErrTagNotFound
+	}
+
+	query = `INSERT INTO secret_tag (secret_id, tag_id) SELECT $1, unnest($2::int[]);`
+	if _, err := tx.ExecContext(ctx, query, secretID, tags); err != nil {
+		return errors.Wrap(err, "set tags")
+	}
+
+	return nil
+}
+
+// attachTags fills in the tags of secrets of the user.
+func attachTags(ctx context.Context, q sqlx.QueryerContext, userID int64, secrets []model.Secret) error {
+	if len(secrets) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, len(secrets))
+	index := make(map[int64]int, len(secrets))
+	for i, v := range secrets {
+		ids[i] = v.ID
+		index[v.ID] = i
+	}
+
+	var rows []struct {
+		SecretID int64 `db:"secret_id"`
+		TagID    int64 `db:"tag_id"`
+	}
+	query := `SELECT st.secret_id, st.tag_id FROM secret_tag st JOIN secret s ON s.id = st.secret_id
+	WHERE s.user_id = $1 and st.secret_id = ANY($2) ORDER BY st.tag_id;`
+	if err := sqlx.SelectContext(ctx, q, &rows, query, userID, ids); err != nil {
+		return errors.Wrap(err, "list secret tags")
+	}
+
+	for _, v := range rows {
+		if i, ok := index[v.SecretID]; ok {
+			secrets[i].Tags = append(secrets[i].Tags, v.TagID)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,123 @@
+package postgres
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/nbvehbq/go-password-keeper/internal/model"
+	"github.com/nbvehbq/go-password-keeper/internal/storage"
+	"github.com/pkg/errors"
+)
+
+// Folders and tags organize the secrets of their owner only; organizing a
+// secret keeps its revision but is synced.
+func TestFoldersAndTags(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+
+	alice := createTestUser(t, s, "alice")
+	bob := createTestUser(t, s, "bob")
+
+	work, err := s.CreateFolder(ctx, &model.Folder{UserID: alice, Name: []byte("work")})
+	if err != nil {
+		t.Fatalf("create folder: %v", err)
+	}
+	banking, err := s.CreateFolder(ctx, &model.Folder{UserID: alice, ParentID: work, Name: []byte("banking")})
+	if err != nil {
+		t.Fatalf("create subfolder: %v", err)
+	}
+	if _, err := s.CreateFolder(ctx, &model.Folder{UserID: bob, ParentID: work, Name: []byte("x")}); !errors.Is(err, storage.ErrFolderNotFound) {
+		t.Errorf("subfolder of another user's folder: got %v, want %v", err, storage.ErrFolderNotFound)
+	}
+
+	tag, err := s.CreateTag(ctx, &model.Tag{UserID: alice, Name: []byte("2fa")})
+	if err != nil {
+		t.Fatalf("create tag: %v", err)
+	}
+	bobsTag, err := s.CreateTag(ctx, &model.Tag{UserID: bob, Name: []byte("x")})
+	if err != nil {
+		t.Fatalf("create tag: %v", err)
+	}
+
+	id, err := s.CreateSecret(ctx, &model.Secret{UserID: alice, Name: "bank", Type: model.TextType,
+		Payload: []byte("p"), FolderID: banking, Tags: []int64{tag}})
+	if err != nil {
+		t.Fatalf("create secret: %v", err)
+	}
+	if _, err := s.CreateSecret(ctx, &model.Secret{UserID: alice, Name: "other", Type: model.TextType,
+		Payload: []byte("p"), Tags: []int64{bobsTag}}); !errors.Is(err, storage.ErrTagNotFound) {
+		t.Errorf("tag of another user: got %v, want %v", err, storage.ErrTagNotFound)
+	}
+	if _, err := s.CreateSecret(ctx, &model.Secret{UserID: bob, Name: "bank", Type: model.TextType,
+		Payload: []byte("p"), FolderID: work}); !errors.Is(err, storage.ErrFolderNotFound) {
+		t.Errorf("folder of another user: got %v, want %v", err, storage.ErrFolderNotFound)
+	}
+
+	listIDs := func(filter model.SecretFilter) []int64 {
+		t.Helper()
+
+		list, err := s.ListSecrets(ctx, alice, filter)
+		if err != nil {
+			t.Fatalf("list: %v", err)
+		}
+		var ids []int64
+		for _, v := range list {
+			ids = append(ids, v.ID)
+		}
+		return ids
+	}
+	if ids := listIDs(model.SecretFilter{FolderID: work}); !slices.Equal(ids, []int64{id}) {
+		t.Errorf("secrets in the parent folder: %v, want %v", ids, []int64{id})
+	}
+	if ids := listIDs(model.SecretFilter{TagID: tag}); !slices.Equal(ids, []int64{id}) {
+		t.Errorf("secrets with tag: %v, want %v", ids, []int64{id})
+	}
+	if ids := listIDs(model.SecretFilter{Favorite: true}); len(ids) != 0 {
+		t.Errorf("favorites: %v, want none", ids)
+	}
+
+	if err := s.DeleteFolder(ctx, alice, work); !errors.Is(err, storage.ErrFolderNotEmpty) {
+		t.Errorf("delete a folder with subfolders: got %v, want %v", err, storage.ErrFolderNotEmpty)
+	}
+	if err := s.DeleteFolder(ctx, bob, banking); !errors.Is(err, storage.ErrFolderNotFound) {
+		t.Errorf("delete by another user: got %v, want %v", err, storage.ErrFolderNotFound)
+	}
+
+	before, err := s.Changes(ctx, alice, 0)
+	if err != nil {
+		t.Fatalf("changes: %v", err)
+	}
+
+	top, favorite := int64(0), true
+	if err := s.PatchSecret(ctx, alice, id, &model.SecretPatch{FolderID: &top, Favorite: &favorite,
+		SetTags: true}); err != nil {
+		t.Fatalf("patch: %v", err)
+	}
+	if err := s.PatchSecret(ctx, bob, id, &model.SecretPatch{Favorite: &favorite}); !errors.Is(err, storage.ErrSecretNotFound) {
+		t.Errorf("patch by another user: got %v, want %v", err, storage.ErrSecretNotFound)
+	}
+
+	secret, err := s.GetSecret(ctx, alice, id)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if secret.FolderID != 0 || !secret.Favorite || secret.Revision != 1 {
+		t.Errorf("patched secret: folder %d, favorite %v, revision %d", secret.FolderID, secret.Favorite, secret.Revision)
+	}
+	if ids := listIDs(model.SecretFilter{TagID: tag}); len(ids) != 0 {
+		t.Errorf("secrets with a removed tag: %v", ids)
+	}
+
+	changes, err := s.Changes(ctx, alice, before.Cursor)
+	if err != nil {
+		t.Fatalf("changes: %v", err)
+	}
+	if len(changes.Updated) != 1 || !changes.Updated[0].Favorite {
+		t.Errorf("patch not synced: %+v", changes.Updated)
+	}
+
+	if err := s.DeleteFolder(ctx, alice, banking); err != nil {
+		t.Errorf("delete an empty folder: %v", err)
+	}
+}
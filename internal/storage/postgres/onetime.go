@@ -0,0 +1,74 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/nbvehbq/go-password-keeper/internal/model"
+	"github.com/nbvehbq/go-password-keeper/internal/storage"
+	"github.com/pkg/errors"
+)
+
+// CreateOneTimeSecret stores a one-time secret under the hash of secret.ID.
+func (s *Storage) CreateOneTimeSecret(ctx context.Context, secret *model.OneTimeSecret) error {
+	query := `INSERT INTO onetime_secret (id_hash, user_id, payload, max_views, expires_at) VALUES ($1, $2, $3, $4, $5);`
+
+	if _, err := s.db.ExecContext(ctx, query, hashToken(secret.ID), secret.UserID, secret.Payload, secret.MaxViews,
+		secret.ExpiresAt); err != nil {
+		return errors.Wrap(err, "create one-time secret")
+	}
+
+	return nil
+}
+
+// TakeOneTimeSecret counts a view of a one-time secret and returns it,
+// deleting it on the last view. Expired and used up secrets are reported as
+// storage.ErrOneTimeNotFound.
+func (s *Storage) TakeOneTimeSecret(ctx context.Context, id string) (*model.OneTimeSecret, error) {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "begin tx")
+	}
+	defer tx.Rollback()
+
+	var secret model.OneTimeSecret
+	query := `UPDATE onetime_secret SET views = views + 1
+	WHERE id_hash = $1 and expires_at > now() and views < max_views
+	RETURNING user_id, payload, max_views, views, expires_at;`
+
+	if err := tx.GetContext(ctx, &secret, query, hashToken(id)); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, storage.ErrOneTimeNotFound
+		}
+		return nil, errors.Wrap(err, "take one-time secret")
+	}
+
+	if secret.Views >= secret.MaxViews {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM onetime_secret WHERE id_hash = $1;`, hashToken(id)); err != nil {
+			return nil, errors.Wrap(err, "burn one-time secret")
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, errors.Wrap(err, "commit tx")
+	}
+
+	secret.ID = id
+	return &secret, nil
+}
+
+// DeleteExpiredOneTimeSecrets deletes expired one-time secrets and returns
+// how many there were.
+func (s *Storage) DeleteExpiredOneTimeSecrets(ctx context.Context) (int64, error) {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM onetime_secret WHERE expires_at <= now();`)
+	if err != nil {
+		return 0, errors.Wrap(err, "delete expired one-time secrets")
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, errors.Wrap(err, "delete expired one-time secrets")
+	}
+
+	return n, nil
+}
@@ -0,0 +1,79 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nbvehbq/go-password-keeper/internal/model"
+	"github.com/nbvehbq/go-password-keeper/internal/storage"
+	"github.com/pkg/errors"
+)
+
+// A one-time secret is gone after its last view, and only the hash of its
+// ID is kept.
+func TestOneTimeSecret(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+
+	alice := createTestUser(t, s, "alice")
+	id := "onetime-" + time.Now().Format(time.RFC3339Nano)
+
+	if err := s.CreateOneTimeSecret(ctx, &model.OneTimeSecret{ID: id, UserID: alice, Payload: []byte("sealed"),
+		MaxViews: 2, ExpiresAt: time.Now().Add(time.Hour)}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	var n int
+	if err := s.db.GetContext(ctx, &n, `SELECT count(*) FROM onetime_secret WHERE id_hash = $1;`,
+		hashToken(id)); err != nil || n != 1 {
+		t.Fatalf("stored by hash: %d, %v", n, err)
+	}
+
+	if _, err := s.TakeOneTimeSecret(ctx, id+"x"); !errors.Is(err, storage.ErrOneTimeNotFound) {
+		t.Errorf("take an unknown secret: got %v, want %v", err, storage.ErrOneTimeNotFound)
+	}
+
+	for views := 1; views <= 2; views++ {
+		secret, err := s.TakeOneTimeSecret(ctx, id)
+		if err != nil {
+			t.Fatalf("view %d: %v", views, err)
+		}
+		if secret.ID != id || string(secret.Payload) != "sealed" || secret.Views != views {
+			t.Errorf("view %d: %+v", views, secret)
+		}
+	}
+	if _, err := s.TakeOneTimeSecret(ctx, id); !errors.Is(err, storage.ErrOneTimeNotFound) {
+		t.Errorf("view after the last one: got %v, want %v", err, storage.ErrOneTimeNotFound)
+	}
+	if err := s.db.GetContext(ctx, &n, `SELECT count(*) FROM onetime_secret WHERE id_hash = $1;`,
+		hashToken(id)); err != nil || n != 0 {
+		t.Errorf("used up secret kept: %d, %v", n, err)
+	}
+}
+
+// Expired secrets can't be read even before they are reaped.
+func TestExpiredOneTimeSecret(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+
+	alice := createTestUser(t, s, "alice")
+	id := "expired-" + time.Now().Format(time.RFC3339Nano)
+
+	if err := s.CreateOneTimeSecret(ctx, &model.OneTimeSecret{ID: id, UserID: alice, Payload: []byte("sealed"),
+		MaxViews: 1, ExpiresAt: time.Now().Add(-time.Second)}); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	if _, err := s.TakeOneTimeSecret(ctx, id); !errors.Is(err, storage.ErrOneTimeNotFound) {
+		t.Errorf("take an expired secret: got %v, want %v", err, storage.ErrOneTimeNotFound)
+	}
+
+	n, err := s.DeleteExpiredOneTimeSecrets(ctx)
+	if err != nil {
+		t.Fatalf("delete expired: %v", err)
+	}
+	if n < 1 {
+		t.Errorf("deleted %d expired secrets, want at least 1", n)
+	}
+}
@@ -0,0 +1,383 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/jackc/pgerrcode"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jmoiron/sqlx"
+	"github.com/nbvehbq/go-password-keeper/internal/model"
+	"github.com/nbvehbq/go-password-keeper/internal/storage"
+	"github.com/pkg/errors"
+)
+
+const orgSecretColumns = `id, org_id, collection_id, "name", type, payload, meta, key, revision,
+    coalesce(updated_by, 0) AS updated_by, updated_at`
+
+// CreateOrganization creates an organization with ownerID as its first
+// owner. org.Key is the private key of the organization wrapped for them.
+func (s *Storage) CreateOrganization(ctx context.Context, org *model.Organization, ownerID int64) (int64, error) {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, errors.Wrap(err, "begin tx")
+	}
+	defer tx.Rollback()
+
+	var id int64
+	query := `INSERT INTO organization ("name", public_key) VALUES ($1, $2) RETURNING id;`
+	if err := tx.QueryRowContext(ctx, query, org.Name, org.PublicKey).Scan(&id); err != nil {
+		return 0, errors.Wrap(err, "create organization")
+	}
+
+	query = `INSERT INTO org_member (org_id, user_id, role, key) VALUES ($1, $2, $3, $4);`
+	if _, err := tx.ExecContext(ctx, query, id, ownerID, model.RoleOwner, org.Key); err != nil {
+		return 0, errors.Wrap(err, "add owner")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, errors.Wrap(err, "commit tx")
+	}
+
+	return id, nil
+}
+
+// ListOrganizations returns the organizations the user is a member of.
+func (s *Storage) ListOrganizations(ctx context.Context, userID int64) ([]model.Organization, error) {
+	orgs := make([]model.Organization, 0)
+
+	query := `SELECT o.id, o.name, o.public_key, m.key, m.role, o.created_at
+	FROM org_member m JOIN organization o ON o.id = m.org_id
+	WHERE m.user_id = $1 ORDER BY o.name;`
+
+	if err := s.db.SelectContext(ctx, &orgs, query, userID); err != nil {
+		return nil, errors.Wrap(err, "list organizations")
+	}
+
+	return orgs, nil
+}
+
+// GetOrganization returns an organization as seen by its member userID.
+func (s *Storage) GetOrganization(ctx context.Context, id, userID int64) (*model.Organization, error) {
+	var org model.Organization
+
+	query := `SELECT o.id, o.name, o.public_key, m.key, m.role, o.created_at
+	FROM org_member m JOIN organization o ON o.id = m.org_id
+	WHERE o.id = $1 and m.user_id = $2;`
+
+	if err := s.db.GetContext(ctx, &org, query, id, userID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, storage.ErrOrgNotFound
+		}
+		return nil, errors.Wrap(err, "get organization")
+	}
+
+	return &org, nil
+}
+
+// DeleteOrganization deletes an organization with its members, collections
+// and secrets.
+func (s *Storage) DeleteOrganization(ctx context.Context, id int64) error {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM organization WHERE id = $1;`, id)
+	if err != nil {
+		return errors.Wrap(err, "delete organization")
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err, "delete organization")
+	}
+	if n == 0 {
+		return storage.ErrOrgNotFound
+	}
+
+	return nil
+}
+
+// GetMember returns the membership of userID in an organization, including
+// the wrapped organization key.
+func (s *Storage) GetMember(ctx context.Context, orgID, userID int64) (*model.Member, error) {
+	var member model.Member
+
+	query := `SELECT m.org_id, m.user_id, u.login, m.role, m.key, m.created_at
+	FROM org_member m JOIN "user" u ON u.id = m.user_id
+	WHERE m.org_id = $1 and m.user_id = $2;`
+
+	if err := s.db.GetContext(ctx, &member, query, orgID, userID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, storage.ErrMemberNotFound
+		}
+		return nil, errors.Wrap(err, "get member")
+	}
+
+	return &member, nil
+}
+
+// ListMembers returns the members of an organization without their keys.
+func (s *Storage) ListMembers(ctx context.Context, orgID int64) ([]model.Member, error) {
+	members := make([]model.Member, 0)
+
+	query := `SELECT m.org_id, m.user_id, u.login, m.role, m.created_at
+	FROM org_member m JOIN "user" u ON u.id = m.user_id
+	WHERE m.org_id = $1 ORDER BY u.login;`
+
+	if err := s.db.SelectContext(ctx, &members, query, orgID); err != nil {
+		return nil, errors.Wrap(err, "list members")
+	}
+
+	return members, nil
+}
+
+// AddMember adds a user to an organization. It fails with
+// storage.ErrUserNotFound for unknown users and storage.ErrMemberExists for
+// members.
+func (s *Storage) AddMember(ctx context.Context, member *model.Member) error {
+	query := `INSERT INTO org_member (org_id, user_id, role, key) VALUES ($1, $2, $3, $4);`
+
+	if _, err := s.db.ExecContext(ctx, query, member.OrgID, member.UserID, member.Role, member.Key); err != nil {
+		var pqErr *pgconn.PgError
+		if errors.As(err, &pqErr) {
+			switch pqErr.Code {
+			case pgerrcode.UniqueViolation:
+				return storage.ErrMemberExists
+			case pgerrcode.ForeignKeyViolation:
+				return storage.ErrUserNotFound
+			}
+		}
+		return errors.Wrap(err, "add member")
+	}
+
+	return nil
+}
+
+// UpdateMemberRole changes the role of a member. Demoting the last owner
+// fails with storage.ErrLastOwner.
+func (s *Storage) UpdateMemberRole(ctx context.Context, orgID, userID int64, role model.Role) error {
+	return s.changeMember(ctx, orgID, userID,
+		`UPDATE org_member SET role = $3 WHERE org_id = $1 and user_id = $2;`, role)
+}
+
+// DeleteMember removes a member from an organization. Removing the last
+// owner fails with storage.ErrLastOwner.
+func (s *Storage) DeleteMember(ctx context.Context, orgID, userID int64) error {
+	return s.changeMember(ctx, orgID, userID,
+		`DELETE FROM org_member WHERE org_id = $1 and user_id = $2;`)
+}
+
+// changeMember runs query on the membership of userID and makes sure the
+// organization still has an owner afterwards.
+func (s *Storage) changeMember(ctx context.Context, orgID, userID int64, query string, args ...any) error {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "begin tx")
+	}
+	defer tx.Rollback()
+
+	if err := lockOrg(ctx, tx, orgID); err != nil {
+		return err
+	}
+
+	res, err := tx.ExecContext(ctx, query, append([]any{orgID, userID}, args...)...)
+	if err != nil {
+		return errors.Wrap(err, "change member")
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err, "change member")
+	}
+	if n == 0 {
+		return storage.ErrMemberNotFound
+	}
+
+	var owners int
+	query = `SELECT count(*) FROM org_member WHERE org_id = $1 and role = $2;`
+	if err := tx.GetContext(ctx, &owners, query, orgID, model.RoleOwner); err != nil {
+		return errors.Wrap(err, "count owners")
+	}
+	if owners == 0 {
+		return storage.ErrLastOwner
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.Wrap(err, "commit tx")
+	}
+
+	return nil
+}
+
+// lockOrg serializes membership changes of an organization until tx ends.
+func lockOrg(ctx context.Context, tx *sqlx.Tx, orgID int64) error {
+	var id int64
+	if err := tx.GetContext(ctx, &id, `SELECT id FROM organization WHERE id = $1 FOR UPDATE;`, orgID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return storage.ErrOrgNotFound
+		}
+		return errors.Wrap(err, "lock organization")
+	}
+
+	return nil
+}
+
+func (s *Storage) CreateCollection(ctx context.Context, collection *model.Collection) (int64, error) {
+	var id int64
+	query := `INSERT INTO collection (org_id, "name") VALUES ($1, $2) RETURNING id;`
+
+	if err := s.db.QueryRowContext(ctx, query, collection.OrgID, collection.Name).Scan(&id); err != nil {
+		var pqErr *pgconn.PgError
+		if errors.As(err, &pqErr) && pgerrcode.UniqueViolation == pqErr.Code {
+			return 0, storage.ErrCollectionExists
+		}
+		return 0, errors.Wrap(err, "create collection")
+	}
+
+	return id, nil
+}
+
+func (s *Storage) ListCollections(ctx context.Context, orgID int64) ([]model.Collection, error) {
+	collections := make([]model.Collection, 0)
+
+	query := `SELECT id, org_id, "name" FROM collection WHERE org_id = $1 ORDER BY "name";`
+	if err := s.db.SelectContext(ctx, &collections, query, orgID); err != nil {
+		return nil, errors.Wrap(err, "list collections")
+	}
+
+	return collections, nil
+}
+
+// DeleteCollection deletes an empty collection of an organization.
+func (s *Storage) DeleteCollection(ctx context.Context, orgID, id int64) error {
+	query := `DELETE FROM collection WHERE id = $1 and org_id = $2
+	    and not exists(SELECT 1 FROM org_secret WHERE collection_id = $1);`
+
+	res, err := s.db.ExecContext(ctx, query, id, orgID)
+	if err != nil {
+		return errors.Wrap(err, "delete collection")
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err, "delete collection")
+	}
+	if n == 0 {
+		if err := checkCollection(ctx, s.db, orgID, id); err != nil {
+			return err
+		}
+		return storage.ErrCollectionNotEmpty
+	}
+
+	return nil
+}
+
+// checkCollection reports storage.ErrCollectionNotFound unless the
+// collection belongs to the organization.
+func checkCollection(ctx context.Context, q sqlx.QueryerContext, orgID, id int64) error {
+	var exists bool
+
+	query := `SELECT exists(SELECT 1 FROM collection WHERE id = $1 and org_id = $2);`
+	if err := sqlx.GetContext(ctx, q, &exists, query, id, orgID); err != nil {
+		return errors.Wrap(err, "check collection")
+	}
+	if !exists {
+		return storage.ErrCollectionNotFound
+	}
+
+	return nil
+}
+
+// CreateOrgSecret adds a secret to a collection of data.OrgID. Names are
+// unique per collection.
+func (s *Storage) CreateOrgSecret(ctx context.Context, data *model.OrgSecret) (int64, error) {
+	query := `INSERT INTO org_secret (org_id, collection_id, "name", type, payload, meta, key, updated_by)
+	SELECT org_id, id, $3, $4, $5, $6, $7, $8 FROM collection WHERE id = $2 and org_id = $1 RETURNING id;`
+
+	var id int64
+	if err := s.db.QueryRowContext(ctx, query, data.OrgID, data.CollectionID, data.Name, data.Type, data.Payload,
+		data.Meta, data.Key, data.UpdatedBy).Scan(&id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, storage.ErrCollectionNotFound
+		}
+		var pqErr *pgconn.PgError
+		if errors.As(err, &pqErr) && pgerrcode.UniqueViolation == pqErr.Code {
+			return 0, storage.ErrSecretExists
+		}
+		return 0, errors.Wrap(err, "create org secret")
+	}
+
+	return id, nil
+}
+
+// ListOrgSecrets returns the secrets of an organization, of one collection
+// unless collectionID is zero.
+func (s *Storage) ListOrgSecrets(ctx context.Context, orgID, collectionID int64) ([]model.OrgSecret, error) {
+	secrets := make([]model.OrgSecret, 0)
+
+	query := `SELECT ` + orgSecretColumns + ` FROM org_secret
+	WHERE org_id = $1 and ($2::bigint = 0 or collection_id = $2) ORDER BY id;`
+
+	if err := s.db.SelectContext(ctx, &secrets, query, orgID, collectionID); err != nil {
+		return nil, errors.Wrap(err, "list org secrets")
+	}
+
+	return secrets, nil
+}
+
+func (s *Storage) GetOrgSecret(ctx context.Context, orgID, id int64) (*model.OrgSecret, error) {
+	var secret model.OrgSecret
+
+	query := `SELECT ` + orgSecretColumns + ` FROM org_secret WHERE id = $1 and org_id = $2;`
+	if err := s.db.GetContext(ctx, &secret, query, id, orgID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, storage.ErrSecretNotFound
+		}
+		return nil, errors.Wrap(err, "get org secret")
+	}
+
+	return &secret, nil
+}
+
+// UpdateOrgSecret overwrites a secret of an organization, checking
+// data.Revision like UpdateSecret. The collection is kept.
+func (s *Storage) UpdateOrgSecret(ctx context.Context, orgID, id int64, data *model.OrgSecret) (int64, error) {
+	query := `UPDATE org_secret SET type = $3, payload = $4, meta = $5, key = $6, updated_by = $7,
+	    revision = revision + 1, updated_at = now()
+	WHERE id = $1 and org_id = $2 and revision = $8 RETURNING revision;`
+
+	var revision int64
+	if err := s.db.QueryRowContext(ctx, query, id, orgID, data.Type, data.Payload, data.Meta, data.Key,
+		data.UpdatedBy, data.Revision).Scan(&revision); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			if _, err := s.GetOrgSecret(ctx, orgID, id); err != nil {
+				return 0, err
+			}
+			return 0, storage.ErrRevisionMismatch
+		}
+		return 0, errors.Wrap(err, "update org secret")
+	}
+
+	return revision, nil
+}
+
+// DeleteOrgSecret deletes a secret of an organization, checking revision
+// like DeleteSecret.
+func (s *Storage) DeleteOrgSecret(ctx context.Context, orgID, id, revision int64) error {
+	query := `DELETE FROM org_secret WHERE id = $1 and org_id = $2 and revision = $3;`
+
+	res, err := s.db.ExecContext(ctx, query, id, orgID, revision)
+	if err != nil {
+		return errors.Wrap(err, "delete org secret")
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err, "delete org secret")
+	}
+	if n == 0 {
+		if _, err := s.GetOrgSecret(ctx, orgID, id); err != nil {
+			return err
+		}
+		return storage.ErrRevisionMismatch
+	}
+
+	return nil
+}
@@ -0,0 +1,149 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+
+	"github.com/nbvehbq/go-password-keeper/internal/model"
+	"github.com/nbvehbq/go-password-keeper/internal/storage"
+	"github.com/pkg/errors"
+)
+
+// An organization always keeps an owner, so ownership is transferred by
+// promoting another member first.
+func TestOrgMembers(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+
+	alice := createTestUser(t, s, "alice")
+	bob := createTestUser(t, s, "bob")
+	carol := createTestUser(t, s, "carol")
+
+	org, err := s.CreateOrganization(ctx, &model.Organization{Name: "team", PublicKey: []byte("pub"),
+		Key: []byte("key")}, alice)
+	if err != nil {
+		t.Fatalf("create organization: %v", err)
+	}
+
+	if _, err := s.GetOrganization(ctx, org, bob); !errors.Is(err, storage.ErrOrgNotFound) {
+		t.Errorf("get by a non-member: got %v, want %v", err, storage.ErrOrgNotFound)
+	}
+	if list, err := s.ListOrganizations(ctx, bob); err != nil || len(list) != 0 {
+		t.Errorf("organizations of a non-member: %v, %v", list, err)
+	}
+
+	if err := s.AddMember(ctx, &model.Member{OrgID: org, UserID: bob, Role: model.RoleMember,
+		Key: []byte("key")}); err != nil {
+		t.Fatalf("add member: %v", err)
+	}
+	if err := s.AddMember(ctx, &model.Member{OrgID: org, UserID: bob, Role: model.RoleAdmin,
+		Key: []byte("key")}); !errors.Is(err, storage.ErrMemberExists) {
+		t.Errorf("add twice: got %v, want %v", err, storage.ErrMemberExists)
+	}
+	if err := s.AddMember(ctx, &model.Member{OrgID: org, UserID: carol + 1000000, Role: model.RoleMember,
+		Key: []byte("key")}); !errors.Is(err, storage.ErrUserNotFound) {
+		t.Errorf("add an unknown user: got %v, want %v", err, storage.ErrUserNotFound)
+	}
+
+	member, err := s.GetMember(ctx, org, bob)
+	if err != nil {
+		t.Fatalf("get member: %v", err)
+	}
+	if member.Role != model.RoleMember || string(member.Key) != "key" {
+		t.Errorf("member %+v", member)
+	}
+	if _, err := s.GetMember(ctx, org, carol); !errors.Is(err, storage.ErrMemberNotFound) {
+		t.Errorf("get a non-member: got %v, want %v", err, storage.ErrMemberNotFound)
+	}
+
+	if err := s.UpdateMemberRole(ctx, org, alice, model.RoleAdmin); !errors.Is(err, storage.ErrLastOwner) {
+		t.Errorf("demote the last owner: got %v, want %v", err, storage.ErrLastOwner)
+	}
+	if err := s.DeleteMember(ctx, org, alice); !errors.Is(err, storage.ErrLastOwner) {
+		t.Errorf("remove the last owner: got %v, want %v", err, storage.ErrLastOwner)
+	}
+
+	if err := s.UpdateMemberRole(ctx, org, bob, model.RoleOwner); err != nil {
+		t.Fatalf("promote: %v", err)
+	}
+	if err := s.UpdateMemberRole(ctx, org, alice, model.RoleAdmin); err != nil {
+		t.Fatalf("demote after transfer: %v", err)
+	}
+	if err := s.DeleteMember(ctx, org, bob); !errors.Is(err, storage.ErrLastOwner) {
+		t.Errorf("remove the new owner: got %v, want %v", err, storage.ErrLastOwner)
+	}
+	if err := s.DeleteMember(ctx, org, carol); !errors.Is(err, storage.ErrMemberNotFound) {
+		t.Errorf("remove a non-member: got %v, want %v", err, storage.ErrMemberNotFound)
+	}
+
+	if err := s.DeleteOrganization(ctx, org); err != nil {
+		t.Fatalf("delete organization: %v", err)
+	}
+	if _, err := s.GetMember(ctx, org, bob); !errors.Is(err, storage.ErrMemberNotFound) {
+		t.Errorf("member of a deleted organization: got %v, want %v", err, storage.ErrMemberNotFound)
+	}
+}
+
+// Secrets and collections belong to one organization.
+func TestOrgSecrets(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+
+	alice := createTestUser(t, s, "alice")
+
+	var orgs, collections []int64
+	for _, name := range []string{"team", "other"} {
+		org, err := s.CreateOrganization(ctx, &model.Organization{Name: name, PublicKey: []byte("pub"),
+			Key: []byte("key")}, alice)
+		if err != nil {
+			t.Fatalf("create organization: %v", err)
+		}
+		collection, err := s.CreateCollection(ctx, &model.Collection{OrgID: org, Name: "ops"})
+		if err != nil {
+			t.Fatalf("create collection: %v", err)
+		}
+		orgs, collections = append(orgs, org), append(collections, collection)
+	}
+
+	if _, err := s.CreateOrgSecret(ctx, &model.OrgSecret{OrgID: orgs[0], CollectionID: collections[1],
+		Name: "key", Type: model.TextType}); !errors.Is(err, storage.ErrCollectionNotFound) {
+		t.Errorf("secret in a collection of another organization: got %v, want %v", err, storage.ErrCollectionNotFound)
+	}
+
+	id, err := s.CreateOrgSecret(ctx, &model.OrgSecret{OrgID: orgs[0], CollectionID: collections[0],
+		Name: "key", Type: model.TextType, Payload: []byte("p"), UpdatedBy: alice})
+	if err != nil {
+		t.Fatalf("create secret: %v", err)
+	}
+
+	if _, err := s.GetOrgSecret(ctx, orgs[1], id); !errors.Is(err, storage.ErrSecretNotFound) {
+		t.Errorf("get through another organization: got %v, want %v", err, storage.ErrSecretNotFound)
+	}
+	if _, err := s.UpdateOrgSecret(ctx, orgs[1], id, &model.OrgSecret{Type: model.TextType,
+		Revision: 1}); !errors.Is(err, storage.ErrSecretNotFound) {
+		t.Errorf("update through another organization: got %v, want %v", err, storage.ErrSecretNotFound)
+	}
+
+	if _, err := s.UpdateOrgSecret(ctx, orgs[0], id, &model.OrgSecret{Type: model.TextType, Payload: []byte("x"),
+		Revision: 7}); !errors.Is(err, storage.ErrRevisionMismatch) {
+		t.Errorf("stale update: got %v, want %v", err, storage.ErrRevisionMismatch)
+	}
+	revision, err := s.UpdateOrgSecret(ctx, orgs[0], id, &model.OrgSecret{Type: model.TextType,
+		Payload: []byte("x"), Revision: 1, UpdatedBy: alice})
+	if err != nil || revision != 2 {
+		t.Fatalf("update: revision %d, %v", revision, err)
+	}
+
+	if err := s.DeleteCollection(ctx, orgs[0], collections[0]); !errors.Is(err, storage.ErrCollectionNotEmpty) {
+		t.Errorf("delete a collection with secrets: got %v, want %v", err, storage.ErrCollectionNotEmpty)
+	}
+	if err := s.DeleteOrgSecret(ctx, orgs[1], id, 2); !errors.Is(err, storage.ErrSecretNotFound) {
+		t.Errorf("delete through another organization: got %v, want %v", err, storage.ErrSecretNotFound)
+	}
+	if err := s.DeleteOrgSecret(ctx, orgs[0], id, 2); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if err := s.DeleteCollection(ctx, orgs[0], collections[0]); err != nil {
+		t.Errorf("delete an empty collection: %v", err)
+	}
+}
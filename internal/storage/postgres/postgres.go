@@ -3,6 +3,7 @@ package postgres
 import (
 	"context"
 	"database/sql"
+	"fmt"
 
 	"github.com/jackc/pgerrcode"
 	"github.com/jackc/pgx/v5/pgconn"
@@ -13,11 +14,19 @@ import (
 	"github.com/pkg/errors"
 )
 
+const (
+	userColumns   = `id, login, password_hash, kdf_salt, vault_key, public_key, totp_secret, totp_enabled, totp_last_step`
+	secretColumns = `id, "name", user_id, type, payload, meta, key, uuid, revision, updated_at, coalesce(folder_id, 0) AS folder_id, favorite`
+)
+
 type Storage struct {
 	db *sqlx.DB
+	// historyRetention is the number of previous revisions kept per secret,
+	// zero keeps all of them.
+	historyRetention int
 }
 
-func NewStorage(ctx context.Context, DSN string) (*Storage, error) {
+func NewStorage(ctx context.Context, DSN string, historyRetention int) (*Storage, error) {
 	db, err := sqlx.ConnectContext(ctx, "pgx", DSN)
 	if err != nil {
 		return nil, errors.Wrap(err, "connect to db")
@@ -27,7 +36,7 @@ func NewStorage(ctx context.Context, DSN string) (*Storage, error) {
 		return nil, errors.Wrap(err, "init db")
 	}
 
-	return &Storage{db: db}, nil
+	return &Storage{db: db, historyRetention: historyRetention}, nil
 }
 
 func initDatabaseStructure(ctx context.Context, db *sqlx.DB) error {
@@ -40,11 +49,41 @@ func initDatabaseStructure(ctx context.Context, db *sqlx.DB) error {
 	    login varchar unique not null,
 	    password_hash bytea not null
 	);
+
+	alter table "user" add column if not exists kdf_salt bytea;
+	alter table "user" add column if not exists vault_key bytea;
+	alter table "user" add column if not exists public_key bytea;
+	alter table "user" add column if not exists totp_secret bytea;
+	alter table "user" add column if not exists totp_enabled boolean not null default false;
+	alter table "user" add column if not exists totp_last_step bigint not null default 0;
+
+	-- one-time recovery codes of the second factor, SHA-256 hashed
+	create table if not exists "recovery_code"
+	(
+	    id serial primary key,
+	    user_id int not null,
+	    code_hash bytea not null,
+	    used_at timestamptz,
+
+	    CONSTRAINT fk_users FOREIGN KEY (user_id) REFERENCES "user" (id) on delete cascade
+	);
+	create index if not exists recovery_code_user_idx on "recovery_code" (user_id);
+
+	-- password-verified logins waiting for the second factor
+	create table if not exists "login_challenge"
+	(
+	    token_hash bytea primary key,
+	    user_id int not null,
+	    attempts int not null default 0,
+	    expires_at timestamptz not null,
+
+	    CONSTRAINT fk_users FOREIGN KEY (user_id) REFERENCES "user" (id) on delete cascade
+	);
 	
 	create table if not exists "secret"
 	(
 	    id serial primary key,
-			name varchar unique not null,
+	    name varchar not null,
 	    user_id int,
 	    type int not null,
 	    payload bytea,
@@ -53,6 +92,245 @@ func initDatabaseStructure(ctx context.Context, db *sqlx.DB) error {
 	    CONSTRAINT fk_users FOREIGN KEY (user_id) REFERENCES "user" (id) on delete cascade
 	);
 
+	-- secret names are unique per user, not across the whole vault
+	alter table "secret" drop constraint if exists secret_name_key;
+	create unique index if not exists secret_user_name_idx on "secret" (user_id, name);
+
+	-- wrapped per-secret data key, empty for legacy ciphertexts
+	alter table "secret" add column if not exists key bytea;
+	-- picked by the client, payload & meta are bound to it
+	alter table "secret" add column if not exists uuid varchar not null default '';
+	alter table "secret" add column if not exists revision bigint not null default 1;
+
+	-- every change of a secret takes the next value of secret_change_seq,
+	-- clients sync incrementally from the last value they have seen
+	create sequence if not exists secret_change_seq;
+	alter table "secret" add column if not exists change_seq bigint not null default nextval('secret_change_seq');
+	alter table "secret" add column if not exists updated_at timestamptz not null default now();
+	create index if not exists secret_user_change_idx on "secret" (user_id, change_seq);
+
+	-- folders and tags have names encrypted by the client, like secrets
+	create table if not exists "folder"
+	(
+	    id serial primary key,
+	    user_id int not null,
+	    parent_id int,
+	    name bytea not null,
+	    key bytea,
+
+	    CONSTRAINT fk_users FOREIGN KEY (user_id) REFERENCES "user" (id) on delete cascade,
+	    CONSTRAINT fk_parent FOREIGN KEY (parent_id) REFERENCES "folder" (id)
+	);
+	create index if not exists folder_user_idx on "folder" (user_id);
+
+	alter table "secret" add column if not exists folder_id int REFERENCES "folder" (id);
+	alter table "secret" add column if not exists favorite boolean not null default false;
+
+	create table if not exists "tag"
+	(
+	    id serial primary key,
+	    user_id int not null,
+	    name bytea not null,
+	    key bytea,
+
+	    CONSTRAINT fk_users FOREIGN KEY (user_id) REFERENCES "user" (id) on delete cascade
+	);
+	create index if not exists tag_user_idx on "tag" (user_id);
+
+	create table if not exists "secret_tag"
+	(
+	    secret_id int not null,
+	    tag_id int not null,
+
+	    primary key (secret_id, tag_id),
+	    CONSTRAINT fk_secret FOREIGN KEY (secret_id) REFERENCES "secret" (id) on delete cascade,
+	    CONSTRAINT fk_tag FOREIGN KEY (tag_id) REFERENCES "tag" (id) on delete cascade
+	);
+	create index if not exists secret_tag_tag_idx on "secret_tag" (tag_id);
+
+	create table if not exists "secret_tombstone"
+	(
+	    secret_id int primary key,
+	    user_id int not null,
+	    change_seq bigint not null default nextval('secret_change_seq'),
+	    deleted_at timestamptz not null default now(),
+
+	    CONSTRAINT fk_users FOREIGN KEY (user_id) REFERENCES "user" (id) on delete cascade
+	);
+	create index if not exists secret_tombstone_user_change_idx on "secret_tombstone" (user_id, change_seq);
+
+	create table if not exists "secret_version"
+	(
+	    id serial primary key,
+	    secret_id int not null,
+	    version int not null,
+	    name varchar not null,
+	    type int not null,
+	    payload bytea,
+	    meta bytea,
+	    key bytea,
+	    uuid varchar not null default '',
+	    created_at timestamptz not null default now(),
+
+	    unique (secret_id, version),
+	    CONSTRAINT fk_secret FOREIGN KEY (secret_id) REFERENCES "secret" (id) on delete cascade
+	);
+
+	-- file contents of binary secrets, stored in chunks of blobChunkSize
+	create table if not exists "secret_blob"
+	(
+	    secret_id int not null,
+	    seq int not null,
+	    data bytea not null,
+
+	    primary key (secret_id, seq),
+	    CONSTRAINT fk_secret FOREIGN KEY (secret_id) REFERENCES "secret" (id) on delete cascade
+	);
+
+	-- every upload gets a new blob number, so the contents of archived
+	-- versions stay around until the versions are pruned
+	create sequence if not exists secret_blob_seq;
+	alter table "secret" add column if not exists blob bigint not null default 0;
+	alter table "secret_version" add column if not exists blob bigint not null default 0;
+	alter table "secret_blob" add column if not exists blob bigint not null default 0;
+	alter table "secret_blob" drop constraint if exists secret_blob_pkey;
+	create unique index if not exists secret_blob_chunk_idx on "secret_blob" (secret_id, blob, seq);
+
+	-- secrets shared with other users, key is the data key wrapped for them
+	create table if not exists "secret_share"
+	(
+	    secret_id int not null,
+	    user_id int not null,
+	    key bytea not null,
+	    can_edit boolean not null default false,
+	    created_at timestamptz not null default now(),
+
+	    primary key (secret_id, user_id),
+	    CONSTRAINT fk_secret FOREIGN KEY (secret_id) REFERENCES "secret" (id) on delete cascade,
+	    CONSTRAINT fk_users FOREIGN KEY (user_id) REFERENCES "user" (id) on delete cascade
+	);
+	create index if not exists secret_share_user_idx on "secret_share" (user_id);
+
+	-- organizations share collections of secrets between their members, key
+	-- of a member is the private key of the organization wrapped for them
+	create table if not exists "organization"
+	(
+	    id serial primary key,
+	    name varchar not null,
+	    public_key bytea not null,
+	    created_at timestamptz not null default now()
+	);
+
+	create table if not exists "org_member"
+	(
+	    org_id int not null,
+	    user_id int not null,
+	    role int not null,
+	    key bytea not null,
+	    created_at timestamptz not null default now(),
+
+	    primary key (org_id, user_id),
+	    CONSTRAINT fk_org FOREIGN KEY (org_id) REFERENCES "organization" (id) on delete cascade,
+	    CONSTRAINT fk_users FOREIGN KEY (user_id) REFERENCES "user" (id) on delete cascade
+	);
+	create index if not exists org_member_user_idx on "org_member" (user_id);
+
+	create table if not exists "collection"
+	(
+	    id serial primary key,
+	    org_id int not null,
+	    name varchar not null,
+
+	    unique (org_id, name),
+	    CONSTRAINT fk_org FOREIGN KEY (org_id) REFERENCES "organization" (id) on delete cascade
+	);
+
+	create table if not exists "org_secret"
+	(
+	    id serial primary key,
+	    org_id int not null,
+	    collection_id int not null,
+	    name varchar not null,
+	    type int not null,
+	    payload bytea,
+	    meta bytea,
+	    key bytea,
+	    revision bigint not null default 1,
+	    updated_by int,
+	    updated_at timestamptz not null default now(),
+
+	    unique (collection_id, name),
+	    CONSTRAINT fk_org FOREIGN KEY (org_id) REFERENCES "organization" (id) on delete cascade,
+	    CONSTRAINT fk_collection FOREIGN KEY (collection_id) REFERENCES "collection" (id) on delete cascade,
+	    CONSTRAINT fk_users FOREIGN KEY (updated_by) REFERENCES "user" (id) on delete set null
+	);
+	create index if not exists org_secret_org_idx on "org_secret" (org_id);
+
+	-- secrets handed out by one-time links, id_hash is the SHA-256 hash of
+	-- the link ID and payload is encrypted with a key only the link carries
+	create table if not exists "onetime_secret"
+	(
+	    id_hash bytea primary key,
+	    user_id int not null,
+	    payload bytea not null,
+	    max_views int not null,
+	    views int not null default 0,
+	    expires_at timestamptz not null,
+	    created_at timestamptz not null default now(),
+
+	    CONSTRAINT fk_users FOREIGN KEY (user_id) REFERENCES "user" (id) on delete cascade
+	);
+	create index if not exists onetime_secret_expires_idx on "onetime_secret" (expires_at);
+
+	-- emergency contacts: key is a data key wrapped for the grantee and
+	-- vault_key the grantor's private key sealed with it
+	create table if not exists "emergency_grant"
+	(
+	    id serial primary key,
+	    grantor_id int not null,
+	    grantee_id int not null,
+	    wait_days int not null,
+	    requested_at timestamptz,
+	    key bytea not null,
+	    vault_key bytea not null,
+	    created_at timestamptz not null default now(),
+
+	    unique (grantor_id, grantee_id),
+	    CONSTRAINT fk_grantor FOREIGN KEY (grantor_id) REFERENCES "user" (id) on delete cascade,
+	    CONSTRAINT fk_grantee FOREIGN KEY (grantee_id) REFERENCES "user" (id) on delete cascade
+	);
+	create index if not exists emergency_grant_grantee_idx on "emergency_grant" (grantee_id);
+
+	-- actions on an account, user_id is the account and actor_id who acted
+	create table if not exists "audit_event"
+	(
+	    id bigserial primary key,
+	    user_id int not null,
+	    actor_id int not null,
+	    action varchar not null,
+	    detail varchar not null default '',
+	    created_at timestamptz not null default now(),
+
+	    CONSTRAINT fk_users FOREIGN KEY (user_id) REFERENCES "user" (id) on delete cascade
+	);
+	create index if not exists audit_event_user_idx on "audit_event" (user_id, id);
+	-- hash chain of the events of a user, see model.AuditEvent
+	alter table "audit_event" add column if not exists prev_hash bytea;
+	alter table "audit_event" add column if not exists hash bytea;
+
+	create table if not exists "session"
+	(
+	    id serial primary key,
+	    sid_hash bytea unique not null,
+	    user_id int not null,
+	    user_agent varchar not null default '',
+	    created_at timestamptz not null default now(),
+	    last_seen_at timestamptz not null default now(),
+	    expires_at timestamptz not null,
+
+	    CONSTRAINT fk_users FOREIGN KEY (user_id) REFERENCES "user" (id) on delete cascade
+	);
+
 	COMMIT;
 	`
 	_, err := db.ExecContext(ctx, query)
@@ -63,11 +341,11 @@ func initDatabaseStructure(ctx context.Context, db *sqlx.DB) error {
 	return nil
 }
 
-func (s *Storage) CreateUser(ctx context.Context, login, pass string) (int64, error) {
+func (s *Storage) CreateUser(ctx context.Context, user *model.User) (int64, error) {
 	var id int64
-	query := `INSERT INTO "user" (login, password_hash) VALUES ($1, $2) returning id;`
+	query := `INSERT INTO "user" (login, password_hash, kdf_salt, vault_key, public_key) VALUES ($1, $2, $3, $4, $5) returning id;`
 
-	if err := s.db.QueryRowContext(ctx, query, login, pass).
+	if err := s.db.QueryRowContext(ctx, query, user.Login, user.PasswordHash, user.Salt, user.VaultKey, user.PublicKey).
 		Scan(&id); err != nil {
 		var pqErr *pgconn.PgError
 		if errors.As(err, &pqErr) && pgerrcode.UniqueViolation == pqErr.Code {
@@ -82,7 +360,7 @@ func (s *Storage) CreateUser(ctx context.Context, login, pass string) (int64, er
 
 func (s *Storage) GetUserByLogin(ctx context.Context, login string) (*model.User, error) {
 	var user model.User
-	query := `SELECT id, login, password_hash FROM "user" WHERE login = $1;`
+	query := `SELECT ` + userColumns + ` FROM "user" WHERE login = $1;`
 
 	if err := s.db.GetContext(ctx, &user, query, login); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -94,12 +372,80 @@ func (s *Storage) GetUserByLogin(ctx context.Context, login string) (*model.User
 	return &user, nil
 }
 
+func (s *Storage) GetUser(ctx context.Context, id int64) (*model.User, error) {
+	var user model.User
+	query := `SELECT ` + userColumns + ` FROM "user" WHERE id = $1;`
+
+	if err := s.db.GetContext(ctx, &user, query, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, storage.ErrUserNotFound
+		}
+		return nil, errors.Wrap(err, "get user")
+	}
+
+	return &user, nil
+}
+
+// UpdateUserKeys replaces the password and key material of the user and
+// deletes all their sessions but currentSID in the same transaction, so a
+// session opened with the old password doesn't outlive it.
+func (s *Storage) UpdateUserKeys(ctx context.Context, user *model.User, currentSID string) error {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "begin tx")
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE "user" SET password_hash = $2, kdf_salt = $3, vault_key = $4, public_key = $5 WHERE id = $1;`
+
+	res, err := tx.ExecContext(ctx, query, user.ID, user.PasswordHash, user.Salt, user.VaultKey, user.PublicKey)
+	if err != nil {
+		return errors.Wrap(err, "update user keys")
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err, "update user keys")
+	}
+	if n == 0 {
+		return storage.ErrUserNotFound
+	}
+
+	query = `DELETE FROM "session" WHERE user_id = $1 and sid_hash <> $2;`
+	if _, err := tx.ExecContext(ctx, query, user.ID, hashSID(currentSID)); err != nil {
+		return errors.Wrap(err, "revoke sessions")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.Wrap(err, "commit tx")
+	}
+
+	return nil
+}
+
 func (s *Storage) CreateSecret(ctx context.Context, data *model.Secret) (int64, error) {
-	query := `INSERT INTO secret (user_id, "name", type, payload, meta) VALUES ($1, $2, $3, $4, $5) RETURNING id;`
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, errors.Wrap(err, "begin tx")
+	}
+	defer tx.Rollback()
+
+	if err := lockVault(ctx, tx, data.UserID); err != nil {
+		return 0, err
+	}
+
+	if data.FolderID != 0 {
+		if err := checkFolder(ctx, tx, data.UserID, data.FolderID); err != nil {
+			return 0, err
+		}
+	}
+
+	query := `INSERT INTO secret (user_id, "name", type, payload, meta, key, uuid, folder_id, favorite)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, nullif($8, 0), $9) RETURNING id;`
 
 	var id int64
-	if err := s.db.QueryRowContext(ctx, query, data.UserID, data.Name, data.Type, data.Payload, data.Meta).
-		Scan(&id); err != nil {
+	if err := tx.QueryRowContext(ctx, query, data.UserID, data.Name, data.Type, data.Payload, data.Meta, data.Key,
+		data.UUID, data.FolderID, data.Favorite).Scan(&id); err != nil {
 		var pqErr *pgconn.PgError
 		if errors.As(err, &pqErr) && pgerrcode.UniqueViolation == pqErr.Code {
 			return id, storage.ErrSecretExists
@@ -107,57 +453,155 @@ func (s *Storage) CreateSecret(ctx context.Context, data *model.Secret) (int64,
 		return 0, errors.Wrap(err, "create secret")
 	}
 
-	return id, nil
-}
+	if err := setTags(ctx, tx, data.UserID, id, data.Tags); err != nil {
+		return 0, err
+	}
 
-func (s *Storage) ListSecrets(ctx context.Context, userID int64, param uint8) ([]model.Secret, error) {
-	var secrets []model.Secret
+	if err := auditSecret(ctx, tx, data.UserID, data.UserID, model.AuditSecretCreated,
+		fmt.Sprintf("secret %d", id)); err != nil {
+		return 0, err
+	}
 
-	query := `SELECT id, "name", user_id, type, payload, meta FROM "secret" WHERE user_id = $1 and type = $2;`
-	if param == 0 {
-		query = `SELECT id, "name", user_id, type, payload, meta FROM "secret" WHERE user_id = $1 and type > $2;`
+	if err := tx.Commit(); err != nil {
+		return 0, errors.Wrap(err, "commit tx")
 	}
 
-	if err := s.db.SelectContext(ctx, &secrets, query, userID, param); err != nil {
+	return id, nil
+}
+
+// ListSecrets returns the secrets of a user matching filter.
+func (s *Storage) ListSecrets(ctx context.Context, userID int64, filter model.SecretFilter) ([]model.Secret, error) {
+	secrets := make([]model.Secret, 0)
+
+	query := `WITH RECURSIVE subfolder AS (
+		SELECT id FROM folder WHERE id = $3 and user_id = $1
+		UNION ALL
+		SELECT f.id FROM folder f JOIN subfolder ON f.parent_id = subfolder.id
+	)
+	SELECT ` + secretColumns + ` FROM "secret" WHERE user_id = $1
+	    and ($2::int = 0 or type = $2)
+	    and ($3::bigint = 0 or folder_id IN (SELECT id FROM subfolder))
+	    and ($4::bigint = 0 or exists(SELECT 1 FROM secret_tag WHERE secret_id = secret.id and tag_id = $4))
+	    and (not $5::boolean or favorite);`
+
+	if err := s.db.SelectContext(ctx, &secrets, query, userID, filter.Type, filter.FolderID, filter.TagID,
+		filter.Favorite); err != nil {
 		return nil, errors.Wrap(err, "list secrets")
 	}
 
+	if err := attachTags(ctx, s.db, userID, secrets); err != nil {
+		return nil, err
+	}
+
 	return secrets, nil
 }
 
-func (s *Storage) GetSecret(ctx context.Context, id int64) (*model.Secret, error) {
+func (s *Storage) GetSecret(ctx context.Context, userID, id int64) (*model.Secret, error) {
 	var secret model.Secret
 
-	query := `SELECT id, "name", user_id, type, payload, meta FROM "secret" WHERE id = $1;`
-	if err := s.db.GetContext(ctx, &secret, query, id); err != nil {
+	query := `SELECT ` + secretColumns + ` FROM "secret" WHERE id = $1 and user_id = $2;`
+	if err := s.db.GetContext(ctx, &secret, query, id, userID); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, storage.ErrSecretNotFound
 		}
 		return nil, errors.Wrap(err, "get secret")
 	}
 
+	list := []model.Secret{secret}
+	if err := attachTags(ctx, s.db, userID, list); err != nil {
+		return nil, err
+	}
+	secret = list[0]
+
 	return &secret, nil
 }
 
-func (s *Storage) UpdateSecret(ctx context.Context, id int64, data *model.Secret) (int64, error) {
-	query := `UPDATE secret SET type = $2, payload = $3, meta = $4 WHERE id = $1 RETURNING id;`
+// UpdateSecret overwrites a secret and returns its new revision. The update
+// only succeeds if data.Revision matches the current revision, otherwise
+// storage.ErrRevisionMismatch is returned.
+func (s *Storage) UpdateSecret(ctx context.Context, userID, id int64, data *model.Secret) (int64, error) {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, errors.Wrap(err, "begin tx")
+	}
+	defer tx.Rollback()
+
+	if err := lockVault(ctx, tx, userID); err != nil {
+		return 0, err
+	}
+
+	// keep the current revision before overwriting it
+	if err := s.archiveSecret(ctx, tx, userID, id); err != nil {
+		return 0, err
+	}
+
+	query := `UPDATE secret SET type = $3, payload = $4, meta = $5, key = $6, uuid = $7, revision = revision + 1,
+	    change_seq = nextval('secret_change_seq'), updated_at = now()
+	WHERE id = $1 and user_id = $2 and revision = $8 RETURNING revision;`
 
-	var newID int64
-	if err := s.db.QueryRowContext(ctx, query, id, data.Type, data.Payload, data.Meta).
-		Scan(&newID); err != nil {
+	var revision int64
+	if err := tx.QueryRowContext(ctx, query, id, userID, data.Type, data.Payload, data.Meta, data.Key, data.UUID,
+		data.Revision).Scan(&revision); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			// archiveSecret has already checked the secret exists
+			return 0, storage.ErrRevisionMismatch
+		}
 		return 0, errors.Wrap(err, "update secret")
 	}
 
-	return newID, nil
+	if err := auditSecret(ctx, tx, userID, userID, model.AuditSecretUpdated,
+		fmt.Sprintf("secret %d, revision %d", id, revision)); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, errors.Wrap(err, "commit tx")
+	}
+
+	return revision, nil
 }
 
-func (s *Storage) DeleteSecret(ctx context.Context, id int64) error {
-	query := `DELETE FROM secret WHERE id = $1;`
+// DeleteSecret removes a secret and leaves a tombstone for incremental
+// sync. As with UpdateSecret the revision must match the current one.
+func (s *Storage) DeleteSecret(ctx context.Context, userID, id, revision int64) error {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "begin tx")
+	}
+	defer tx.Rollback()
 
-	_, err := s.db.ExecContext(ctx, query, id)
+	if err := lockVault(ctx, tx, userID); err != nil {
+		return err
+	}
+
+	query := `WITH deleted AS (
+		DELETE FROM secret WHERE id = $1 and user_id = $2 and revision = $3 RETURNING id, user_id
+	)
+	INSERT INTO secret_tombstone (secret_id, user_id) SELECT id, user_id FROM deleted;`
+
+	res, err := tx.ExecContext(ctx, query, id, userID, revision)
 	if err != nil {
 		return errors.Wrap(err, "delete secret")
 	}
 
+	n, err := res.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err, "delete secret")
+	}
+	if n == 0 {
+		if err := s.checkOwner(ctx, userID, id); err != nil {
+			return err
+		}
+		return storage.ErrRevisionMismatch
+	}
+
+	if err := auditSecret(ctx, tx, userID, userID, model.AuditSecretDeleted, fmt.Sprintf("secret %d", id)); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.Wrap(err, "commit tx")
+	}
+
 	return nil
 }
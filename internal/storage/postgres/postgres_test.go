@@ -0,0 +1,251 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/nbvehbq/go-password-keeper/internal/model"
+	"github.com/nbvehbq/go-password-keeper/internal/storage"
+	"github.com/pkg/errors"
+)
+
+// testDSNEnv names the database the tests run against. The tests create
+// their own users but never clean up, so don't point it at a real vault.
+const testDSNEnv = "KEEPER_TEST_POSTGRES_DSN"
+
+func newTestStorage(t *testing.T) *Storage {
+	t.Helper()
+
+	dsn := os.Getenv(testDSNEnv)
+	if dsn == "" {
+		t.Skipf("%s is not set", testDSNEnv)
+	}
+
+	s, err := NewStorage(context.Background(), dsn, 0)
+	if err != nil {
+		t.Fatalf("NewStorage: %v", err)
+	}
+	t.Cleanup(func() { s.db.Close() })
+
+	return s
+}
+
+func createTestUser(t *testing.T, s *Storage, name string) int64 {
+	t.Helper()
+
+	login := fmt.Sprintf("%s-%d", name, time.Now().UnixNano())
+	id, err := s.CreateUser(context.Background(), &model.User{Login: login, PasswordHash: "x"})
+	if err != nil {
+		t.Fatalf("create user %s: %v", login, err)
+	}
+	return id
+}
+
+// Every query on a secret must be scoped to its owner: another user gets
+// the same error as for a secret that doesn't exist.
+func TestSecretIsScopedToOwner(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+
+	alice := createTestUser(t, s, "alice")
+	bob := createTestUser(t, s, "bob")
+
+	id, err := s.CreateSecret(ctx, &model.Secret{UserID: alice, Name: "mail", Type: model.TextType,
+		Payload: []byte("p")})
+	if err != nil {
+		t.Fatalf("create secret: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		call func(userID, id int64) error
+	}{
+		{
+			name: "get",
+			call: func(userID, id int64) error {
+				_, err := s.GetSecret(ctx, userID, id)
+				return err
+			},
+		},
+		{
+			name: "update",
+			call: func(userID, id int64) error {
+				_, err := s.UpdateSecret(ctx, userID, id,
+					&model.Secret{Type: model.TextType, Payload: []byte("x"), Revision: 1})
+				return err
+			},
+		},
+		{
+			name: "delete",
+			call: func(userID, id int64) error { return s.DeleteSecret(ctx, userID, id, 1) },
+		},
+		{
+			name: "list versions",
+			call: func(userID, id int64) error {
+				_, err := s.ListSecretVersions(ctx, userID, id)
+				return err
+			},
+		},
+		{
+			name: "get blob",
+			call: func(userID, id int64) error {
+				r, err := s.GetBlob(ctx, userID, id)
+				if err == nil {
+					r.Close()
+				}
+				return err
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.call(bob, id); !errors.Is(err, storage.ErrSecretNotFound) {
+				t.Errorf("other user: got %v, want %v", err, storage.ErrSecretNotFound)
+			}
+			if err := tt.call(bob, id+1_000_000); !errors.Is(err, storage.ErrSecretNotFound) {
+				t.Errorf("missing secret: got %v, want %v", err, storage.ErrSecretNotFound)
+			}
+		})
+	}
+
+	secret, err := s.GetSecret(ctx, alice, id)
+	if err != nil {
+		t.Fatalf("owner: %v", err)
+	}
+	if secret.Revision != 1 || string(secret.Payload) != "p" {
+		t.Errorf("secret changed by another user: revision %d, payload %q", secret.Revision, secret.Payload)
+	}
+
+	list, err := s.ListSecrets(ctx, bob, model.SecretFilter{})
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(list) != 0 {
+		t.Errorf("other user lists %d secrets, want none", len(list))
+	}
+}
+
+// Changing the keys of a user ends all their sessions but the current one.
+func TestUpdateUserKeysEndsOtherSessions(t *testing.T) {
+	s := newTestStorage(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	alice := createTestUser(t, s, "alice")
+	bob := createTestUser(t, s, "bob")
+
+	sessions := NewSessionStorage(ctx, s)
+	var sids []string
+	for _, uid := range []int64{alice, alice, bob} {
+		sid, err := sessions.Set(ctx, uid, "test")
+		if err != nil {
+			t.Fatalf("session: %v", err)
+		}
+		sids = append(sids, sid)
+	}
+
+	user := &model.User{ID: alice, PasswordHash: "y", Salt: []byte("s"), VaultKey: []byte("v"), PublicKey: []byte("p")}
+	if err := s.UpdateUserKeys(ctx, user, sids[0]); err != nil {
+		t.Fatalf("update keys: %v", err)
+	}
+
+	for i, want := range []bool{true, false, true} {
+		if _, ok := sessions.Get(ctx, sids[i]); ok != want {
+			t.Errorf("session %d: valid %v, want %v", i, ok, want)
+		}
+	}
+}
+
+// A change without the revision it was made to is a stale one.
+func TestSecretChangeRequiresRevision(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+
+	alice := createTestUser(t, s, "alice")
+	id, err := s.CreateSecret(ctx, &model.Secret{UserID: alice, Name: "mail", Type: model.TextType,
+		Payload: []byte("p")})
+	if err != nil {
+		t.Fatalf("create secret: %v", err)
+	}
+
+	_, err = s.UpdateSecret(ctx, alice, id, &model.Secret{Type: model.TextType, Payload: []byte("x")})
+	if !errors.Is(err, storage.ErrRevisionMismatch) {
+		t.Errorf("update: got %v, want %v", err, storage.ErrRevisionMismatch)
+	}
+	if err := s.DeleteSecret(ctx, alice, id, 0); !errors.Is(err, storage.ErrRevisionMismatch) {
+		t.Errorf("delete: got %v, want %v", err, storage.ErrRevisionMismatch)
+	}
+
+	secret, err := s.GetSecret(ctx, alice, id)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if secret.Revision != 1 || string(secret.Payload) != "p" {
+		t.Errorf("secret changed: revision %d, payload %q", secret.Revision, secret.Payload)
+	}
+}
+
+// Changes of a secret and reads of it by a recipient land in the trail of
+// the owner, and a failed change leaves no event behind.
+func TestSecretChangesAreAudited(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+
+	alice := createTestUser(t, s, "alice")
+	bob := createTestUser(t, s, "bob")
+
+	id, err := s.CreateSecret(ctx, &model.Secret{UserID: alice, Name: "mail", Type: model.TextType,
+		Payload: []byte("p")})
+	if err != nil {
+		t.Fatalf("create secret: %v", err)
+	}
+	if _, err := s.UpdateSecret(ctx, alice, id, &model.Secret{Type: model.TextType, Payload: []byte("x"),
+		Revision: 7}); !errors.Is(err, storage.ErrRevisionMismatch) {
+		t.Fatalf("stale update: got %v, want %v", err, storage.ErrRevisionMismatch)
+	}
+	if err := s.ShareSecret(ctx, alice, &model.Share{SecretID: id, UserID: bob, Key: []byte("k")}); err != nil {
+		t.Fatalf("share: %v", err)
+	}
+	if _, err := s.ListSharedSecrets(ctx, bob); err != nil {
+		t.Fatalf("list shared: %v", err)
+	}
+	if _, err := s.RekeySecret(ctx, alice, id, &model.SecretRekey{Payload: []byte("y"), Key: []byte("k"),
+		Revision: 1, Revoke: []int64{bob}}); err != nil {
+		t.Fatalf("rekey: %v", err)
+	}
+	if err := s.DeleteSecret(ctx, alice, id, 2); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+
+	events, err := s.ListAuditEvents(ctx, alice, 0, 100)
+	if err != nil {
+		t.Fatalf("list audit events: %v", err)
+	}
+	if i := model.VerifyAuditChain(events); i >= 0 {
+		t.Errorf("chain broken at event %d", i)
+	}
+
+	want := []struct {
+		action  string
+		actorID int64
+	}{
+		{model.AuditSecretCreated, alice},
+		{model.AuditSecretShared, alice},
+		{model.AuditSecretRead, bob},
+		{model.AuditSecretRekeyed, alice},
+		{model.AuditSecretUnshared, alice},
+		{model.AuditSecretDeleted, alice},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("%d events, want %d", len(events), len(want))
+	}
+	for i, w := range want {
+		if events[i].Action != w.action || events[i].ActorID != w.actorID {
+			t.Errorf("event %d: %s by %d, want %s by %d", i, events[i].Action, events[i].ActorID, w.action, w.actorID)
+		}
+	}
+}
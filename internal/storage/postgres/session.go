@@ -0,0 +1,156 @@
+package postgres
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	gonanoid "github.com/matoous/go-nanoid/v2"
+	"github.com/nbvehbq/go-password-keeper/internal/logger"
+	"github.com/nbvehbq/go-password-keeper/internal/model"
+	"github.com/nbvehbq/go-password-keeper/internal/storage"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+const (
+	sessionTTL    = time.Hour * 1
+	clearInterval = time.Minute * 10
+)
+
+// SessionStorage keeps sessions in postgres, so they survive restarts and
+// are shared between server replicas. Only SHA-256 hashes of session IDs
+// are stored.
+type SessionStorage struct {
+	db *sqlx.DB
+}
+
+func NewSessionStorage(ctx context.Context, s *Storage) *SessionStorage {
+	ss := SessionStorage{db: s.db}
+
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(clearInterval):
+				if err := ss.reduceSessions(ctx); err != nil {
+					logger.Log.Error("reduce sessions", zap.Error(err))
+				}
+			}
+		}
+	}()
+
+	return &ss
+}
+
+func hashSID(sid string) []byte {
+	sum := sha256.Sum256([]byte(sid))
+	return sum[:]
+}
+
+func (s *SessionStorage) Set(ctx context.Context, id int64, userAgent string) (string, error) {
+	sid, err := gonanoid.New()
+	if err != nil {
+		return "", errors.Wrap(err, "generate sid")
+	}
+
+	query := `INSERT INTO "session" (sid_hash, user_id, user_agent, expires_at) VALUES ($1, $2, $3, $4);`
+	if _, err := s.db.ExecContext(ctx, query, hashSID(sid), id, userAgent, time.Now().Add(sessionTTL)); err != nil {
+		return "", errors.Wrap(err, "create session")
+	}
+
+	return sid, nil
+}
+
+func (s *SessionStorage) Get(ctx context.Context, sid string) (int64, bool) {
+	var id int64
+	query := `SELECT user_id FROM "session" WHERE sid_hash = $1 and expires_at > now();`
+
+	if err := s.db.QueryRowContext(ctx, query, hashSID(sid)).Scan(&id); err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			logger.Log.Error("get session", zap.Error(err))
+		}
+		return 0, false
+	}
+
+	return id, true
+}
+
+// Refresh extends the session TTL, sliding it from the current moment.
+func (s *SessionStorage) Refresh(ctx context.Context, sid string) error {
+	query := `UPDATE "session" SET last_seen_at = now(), expires_at = $2 WHERE sid_hash = $1 and expires_at > now();`
+
+	res, err := s.db.ExecContext(ctx, query, hashSID(sid), time.Now().Add(sessionTTL))
+	if err != nil {
+		return errors.Wrap(err, "refresh session")
+	}
+
+	return affectedOrNotFound(res)
+}
+
+func (s *SessionStorage) Delete(ctx context.Context, sid string) error {
+	query := `DELETE FROM "session" WHERE sid_hash = $1;`
+
+	res, err := s.db.ExecContext(ctx, query, hashSID(sid))
+	if err != nil {
+		return errors.Wrap(err, "delete session")
+	}
+
+	return affectedOrNotFound(res)
+}
+
+func (s *SessionStorage) List(ctx context.Context, uid int64, current string) ([]model.Session, error) {
+	list := make([]model.Session, 0)
+
+	query := `SELECT id, user_agent, created_at, last_seen_at, expires_at, sid_hash = $2 as current
+	FROM "session" WHERE user_id = $1 and expires_at > now() ORDER BY id;`
+	if err := s.db.SelectContext(ctx, &list, query, uid, hashSID(current)); err != nil {
+		return nil, errors.Wrap(err, "list sessions")
+	}
+
+	return list, nil
+}
+
+func (s *SessionStorage) Revoke(ctx context.Context, uid, id int64) error {
+	query := `DELETE FROM "session" WHERE id = $1 and user_id = $2;`
+
+	res, err := s.db.ExecContext(ctx, query, id, uid)
+	if err != nil {
+		return errors.Wrap(err, "revoke session")
+	}
+
+	return affectedOrNotFound(res)
+}
+
+// RevokeOthers deletes all sessions of the user but current.
+func (s *SessionStorage) RevokeOthers(ctx context.Context, uid int64, current string) error {
+	query := `DELETE FROM "session" WHERE user_id = $1 and sid_hash <> $2;`
+
+	if _, err := s.db.ExecContext(ctx, query, uid, hashSID(current)); err != nil {
+		return errors.Wrap(err, "revoke sessions")
+	}
+
+	return nil
+}
+
+func affectedOrNotFound(res sql.Result) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err, "rows affected")
+	}
+	if n == 0 {
+		return storage.ErrSessionNotFound
+	}
+
+	return nil
+}
+
+func (s *SessionStorage) reduceSessions(ctx context.Context) error {
+	query := `DELETE FROM "session" WHERE expires_at <= now();`
+
+	_, err := s.db.ExecContext(ctx, query)
+	return err
+}
@@ -0,0 +1,122 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nbvehbq/go-password-keeper/internal/storage"
+	"github.com/pkg/errors"
+)
+
+// Sessions outlive the store that issued them, as after a restart or on
+// another replica, and only hashes of their IDs are kept.
+func TestSessionStorage(t *testing.T) {
+	s := newTestStorage(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	alice := createTestUser(t, s, "alice")
+
+	sid, err := NewSessionStorage(ctx, s).Set(ctx, alice, "test")
+	if err != nil {
+		t.Fatalf("set: %v", err)
+	}
+
+	sessions := NewSessionStorage(ctx, s)
+	if uid, ok := sessions.Get(ctx, sid); !ok || uid != alice {
+		t.Fatalf("get from another store: user %d, %v", uid, ok)
+	}
+	if _, ok := sessions.Get(ctx, sid+"x"); ok {
+		t.Error("unknown session resolved")
+	}
+
+	count := func(hash []byte) int {
+		var n int
+		if err := s.db.GetContext(ctx, &n, `SELECT count(*) FROM "session" WHERE sid_hash = $1;`, hash); err != nil {
+			t.Fatalf("count sessions: %v", err)
+		}
+		return n
+	}
+	if count([]byte(sid)) != 0 || count(hashSID(sid)) != 1 {
+		t.Error("session ID is not stored hashed")
+	}
+
+	if _, err := s.db.ExecContext(ctx, `UPDATE "session" SET expires_at = now() - interval '1 second'
+	WHERE sid_hash = $1;`, hashSID(sid)); err != nil {
+		t.Fatalf("expire session: %v", err)
+	}
+	if _, ok := sessions.Get(ctx, sid); ok {
+		t.Error("expired session resolved")
+	}
+	if err := sessions.reduceSessions(ctx); err != nil {
+		t.Fatalf("reduce sessions: %v", err)
+	}
+	if count(hashSID(sid)) != 0 {
+		t.Error("expired session kept")
+	}
+}
+
+// Users see and revoke their own sessions only.
+func TestSessionManagement(t *testing.T) {
+	s := newTestStorage(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	alice := createTestUser(t, s, "alice")
+	bob := createTestUser(t, s, "bob")
+
+	sessions := NewSessionStorage(ctx, s)
+	var sids []string
+	for _, uid := range []int64{alice, alice, bob} {
+		sid, err := sessions.Set(ctx, uid, "test")
+		if err != nil {
+			t.Fatalf("set: %v", err)
+		}
+		sids = append(sids, sid)
+	}
+
+	list, err := sessions.List(ctx, alice, sids[0])
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(list) != 2 || !list[0].Current || list[1].Current {
+		t.Fatalf("sessions of alice: %+v", list)
+	}
+
+	bobs, err := sessions.List(ctx, bob, "")
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if err := sessions.Revoke(ctx, alice, bobs[0].ID); !errors.Is(err, storage.ErrSessionNotFound) {
+		t.Errorf("revoke a session of another user: got %v, want %v", err, storage.ErrSessionNotFound)
+	}
+	if err := sessions.Revoke(ctx, alice, list[1].ID); err != nil {
+		t.Fatalf("revoke: %v", err)
+	}
+	if _, ok := sessions.Get(ctx, sids[1]); ok {
+		t.Error("revoked session resolved")
+	}
+
+	if _, err := s.db.ExecContext(ctx, `UPDATE "session" SET expires_at = now() + interval '1 second'
+	WHERE sid_hash = $1;`, hashSID(sids[0])); err != nil {
+		t.Fatalf("shorten session: %v", err)
+	}
+	if err := sessions.Refresh(ctx, sids[0]); err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+	list, err = sessions.List(ctx, alice, sids[0])
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if left := time.Until(list[0].ExpiresAt); left < sessionTTL-time.Minute {
+		t.Errorf("expires in %v after refresh, want %v", left, sessionTTL)
+	}
+
+	if err := sessions.Delete(ctx, sids[0]); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if err := sessions.Refresh(ctx, sids[0]); !errors.Is(err, storage.ErrSessionNotFound) {
+		t.Errorf("refresh after logout: got %v, want %v", err, storage.ErrSessionNotFound)
+	}
+}
@@ -0,0 +1,276 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+
+	"github.com/nbvehbq/go-password-keeper/internal/model"
+	"github.com/nbvehbq/go-password-keeper/internal/storage"
+	"github.com/pkg/errors"
+)
+
+// ShareSecret shares a secret of the owner with share.UserID, or changes
+// the key and permission of an existing share.
+func (s *Storage) ShareSecret(ctx context.Context, ownerID int64, share *model.Share) error {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "begin tx")
+	}
+	defer tx.Rollback()
+
+	if err := lockVault(ctx, tx, ownerID); err != nil {
+		return err
+	}
+
+	query := `INSERT INTO secret_share (secret_id, user_id, key, can_edit)
+	SELECT id, $3, $4, $5 FROM secret WHERE id = $1 and user_id = $2
+	ON CONFLICT (secret_id, user_id) DO UPDATE SET key = excluded.key, can_edit = excluded.can_edit;`
+
+	res, err := tx.ExecContext(ctx, query, share.SecretID, ownerID, share.UserID, share.Key, share.CanEdit)
+	if err != nil {
+		return errors.Wrap(err, "share secret")
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err, "share secret")
+	}
+	if n == 0 {
+		return storage.ErrSecretNotFound
+	}
+
+	var login string
+	if err := tx.GetContext(ctx, &login, `SELECT login FROM "user" WHERE id = $1;`, share.UserID); err != nil {
+		return errors.Wrap(err, "get recipient")
+	}
+
+	if err := auditSecret(ctx, tx, ownerID, ownerID, model.AuditSecretShared,
+		shareDetail(share.SecretID, login, share.CanEdit)); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.Wrap(err, "commit tx")
+	}
+
+	return nil
+}
+
+// shareDetail describes a share in the audit trail.
+func shareDetail(secretID int64, login string, canEdit bool) string {
+	permission := "read-only"
+	if canEdit {
+		permission = "read & edit"
+	}
+	return fmt.Sprintf("secret %d with %s, %s", secretID, login, permission)
+}
+
+// ListShares returns the users a secret of the owner is shared with.
+func (s *Storage) ListShares(ctx context.Context, ownerID, secretID int64) ([]model.Share, error) {
+	if err := s.checkOwner(ctx, ownerID, secretID); err != nil {
+		return nil, err
+	}
+
+	shares := make([]model.Share, 0)
+	query := `SELECT sh.secret_id, sh.user_id, u.login, sh.can_edit, sh.created_at
+	FROM secret_share sh JOIN "user" u ON u.id = sh.user_id
+	WHERE sh.secret_id = $1 ORDER BY u.login;`
+
+	if err := s.db.SelectContext(ctx, &shares, query, secretID); err != nil {
+		return nil, errors.Wrap(err, "list shares")
+	}
+
+	return shares, nil
+}
+
+// ListSharedSecrets returns the secrets other users have shared with the
+// user. Each read is recorded in the audit trail of the owner.
+func (s *Storage) ListSharedSecrets(ctx context.Context, userID int64) ([]model.SharedSecret, error) {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "begin tx")
+	}
+	defer tx.Rollback()
+
+	secrets := make([]model.SharedSecret, 0)
+
+	query := `SELECT s.id, s.name, s.user_id, s.type, s.payload, s.meta, sh.key, s.uuid, s.revision, s.updated_at,
+	    0 AS folder_id, false AS favorite, u.login AS owner, sh.can_edit
+	FROM secret_share sh
+	JOIN secret s ON s.id = sh.secret_id
+	JOIN "user" u ON u.id = s.user_id
+	WHERE sh.user_id = $1 ORDER BY s.id;`
+
+	if err := tx.SelectContext(ctx, &secrets, query, userID); err != nil {
+		return nil, errors.Wrap(err, "list shared secrets")
+	}
+
+	// trails of several owners are locked in the same order by every reader
+	owners := make([]int64, 0, len(secrets))
+	for _, v := range secrets {
+		owners = append(owners, v.UserID)
+	}
+	slices.Sort(owners)
+	for _, ownerID := range slices.Compact(owners) {
+		if err := lockAuditTrail(ctx, tx, ownerID); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, v := range secrets {
+		if err := auditSecret(ctx, tx, v.UserID, userID, model.AuditSecretRead,
+			fmt.Sprintf("secret %d", v.ID)); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, errors.Wrap(err, "commit tx")
+	}
+
+	return secrets, nil
+}
+
+// UpdateSharedSecret overwrites a secret shared with the user for editing.
+// The data key and the type are kept, payload & meta must be sealed with
+// the key. Revisions are checked as by UpdateSecret.
+func (s *Storage) UpdateSharedSecret(ctx context.Context, userID, id int64, data *model.Secret) (int64, error) {
+	var share struct {
+		OwnerID int64 `db:"owner_id"`
+		CanEdit bool  `db:"can_edit"`
+	}
+
+	query := `SELECT s.user_id AS owner_id, sh.can_edit FROM secret_share sh JOIN secret s ON s.id = sh.secret_id
+	WHERE sh.secret_id = $1 and sh.user_id = $2;`
+	if err := s.db.GetContext(ctx, &share, query, id, userID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, storage.ErrSecretNotFound
+		}
+		return 0, errors.Wrap(err, "get share")
+	}
+	if !share.CanEdit {
+		return 0, storage.ErrReadOnly
+	}
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, errors.Wrap(err, "begin tx")
+	}
+	defer tx.Rollback()
+
+	// changes of the secret are serialized by the vault of its owner
+	if err := lockVault(ctx, tx, share.OwnerID); err != nil {
+		return 0, err
+	}
+
+	if err := s.archiveSecret(ctx, tx, share.OwnerID, id); err != nil {
+		return 0, err
+	}
+
+	query = `UPDATE secret SET payload = $3, meta = $4, uuid = $5, revision = revision + 1,
+	    change_seq = nextval('secret_change_seq'), updated_at = now()
+	WHERE id = $1 and revision = $6
+	    and exists(SELECT 1 FROM secret_share WHERE secret_id = $1 and user_id = $2 and can_edit)
+	RETURNING revision;`
+
+	var revision int64
+	if err := tx.QueryRowContext(ctx, query, id, userID, data.Payload, data.Meta, data.UUID,
+		data.Revision).Scan(&revision); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, storage.ErrRevisionMismatch
+		}
+		return 0, errors.Wrap(err, "update shared secret")
+	}
+
+	if err := auditSecret(ctx, tx, share.OwnerID, userID, model.AuditSecretUpdated,
+		fmt.Sprintf("secret %d, revision %d", id, revision)); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, errors.Wrap(err, "commit tx")
+	}
+
+	return revision, nil
+}
+
+// RekeySecret replaces the data key of a secret of the owner together with
+// the keys of all its shares and deletes the shares of rekey.Revoke, so a
+// share is never revoked without replacing the key. rekey.Shares and
+// rekey.Revoke together must list exactly the current recipients, otherwise
+// storage.ErrSharesChanged is returned.
+func (s *Storage) RekeySecret(ctx context.Context, ownerID, id int64, rekey *model.SecretRekey) (int64, error) {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, errors.Wrap(err, "begin tx")
+	}
+	defer tx.Rollback()
+
+	if err := lockVault(ctx, tx, ownerID); err != nil {
+		return 0, err
+	}
+
+	if err := s.archiveSecret(ctx, tx, ownerID, id); err != nil {
+		return 0, err
+	}
+
+	var current []int64
+	if err := tx.SelectContext(ctx, &current, `SELECT user_id FROM secret_share WHERE secret_id = $1 ORDER BY user_id;`, id); err != nil {
+		return 0, errors.Wrap(err, "list shares")
+	}
+	recipients := make([]int64, 0, len(rekey.Shares)+len(rekey.Revoke))
+	for _, v := range rekey.Shares {
+		recipients = append(recipients, v.UserID)
+	}
+	recipients = append(recipients, rekey.Revoke...)
+	slices.Sort(recipients)
+	if !slices.Equal(current, recipients) {
+		return 0, storage.ErrSharesChanged
+	}
+
+	query := `UPDATE secret SET payload = $3, meta = $4, key = $5, uuid = $6, revision = revision + 1,
+	    change_seq = nextval('secret_change_seq'), updated_at = now()
+	WHERE id = $1 and user_id = $2 and revision = $7 RETURNING revision;`
+
+	var revision int64
+	if err := tx.QueryRowContext(ctx, query, id, ownerID, rekey.Payload, rekey.Meta, rekey.Key, rekey.UUID,
+		rekey.Revision).Scan(&revision); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, storage.ErrRevisionMismatch
+		}
+		return 0, errors.Wrap(err, "rekey secret")
+	}
+
+	if len(rekey.Revoke) > 0 {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM secret_share WHERE secret_id = $1 and user_id = ANY($2);`,
+			id, rekey.Revoke); err != nil {
+			return 0, errors.Wrap(err, "delete shares")
+		}
+	}
+
+	for _, v := range rekey.Shares {
+		if _, err := tx.ExecContext(ctx, `UPDATE secret_share SET key = $3 WHERE secret_id = $1 and user_id = $2;`,
+			id, v.UserID, v.Key); err != nil {
+			return 0, errors.Wrap(err, "rekey share")
+		}
+	}
+
+	if err := auditSecret(ctx, tx, ownerID, ownerID, model.AuditSecretRekeyed,
+		fmt.Sprintf("secret %d, revision %d", id, revision)); err != nil {
+		return 0, err
+	}
+	for _, userID := range rekey.Revoke {
+		if err := auditSecret(ctx, tx, ownerID, ownerID, model.AuditSecretUnshared,
+			fmt.Sprintf("secret %d from user %d", id, userID)); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, errors.Wrap(err, "commit tx")
+	}
+
+	return revision, nil
+}
@@ -0,0 +1,74 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/nbvehbq/go-password-keeper/internal/model"
+	"github.com/pkg/errors"
+)
+
+// lockVault serializes writes to the vault of a user until the end of tx.
+// Change sequence values are taken under the lock, so within a vault they
+// become visible in increasing order and a sync cursor never skips a change.
+func lockVault(ctx context.Context, tx *sqlx.Tx, userID int64) error {
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1);`, userID); err != nil {
+		return errors.Wrap(err, "lock vault")
+	}
+
+	return nil
+}
+
+// Changes returns secrets created or updated and IDs of secrets deleted
+// after the since cursor, together with the cursor to use next time.
+func (s *Storage) Changes(ctx context.Context, userID, since int64) (*model.Changes, error) {
+	tx, err := s.db.BeginTxx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, errors.Wrap(err, "begin tx")
+	}
+	defer tx.Rollback()
+
+	changes := &model.Changes{
+		Cursor:  since,
+		Updated: make([]model.Secret, 0),
+		Deleted: make([]int64, 0),
+	}
+
+	var updated []struct {
+		model.Secret
+		ChangeSeq int64 `db:"change_seq"`
+	}
+	query := `SELECT ` + secretColumns + `, change_seq
+	FROM "secret" WHERE user_id = $1 and change_seq > $2 ORDER BY change_seq;`
+	if err := tx.SelectContext(ctx, &updated, query, userID, since); err != nil {
+		return nil, errors.Wrap(err, "list changed secrets")
+	}
+
+	for _, v := range updated {
+		changes.Updated = append(changes.Updated, v.Secret)
+		changes.Cursor = max(changes.Cursor, v.ChangeSeq)
+	}
+	if err := attachTags(ctx, tx, userID, changes.Updated); err != nil {
+		return nil, err
+	}
+
+	var deleted []struct {
+		SecretID  int64 `db:"secret_id"`
+		ChangeSeq int64 `db:"change_seq"`
+	}
+	query = `SELECT secret_id, change_seq FROM secret_tombstone WHERE user_id = $1 and change_seq > $2 ORDER BY change_seq;`
+	if err := tx.SelectContext(ctx, &deleted, query, userID, since); err != nil {
+		return nil, errors.Wrap(err, "list deleted secrets")
+	}
+
+	for _, v := range deleted {
+		// a first sync has nothing to delete
+		if since > 0 {
+			changes.Deleted = append(changes.Deleted, v.SecretID)
+		}
+		changes.Cursor = max(changes.Cursor, v.ChangeSeq)
+	}
+
+	return changes, nil
+}
@@ -0,0 +1,84 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+
+	"github.com/nbvehbq/go-password-keeper/internal/model"
+)
+
+// Each sync returns only what changed after its cursor: secrets created or
+// updated since, and IDs of deleted ones unless the sync starts from zero.
+func TestChanges(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+
+	alice := createTestUser(t, s, "alice")
+	bob := createTestUser(t, s, "bob")
+
+	var ids []int64
+	for _, name := range []string{"mail", "bank"} {
+		id, err := s.CreateSecret(ctx, &model.Secret{UserID: alice, Name: name, Type: model.TextType,
+			Payload: []byte("p")})
+		if err != nil {
+			t.Fatalf("create secret: %v", err)
+		}
+		ids = append(ids, id)
+	}
+
+	changes, err := s.Changes(ctx, alice, 0)
+	if err != nil {
+		t.Fatalf("changes: %v", err)
+	}
+	if len(changes.Updated) != 2 || len(changes.Deleted) != 0 || changes.Cursor == 0 {
+		t.Fatalf("first sync: %+v", changes)
+	}
+	first := changes.Cursor
+
+	if _, err := s.UpdateSecret(ctx, alice, ids[0], &model.Secret{Type: model.TextType, Payload: []byte("x"),
+		Revision: 1}); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if err := s.DeleteSecret(ctx, alice, ids[1], 1); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+
+	changes, err = s.Changes(ctx, alice, first)
+	if err != nil {
+		t.Fatalf("changes: %v", err)
+	}
+	if len(changes.Updated) != 1 || changes.Updated[0].ID != ids[0] || string(changes.Updated[0].Payload) != "x" {
+		t.Errorf("updated since the first sync: %+v", changes.Updated)
+	}
+	if len(changes.Deleted) != 1 || changes.Deleted[0] != ids[1] {
+		t.Errorf("deleted since the first sync: %v", changes.Deleted)
+	}
+	if changes.Cursor <= first {
+		t.Errorf("cursor %d not advanced from %d", changes.Cursor, first)
+	}
+
+	latest := changes.Cursor
+	changes, err = s.Changes(ctx, alice, latest)
+	if err != nil {
+		t.Fatalf("changes: %v", err)
+	}
+	if len(changes.Updated) != 0 || len(changes.Deleted) != 0 || changes.Cursor != latest {
+		t.Errorf("sync without changes: %+v", changes)
+	}
+
+	changes, err = s.Changes(ctx, alice, 0)
+	if err != nil {
+		t.Fatalf("changes: %v", err)
+	}
+	if len(changes.Updated) != 1 || len(changes.Deleted) != 0 {
+		t.Errorf("sync from zero: %+v", changes)
+	}
+
+	changes, err = s.Changes(ctx, bob, 0)
+	if err != nil {
+		t.Fatalf("changes: %v", err)
+	}
+	if len(changes.Updated) != 0 || len(changes.Deleted) != 0 {
+		t.Errorf("changes of alice synced to bob: %+v", changes)
+	}
+}
@@ -0,0 +1,182 @@
+package postgres
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"time"
+
+	"github.com/nbvehbq/go-password-keeper/internal/storage"
+	"github.com/pkg/errors"
+)
+
+// hashToken hashes challenge tokens & recovery codes, like hashSID does for
+// sessions. Both are random enough for a plain SHA-256.
+func hashToken(token string) []byte {
+	sum := sha256.Sum256([]byte(token))
+	return sum[:]
+}
+
+// SetTOTPSecret stores the seed of a pending enrollment. It is not used for
+// logins until EnableTOTP.
+func (s *Storage) SetTOTPSecret(ctx context.Context, userID int64, secret []byte) error {
+	query := `UPDATE "user" SET totp_secret = $2 WHERE id = $1 and not totp_enabled;`
+
+	res, err := s.db.ExecContext(ctx, query, userID, secret)
+	if err != nil {
+		return errors.Wrap(err, "set totp secret")
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err, "set totp secret")
+	}
+	if n == 0 {
+		return storage.ErrUserNotFound
+	}
+
+	return nil
+}
+
+// EnableTOTP turns on the second factor and replaces the recovery codes.
+// step is the time step of the code that confirmed the enrollment.
+func (s *Storage) EnableTOTP(ctx context.Context, userID, step int64, recoveryCodes []string) error {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "begin tx")
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE "user" SET totp_enabled = true, totp_last_step = $2 WHERE id = $1 and totp_secret is not null;`
+	res, err := tx.ExecContext(ctx, query, userID, step)
+	if err != nil {
+		return errors.Wrap(err, "enable totp")
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err, "enable totp")
+	}
+	if n == 0 {
+		return storage.ErrUserNotFound
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM "recovery_code" WHERE user_id = $1;`, userID); err != nil {
+		return errors.Wrap(err, "delete recovery codes")
+	}
+
+	for _, code := range recoveryCodes {
+		query := `INSERT INTO "recovery_code" (user_id, code_hash) VALUES ($1, $2);`
+		if _, err := tx.ExecContext(ctx, query, userID, hashToken(code)); err != nil {
+			return errors.Wrap(err, "insert recovery code")
+		}
+	}
+
+	return tx.Commit()
+}
+
+// DisableTOTP turns off the second factor and drops its seed and recovery
+// codes.
+func (s *Storage) DisableTOTP(ctx context.Context, userID int64) error {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "begin tx")
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE "user" SET totp_secret = null, totp_enabled = false, totp_last_step = 0 WHERE id = $1;`
+	if _, err := tx.ExecContext(ctx, query, userID); err != nil {
+		return errors.Wrap(err, "disable totp")
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM "recovery_code" WHERE user_id = $1;`, userID); err != nil {
+		return errors.Wrap(err, "delete recovery codes")
+	}
+
+	return tx.Commit()
+}
+
+// UseTOTPStep records step as used. It fails with storage.ErrCodeReused
+// unless step is later than the last accepted one.
+func (s *Storage) UseTOTPStep(ctx context.Context, userID, step int64) error {
+	query := `UPDATE "user" SET totp_last_step = $2 WHERE id = $1 and totp_last_step < $2;`
+
+	res, err := s.db.ExecContext(ctx, query, userID, step)
+	if err != nil {
+		return errors.Wrap(err, "use totp step")
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err, "use totp step")
+	}
+	if n == 0 {
+		return storage.ErrCodeReused
+	}
+
+	return nil
+}
+
+// UseRecoveryCode marks an unused recovery code as used, or fails with
+// storage.ErrRecoveryCode.
+func (s *Storage) UseRecoveryCode(ctx context.Context, userID int64, code string) error {
+	query := `UPDATE "recovery_code" SET used_at = now() WHERE user_id = $1 and code_hash = $2 and used_at is null;`
+
+	res, err := s.db.ExecContext(ctx, query, userID, hashToken(code))
+	if err != nil {
+		return errors.Wrap(err, "use recovery code")
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err, "use recovery code")
+	}
+	if n == 0 {
+		return storage.ErrRecoveryCode
+	}
+
+	return nil
+}
+
+// CreateChallenge stores a login challenge of userID until expiresAt, and
+// drops expired ones.
+func (s *Storage) CreateChallenge(ctx context.Context, userID int64, token string, expiresAt time.Time) error {
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM "login_challenge" WHERE expires_at <= now();`); err != nil {
+		return errors.Wrap(err, "reduce challenges")
+	}
+
+	query := `INSERT INTO "login_challenge" (token_hash, user_id, expires_at) VALUES ($1, $2, $3);`
+	if _, err := s.db.ExecContext(ctx, query, hashToken(token), userID, expiresAt); err != nil {
+		return errors.Wrap(err, "create challenge")
+	}
+
+	return nil
+}
+
+// CheckChallenge counts an attempt to answer a challenge and returns its
+// user. It fails with storage.ErrChallengeExpired once the challenge
+// expired or maxAttempts were made.
+func (s *Storage) CheckChallenge(ctx context.Context, token string, maxAttempts int) (int64, error) {
+	var userID int64
+	query := `UPDATE "login_challenge" SET attempts = attempts + 1
+	WHERE token_hash = $1 and expires_at > now() and attempts < $2 RETURNING user_id;`
+
+	if err := s.db.QueryRowContext(ctx, query, hashToken(token), maxAttempts).Scan(&userID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, storage.ErrChallengeExpired
+		}
+		return 0, errors.Wrap(err, "check challenge")
+	}
+
+	return userID, nil
+}
+
+func (s *Storage) DeleteChallenge(ctx context.Context, token string) error {
+	query := `DELETE FROM "login_challenge" WHERE token_hash = $1;`
+
+	if _, err := s.db.ExecContext(ctx, query, hashToken(token)); err != nil {
+		return errors.Wrap(err, "delete challenge")
+	}
+
+	return nil
+}
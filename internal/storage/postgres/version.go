@@ -0,0 +1,154 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/nbvehbq/go-password-keeper/internal/model"
+	"github.com/nbvehbq/go-password-keeper/internal/storage"
+	"github.com/pkg/errors"
+)
+
+// archiveSecret copies the current state of a secret into secret_version
+// and drops revisions exceeding the retention count.
+func (s *Storage) archiveSecret(ctx context.Context, tx *sqlx.Tx, userID, id int64) error {
+	// file contents referenced neither by the secret nor by a kept version;
+	// those of versions pruned below go the next time, so a restore can
+	// still bring back a version pruned in its own transaction
+	query := `DELETE FROM secret_blob b WHERE b.secret_id = $1
+	and b.blob <> (SELECT blob FROM secret WHERE id = $1)
+	and not exists (SELECT 1 FROM secret_version v WHERE v.secret_id = $1 and v.blob = b.blob);`
+	if _, err := tx.ExecContext(ctx, query, id); err != nil {
+		return errors.Wrap(err, "prune secret blobs")
+	}
+
+	query = `INSERT INTO secret_version (secret_id, version, name, type, payload, meta, key, uuid, blob)
+	SELECT s.id, coalesce((SELECT max(version) FROM secret_version WHERE secret_id = s.id), 0) + 1,
+	       s.name, s.type, s.payload, s.meta, s.key, s.uuid, s.blob
+	FROM secret s WHERE s.id = $1 and s.user_id = $2
+	FOR UPDATE;`
+
+	res, err := tx.ExecContext(ctx, query, id, userID)
+	if err != nil {
+		return errors.Wrap(err, "archive secret")
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err, "archive secret")
+	}
+	if n == 0 {
+		return storage.ErrSecretNotFound
+	}
+
+	if s.historyRetention <= 0 {
+		return nil
+	}
+
+	query = `DELETE FROM secret_version WHERE secret_id = $1 and version <= (
+		SELECT max(version) FROM secret_version WHERE secret_id = $1
+	) - $2;`
+	if _, err := tx.ExecContext(ctx, query, id, s.historyRetention); err != nil {
+		return errors.Wrap(err, "prune secret versions")
+	}
+
+	return nil
+}
+
+func (s *Storage) ListSecretVersions(ctx context.Context, userID, id int64) ([]model.SecretVersion, error) {
+	if err := s.checkOwner(ctx, userID, id); err != nil {
+		return nil, err
+	}
+
+	versions := make([]model.SecretVersion, 0)
+	query := `SELECT secret_id, version, name, type, created_at FROM secret_version
+	WHERE secret_id = $1 ORDER BY version DESC;`
+	if err := s.db.SelectContext(ctx, &versions, query, id); err != nil {
+		return nil, errors.Wrap(err, "list secret versions")
+	}
+
+	return versions, nil
+}
+
+func (s *Storage) GetSecretVersion(ctx context.Context, userID, id, version int64) (*model.SecretVersion, error) {
+	var v model.SecretVersion
+
+	query := `SELECT v.secret_id, v.version, v.name, v.type, v.payload, v.meta, v.key, v.uuid, v.created_at
+	FROM secret_version v JOIN secret s ON s.id = v.secret_id
+	WHERE v.secret_id = $1 and s.user_id = $2 and v.version = $3;`
+	if err := s.db.GetContext(ctx, &v, query, id, userID, version); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, storage.ErrVersionNotFound
+		}
+		return nil, errors.Wrap(err, "get secret version")
+	}
+
+	return &v, nil
+}
+
+// RestoreSecretVersion makes an archived revision current again, file
+// contents included. The state being replaced is archived as well, so a
+// restore can be undone.
+func (s *Storage) RestoreSecretVersion(ctx context.Context, userID, id, version int64) error {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "begin tx")
+	}
+	defer tx.Rollback()
+
+	if err := lockVault(ctx, tx, userID); err != nil {
+		return err
+	}
+
+	var v struct {
+		model.SecretVersion
+		Blob int64 `db:"blob"`
+	}
+	query := `SELECT v.type, v.payload, v.meta, v.key, v.uuid, v.blob
+	FROM secret_version v JOIN secret s ON s.id = v.secret_id
+	WHERE v.secret_id = $1 and s.user_id = $2 and v.version = $3;`
+	if err := tx.GetContext(ctx, &v, query, id, userID, version); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return storage.ErrVersionNotFound
+		}
+		return errors.Wrap(err, "get secret version")
+	}
+
+	if err := s.archiveSecret(ctx, tx, userID, id); err != nil {
+		return err
+	}
+
+	query = `UPDATE secret SET type = $3, payload = $4, meta = $5, key = $6, uuid = $7, blob = $8,
+	    revision = revision + 1, change_seq = nextval('secret_change_seq'), updated_at = now()
+	WHERE id = $1 and user_id = $2;`
+	if _, err := tx.ExecContext(ctx, query, id, userID, v.Type, v.Payload, v.Meta, v.Key, v.UUID, v.Blob); err != nil {
+		return errors.Wrap(err, "restore secret version")
+	}
+
+	if err := auditSecret(ctx, tx, userID, userID, model.AuditSecretRestored,
+		fmt.Sprintf("secret %d from version %d", id, version)); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.Wrap(err, "commit tx")
+	}
+
+	return nil
+}
+
+func (s *Storage) checkOwner(ctx context.Context, userID, id int64) error {
+	var exists bool
+
+	query := `SELECT exists(SELECT 1 FROM secret WHERE id = $1 and user_id = $2);`
+	if err := s.db.GetContext(ctx, &exists, query, id, userID); err != nil {
+		return errors.Wrap(err, "check secret owner")
+	}
+	if !exists {
+		return storage.ErrSecretNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,104 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+
+	"github.com/nbvehbq/go-password-keeper/internal/model"
+	"github.com/nbvehbq/go-password-keeper/internal/storage"
+	"github.com/pkg/errors"
+)
+
+// Every change archives the state it replaces, and a restore is a change
+// too, so it can be undone.
+func TestSecretVersions(t *testing.T) {
+	s := newTestStorage(t)
+	ctx := context.Background()
+
+	alice := createTestUser(t, s, "alice")
+	bob := createTestUser(t, s, "bob")
+
+	id, err := s.CreateSecret(ctx, &model.Secret{UserID: alice, Name: "mail", Type: model.TextType,
+		Payload: []byte("v1")})
+	if err != nil {
+		t.Fatalf("create secret: %v", err)
+	}
+	for i, payload := range []string{"v2", "v3"} {
+		if _, err := s.UpdateSecret(ctx, alice, id, &model.Secret{Type: model.TextType, Payload: []byte(payload),
+			Revision: int64(i + 1)}); err != nil {
+			t.Fatalf("update: %v", err)
+		}
+	}
+
+	versions, err := s.ListSecretVersions(ctx, alice, id)
+	if err != nil {
+		t.Fatalf("list versions: %v", err)
+	}
+	if len(versions) != 2 || versions[0].Version != 2 || versions[1].Version != 1 {
+		t.Fatalf("versions %+v, want 2 and 1", versions)
+	}
+
+	v, err := s.GetSecretVersion(ctx, alice, id, 1)
+	if err != nil {
+		t.Fatalf("get version: %v", err)
+	}
+	if string(v.Payload) != "v1" {
+		t.Errorf("version 1: payload %q, want %q", v.Payload, "v1")
+	}
+
+	if err := s.RestoreSecretVersion(ctx, alice, id, 1); err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	secret, err := s.GetSecret(ctx, alice, id)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if string(secret.Payload) != "v1" || secret.Revision != 4 {
+		t.Errorf("restored secret: payload %q, revision %d, want %q, 4", secret.Payload, secret.Revision, "v1")
+	}
+
+	v, err = s.GetSecretVersion(ctx, alice, id, 3)
+	if err != nil {
+		t.Fatalf("get version: %v", err)
+	}
+	if string(v.Payload) != "v3" {
+		t.Errorf("state replaced by the restore: payload %q, want %q", v.Payload, "v3")
+	}
+
+	if _, err := s.ListSecretVersions(ctx, bob, id); !errors.Is(err, storage.ErrSecretNotFound) {
+		t.Errorf("list by another user: got %v, want %v", err, storage.ErrSecretNotFound)
+	}
+	if _, err := s.GetSecretVersion(ctx, bob, id, 1); !errors.Is(err, storage.ErrVersionNotFound) {
+		t.Errorf("get by another user: got %v, want %v", err, storage.ErrVersionNotFound)
+	}
+	if err := s.RestoreSecretVersion(ctx, bob, id, 1); !errors.Is(err, storage.ErrVersionNotFound) {
+		t.Errorf("restore by another user: got %v, want %v", err, storage.ErrVersionNotFound)
+	}
+}
+
+func TestSecretVersionRetention(t *testing.T) {
+	s := newTestStorage(t)
+	s.historyRetention = 2
+	ctx := context.Background()
+
+	alice := createTestUser(t, s, "alice")
+	id, err := s.CreateSecret(ctx, &model.Secret{UserID: alice, Name: "mail", Type: model.TextType,
+		Payload: []byte("p")})
+	if err != nil {
+		t.Fatalf("create secret: %v", err)
+	}
+	for revision := int64(1); revision <= 4; revision++ {
+		if _, err := s.UpdateSecret(ctx, alice, id, &model.Secret{Type: model.TextType, Payload: []byte("p"),
+			Revision: revision}); err != nil {
+			t.Fatalf("update: %v", err)
+		}
+	}
+
+	versions, err := s.ListSecretVersions(ctx, alice, id)
+	if err != nil {
+		t.Fatalf("list versions: %v", err)
+	}
+	if len(versions) != 2 || versions[0].Version != 4 || versions[1].Version != 3 {
+		t.Errorf("versions %+v, want the last 2", versions)
+	}
+}
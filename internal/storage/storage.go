@@ -3,8 +3,34 @@ package storage
 import "errors"
 
 var (
-	ErrUserExists     = errors.New("user exists")
-	ErrUserNotFound   = errors.New("user not found")
-	ErrSecretNotFound = errors.New("secret not found")
-	ErrSecretExists   = errors.New("secret exists")
+	ErrUserExists          = errors.New("user exists")
+	ErrUserNotFound        = errors.New("user not found")
+	ErrSecretNotFound      = errors.New("secret not found")
+	ErrSecretExists        = errors.New("secret exists")
+	ErrSessionNotFound     = errors.New("session not found")
+	ErrVersionNotFound     = errors.New("secret version not found")
+	ErrRevisionMismatch    = errors.New("secret revision mismatch")
+	ErrBlobNotFound        = errors.New("secret blob not found")
+	ErrNotBinary           = errors.New("secret is not a file")
+	ErrChallengeExpired    = errors.New("login challenge expired")
+	ErrCodeReused          = errors.New("one-time code already used")
+	ErrRecoveryCode        = errors.New("recovery code not found")
+	ErrFolderNotFound      = errors.New("folder not found")
+	ErrFolderNotEmpty      = errors.New("folder is not empty")
+	ErrTagNotFound         = errors.New("tag not found")
+	ErrShareNotFound       = errors.New("share not found")
+	ErrReadOnly            = errors.New("secret is shared read-only")
+	ErrSharesChanged       = errors.New("shares of the secret have changed")
+	ErrOrgNotFound         = errors.New("organization not found")
+	ErrMemberNotFound      = errors.New("member not found")
+	ErrMemberExists        = errors.New("user is already a member")
+	ErrLastOwner           = errors.New("organization must keep an owner")
+	ErrCollectionExists    = errors.New("collection exists")
+	ErrCollectionNotFound  = errors.New("collection not found")
+	ErrCollectionNotEmpty  = errors.New("collection is not empty")
+	ErrOneTimeNotFound     = errors.New("one-time secret not found or expired")
+	ErrEmergencyNotFound   = errors.New("emergency access not found")
+	ErrEmergencyExists     = errors.New("emergency access already granted")
+	ErrEmergencyNotPending = errors.New("no pending emergency request")
+	ErrEmergencyWaiting    = errors.New("emergency access is not released yet")
 )